@@ -1,69 +1,222 @@
 package authorization
 
 import (
-	"AuthorizationServer/utils"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/joeshaw/envdecode"
 	"github.com/joho/godotenv"
 	"gopkg.in/oauth2.v3/models"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// AuthServerConfig is read from sources in this order, every one overriding the previous ones:
+// defaults, YAML file given by -config flag or CONFIG_FILE variable, .env file, environment variables, command line flags
 type AuthServerConfig struct {
-	Domain  string `env:"AUTHORIZATION_SERVER_DOMAIN,required"`
-	Port    string `env:"AUTHORIZATION_SERVER_PORT,required"`
-	DBUsername string `env:"DB_USER,required"`
-	DBPassword string `env:"DB_PASS,required"`
-	DBName     string `env:"DB_NAME,required"`
-	DBHost     string `env:"DB_HOST,required"`
-	Clients []models.Client
+	Domain     string `yaml:"domain" env:"AUTHORIZATION_SERVER_DOMAIN"`
+	Port       string `yaml:"port" env:"AUTHORIZATION_SERVER_PORT"`
+	DBUsername string `yaml:"db_user" env:"DB_USER"`
+	DBPassword string `yaml:"db_password" env:"DB_PASS"`
+	DBName     string `yaml:"db_name" env:"DB_NAME"`
+	DBHost     string `yaml:"db_host" env:"DB_HOST"`
+	DBPort     string `yaml:"db_port" env:"DB_PORT"`
+
+	DatabaseDebug bool `yaml:"database_debug" env:"DATABASE_DEBUG,strict"`
+
+	Lockout LockoutConfig `yaml:"lockout"`
+
+	// the app, the only client registered at the server
+	Client  Client          `yaml:"client"`
+	Clients []models.Client `yaml:"-"`
+
+	// file the config was read from, empty when there was none
+	File string `yaml:"-"`
+	// set by -print-config, the config should be printed instead of starting the server
+	PrintConfig bool `yaml:"-"`
+}
+
+// LockoutConfig of accounts locked after repeated failed logins, every failure past Threshold
+// doubles the lock starting from Base up to Max. Failures older than Max are forgotten
+type LockoutConfig struct {
+	Threshold int           `yaml:"threshold" env:"LOCKOUT_THRESHOLD,strict"`
+	Base      time.Duration `yaml:"base" env:"LOCKOUT_BASE,strict"`
+	Max       time.Duration `yaml:"max" env:"LOCKOUT_MAX,strict"`
 }
 
 type Client struct {
-	ID     string `env:"CLIENT_ID,required"`
-	Secret string `env:"CLIENT_SECRET,required"`
-	Domain string `env:"CLIENT_DOMAIN,required"`
-	UserID string
+	ID     string `yaml:"id" env:"CLIENT_ID"`
+	Secret string `yaml:"secret" env:"CLIENT_SECRET"`
+	Domain string `yaml:"domain" env:"CLIENT_DOMAIN"`
+	UserID string `yaml:"-"`
 }
 
 func (client *Client) GetOauthClient() models.Client {
-	return models.Client{client.ID,client.Secret, client.Domain,""}
+	return models.Client{ID: client.ID, Secret: client.Secret, Domain: client.Domain}
 }
 
-var authInstance *AuthServerConfig
+// config of running server, it is empty until LoadConfig succeeds
+var authInstance = &AuthServerConfig{}
 
-func init() {
-	entry := utils.AuthLoggerEntry()
-	if value, exists := os.LookupEnv("ENV_INITIALIZED"); !exists || value == "false" {
-		err := godotenv.Load() //Load .env file
-		if err != nil {
-			entry.Fatal("Error while loading .env file: " + err.Error())
+func GetAuthServerConfig() *AuthServerConfig {
+	return authInstance
+}
+
+// DefaultConfig returns config with values used when no source sets them
+func DefaultConfig() *AuthServerConfig {
+	return &AuthServerConfig{
+		Port:    "5000",
+		DBPort:  "5432",
+		Lockout: LockoutConfig{Threshold: 5, Base: time.Minute, Max: time.Hour},
+	}
+}
+
+// LoadConfig reads config from all sources, args are command line arguments without program name.
+// Valid config becomes the one returned by GetAuthServerConfig, invalid one is returned together with the error
+// so that it can still be printed
+func LoadConfig(args []string) (*AuthServerConfig, error) {
+	// flags are parsed twice, at first only to find the file, then to override values read from other sources
+	scratch := DefaultConfig()
+	if err := newFlagSet(scratch, os.Stderr).Parse(args); err != nil {
+		return nil, err
+	}
+	path := scratch.File
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
 		}
-		entry.Info("Using .env file")
-	} else {
-		entry.Info("Using environmental variables")
 	}
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := newFlagSet(cfg, ioutil.Discard).Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.File = path
+	cfg.Clients = []models.Client{cfg.Client.GetOauthClient()}
 
-	authInstance = new(AuthServerConfig)
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	authInstance = cfg
+	return cfg, nil
+}
 
-	entry.Info("Initializing authentication server config")
-	authInstance.LoadFromEnvVariables()
+// secrets aren't accepted as flags, command line is visible to other users of the machine
+func newFlagSet(cfg *AuthServerConfig, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("auth_server", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.File, "config", cfg.File, "YAML file with config, CONFIG_FILE variable is used when not given")
+	flags.BoolVar(&cfg.PrintConfig, "print-config", cfg.PrintConfig, "print effective config with secrets redacted and exit")
 
+	flags.StringVar(&cfg.Port, "port", cfg.Port, "port the server listens on")
+	flags.StringVar(&cfg.DBHost, "db-host", cfg.DBHost, "database host")
+	flags.StringVar(&cfg.DBPort, "db-port", cfg.DBPort, "database port")
+	flags.StringVar(&cfg.DBName, "db-name", cfg.DBName, "database name")
+	flags.StringVar(&cfg.DBUsername, "db-user", cfg.DBUsername, "database user")
+	flags.StringVar(&cfg.Client.Domain, "client-domain", cfg.Client.Domain, "domain of the app")
+	return flags
 }
 
-func (cfg *AuthServerConfig) LoadFromEnvVariables() {
-	entry := utils.AuthLogger()
-	err := envdecode.Decode(cfg)
+func (cfg *AuthServerConfig) readFile(path string) error {
+	file, err := os.Open(path)
 	if err != nil {
-		entry.Fatal("Failed to load authorization server config: ", err.Error())
+		return fmt.Errorf("reading config file: %s", err)
 	}
-	client := Client{}
-	err = envdecode.Decode(&client)
-	if err != nil {
-		entry.Fatal("Failed to load app client oauth config: ", err.Error())
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	// misspelled keys would be silently ignored otherwise
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("reading config file %s: %s", path, err)
 	}
-	cfg.Clients = []models.Client{client.GetOauthClient()}
+	return nil
 }
 
-func GetAuthServerConfig() *AuthServerConfig {
-	return authInstance
-}
\ No newline at end of file
+// variables from .env file don't override the ones set in the environment,
+// the file is skipped when ENV_INITIALIZED is set as it is in containers
+func loadEnv(cfg *AuthServerConfig) error {
+	if value, exists := os.LookupEnv("ENV_INITIALIZED"); !exists || value == "false" {
+		if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading .env file: %s", err)
+		}
+	}
+	if err := envdecode.Decode(cfg); err != nil && err != envdecode.ErrNoTargetFieldsAreSet {
+		return fmt.Errorf("reading environment variables: %s", err)
+	}
+	return nil
+}
+
+// Validate reports all missing and malformed values at once
+func (cfg *AuthServerConfig) Validate() error {
+	var problems []string
+	required := func(name string, value string) {
+		if value == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+	port := func(name string, value string) {
+		if number, err := strconv.Atoi(value); err != nil || number <= 0 || number > 65535 {
+			problems = append(problems, fmt.Sprintf("%s '%s' is not a valid port", name, value))
+		}
+	}
+
+	port("port", cfg.Port)
+	required("db_user", cfg.DBUsername)
+	required("db_password", cfg.DBPassword)
+	required("db_name", cfg.DBName)
+	required("db_host", cfg.DBHost)
+	port("db_port", cfg.DBPort)
+	required("client.id", cfg.Client.ID)
+	required("client.secret", cfg.Client.Secret)
+	if cfg.Lockout.Threshold <= 0 || cfg.Lockout.Base <= 0 || cfg.Lockout.Max < cfg.Lockout.Base {
+		problems = append(problems, "lockout should have positive threshold and base not longer than max")
+	}
+	if u, err := url.Parse(cfg.Client.Domain); err != nil || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("client.domain '%s' should be an url", cfg.Client.Domain))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+const redacted = "[REDACTED]"
+
+// Redacted returns copy of the config with secrets replaced, set secrets are distinguishable from missing ones
+func (cfg AuthServerConfig) Redacted() AuthServerConfig {
+	for _, secret := range []*string{&cfg.DBPassword, &cfg.Client.Secret} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	cfg.Clients = nil
+	return cfg
+}
+
+// Print writes effective config as YAML with secrets redacted
+func (cfg *AuthServerConfig) Print(w io.Writer) error {
+	if cfg.File != "" {
+		if _, err := fmt.Fprintf(w, "# read from %s\n", cfg.File); err != nil {
+			return err
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
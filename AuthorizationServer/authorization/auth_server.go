@@ -2,6 +2,8 @@ package authorization
 
 import (
 	"AuthorizationServer/database"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
@@ -10,8 +12,12 @@ import (
 	"gopkg.in/oauth2.v3/manage"
 	"gopkg.in/oauth2.v3/server"
 	"gopkg.in/oauth2.v3/store"
+	"math"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 )
 
@@ -23,12 +29,19 @@ type AuthorizationServer struct {
 	Router      *mux.Router
 	DB          *database.AuthDatabase // db connection is needed for when it is needed to check user's existence in db and validate his credentials
 	Logger	    *logrus.Logger
+	Metrics     *Metrics
+	TokenStore  *Store
+	Lockouts    *Lockouts
+	httpServer  *http.Server
 }
 
 func NewAuthorizationServer(db *database.AuthDatabase, logger *logrus.Logger) *AuthorizationServer {
 	return &AuthorizationServer{DB: db, Logger: logger}
 }
 
+// seconds between attempts of reconnecting to database, clients are told to retry after it while database is down
+const DatabaseReconnectInterval = 10
+
 var CustomAuthorizationCodeTokenCfg = &manage.Config{AccessTokenExp: time.Hour * 24 * 7, RefreshTokenExp: time.Second * 20, IsGenerateRefresh: false}
 
 func (authserver *AuthorizationServer) Initialize(cfg *AuthServerConfig) {
@@ -38,7 +51,9 @@ func (authserver *AuthorizationServer) Initialize(cfg *AuthServerConfig) {
 	manager.SetPasswordTokenCfg(CustomAuthorizationCodeTokenCfg)
 
 	// token store
-	manager.MustTokenStorage(NewStoreWithDB(authserver.DB, 3600, 30, authserver.Logger))
+	tokenStore, err := NewStoreWithDB(authserver.DB, 3600, 30, authserver.Logger)
+	manager.MustTokenStorage(tokenStore, err)
+	authserver.TokenStore = tokenStore
 
 	clientStore := store.NewClientStore()
 
@@ -50,14 +65,18 @@ func (authserver *AuthorizationServer) Initialize(cfg *AuthServerConfig) {
 	}
 	manager.MapClientStorage(clientStore)
 
+	lockouts := NewLockouts(authserver.DB, cfg.Lockout)
 	srv := server.NewServer(server.NewConfig(), manager)
-	srv.SetPasswordAuthorizationHandler(PasswordAuthenticationHandler(authserver.DB))
+	srv.SetPasswordAuthorizationHandler(PasswordAuthenticationHandler(authserver.DB, lockouts))
 
 	srv.SetInternalErrorHandler(func(err error) (re *errors.Response) {
 		re = &errors.Response{}
 		re.Error = err
 		if err == ErrUnauthorizedAccount {
 			re.StatusCode = http.StatusUnauthorized
+		} else if locked, ok := err.(*AccountLockedError); ok {
+			re.StatusCode = http.StatusTooManyRequests
+			re.Header = http.Header{"Retry-After": {strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds())))}}
 		} else {
 			re.StatusCode = 500
 		}
@@ -70,15 +89,15 @@ func (authserver *AuthorizationServer) Initialize(cfg *AuthServerConfig) {
 
 	router := mux.NewRouter()
 
-	router.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+	router.HandleFunc("/token", authserver.requireDatabase(func(w http.ResponseWriter, r *http.Request) {
 		err := srv.HandleTokenRequest(w, r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-	}).Methods("POST")
+	})).Methods("POST")
 
-	router.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+	router.HandleFunc("/authorize", authserver.requireDatabase(func(w http.ResponseWriter, r *http.Request) {
 		token, err := srv.ValidationBearerToken(r)
 		if err != nil {
 			if err == ErrDatabaseError {
@@ -98,30 +117,103 @@ func (authserver *AuthorizationServer) Initialize(cfg *AuthServerConfig) {
 		e.SetIndent("", "  ")
 		err = e.Encode(data)
 		authserver.Logger.WithField("prefix", "[AUTH SERVER]").Debug("Authorizing user with id: ", userID)
-	}).Methods("POST")
+	})).Methods("POST")
 
+	metrics := NewMetrics(func() *sql.DB {
+		if authserver.DB == nil || authserver.DB.DB() == nil {
+			return nil
+		}
+		return authserver.DB.DB().DB()
+	})
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	health := newHealthChecker("flanki-auth", map[string]healthCheck{"database": authserver.DB.Ping})
+	router.HandleFunc("/healthz", health.liveness).Methods("GET")
+	router.HandleFunc("/readyz", health.readiness).Methods("GET")
+	router.HandleFunc("/status", health.statusPage).Methods("GET")
+	router.Use(tracingMiddleware, metrics.Middleware)
+
+	authserver.Metrics = metrics
+	authserver.Lockouts = lockouts
 	authserver.Manager = manager
 	authserver.ClientStore = clientStore
 	authserver.TokenServer = srv
 	authserver.Router = router
 }
 
+// time given to requests being served to finish when shutting down
+var ShutdownTimeout = 15 * time.Second
+
+// Run serves tokens until it fails or SIGTERM (or interrupt) is received, then it shuts down gracefully
 func (authserver *AuthorizationServer) Run(cfg *AuthServerConfig) error {
 	authserver.Logger.Info("Authorization server is listening on port " + cfg.Port)
-	err := http.ListenAndServe(":" + cfg.Port, authserver.Router)
+	authserver.httpServer = &http.Server{Addr: ":" + cfg.Port, Handler: authserver.Router}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- authserver.httpServer.ListenAndServe() }()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serveErr:
+		_ = authserver.Shutdown(context.Background())
+		return err
+	case sig := <-signals:
+		authserver.Logger.Info("Received ", sig, ", shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		return authserver.Shutdown(ctx)
+	}
+}
+
+// Shutdown stops accepting new connections, waits until requests being served are finished or ctx is done
+// and stops token store's tickers
+func (authserver *AuthorizationServer) Shutdown(ctx context.Context) error {
+	var err error
+	if authserver.httpServer != nil {
+		err = authserver.httpServer.Shutdown(ctx)
+	}
+	if authserver.TokenStore != nil {
+		authserver.TokenStore.Close()
+	}
 	return err
 }
 
-func PasswordAuthenticationHandler(db *database.AuthDatabase) func(string, string) (string, error) {
+// tokens are stored in the database, requests for them are answered with 503 while it is down
+func (authserver *AuthorizationServer) requireDatabase(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authserver.DB.Available() {
+			w.Header().Set("Retry-After", strconv.Itoa(DatabaseReconnectInterval))
+			http.Error(w, "database is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		f(w, r)
+	}
+}
+
+// PasswordAuthenticationHandler checks credentials of users, password of locked account isn't even checked
+// so that guessing it doesn't get any faster by ignoring the lock
+func PasswordAuthenticationHandler(db *database.AuthDatabase, lockouts *Lockouts) func(string, string) (string, error) {
 	return func(username, password string) (userID string, err error) {
 		if db == nil {
 			err = fmt.Errorf("Database not found while trying to authorize user")
 			return
 		}
+		if err = lockouts.Check(username); err != nil {
+			return
+		}
 		id, err := FetchUserID(db.DB(), username, password)
+		if err == ErrUnauthorizedAccount {
+			if failErr := lockouts.Failed(username); failErr != nil {
+				err = ErrDatabaseError
+			}
+			return
+		}
 		if err != nil {
 			return
 		}
+		// failures are forgotten on a best effort basis, the user has already logged in
+		_ = lockouts.Succeeded(username)
 		userID = fmt.Sprint(id)
 		return
 	}
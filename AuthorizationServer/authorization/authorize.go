@@ -17,7 +17,8 @@ func FetchUserID(db *gorm.DB, email, password string) (id uint, err error) {
 		err = ErrDatabaseError
 		return
 	}
-	err = db.Table("accounts").Where("email=?", email).Scan(&user).Error
+	// deleted accounts can only be restored, they can't log in until then
+	err = db.Table("accounts").Where("email = ? AND deleted_at IS NULL", email).Scan(&user).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			err = ErrUnauthorizedAccount
@@ -8,6 +8,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"gopkg.in/oauth2.v3"
 	"gopkg.in/oauth2.v3/models"
+	"sync"
 	"time"
 )
 
@@ -39,6 +40,7 @@ func NewStoreWithDB(apidb *database.AuthDatabase, gcInterval int, dbInterval int
 		db:        apidb,
 		tableName: "oauth2_token",
 		logEntry: logger.WithField("prefix", "[TOKEN STORE]"),
+		done:      make(chan struct{}),
 	}
 
 	// garbage collecting old tokens
@@ -53,6 +55,8 @@ func NewStoreWithDB(apidb *database.AuthDatabase, gcInterval int, dbInterval int
 	store.gcTicker = time.NewTicker(time.Second * time.Duration(gcInterval))
 	store.dbTicker = time.NewTicker(time.Second * time.Duration(dbInterval))
 
+	// don't wait for the first tick, tokens can be requested right after start
+	store.checkSchema()
 	go store.databaseCheck()
 	go store.gc()
 	return
@@ -65,12 +69,17 @@ type Store struct {
 	gcTicker  *time.Ticker
 	dbTicker  *time.Ticker
 	logEntry  *logrus.Entry
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-
+// Close stops tickers together with goroutines checking schema and collecting expired tokens
 func (s *Store) Close() {
-	s.gcTicker.Stop()
-	s.dbTicker.Stop()
+	s.closeOnce.Do(func() {
+		s.gcTicker.Stop()
+		s.dbTicker.Stop()
+		close(s.done)
+	})
 }
 
 // when database is down and then reconnects, token model migration might be needed
@@ -78,25 +87,43 @@ func (s *Store) Close() {
 // token store should not try to reconnect with database because it should be done by auth server
 func (s *Store) databaseCheck() {
 
-	for range s.dbTicker.C {
-		db := s.db.DB()
-		if err := db.DB().Ping(); err != nil {
-			s.logEntry.Error("Database connection is closed")
-			continue
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.dbTicker.C:
+			s.checkSchema()
 		}
-		if !db.HasTable(s.tableName) {
-			if err := db.Table(s.tableName).CreateTable(&StoreItem{}).Error; err != nil {
-				s.logEntry.Error(err.Error())
-			} else {
-				s.logEntry.Info("Created database schema")
-			}
+	}
+}
+
+func (s *Store) checkSchema() {
+	db := s.db.DB()
+	if db == nil {
+		s.logEntry.Error("Database connection has not been established")
+		return
+	}
+	if err := db.DB().Ping(); err != nil {
+		s.logEntry.Error("Database connection is closed")
+		return
+	}
+	if !db.HasTable(s.tableName) {
+		if err := db.Table(s.tableName).CreateTable(&StoreItem{}).Error; err != nil {
+			s.logEntry.Error(err.Error())
+		} else {
+			s.logEntry.Info("Created database schema")
 		}
 	}
 }
 
 // garbage collector for expired tokens
 func (s *Store) gc() {
-	for range s.gcTicker.C {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.gcTicker.C:
+		}
 		db := s.db.DB()
 		now := time.Now().Unix()
 		var count int
@@ -0,0 +1,121 @@
+package authorization
+
+import (
+	"AuthorizationServer/database"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// LoginFailure counts failed logins of an email, whether the account exists or not
+// so that locking doesn't reveal which emails are registered
+type LoginFailure struct {
+	Email       string `gorm:"primary_key"`
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+func (LoginFailure) TableName() string {
+	return "login_failures"
+}
+
+// AccountLockedError is returned instead of checking the password while the account is locked
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (err *AccountLockedError) Error() string {
+	return "Account is locked after too many failed logins"
+}
+
+// Lockouts lock accounts progressively after repeated failed logins, the state is kept in the database
+// so that locks survive restarts of the server
+type Lockouts struct {
+	db  *database.AuthDatabase
+	cfg LockoutConfig
+	now func() time.Time
+}
+
+func NewLockouts(db *database.AuthDatabase, cfg LockoutConfig) *Lockouts {
+	return &Lockouts{db: db, cfg: cfg, now: time.Now}
+}
+
+// MigrateLockouts creates table of login failures, it has to be called after every reconnect
+func MigrateLockouts(db *gorm.DB) error {
+	return db.AutoMigrate(&LoginFailure{}).Error
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check returns *AccountLockedError while the account is locked
+func (lockouts *Lockouts) Check(email string) error {
+	failure := &LoginFailure{}
+	err := lockouts.db.DB().Where("email = ?", normalizeEmail(email)).First(failure).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return ErrDatabaseError
+	}
+	if wait := failure.LockedUntil.Sub(lockouts.now()); wait > 0 {
+		return &AccountLockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Failed counts failed login, reaching the threshold locks the account for Base,
+// every next failure doubles the lock up to Max
+func (lockouts *Lockouts) Failed(email string) (err error) {
+	now := lockouts.now()
+	email = normalizeEmail(email)
+	tx := lockouts.db.DB().Begin()
+	if err = tx.Error; err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	// row is locked for the time of transaction so that concurrent failures are all counted
+	err = tx.Exec("INSERT INTO login_failures (email, failures, last_failure, locked_until) VALUES (?, 0, ?, ?) ON CONFLICT (email) DO NOTHING", email, now, now).Error
+	if err != nil {
+		return
+	}
+	failure := &LoginFailure{}
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("email = ?", email).First(failure).Error; err != nil {
+		return
+	}
+	if now.Sub(failure.LastFailure) > lockouts.cfg.Max {
+		failure.Failures = 0
+	}
+	failure.Failures++
+	failure.LastFailure = now
+	if over := failure.Failures - lockouts.cfg.Threshold; over >= 0 {
+		failure.LockedUntil = now.Add(lockouts.lockDuration(over))
+	}
+	if err = tx.Save(failure).Error; err != nil {
+		return
+	}
+	return tx.Commit().Error
+}
+
+// lock after reaching the threshold and then after every next failure
+func (lockouts *Lockouts) lockDuration(failuresOverThreshold int) time.Duration {
+	duration := lockouts.cfg.Base
+	for i := 0; i < failuresOverThreshold && duration < lockouts.cfg.Max; i++ {
+		duration *= 2
+	}
+	if duration > lockouts.cfg.Max {
+		return lockouts.cfg.Max
+	}
+	return duration
+}
+
+// Succeeded forgets failures of the account after successful login
+func (lockouts *Lockouts) Succeeded(email string) error {
+	return lockouts.db.DB().Where("email = ?", normalizeEmail(email)).Delete(&LoginFailure{}).Error
+}
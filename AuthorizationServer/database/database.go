@@ -2,12 +2,27 @@ package database
 
 
 
-import "github.com/jinzhu/gorm"
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"sync"
+	"time"
+)
+
+// how long result of checking database availability is trusted before pinging it again
+const availabilityTTL = 2 * time.Second
+
+var ErrNotConnected = errors.New("database connection has not been established")
 
 // this struct is just a dummy wrapper for db connection pointer
 // its main goal is to keep db pointer for reconnecting goroutine
 type AuthDatabase struct {
 	db *gorm.DB
+
+	mutex     sync.Mutex
+	checkedAt time.Time
+	pingErr   error
 }
 
 func (apidb *AuthDatabase) DB() *gorm.DB {
@@ -16,6 +31,7 @@ func (apidb *AuthDatabase) DB() *gorm.DB {
 
 func (apidb *AuthDatabase) NewConnection(uri string) (err error) {
 	apidb.db, err = gorm.Open("postgres", uri)
+	apidb.remember(err)
 	return
 }
 
@@ -23,4 +39,35 @@ func (apidb *AuthDatabase) Close() error {
 	return apidb.db.Close()
 }
 
+// Ping checks whether database can be used right now
+func (apidb *AuthDatabase) Ping(ctx context.Context) error {
+	db := apidb.DB()
+	if db == nil {
+		return ErrNotConnected
+	}
+	err := db.DB().PingContext(ctx)
+	apidb.remember(err)
+	return err
+}
+
+// Available tells whether database could be used recently, it pings database at most once per availabilityTTL
+// so it can be checked before serving every request
+func (apidb *AuthDatabase) Available() bool {
+	apidb.mutex.Lock()
+	fresh := time.Since(apidb.checkedAt) < availabilityTTL
+	err := apidb.pingErr
+	apidb.mutex.Unlock()
+	if fresh {
+		return err == nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return apidb.Ping(ctx) == nil
+}
 
+func (apidb *AuthDatabase) remember(pingErr error) {
+	apidb.mutex.Lock()
+	apidb.checkedAt, apidb.pingErr = time.Now(), pingErr
+	apidb.mutex.Unlock()
+}
@@ -4,29 +4,48 @@ import (
 	auth "AuthorizationServer/authorization"
 	"AuthorizationServer/database"
 	"AuthorizationServer/utils"
+	"context"
+	"flag"
 	"fmt"
 	"os"
 )
 
 func main() {
-	cfg := auth.GetAuthServerConfig()
 	log := utils.AuthLoggerEntry()
+	cfg, err := auth.LoadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if cfg != nil && cfg.PrintConfig {
+		if printErr := cfg.Print(os.Stdout); printErr != nil {
+			log.Fatal(printErr)
+		}
+		if err == nil {
+			return
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 	utils.SetEnvDebug()
 	db := &database.AuthDatabase{}
 	defer db.Close()
-	dbUri := fmt.Sprintf("host=%s user=%s dbname=%s sslmode=disable password=%s", cfg.DBHost, cfg.DBUsername, cfg.DBName, cfg.DBPassword)
-	dbTicker := utils.NewDatabaseReconnectTicker(db, dbUri, 10)
+	dbUri := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", cfg.DBHost, cfg.DBPort, cfg.DBUsername, cfg.DBName, cfg.DBPassword)
+	dbTicker := utils.NewDatabaseReconnectTicker(db, dbUri, auth.DatabaseReconnectInterval)
 
 	dbSetup := func() {
 		db.DB().SetLogger(&utils.GormLogger{})
-		if debug := os.Getenv("DATABASE_DEBUG"); debug == "true" {
+		if cfg.DatabaseDebug {
 			db.DB().LogMode(true)
 		}
+		if err := auth.MigrateLockouts(db.DB()); err != nil {
+			log.Error("Couldn't migrate login failures: ", err)
+		}
 	}
 
 
 	log.Info("Connecting with database...")
-	err := db.NewConnection(dbUri)
+	err = db.NewConnection(dbUri)
 	db.DB().SetLogger(&utils.GormLogger{})
 	if err != nil {
 		log.Error("Connecting failed: ", err.Error())
@@ -36,7 +55,12 @@ func main() {
 	}
 
 	go func() {
-		for range dbTicker.Ticker.C {
+		for {
+			select {
+			case <-dbTicker.Done():
+				return
+			case <-dbTicker.Ticker.C:
+			}
 			reconnected, err := dbTicker.TryReconnect()
 			logEntry := utils.AuthLogger().WithField("prefix", "[AUTH DATABASE CONNECT]")
 			if err != nil {
@@ -52,6 +76,17 @@ func main() {
 	authServer := auth.NewAuthorizationServer(db, utils.AuthLogger())
 	authServer.Initialize(cfg)
 
-	utils.AuthLogger().Fatal(authServer.Run(cfg))
+	shutdownTracing, err := auth.SetupTracing("flanki-auth")
+	if err != nil {
+		log.Fatal("Couldn't set up tracing: ", err)
+	}
+	err = authServer.Run(cfg)
+	dbTicker.Stop()
+	shutdownTracing(context.Background())
+	if err != nil {
+		db.Close()
+		utils.AuthLogger().Fatal(err)
+	}
+	log.Info("Authorization server has been shut down")
 
 }
\ No newline at end of file
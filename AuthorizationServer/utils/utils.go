@@ -2,6 +2,7 @@ package utils
 
 import (
 	"AuthorizationServer/database"
+	"sync"
 	"time"
 )
 
@@ -9,13 +10,28 @@ type DatabseReconnectTicker struct {
 	db *database.AuthDatabase
 	uri string
 	Ticker *time.Ticker
+	done chan struct{}
+	stopOnce sync.Once
 }
 
 func NewDatabaseReconnectTicker(conn *database.AuthDatabase,uri string,  interval time.Duration) *DatabseReconnectTicker {
-	drt := &DatabseReconnectTicker{conn,uri, time.NewTicker(time.Second * interval)}
+	drt := &DatabseReconnectTicker{db: conn, uri: uri, Ticker: time.NewTicker(time.Second * interval), done: make(chan struct{})}
 	return drt
 }
 
+// Stop stops ticking and closes Done, goroutine reconnecting on ticks should return then
+func (drt *DatabseReconnectTicker) Stop() {
+	drt.stopOnce.Do(func() {
+		drt.Ticker.Stop()
+		close(drt.done)
+	})
+}
+
+// Done is closed when ticker has been stopped
+func (drt *DatabseReconnectTicker) Done() <-chan struct{} {
+	return drt.done
+}
+
 func (drt *DatabseReconnectTicker) TryReconnect() (bool, error) {
 	if err := drt.db.DB().DB().Ping(); err != nil {
 		err = drt.db.NewConnection(drt.uri)
@@ -2,23 +2,45 @@ package main
 
 import (
 	"Chat/websocketchat"
-	"github.com/joho/godotenv"
+	"context"
+	"flag"
 	"os"
 )
 
 func main() {
-	if v,found := os.LookupEnv("ENV_INITIALIZED"); !found || v == "false" {
-		err := godotenv.Load()
-		if err != nil {
-			websocketchat.Logger().Fatal(err.Error())
+	cfg, err := websocketchat.LoadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if cfg != nil && cfg.PrintConfig {
+		if printErr := cfg.Print(os.Stdout); printErr != nil {
+			websocketchat.Logger().Fatal(printErr)
+		}
+		if err == nil {
+			return
 		}
 	}
+	if err != nil {
+		websocketchat.Logger().Fatal(err)
+	}
+	if cfg.Production {
+		if err := websocketchat.TrustCertificate(cfg.CertFile); err != nil {
+			websocketchat.Logger().Fatal(err)
+		}
+	}
+	websocketchat.GetFlankiChecker.SetEndpoints(cfg.APIURL, cfg.AuthURL)
+	if err := websocketchat.History.SetDirectory(cfg.MessagesDirectory); err != nil {
+		websocketchat.Logger().Fatal("Couldn't open directory of messages: ", err)
+	}
 
-	port := os.Getenv("PORT")
-	enableSLL := os.Getenv("ENABLE_SSL")
-	apiEndpoint := os.Getenv("API_URL")
-	authEndpoint := os.Getenv("AUTH_URL")
-	websocketchat.GetFlankiChecker.SetEndpoints(apiEndpoint, authEndpoint)
-
-	websocketchat.Logger().Fatal(websocketchat.NewChatServer().Run(":" + port, enableSLL))
-}
\ No newline at end of file
+	shutdownTracing, err := websocketchat.SetupTracing("flanki-chat")
+	if err != nil {
+		websocketchat.Logger().Fatal("Couldn't set up tracing: ", err)
+	}
+	err = websocketchat.NewChatServer().Run(":" + cfg.Port, cfg.EnableSSL)
+	shutdownTracing(context.Background())
+	if err != nil {
+		websocketchat.Logger().Fatal(err)
+	}
+	websocketchat.Logger().Info("Chat has been shut down")
+}
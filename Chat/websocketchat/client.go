@@ -14,27 +14,30 @@ type Client struct {
 	User User
 }
 
-func (client *Client) ReadRoutine() {
-	defer client.socket.Close()
-
-	err := client.socket.ReadJSON(&client.User)
-	if err != nil {
-		fmt.Println("Couldn't read client's user information: ", err.Error())
-		return
+// Authenticate reads the authorization message and checks the user, members of the lobby are the only ones
+// let into its room, lobbyID is 0 for other rooms. ctx is the context of the request which opened the connection
+func (client *Client) Authenticate(ctx context.Context, lobbyID uint) error {
+	if err := client.socket.ReadJSON(&client.User); err != nil {
+		return fmt.Errorf("couldn't read client's user information: %s", err.Error())
 	}
-
-	err = GetFlankiChecker.Authorize(&client.User)
-	if err != nil {
-		fmt.Println("Invalid user: ", err.Error())
-		return
+	if err := checker.Authorize(ctx, &client.User); err != nil {
+		return fmt.Errorf("invalid user: %s", err.Error())
 	}
-
-	err = GetFlankiChecker.FetchUserInformation(&client.User)
-	if err != nil {
-		fmt.Println("Error while fetching nickname", err.Error())
-		return
+	if lobbyID != 0 {
+		if err := checker.CheckLobbyMember(ctx, &client.User, lobbyID); err != nil {
+			return fmt.Errorf("user is not a member of the lobby: %s", err.Error())
+		}
+	}
+	if err := checker.FetchUserInformation(ctx, &client.User); err != nil {
+		return fmt.Errorf("error while fetching nickname: %s", err.Error())
 	}
 	client.User.Connected = time.Now()
+	return nil
+}
+
+// ReadRoutine dispatches messages of the authenticated client to its room
+func (client *Client) ReadRoutine() {
+	defer client.socket.Close()
 
 	for {
 		var message *Message
@@ -46,9 +49,8 @@ func (client *Client) ReadRoutine() {
 		// overwrite message's nickname with client's user nickname
 		message.Nickname = client.User.Nickname
 		message.Time = time.Now()
-		ctx := context.Background()
-		ctx = context.WithValue(ctx, "client", client)
-		client.Room.DispatchMessage(ctx, message)
+		messageCtx := context.WithValue(context.Background(), "client", client)
+		client.Room.DispatchMessage(messageCtx, message)
 	}
 }
 
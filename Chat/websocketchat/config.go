@@ -0,0 +1,156 @@
+package websocketchat
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joeshaw/envdecode"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config of the chat is read from sources in this order, every one overriding the previous ones:
+// defaults, YAML file given by -config flag or CONFIG_FILE variable, .env file, environment variables, command line flags
+type Config struct {
+	Port      string `yaml:"port" env:"PORT"`
+	EnableSSL bool   `yaml:"enable_ssl" env:"ENABLE_SSL,strict"`
+	// endpoints of the app, nicknames are fetched from it, and of the authorization server validating tokens
+	APIURL  string `yaml:"api_url" env:"API_URL"`
+	AuthURL string `yaml:"auth_url" env:"AUTH_URL"`
+	// in production the app is reached over TLS with certificate which has to be trusted
+	Production bool   `yaml:"production" env:"PRODUCTION,strict"`
+	CertFile   string `yaml:"cert_file" env:"CERT_FILE"`
+	// messages are kept there for players who have sent them, until they export or delete their accounts
+	MessagesDirectory string `yaml:"messages_directory" env:"MESSAGES_DIRECTORY"`
+
+	// file the config was read from, empty when there was none
+	File string `yaml:"-"`
+	// set by -print-config, the config should be printed instead of starting the chat
+	PrintConfig bool `yaml:"-"`
+}
+
+// DefaultConfig returns config with values used when no source sets them
+func DefaultConfig() *Config {
+	return &Config{Port: "8081", CertFile: "/ssl_certs/cert.pem", MessagesDirectory: "messages"}
+}
+
+// LoadConfig reads config from all sources, args are command line arguments without program name.
+// Invalid config is returned together with the error so that it can still be printed
+func LoadConfig(args []string) (*Config, error) {
+	// flags are parsed twice, at first only to find the file, then to override values read from other sources
+	scratch := DefaultConfig()
+	if err := newFlagSet(scratch, os.Stderr).Parse(args); err != nil {
+		return nil, err
+	}
+	path := scratch.File
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := newFlagSet(cfg, ioutil.Discard).Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.File = path
+	return cfg, cfg.Validate()
+}
+
+func newFlagSet(cfg *Config, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("chat", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.File, "config", cfg.File, "YAML file with config, CONFIG_FILE variable is used when not given")
+	flags.BoolVar(&cfg.PrintConfig, "print-config", cfg.PrintConfig, "print effective config and exit")
+
+	flags.StringVar(&cfg.Port, "port", cfg.Port, "port the chat listens on")
+	flags.BoolVar(&cfg.EnableSSL, "ssl", cfg.EnableSSL, "serve over TLS")
+	flags.StringVar(&cfg.APIURL, "api-url", cfg.APIURL, "url of the app")
+	flags.StringVar(&cfg.AuthURL, "auth-url", cfg.AuthURL, "url of the authorization server")
+	flags.BoolVar(&cfg.Production, "production", cfg.Production, "trust certificate from cert-file when calling the app")
+	flags.StringVar(&cfg.CertFile, "cert-file", cfg.CertFile, "certificate of the app trusted in production")
+	flags.StringVar(&cfg.MessagesDirectory, "messages-dir", cfg.MessagesDirectory, "directory sent messages are kept in")
+	return flags
+}
+
+func (cfg *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %s", err)
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	// misspelled keys would be silently ignored otherwise
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("reading config file %s: %s", path, err)
+	}
+	return nil
+}
+
+// variables from .env file don't override the ones set in the environment,
+// the file is skipped when ENV_INITIALIZED is set as it is in containers
+func loadEnv(cfg *Config) error {
+	if value, exists := os.LookupEnv("ENV_INITIALIZED"); !exists || value == "false" {
+		if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading .env file: %s", err)
+		}
+	}
+	if err := envdecode.Decode(cfg); err != nil && err != envdecode.ErrNoTargetFieldsAreSet {
+		return fmt.Errorf("reading environment variables: %s", err)
+	}
+	return nil
+}
+
+// Validate reports all missing and malformed values at once
+func (cfg *Config) Validate() error {
+	var problems []string
+	if number, err := strconv.Atoi(cfg.Port); err != nil || number <= 0 || number > 65535 {
+		problems = append(problems, fmt.Sprintf("port '%s' is not a valid port", cfg.Port))
+	}
+	endpoint := func(name string, value string) {
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s '%s' should be an http(s) url", name, value))
+		}
+	}
+	endpoint("api_url", cfg.APIURL)
+	endpoint("auth_url", cfg.AuthURL)
+	if cfg.Production && cfg.CertFile == "" {
+		problems = append(problems, "cert_file is required in production")
+	}
+	if cfg.MessagesDirectory == "" {
+		problems = append(problems, "messages_directory is required")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Print writes effective config as YAML, chat's config has no secrets
+func (cfg *Config) Print(w io.Writer) error {
+	if cfg.File != "" {
+		if _, err := fmt.Fprintf(w, "# read from %s\n", cfg.File); err != nil {
+			return err
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"net/http"
+	"sync"
 )
 
 const (
@@ -15,6 +16,9 @@ const (
 type ConnectController struct {
 	roomManager *RoomManager
 	upgrader  *websocket.Upgrader
+
+	// web socket connections which are still open, server waits for them when shutting down
+	connections sync.WaitGroup
 }
 
 
@@ -24,13 +28,14 @@ func NewConnectController(manager *RoomManager) *ConnectController{
 	upgrader.CheckOrigin = func(r *http.Request) bool {
 		return true;
 	}
-	return &ConnectController{manager, upgrader}
+	return &ConnectController{roomManager: manager, upgrader: upgrader}
 }
 
 func (controller *ConnectController) JoinRoom(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
-	room := controller.roomManager.GetRoom(name)
-	if room == nil {
+	lobbyID := LobbyRoomID(name)
+	// rooms of lobbies are created only once their members are authorized
+	if lobbyID == 0 && controller.roomManager.GetRoom(name) == nil {
 		ChatErrorResponse(w, "room not found", 404)
 		return
 	}
@@ -40,9 +45,34 @@ func (controller *ConnectController) JoinRoom(w http.ResponseWriter, r *http.Req
 		Logger().Error("socker error: ", err.Error())
 		return
 	}
+	controller.connections.Add(1)
+	defer controller.connections.Done()
+	// every message has to fit into a line of its sender's history
+	socket.SetReadLimit(maxStoredMessageSize)
+
+	// the client doesn't get any messages of the room until it is authorized
+	client := &Client{socket, nil, make(chan *Message, 10), User{}}
+	if err := client.Authenticate(r.Context(), lobbyID); err != nil {
+		Logger().Warn("Client couldn't join room ", name, ": ", err.Error())
+		socket.Close()
+		return
+	}
 
-	client := &Client{socket, room, make(chan *Message, 10), User{}}
-	//TODO we should wait for client to register maybe? Or we don't really have to?
+	var room *ChatRoom
+	if lobbyID != 0 {
+		room, err = controller.roomManager.JoinLobbyRoom(name)
+		if err != nil {
+			Logger().Error("Couldn't open room ", name, ": ", err.Error())
+			socket.Close()
+			return
+		}
+		defer controller.roomManager.LeaveLobbyRoom(name, room)
+	} else if room = controller.roomManager.GetRoom(name); room == nil {
+		// the room has been closed while the client was authorized
+		socket.Close()
+		return
+	}
+	client.Room = room
 	room.Register <- client
 	defer func() { room.Unregister <- client }()
 
@@ -53,7 +83,7 @@ func (controller *ConnectController) JoinRoom(w http.ResponseWriter, r *http.Req
 func (controller *ConnectController) CreateRoom(w http.ResponseWriter, r *http.Request) {
 
 	user := User{Token: r.Header.Get("Authorization")}
-	if err := GetFlankiChecker.Authorize(&user); err != nil {
+	if err := checker.Authorize(r.Context(), &user); err != nil {
 		ChatErrorResponse(w, "unauthorized user", 401)
 		return
 	}
@@ -63,6 +93,10 @@ func (controller *ConnectController) CreateRoom(w http.ResponseWriter, r *http.R
 		ChatErrorResponse(w, "room's name is already taken", 400)
 		return
 	}
+	if LobbyRoomID(name) != 0 {
+		ChatErrorResponse(w, "rooms of lobbies are created when their members join", 400)
+		return
+	}
 
 	err := controller.roomManager.CreateNewRoom(name)
 	if err != nil {
@@ -76,7 +110,7 @@ func (controller *ConnectController) CreateRoom(w http.ResponseWriter, r *http.R
 
 func (controller *ConnectController) CloseRoom(w http.ResponseWriter, r *http.Request) {
 	user := User{Token: r.Header.Get("Authorization")}
-	if err := GetFlankiChecker.Authorize(&user); err != nil {
+	if err := checker.Authorize(r.Context(), &user); err != nil {
 		ChatErrorResponse(w, "unauthorized user", 401)
 		return
 	}
@@ -99,6 +133,38 @@ func (controller *ConnectController) CloseRoom(w http.ResponseWriter, r *http.Re
 	return
 }
 
+// ExportMessages responds with all messages the user has sent
+func (controller *ConnectController) ExportMessages(w http.ResponseWriter, r *http.Request) {
+	user := User{Token: r.Header.Get("Authorization")}
+	if err := checker.Authorize(r.Context(), &user); err != nil {
+		ChatErrorResponse(w, "unauthorized user", 401)
+		return
+	}
+	messages, err := History.ByUser(user.ID)
+	if err != nil {
+		ChatErrorResponse(w, "couldn't read messages: "+err.Error(), 500)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(messages); err != nil {
+		Logger().Error(err.Error())
+	}
+}
+
+// DeleteMessages removes all messages the user has sent, the app calls it when the account is deleted
+func (controller *ConnectController) DeleteMessages(w http.ResponseWriter, r *http.Request) {
+	user := User{Token: r.Header.Get("Authorization")}
+	if err := checker.Authorize(r.Context(), &user); err != nil {
+		ChatErrorResponse(w, "unauthorized user", 401)
+		return
+	}
+	if err := History.DeleteByUser(user.ID); err != nil {
+		ChatErrorResponse(w, "couldn't delete messages: "+err.Error(), 500)
+		return
+	}
+	SimpleRespond(w, "messages have been deleted")
+}
+
 func (controller *ConnectController) ListRooms(w http.ResponseWriter, r *http.Request) {
 	rooms := controller.roomManager.ListRooms()
 	err := json.NewEncoder(w).Encode(rooms)
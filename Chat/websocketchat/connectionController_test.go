@@ -0,0 +1,206 @@
+package websocketchat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeChecker authorizes "Bearer token-<id>" and lets into lobby's room only the members it has been given
+type fakeChecker struct {
+	mutex   sync.Mutex
+	members map[uint]bool
+	closed  bool
+}
+
+func (fake *fakeChecker) Authorize(ctx context.Context, user *User) error {
+	if _, err := fmt.Sscanf(user.Token, "Bearer token-%d", &user.ID); err != nil {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+func (fake *fakeChecker) FetchUserInformation(ctx context.Context, user *User) error {
+	user.Nickname = fmt.Sprintf("player%d", user.ID)
+	return nil
+}
+
+func (fake *fakeChecker) CheckLobbyMember(ctx context.Context, user *User, lobbyID uint) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if fake.closed {
+		return LobbyClosed
+	}
+	if !fake.members[user.ID] {
+		return errors.New("not a member")
+	}
+	return nil
+}
+
+func (fake *fakeChecker) closeLobby() {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.closed = true
+}
+
+// starts chat checking its users with the fake, members are ids of players of every lobby
+func newTestChat(t *testing.T, members ...uint) (*ChatServer, *httptest.Server, *fakeChecker) {
+	t.Helper()
+	fake := &fakeChecker{members: map[uint]bool{}}
+	for _, id := range members {
+		fake.members[id] = true
+	}
+	dir, err := ioutil.TempDir("", "flanki_messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := History.SetDirectory(dir); err != nil {
+		t.Fatal(err)
+	}
+	previous := checker
+	checker = fake
+	server := NewChatServer()
+	httpServer := httptest.NewServer(server.Router())
+	t.Cleanup(func() {
+		httpServer.Close()
+		checker = previous
+		os.RemoveAll(dir)
+	})
+	return server, httpServer, fake
+}
+
+// connects to the room and sends the authorization message of the user
+func dial(t *testing.T, httpServer *httptest.Server, room string, userID uint) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/chat/join/" + room
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("connecting to %s: %v", room, err)
+	}
+	if err := conn.WriteJSON(User{Token: fmt.Sprintf("Bearer token-%d", userID)}); err != nil {
+		t.Fatalf("sending authorization: %v", err)
+	}
+	return conn
+}
+
+// asks for users of the room, chat answers only once the client has joined
+func awaitJoined(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	if err := conn.WriteJSON(&Message{Action: "users"}); err != nil {
+		t.Fatalf("asking for users: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("waiting to join: %v", err)
+	}
+}
+
+func eventually(t *testing.T, condition func() bool, failure string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(failure)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNonMemberIsRefusedFromLobbyRoom(t *testing.T) {
+	server, httpServer, _ := newTestChat(t, 1)
+
+	conn := dial(t, httpServer, "lobby-5", 2)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err == nil {
+		t.Fatalf("expected connection of non-member to be closed, got %+v", msg)
+	} else if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+		t.Fatalf("expected connection of non-member to be closed, it is still open")
+	}
+	if server.roomManager.GetRoom("lobby-5") != nil {
+		t.Fatalf("room shouldn't be created for non-member")
+	}
+}
+
+func TestLobbyRoomIsClosedWhenLastMemberLeaves(t *testing.T) {
+	server, httpServer, _ := newTestChat(t, 1, 2)
+
+	first := dial(t, httpServer, "lobby-5", 1)
+	awaitJoined(t, first)
+	second := dial(t, httpServer, "lobby-5", 2)
+	awaitJoined(t, second)
+	room := server.roomManager.GetRoom("lobby-5")
+	if room == nil || len(room.Users()) != 2 {
+		t.Fatalf("expected both members in the room")
+	}
+
+	first.Close()
+	eventually(t, func() bool { return len(room.Users()) == 1 }, "first member should leave the room")
+	if server.roomManager.GetRoom("lobby-5") != room {
+		t.Fatalf("room should stay open while a member is in it")
+	}
+	second.Close()
+	eventually(t, func() bool { return server.roomManager.GetRoom("lobby-5") == nil }, "empty room should be closed")
+}
+
+func TestLobbyRoomIsClosedTogetherWithLobby(t *testing.T) {
+	server, httpServer, fake := newTestChat(t, 1)
+
+	conn := dial(t, httpServer, "lobby-5", 1)
+	defer conn.Close()
+	awaitJoined(t, conn)
+	server.roomManager.CloseFinishedLobbyRooms(context.Background())
+	if server.roomManager.GetRoom("lobby-5") == nil {
+		t.Fatalf("room of open lobby shouldn't be closed")
+	}
+
+	fake.closeLobby()
+	server.roomManager.CloseFinishedLobbyRooms(context.Background())
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil || msg.Action != LobbyClosedAction {
+		t.Fatalf("expected lobby_closed message, got %+v, %v", msg, err)
+	}
+	if server.roomManager.GetRoom("lobby-5") != nil {
+		t.Fatalf("room of closed lobby should be closed")
+	}
+}
+
+func TestReceivedMessagesAreCountedByKnownActions(t *testing.T) {
+	_, httpServer, _ := newTestChat(t)
+	for _, action := range []string{"message", "other"} {
+		messagesReceivedMetric.WithLabelValues(action).Add(0)
+	}
+	messages := testutil.ToFloat64(messagesReceivedMetric.WithLabelValues("message"))
+	others := testutil.ToFloat64(messagesReceivedMetric.WithLabelValues("other"))
+
+	conn := dial(t, httpServer, "general", 1)
+	defer conn.Close()
+	awaitJoined(t, conn)
+	for _, action := range []string{"message", "made-up-action", "another-one"} {
+		if err := conn.WriteJSON(&Message{Action: action, Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, func() bool {
+		return testutil.ToFloat64(messagesReceivedMetric.WithLabelValues("other")) == others+2
+	}, "unknown actions should be counted as other")
+	if got := testutil.ToFloat64(messagesReceivedMetric.WithLabelValues("message")); got != messages+1 {
+		t.Fatalf("expected one more message, got %v more", got-messages)
+	}
+	if count := testutil.CollectAndCount(messagesReceivedMetric); count > len(Actions)+1 {
+		t.Fatalf("expected series only for known actions and other, got %d", count)
+	}
+}
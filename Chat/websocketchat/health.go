@@ -0,0 +1,132 @@
+package websocketchat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+
+	// time given to every check, they are run concurrently
+	healthCheckTimeout = 2 * time.Second
+)
+
+// reports why a dependency of the service can't be used, nil when it can
+type healthCheck func(ctx context.Context) error
+
+// runs checks of service's dependencies and serves their results at /healthz, /readyz and /status
+type healthChecker struct {
+	service string
+	started time.Time
+	checks  map[string]healthCheck
+}
+
+func newHealthChecker(service string, checks map[string]healthCheck) *healthChecker {
+	return &healthChecker{service: service, started: time.Now(), checks: checks}
+}
+
+type checkResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type healthReport struct {
+	Service       string                 `json:"service"`
+	Status        string                 `json:"status"`
+	UptimeSeconds int64                  `json:"uptime_seconds"`
+	Checks        map[string]checkResult `json:"checks"`
+}
+
+func (checker *healthChecker) run(ctx context.Context) *healthReport {
+	report := &healthReport{
+		Service:       checker.service,
+		Status:        healthOK,
+		UptimeSeconds: int64(time.Since(checker.started).Seconds()),
+		Checks:        make(map[string]checkResult, len(checker.checks)),
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checker.checks {
+		wg.Add(1)
+		go func(name string, check healthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := check(checkCtx)
+			result := checkResult{Status: healthOK, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status, result.Error = healthUnavailable, err.Error()
+			}
+			mutex.Lock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = healthUnavailable
+			}
+			mutex.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// responds as long as the process is able to serve requests
+func (checker *healthChecker) liveness(w http.ResponseWriter, r *http.Request) {
+	respondHealth(w, http.StatusOK, map[string]string{"status": healthOK})
+}
+
+// responds with 503 and names of failing checks when any of them fails
+func (checker *healthChecker) readiness(w http.ResponseWriter, r *http.Request) {
+	report := checker.run(r.Context())
+	failing := []string{}
+	for name, result := range report.Checks {
+		if result.Status != healthOK {
+			failing = append(failing, name)
+		}
+	}
+	sort.Strings(failing)
+	code := http.StatusOK
+	if report.Status != healthOK {
+		code = http.StatusServiceUnavailable
+	}
+	respondHealth(w, code, map[string]interface{}{"status": report.Status, "failing": failing})
+}
+
+// responds with results of all checks, it succeeds even when some of them fail
+func (checker *healthChecker) statusPage(w http.ResponseWriter, r *http.Request) {
+	respondHealth(w, http.StatusOK, checker.run(r.Context()))
+}
+
+func respondHealth(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// checks whether service at url responds, any response other than server error counts
+func reachable(client *http.Client, url func() string) healthCheck {
+	return func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("responded with %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package websocketchat
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StoredMessage is a chat message kept for the player who has sent it, so that it can be exported
+type StoredMessage struct {
+	Room string    `json:"room"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// MessageHistory keeps messages sent by every player in a file of their own, one JSON message per line
+type MessageHistory struct {
+	directory string
+	mutex     sync.Mutex
+}
+
+// longest line of the history which can be read back
+const maxStoredMessageSize = 1024 * 1024
+
+var (
+	History = &MessageHistory{}
+
+	HistoryNotConfigured = errors.New("directory of message history is not set")
+)
+
+// SetDirectory creates the directory of the history unless it exists
+func (history *MessageHistory) SetDirectory(directory string) error {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return err
+	}
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	history.directory = directory
+	return nil
+}
+
+func (history *MessageHistory) file(userID uint) (string, error) {
+	if history.directory == "" {
+		return "", HistoryNotConfigured
+	}
+	return filepath.Join(history.directory, strconv.FormatUint(uint64(userID), 10)+".jsonl"), nil
+}
+
+// Save appends the message to the history of its sender
+func (history *MessageHistory) Save(userID uint, msg StoredMessage) error {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	path, err := history.file(userID)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(msg); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ByUser returns messages sent by the user, the oldest first
+func (history *MessageHistory) ByUser(userID uint) ([]StoredMessage, error) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	messages := []StoredMessage{}
+	path, err := history.file(userID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return messages, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStoredMessageSize)
+	for scanner.Scan() {
+		var msg StoredMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, scanner.Err()
+}
+
+// DeleteByUser removes all messages sent by the user
+func (history *MessageHistory) DeleteByUser(userID uint) error {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	path, err := history.file(userID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
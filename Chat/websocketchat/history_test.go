@@ -0,0 +1,50 @@
+package websocketchat
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHistoryKeepsMessagesOfEverySender(t *testing.T) {
+	if _, err := (&MessageHistory{}).ByUser(1); err != HistoryNotConfigured {
+		t.Fatalf("expected history without directory to fail, got %v", err)
+	}
+	dir, err := ioutil.TempDir("", "flanki_messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	history := &MessageHistory{}
+	if err := history.SetDirectory(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	for _, saved := range []struct {
+		userID uint
+		text   string
+	}{{1, "first"}, {2, "hello"}, {1, "second"}} {
+		if err := history.Save(saved.userID, StoredMessage{Room: "general", Text: saved.text, Time: now}); err != nil {
+			t.Fatalf("saving message: %v", err)
+		}
+	}
+	messages, err := history.ByUser(1)
+	if err != nil || len(messages) != 2 || messages[0].Text != "first" || messages[1].Text != "second" || !messages[0].Time.Equal(now) {
+		t.Fatalf("expected messages of the sender in order, got %+v, %v", messages, err)
+	}
+
+	if err := history.DeleteByUser(1); err != nil {
+		t.Fatalf("deleting messages: %v", err)
+	}
+	if messages, err := history.ByUser(1); err != nil || len(messages) != 0 {
+		t.Fatalf("expected no messages after deleting them, got %+v, %v", messages, err)
+	}
+	if messages, _ := history.ByUser(2); len(messages) != 1 {
+		t.Fatalf("messages of other senders should be kept, got %+v", messages)
+	}
+	if err := history.DeleteByUser(3); err != nil {
+		t.Fatalf("deleting messages of user without any should succeed, got %v", err)
+	}
+}
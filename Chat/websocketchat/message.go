@@ -4,9 +4,16 @@ import "time"
 
 
 var (
-	Actions = []string{"message", "users"}
+	// actions clients can send, rooms ignore messages with other actions
+	Actions = []string{"message", "users", "kick"}
 )
 
+// action of the message sent by the server to all clients right before it shuts down
+const ServerShutdownAction = "server_shutdown"
+
+// action of the message sent to clients of lobby's room right before it is closed together with the lobby
+const LobbyClosedAction = "lobby_closed"
+
 type Message struct {
 	Nickname string      `json:"nickname"`
 	Action   string      `json:"action,omitempty"`
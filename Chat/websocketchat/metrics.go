@@ -0,0 +1,137 @@
+package websocketchat
+
+import (
+	"bufio"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	metricsNamespace = "flanki"
+	metricsSubsystem = "chat"
+)
+
+var (
+	requestsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name: "http_requests_total",
+		Help: "Number of served requests by route template, method and status",
+	}, []string{"route", "method", "status"})
+
+	requestDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name:    "http_request_duration_seconds",
+		Help:    "Time of serving requests by route template, method and status, for web sockets it is the time of whole connection",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	authRequestDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name:    "auth_request_duration_seconds",
+		Help:    "Time of validating access tokens with authorization server",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"result"})
+
+	roomsMetric = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name: "rooms",
+		Help: "Number of opened rooms",
+	})
+
+	clientsMetric = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name: "clients",
+		Help: "Number of clients connected to all rooms",
+	})
+
+	messagesReceivedMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name: "messages_received_total",
+		Help: "Number of messages received from clients by action",
+	}, []string{"action"})
+
+	messagesSentMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name: "messages_sent_total",
+		Help: "Number of messages queued for sending to clients",
+	})
+
+	messagesDroppedMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name: "messages_dropped_total",
+		Help: "Number of messages not delivered because client's queue was full",
+	})
+)
+
+// registry separate from prometheus' default one, services running in one process (e.g. in tests) don't mix their metrics
+var metricsRegistry = newMetricsRegistry()
+
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		requestsMetric, requestDurationMetric, authRequestDurationMetric,
+		roomsMetric, clientsMetric, messagesReceivedMetric, messagesSentMetric, messagesDroppedMetric,
+	)
+	return registry
+}
+
+// actionLabel keeps the action label of received messages to known actions, clients choose actions freely
+// and every other value would make a new series
+func actionLabel(msg *Message) string {
+	if msg.HasValidAction() {
+		return msg.Action
+	}
+	return "other"
+}
+
+// MetricsHandler serves chat's metrics in prometheus' text format
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// counts served requests and measures their time labeled with route's template
+func requestMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		status := strconv.Itoa(recorder.status)
+		requestsMetric.WithLabelValues(route, r.Method, status).Inc()
+		requestDurationMetric.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(code int) {
+	recorder.status = code
+	recorder.ResponseWriter.WriteHeader(code)
+}
+
+// Hijack lets web socket upgrader take over the connection
+func (recorder *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := recorder.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer doesn't support hijacking")
+	}
+	recorder.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
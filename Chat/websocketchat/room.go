@@ -7,9 +7,15 @@ import (
 
 type ChatRoom struct {
 
+	// name the room has been created with
+	Name string
+
 	// owner of the room, allows him to kick people out, mute them and close the room (not all of this is implemented right now)
 	OwnerID uint
 
+	// lobby whose owner, players and spectators are the only ones let in, 0 for rooms open to everyone
+	LobbyID uint
+
 	// clients map(sneaky set)
 	Clients map[*Client] bool
 
@@ -25,6 +31,9 @@ type ChatRoom struct {
 	// chanel for closing chanel launched by Run() method
 	Close chan struct{}
 
+	// members of the lobby who have joined its room and haven't left yet, guarded by the manager's mutex
+	members int
+
 	// mutex for securing clients' map
 	clientMutex sync.RWMutex
 
@@ -55,10 +64,27 @@ func (room *ChatRoom) Users() []string {
 	return users
 }
 
+// anyUser returns user of one of the clients, false when the room is empty
+func (room *ChatRoom) anyUser() (User, bool) {
+	room.clientMutex.RLock()
+	defer room.clientMutex.RUnlock()
+
+	for k := range room.Clients {
+		return k.User, true
+	}
+	return User{}, false
+}
+
 func (room *ChatRoom) DispatchMessage(ctx context.Context, msg *Message) {
 
+	messagesReceivedMetric.WithLabelValues(actionLabel(msg)).Inc()
 	switch msg.Action {
 	case "message":
+		// messages are kept for their senders so that they can export them
+		client := ctx.Value("client").(*Client)
+		if err := History.Save(client.User.ID, StoredMessage{Room: room.Name, Text: msg.Text, Time: msg.Time}); err != nil {
+			Logger().Error("Couldn't save message of user ", client.User.ID, ": ", err.Error())
+		}
 		room.OutMessages <- msg
 	case "users":
 		client := ctx.Value("client").(*Client)
@@ -95,13 +121,14 @@ func (room *ChatRoom) Run() {
 	for {
 		select {
 		case msg := <- room.OutMessages:
-			for c, _ := range room.Clients {
-				c.Send <- msg
-			}
+			room.broadcast(msg)
 
 		case c := <- room.Register:
-			c.Room = room
+			// client's room is set when it connects, before it is read by client's goroutines
+			room.clientMutex.Lock()
 			room.Clients[c] = true
+			room.clientMutex.Unlock()
+			clientsMetric.Inc()
 
 		case c := <- room.Unregister:
 			// write lock
@@ -109,15 +136,44 @@ func (room *ChatRoom) Run() {
 			if _, ok := room.Clients[c]; ok {
 				delete(room.Clients, c)
 				close(c.Send)
+				clientsMetric.Dec()
 			}
 			room.clientMutex.Unlock()
 
 		case <- room.Close:
+			// messages queued before closing (e.g. notice about server shutting down) still reach the clients
+			room.flush()
+			room.clientMutex.Lock()
 			for c,_ := range room.Clients {
 				close(c.Send)
 			}
+			clientsMetric.Sub(float64(len(room.Clients)))
 			// let the grabage collector clean clients' set
 			room.Clients = map[*Client] bool{}
+			room.clientMutex.Unlock()
+			return
+		}
+	}
+}
+
+func (room *ChatRoom) broadcast(msg *Message) {
+	for c, _ := range room.Clients {
+		// slow client can't block the whole room, it just misses the message
+		select {
+		case c.Send <- msg:
+			messagesSentMetric.Inc()
+		default:
+			messagesDroppedMetric.Inc()
+		}
+	}
+}
+
+func (room *ChatRoom) flush() {
+	for {
+		select {
+		case msg := <- room.OutMessages:
+			room.broadcast(msg)
+		default:
 			return
 		}
 	}
@@ -1,8 +1,12 @@
 package websocketchat
 
 import (
+	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -15,6 +19,22 @@ var (
 	MaxLobbiesLimit = 100
 )
 
+// rooms of lobbies are named after them e.g. lobby-17, they are created when the first member of the lobby joins
+// and closed when the last one leaves or when the lobby is closed
+const LobbyRoomPrefix = "lobby-"
+
+// LobbyRoomID returns id of the lobby whose room has given name, 0 for other rooms
+func LobbyRoomID(name string) uint {
+	if !strings.HasPrefix(name, LobbyRoomPrefix) {
+		return 0
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(name, LobbyRoomPrefix), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
 type RoomManager struct {
 	rooms map[string] *ChatRoom
 	mutex sync.Mutex
@@ -28,31 +48,116 @@ func (manager *RoomManager) CreateNewRoom(name string) error {
 	manager.mutex.Lock()
 	defer manager.mutex.Unlock()
 
+	_, err := manager.createRoom(name)
+	return err
+}
+
+// createRoom has to be called with the manager's mutex locked
+func (manager *RoomManager) createRoom(name string) (*ChatRoom, error) {
 	if len(manager.rooms) >= MaxLobbiesLimit {
-		return RoomLimitReached
+		return nil, RoomLimitReached
 	}
 	if _, found := manager.rooms[name]; found {
-		return RoomNameTaken
+		return nil, RoomNameTaken
 	}
 	room := NewChatRoom()
+	room.Name = name
+	room.LobbyID = LobbyRoomID(name)
 	manager.rooms[name] = room
 	go room.Run()
-	return nil
+	roomsMetric.Inc()
+	return room, nil
 }
 
 func (manager *RoomManager) CloseRoom(name string) error {
 	manager.mutex.Lock()
 	defer manager.mutex.Unlock()
 
+	return manager.closeRoom(name)
+}
+
+// closeRoom has to be called with the manager's mutex locked
+func (manager *RoomManager) closeRoom(name string) error {
 	if room, found := manager.rooms[name]; !found {
 		return RoomNotFound
 	} else {
 		room.Close <- struct{}{}
 		delete(manager.rooms, name)
+		roomsMetric.Dec()
 		return nil
 	}
 }
 
+// JoinLobbyRoom returns room of the lobby for its authorized member, the room is created when they are the first one
+func (manager *RoomManager) JoinLobbyRoom(name string) (*ChatRoom, error) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	room, found := manager.rooms[name]
+	if !found {
+		var err error
+		if room, err = manager.createRoom(name); err != nil {
+			return nil, err
+		}
+	}
+	room.members++
+	return room, nil
+}
+
+// LeaveLobbyRoom closes room of the lobby once its last member leaves, unless it has been closed already
+func (manager *RoomManager) LeaveLobbyRoom(name string, room *ChatRoom) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	room.members--
+	if room.members == 0 && manager.rooms[name] == room {
+		_ = manager.closeRoom(name)
+	}
+}
+
+// CloseFinishedLobbyRooms closes rooms of lobbies which have been closed, each lobby is fetched with the token
+// of one of the room's clients. Clients of the rooms are told the lobby has been closed
+func (manager *RoomManager) CloseFinishedLobbyRooms(ctx context.Context) {
+	manager.mutex.Lock()
+	lobbyRooms := map[string]*ChatRoom{}
+	for name, room := range manager.rooms {
+		if room.LobbyID != 0 {
+			lobbyRooms[name] = room
+		}
+	}
+	manager.mutex.Unlock()
+
+	for name, room := range lobbyRooms {
+		user, found := room.anyUser()
+		if !found {
+			continue
+		}
+		err := checker.CheckLobbyMember(ctx, &user, room.LobbyID)
+		if err != LobbyClosed {
+			continue
+		}
+		manager.mutex.Lock()
+		if manager.rooms[name] == room {
+			room.OutMessages <- &Message{Action: LobbyClosedAction, Text: "The lobby has been closed", Time: time.Now()}
+			_ = manager.closeRoom(name)
+		}
+		manager.mutex.Unlock()
+	}
+}
+
+// CloseAll sends farewell message to clients of all rooms and closes them
+func (manager *RoomManager) CloseAll(farewell *Message) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	for name, room := range manager.rooms {
+		room.OutMessages <- farewell
+		room.Close <- struct{}{}
+		delete(manager.rooms, name)
+		roomsMetric.Dec()
+	}
+}
+
 func (manager *RoomManager) SelfCloseRoom(room *ChatRoom) (err error) {
 	for k,v := range manager.rooms {
 		if v == room {
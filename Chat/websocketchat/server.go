@@ -1,16 +1,29 @@
 package websocketchat
 
 import (
+	"context"
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 type ChatServer struct {
 	roomManager *RoomManager
+	connectController *ConnectController
 	router *mux.Router
+	httpServer *http.Server
 }
 
+// time given to clients to receive the farewell message and to requests being served to finish when shutting down
+var ShutdownTimeout = 15 * time.Second
+
+// how often rooms of lobbies are checked whether their lobbies have been closed
+var LobbyRoomsCheckInterval = time.Minute
+
 
 func LoggerFuncWrapper(f func(w http.ResponseWriter, r *http.Request))  func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -26,22 +39,106 @@ func NewChatServer() *ChatServer {
 	server := ChatServer{}
 	server.roomManager = NewRoomManager()
 	connectionController := NewConnectController(server.roomManager)
+	server.connectController = connectionController
 	server.router = mux.NewRouter()
 
 	server.Get("/chat/join/{name}", connectionController.JoinRoom)
 	server.Get("/chat/rooms", connectionController.ListRooms)
 	server.Post("/chat/create/{name}", connectionController.CreateRoom)
 	server.Post("/chat/close/{name}", connectionController.CloseRoom)
+	server.Get("/chat/messages", connectionController.ExportMessages)
+	server.Delete("/chat/messages", connectionController.DeleteMessages)
+
+	// not wrapped with request logging, metrics are scraped every few seconds
+	server.router.Handle("/metrics", MetricsHandler()).Methods("GET")
+	health := newHealthChecker("flanki-chat", map[string]healthCheck{
+		"auth_server": reachable(authClient, func() string { return GetFlankiChecker.authEndpoint + "/healthz" }),
+		"api":         reachable(httpClient, func() string { return GetFlankiChecker.apiEndpoint + "/healthz" }),
+	})
+	server.router.HandleFunc("/healthz", health.liveness).Methods("GET")
+	server.router.HandleFunc("/readyz", health.readiness).Methods("GET")
+	server.router.HandleFunc("/status", health.statusPage).Methods("GET")
+	server.router.Use(tracingMiddleware, requestMetricsMiddleware)
 
 	_ = server.roomManager.CreateNewRoom("general")
 	return &server
 }
 
-func (server *ChatServer) Run(address string, enableSLL string) error {
+// Run serves the chat until it fails or SIGTERM (or interrupt) is received, then it shuts down gracefully
+func (server *ChatServer) Run(address string, enableSSL bool) error {
 	// SLL not needed yet
 
 	RawLogger().Info("Chat running on ", address)
-	return http.ListenAndServe(address, server.router)
+	server.httpServer = &http.Server{Addr: address, Handler: server.router}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.httpServer.ListenAndServe() }()
+	stopChecks := make(chan struct{})
+	defer close(stopChecks)
+	go server.checkLobbyRooms(stopChecks)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case sig := <-signals:
+		RawLogger().Info("Received ", sig, ", shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}
+}
+
+// Shutdown stops accepting new connections, sends server_shutdown message to every connected client
+// and waits until all web sockets are closed or ctx is done
+func (server *ChatServer) Shutdown(ctx context.Context) error {
+	var err error
+	if server.httpServer != nil {
+		// web sockets are hijacked connections, http server doesn't wait for them
+		err = server.httpServer.Shutdown(ctx)
+	}
+	server.roomManager.CloseAll(&Message{
+		Action: ServerShutdownAction,
+		Text:   "Chat server is shutting down, reconnect in a moment",
+		Time:   time.Now(),
+	})
+
+	closed := make(chan struct{})
+	go func() {
+		server.connectController.connections.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// checkLobbyRooms closes rooms of closed lobbies every LobbyRoomsCheckInterval until stop is closed
+func (server *ChatServer) checkLobbyRooms(stop <-chan struct{}) {
+	ticker := time.NewTicker(LobbyRoomsCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), LobbyRoomsCheckInterval)
+			server.roomManager.CloseFinishedLobbyRooms(ctx)
+			cancel()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Router returns handler serving all chat's endpoints
+func (server *ChatServer) Router() http.Handler {
+	return server.router
 }
 
 func (server *ChatServer) Get(path string, f func(w http.ResponseWriter, r *http.Request)) {
@@ -53,3 +150,7 @@ func (server *ChatServer) Post(path string, f func(w http.ResponseWriter, r *htt
 	server.router.HandleFunc(path, LoggerFuncWrapper(f)).Methods("POST")
 }
 
+// Delete wraps the Router for DELETE method
+func (server *ChatServer) Delete(path string, f func(w http.ResponseWriter, r *http.Request)) {
+	server.router.HandleFunc(path, LoggerFuncWrapper(f)).Methods("DELETE")
+}
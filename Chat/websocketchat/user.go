@@ -2,13 +2,14 @@ package websocketchat
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"time"
 )
 
@@ -23,11 +24,15 @@ type User struct {
 // validating token which will be used for nickname retrieving
 type UserChecker interface {
 
-	// checks user authorization with given access token and saves his id fetched from authorization server
-	Authorize(*User) error
+	// checks user authorization with given access token and saves his id fetched from authorization server,
+	// context is the one of served request so that the check is traced as its part
+	Authorize(context.Context, *User) error
 
 	// obtains user information e.g. nickname
-	FetchUserInformation(*User) error
+	FetchUserInformation(context.Context, *User) error
+
+	// checks whether the user owns, co-owns, referees, plays in or watches the lobby, LobbyClosed is returned once the lobby is closed
+	CheckLobbyMember(context.Context, *User, uint) error
 }
 
 type FlankiChecker struct {
@@ -37,7 +42,10 @@ type FlankiChecker struct {
 
 var (
 	GetFlankiChecker  = FlankiChecker{}
-	httpClient = &http.Client{}
+	// checks users of every connection and request, tests replace it with a fake one
+	checker UserChecker = &GetFlankiChecker
+	httpClient = &http.Client{Transport: &tracingTransport{}}
+	authClient = &http.Client{Transport: &tracingTransport{}}
 )
 
 func (checker *FlankiChecker) SetEndpoints(api string, auth string) {
@@ -51,24 +59,31 @@ type TokenResponse struct {
 	UserID   uint `json:"user_id"`
 }
 
-func (checker *FlankiChecker) Authorize(user *User) error {
+func (checker *FlankiChecker) Authorize(ctx context.Context, user *User) error {
 	url := checker.authEndpoint + "/authorize"
-	request, err := http.NewRequest("POST", url, bytes.NewBuffer(nil))
+	request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(nil))
 	if err != nil {
 		return err
 	}
 	request.Header.Add("Authorization", user.Token)
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	start := time.Now()
+	resp, err := authClient.Do(request)
 	if err != nil {
+		authRequestDurationMetric.WithLabelValues("error").Observe(time.Since(start).Seconds())
 		return err
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
+		result := "unauthorized"
+		if resp.StatusCode >= 500 {
+			result = "error"
+		}
+		authRequestDurationMetric.WithLabelValues(result).Observe(time.Since(start).Seconds())
 		return errors.New("unauthorized user")
 	}
+	authRequestDurationMetric.WithLabelValues("ok").Observe(time.Since(start).Seconds())
 
 	tokenInfo := TokenResponse{}
 	err = json.NewDecoder(resp.Body).Decode(&tokenInfo)
@@ -79,10 +94,10 @@ func (checker *FlankiChecker) Authorize(user *User) error {
 	return nil
 }
 
-func (checker *FlankiChecker) FetchUserInformation(user *User) error {
+func (checker *FlankiChecker) FetchUserInformation(ctx context.Context, user *User) error {
 	url := checker.apiEndpoint + "/user/me"
 
-	request, err := http.NewRequest("GET", url, bytes.NewBuffer(nil))
+	request, err := http.NewRequestWithContext(ctx, "GET", url, bytes.NewBuffer(nil))
 	if err != nil {
 		return err
 	}
@@ -112,23 +127,95 @@ func (checker *FlankiChecker) FetchUserInformation(user *User) error {
 	return nil
 }
 
-func init() {
+// LobbyClosed is returned when checking membership of the lobby which has been closed or no longer exists
+var LobbyClosed = errors.New("lobby has been closed")
+
+// members of the lobby as the api returns them
+type lobbyMembers struct {
+	Closed    bool          `json:"closed"`
+	OwnerID   uint          `json:"lobby_owner"`
+	CoOwners  []lobbyMember `json:"co_owners"`
+	RefereeID uint          `json:"referee_id"` // 0 when the lobby has no referee
+	Teams   []struct {
+		Players []lobbyMember `json:"players"`
+	} `json:"teams"`
+	Spectators []lobbyMember `json:"spectators"`
+}
+
+type lobbyMember struct {
+	PlayerID uint `json:"player_id"`
+}
 
-	if production := os.Getenv("PRODUCTION"); production != "true" {
-		return
+func (members *lobbyMembers) contains(id uint) bool {
+	if members.OwnerID == id || (members.RefereeID != 0 && members.RefereeID == id) {
+		return true
 	}
+	for _, coOwner := range members.CoOwners {
+		if coOwner.PlayerID == id {
+			return true
+		}
+	}
+	for _, team := range members.Teams {
+		for _, player := range team.Players {
+			if player.PlayerID == id {
+				return true
+			}
+		}
+	}
+	for _, spectator := range members.Spectators {
+		if spectator.PlayerID == id {
+			return true
+		}
+	}
+	return false
+}
 
+func (checker *FlankiChecker) CheckLobbyMember(ctx context.Context, user *User, lobbyID uint) error {
+	url := fmt.Sprintf("%s/lobbies/%d", checker.apiEndpoint, lobbyID)
 
-	localCertFile := "/ssl_certs/cert.pem"
+	request, err := http.NewRequestWithContext(ctx, "GET", url, bytes.NewBuffer(nil))
+	if err != nil {
+		return err
+	}
+	request.Header.Add("Authorization", user.Token)
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return LobbyClosed
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("couldn't get lobby, status %d", resp.StatusCode)
+	}
+	members := &lobbyMembers{}
+	if err = json.NewDecoder(resp.Body).Decode(members); err != nil {
+		return err
+	}
+	if members.Closed {
+		return LobbyClosed
+	}
+	if !members.contains(user.ID) {
+		return errors.New("user is not a member of the lobby")
+	}
+	return nil
+}
+
+// TrustCertificate makes requests to the app trust certificate from certFile next to system ones,
+// it is needed in production where the app serves its own certificate
+func TrustCertificate(certFile string) error {
 	rootCAs, _ := x509.SystemCertPool()
 	if rootCAs == nil {
 		rootCAs = x509.NewCertPool()
 	}
 
 	// Read in the cert file
-	certs, err := ioutil.ReadFile(localCertFile)
+	certs, err := ioutil.ReadFile(certFile)
 	if err != nil {
-		Logger().Fatal("Failed to append %q to RootCAs: %v", localCertFile, err)
+		return fmt.Errorf("failed to append %s to RootCAs: %s", certFile, err)
 	}
 
 	// Append our cert to the system pool
@@ -142,5 +229,6 @@ func init() {
 		RootCAs:            rootCAs,
 	}
 	tr := &http.Transport{TLSClientConfig: config}
-	httpClient = &http.Client{Transport: tr}
-}
\ No newline at end of file
+	httpClient = &http.Client{Transport: &tracingTransport{Base: tr}}
+	return nil
+}
@@ -0,0 +1,18 @@
+package app
+
+import (
+	"FlankiRest/models"
+)
+
+// backfillAchievements awards badges earned by matches played before their rules existed, it's done
+// every time database gets connected since awarding them again changes nothing
+func (app *App) backfillAchievements() {
+	logEntry := app.Logger.WithField("prefix", "[ACHIEVEMENTS]")
+	awarded, err := models.BackfillAchievements(app.Repos.Achievements, app.Repos.Accounts, app.Repos.Statistics, app.Repos.Tournaments)
+	if err != nil {
+		logEntry.Error("Error while backfilling achievements: ", err.Error())
+	}
+	if awarded > 0 {
+		logEntry.Infof("Awarded %d achievements for past matches", awarded)
+	}
+}
@@ -4,10 +4,17 @@ import (
 	"FlankiRest/config"
 	"FlankiRest/controllers"
 	"FlankiRest/database"
+	"FlankiRest/jobs"
 	"FlankiRest/logger"
+	"FlankiRest/metrics"
 	"FlankiRest/models"
+	"FlankiRest/ratelimit"
+	"FlankiRest/repositories"
 	"FlankiRest/services"
+	"FlankiRest/tracing"
 	"FlankiRest/utils"
+	"context"
+	"database/sql"
 	"fmt"
 	"github.com/gorilla/mux"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
@@ -17,20 +24,32 @@ import (
 	stdlogger "log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 type App struct {
 	ApiDB     *database.ApiDatabase
+	Repos     *repositories.Repositories
 	dbTicker  *utils.DatabaseReconnectTicker
+	stopJobs  chan struct{} // closed on shutdown to stop background jobs
+	ownersSeen *seenAccounts // accounts whose activity has been recorded recently
+	server    *http.Server
 	Router    *mux.Router
 	Logger    *logrus.Logger
 	AuthCfg   *oauth2.Config // needed for account's controller when setting routing
 	AppConfig *config.AppConfig
+	Limiter   *ratelimit.Limiter // throttles routes open to guessing passwords
+	Scheduler *jobs.Scheduler    // runs background jobs, one instance of the app at a time
 }
 
 func NewApp(cfg *oauth2.Config) *App {
-	return &App{AuthCfg: cfg,ApiDB: &database.ApiDatabase{}}
+	apiDB := &database.ApiDatabase{}
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), config.GetRateLimitConfig())
+	app := &App{AuthCfg: cfg, ApiDB: apiDB, Repos: repositories.NewPostgresRepositories(apiDB), Limiter: limiter, ownersSeen: newSeenAccounts()}
+	app.Scheduler = app.newScheduler()
+	return app
 }
 
 func (app *App) GetDatabaseInstance() *database.ApiDatabase {
@@ -63,18 +82,75 @@ func (app *App) Initialize(apiConfig *config.AppConfig) {
 	log := app.Logger
 	log.Info("Initializing app")
 
-	dbUri := fmt.Sprintf("host=%s user=%s dbname=%s sslmode=disable password=%s", apiConfig.DBHost, apiConfig.DBUsername, apiConfig.DBName, apiConfig.DBPassword)
+	// buckets are shared by all instances of the app through the database
+	rateLimits := config.GetRateLimitConfig()
+	if rateLimits.Store == config.PostgresRateLimitStore {
+		app.Limiter = ratelimit.NewLimiter(ratelimit.NewPostgresStore(app.ApiDB), rateLimits)
+	}
+
+	dbUri := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", apiConfig.DBHost, apiConfig.DBPort, apiConfig.DBUsername, apiConfig.DBName, apiConfig.DBPassword)
 	databaseSetup := func() {
+		tracing.InstrumentGorm(app.GetDatabaseInstance().DB())
 
-		if apiLogger, found := os.LookupEnv("DATABASE_API_LOGGER"); found && apiLogger == "true" {
+		if apiConfig.DatabaseAPILogger {
 			app.GetDatabaseInstance().DB().SetLogger(&GormLogger{})
 		}
-		if dbDebug, found := os.LookupEnv("DATABASE_DEBUG"); found && dbDebug == "true" {
+		if apiConfig.DatabaseDebug {
 			app.GetDatabaseInstance().DB().LogMode(true)
 		}
-		app.GetDatabaseInstance().DB().AutoMigrate(&models.Account{}, &models.Lobby{}, &models.Team{}, &models.TeamEntry{}, &models.PlayerStatisticsEntry{}, &services.PasswordReset{})
+		app.GetDatabaseInstance().DB().AutoMigrate(&models.Account{}, &models.Lobby{}, &models.Team{}, &models.TeamEntry{}, &models.PlayerStatisticsEntry{}, &models.ResultRecord{}, &models.MatchEvent{}, &services.PasswordReset{},
+			&models.Tournament{}, &models.TournamentTeam{}, &models.TournamentMember{}, &models.TournamentMatch{}, &models.RSVP{}, &models.Friendship{}, &models.Follow{},
+			&models.Notification{}, &models.NotificationPreference{}, &models.PushSubscription{}, &models.Achievement{}, &models.LobbyInvite{}, &models.LobbySpectator{}, &models.LobbyCoOwner{},
+			&jobs.Lease{}, &jobs.Run{})
 		app.GetDatabaseInstance().DB().Model(&models.Team{}).AddForeignKey("lobby_id", "lobbies(id)", "CASCADE", "CASCADE")
 		app.GetDatabaseInstance().DB().Model(&models.TeamEntry{}).AddForeignKey("team_id", "teams(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.ResultRecord{}).AddForeignKey("lobby_id", "lobbies(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.MatchEvent{}).AddForeignKey("lobby_id", "lobbies(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.RSVP{}).AddForeignKey("lobby_id", "lobbies(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.RSVP{}).AddUniqueIndex("idx_rsvps_lobby_player", "lobby_id", "player_id")
+		app.GetDatabaseInstance().DB().Model(&models.Account{}).AddIndex("idx_accounts_calendar_token", "calendar_token")
+		app.GetDatabaseInstance().DB().Model(&models.Friendship{}).AddForeignKey("requester_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.Friendship{}).AddForeignKey("addressee_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.Friendship{}).AddUniqueIndex("idx_friendships_requester_addressee", "requester_id", "addressee_id")
+		app.GetDatabaseInstance().DB().Model(&models.Friendship{}).AddIndex("idx_friendships_addressee", "addressee_id")
+		app.GetDatabaseInstance().DB().Model(&models.Follow{}).AddForeignKey("follower_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.Follow{}).AddForeignKey("followee_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.Follow{}).AddUniqueIndex("idx_follows_follower_followee", "follower_id", "followee_id")
+		app.GetDatabaseInstance().DB().Model(&models.Follow{}).AddIndex("idx_follows_followee", "followee_id")
+		app.GetDatabaseInstance().DB().Model(&models.Notification{}).AddForeignKey("account_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.Notification{}).AddIndex("idx_notifications_account", "account_id")
+		app.GetDatabaseInstance().DB().Model(&models.NotificationPreference{}).AddForeignKey("account_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.NotificationPreference{}).AddUniqueIndex("idx_notification_preferences_account_type", "account_id", "type")
+		app.GetDatabaseInstance().DB().Model(&models.PushSubscription{}).AddForeignKey("account_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.PushSubscription{}).AddUniqueIndex("idx_push_subscriptions_endpoint", "endpoint")
+		app.GetDatabaseInstance().DB().Model(&models.Achievement{}).AddForeignKey("player_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.Achievement{}).AddUniqueIndex("idx_achievements_player_badge", "player_id", "badge")
+		app.GetDatabaseInstance().DB().Model(&models.LobbyInvite{}).AddForeignKey("lobby_id", "lobbies(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.LobbyInvite{}).AddForeignKey("player_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.LobbyInvite{}).AddUniqueIndex("idx_lobby_invites_code", "code")
+		app.GetDatabaseInstance().DB().Model(&models.LobbySpectator{}).AddForeignKey("lobby_id", "lobbies(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.LobbySpectator{}).AddForeignKey("player_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.LobbySpectator{}).AddUniqueIndex("idx_lobby_spectators_lobby_player", "lobby_id", "player_id")
+		app.GetDatabaseInstance().DB().Model(&models.LobbyCoOwner{}).AddForeignKey("lobby_id", "lobbies(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.LobbyCoOwner{}).AddForeignKey("player_id", "accounts(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.LobbyCoOwner{}).AddUniqueIndex("idx_lobby_co_owners_lobby_player", "lobby_id", "player_id")
+		app.GetDatabaseInstance().DB().Model(&jobs.Run{}).AddIndex("idx_job_runs_job_started_at", "job", "started_at")
+		app.GetDatabaseInstance().DB().Model(&models.TournamentTeam{}).AddForeignKey("tournament_id", "tournaments(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.TournamentMember{}).AddForeignKey("tournament_team_id", "tournament_teams(id)", "CASCADE", "CASCADE")
+		app.GetDatabaseInstance().DB().Model(&models.TournamentMatch{}).AddForeignKey("tournament_id", "tournaments(id)", "CASCADE", "CASCADE")
+		// lobbies created before states were introduced get them from closed flag and winner
+		app.GetDatabaseInstance().DB().Exec(`UPDATE lobbies SET state = CASE WHEN closed = false THEN 'open' WHEN winner <> '' THEN 'finished' ELSE 'cancelled' END
+			WHERE state IS NULL OR state = ''`)
+		// lobbies created before visibility was introduced are visible to everyone
+		app.GetDatabaseInstance().DB().Exec(`UPDATE lobbies SET visibility = 'everyone' WHERE visibility IS NULL OR visibility = ''`)
+		// results submitted before they could be confirmed count as confirmed
+		app.GetDatabaseInstance().DB().Exec(`UPDATE lobbies SET result_status = 'confirmed' WHERE state = 'finished' AND (result_status IS NULL OR result_status = '')`)
+		// owners of lobbies opened before their activity was recorded were last seen when the lobby was updated
+		app.GetDatabaseInstance().DB().Exec(`UPDATE lobbies SET owner_seen_at = updated_at WHERE closed = false AND owner_seen_at IS NULL`)
+		if rateLimits.Store == config.PostgresRateLimitStore {
+			app.GetDatabaseInstance().DB().AutoMigrate(&ratelimit.Bucket{})
+		}
+		go app.backfillAchievements()
 
 	}
 
@@ -89,9 +165,21 @@ func (app *App) Initialize(apiConfig *config.AppConfig) {
 
 
 
-	app.dbTicker = utils.NewDatabaseReconnectTicker(app.ApiDB, dbUri, time.Second * 30)
+	metrics.SetDatabase(func() *sql.DB {
+		if db := app.GetDatabaseInstance().DB(); db != nil {
+			return db.DB()
+		}
+		return nil
+	})
+
+	app.dbTicker = utils.NewDatabaseReconnectTicker(app.ApiDB, dbUri, databaseReconnectInterval)
 	go func() {
-		for range app.dbTicker.Ticker.C {
+		for {
+			select {
+			case <-app.dbTicker.Done():
+				return
+			case <-app.dbTicker.Ticker.C:
+			}
 			reconnected, err := app.dbTicker.TryReconnect()
 			logEntry := app.Logger.WithField("prefix", "[DATABSE CONNECT]")
 			if err != nil {
@@ -104,7 +192,8 @@ func (app *App) Initialize(apiConfig *config.AppConfig) {
 		}
 	}()
 
-
+	app.stopJobs = make(chan struct{})
+	app.Scheduler.Start(app.stopJobs, app.Logger.WithField("prefix", "[JOBS]"))
 
 	app.SetRouting(config.API_PREFIX)
 	if app.Router == nil {
@@ -116,23 +205,39 @@ func (app *App) Initialize(apiConfig *config.AppConfig) {
 func (app *App) SetRouting(API_PREFIX string) {
 	log := app.Logger
 	log.Info("Setting routing for app")
-	accountController    := controllers.NewAccountController(app.GetDatabaseInstance(), app.AuthCfg, app.Logger)
-	lobbyController      := controllers.NewLobbyController(app.GetDatabaseInstance(), app.Logger)
-	playerController     := controllers.NewPlayerController(app.GetDatabaseInstance(), app.Logger)
-	statisticsController := controllers.NewStatisticsController(app.GetDatabaseInstance(), app.Logger)
+	accountController    := controllers.NewAccountController(app.GetDatabaseInstance(), app.Repos, app.AuthCfg, app.Logger)
+	lobbyController      := controllers.NewLobbyController(app.Repos, app.Logger)
+	playerController     := controllers.NewPlayerController(app.Repos, app.Logger)
+	statisticsController := controllers.NewStatisticsController(app.Repos, app.Logger)
 	imageController      := controllers.NewImageController(app.Logger)
+	tournamentController := controllers.NewTournamentController(app.Repos, app.Logger)
+	notificationController := controllers.NewNotificationController(app.Repos, app.Logger)
+	adminController      := controllers.NewAdminController(app.Scheduler, app.Logger)
 
 	app.Router = mux.NewRouter()
 	app.Post(  API_PREFIX + "/user/create",                   accountController.CreateAccount)
-	app.Post(  API_PREFIX + "/user/login",                    accountController.LoginAccount)
+	app.Post(  API_PREFIX + "/user/login",                    app.Limiter.Limit(config.LoginRoute, ratelimit.EmailAccount, accountController.LoginAccount))
+	app.Post(  API_PREFIX + "/user/restore",                  app.Limiter.Limit(config.LoginRoute, ratelimit.EmailAccount, accountController.RestoreAccount))
 	app.Patch( API_PREFIX + "/user/me",                       accountController.UpdateAccount)
 	app.Delete(API_PREFIX + "/user/me",                       accountController.DeleteAccount)
 	app.Get(   API_PREFIX + "/user/me",                       accountController.GetAccount)
+	app.Get(   API_PREFIX + "/user/me/calendar",              accountController.CalendarFeed)
+	app.Post(  API_PREFIX + "/user/me/calendar",              accountController.CalendarFeed)
+	app.Get(   API_PREFIX + "/user/me/export",                accountController.ExportAccount)
 
 	app.Get(   API_PREFIX + "/players",                       playerController.GetAllPlayers)
 	app.Get(   API_PREFIX + "/players/{id:[0-9]+}",           playerController.GetPlayerById)
 	app.Get(   API_PREFIX + "/players/{id:[0-9]+}/summary",   statisticsController.GetPlayerSummary)
 	app.Get(   API_PREFIX + "/players/ranking",               statisticsController.GetPlayersRanking)
+	app.Get(   API_PREFIX + "/players/me/friends",            playerController.GetFriends)
+	app.Get(   API_PREFIX + "/players/me/friends/requests",   playerController.GetFriendRequests)
+	app.Get(   API_PREFIX + "/players/me/follows",            playerController.GetFollows)
+	app.Post(  API_PREFIX + "/players/{id:[0-9]+}/friend",    playerController.SendFriendRequest)
+	app.Delete(API_PREFIX + "/players/{id:[0-9]+}/friend",    playerController.RemoveFriend)
+	app.Post(  API_PREFIX + "/players/{id:[0-9]+}/friend/accept",  playerController.AcceptFriendRequest)
+	app.Post(  API_PREFIX + "/players/{id:[0-9]+}/friend/decline", playerController.DeclineFriendRequest)
+	app.Post(  API_PREFIX + "/players/{id:[0-9]+}/follow",    playerController.FollowPlayer)
+	app.Delete(API_PREFIX + "/players/{id:[0-9]+}/follow",    playerController.UnfollowPlayer)
 
 
 	app.Get(   API_PREFIX + "/lobbies/owner",                 lobbyController.OwnerLobby)
@@ -142,29 +247,75 @@ func (app *App) SetRouting(API_PREFIX string) {
 	app.Post(  API_PREFIX + "/lobbies/owner/submit",          lobbyController.SubmitResults)
 	app.Post(  API_PREFIX + "/lobbies/owner/close",           lobbyController.CloseLobby)
 	app.Post(  API_PREFIX + "/lobbies/owner/kick_player",     lobbyController.KickPlayerFromLobby)
+	app.Post(  API_PREFIX + "/lobbies/owner/kick_spectator",  lobbyController.KickSpectator)
+	app.Post(  API_PREFIX + "/lobbies/owner/shuffle",         lobbyController.ShuffleTeams)
+	app.Post(  API_PREFIX + "/lobbies/owner/ready_check",     lobbyController.StartReadyCheck)
+	app.Post(  API_PREFIX + "/lobbies/owner/start",           lobbyController.StartMatch)
+	app.Post(  API_PREFIX + "/lobbies/owner/transfer",        lobbyController.TransferOwnership)
+	app.Post(  API_PREFIX + "/lobbies/owner/co_owners",       lobbyController.AddCoOwner)
+	app.Delete(API_PREFIX + "/lobbies/owner/co_owners/{id:[0-9]+}", lobbyController.RemoveCoOwner)
+	app.Get(   API_PREFIX + "/lobbies/owner/invites",         lobbyController.GetInvites)
+	app.Post(  API_PREFIX + "/lobbies/owner/invites",         lobbyController.CreateInvite)
+	app.Delete(API_PREFIX + "/lobbies/owner/invites/{code}",  lobbyController.RevokeInvite)
 	app.Get(   API_PREFIX + "/lobbies/my",                    lobbyController.GetCurrentLobby)
 
 	app.Get(   API_PREFIX + "/lobbies",                       lobbyController.GetAllLobbies)
 	app.Get(   API_PREFIX + "/lobbies/results",               lobbyController.Results)
+	app.Get(   API_PREFIX + "/lobbies/upcoming",              lobbyController.UpcomingLobbies)
 	app.Get(   API_PREFIX + "/lobbies/{id:[0-9]+}",           lobbyController.GetLobbyById)
-	app.Post(  API_PREFIX + "/lobbies/{id:[0-9]+}/join",      lobbyController.JoinLobbyTeam)
+	app.Post(  API_PREFIX + "/lobbies/{id:[0-9]+}/join",      app.Limiter.Limit(config.JoinLobbyRoute, ratelimit.UserAccount, lobbyController.JoinLobbyTeam))
+	app.Post(  API_PREFIX + "/lobbies/join/{code}",           app.Limiter.Limit(config.JoinLobbyRoute, ratelimit.UserAccount, lobbyController.JoinWithInvite))
+	app.Delete(API_PREFIX + "/lobbies/{id:[0-9]+}/spectators", lobbyController.StopWatching)
+	app.Get(   API_PREFIX + "/lobbies/{id:[0-9]+}/events",    lobbyController.MatchLog)
+	app.Post(  API_PREFIX + "/lobbies/{id:[0-9]+}/events",    lobbyController.RecordEvent)
+	app.Get(   API_PREFIX + "/lobbies/{id:[0-9]+}/rsvp",      lobbyController.LobbyRSVPs)
+	app.Post(  API_PREFIX + "/lobbies/{id:[0-9]+}/rsvp",      lobbyController.RespondToLobby)
+	app.Get(   API_PREFIX + "/lobbies/{id:[0-9]+}/calendar.ics", lobbyController.LobbyCalendar)
+	app.Get(   API_PREFIX + "/calendar/{token:[0-9a-f-]+}.ics",  lobbyController.PlayerCalendar)
+	app.Post(  API_PREFIX + "/lobbies/{id:[0-9]+}/results/confirm", lobbyController.ConfirmResult)
+	app.Post(  API_PREFIX + "/lobbies/{id:[0-9]+}/results/dispute", lobbyController.DisputeResult)
+	app.Post(  API_PREFIX + "/lobbies/{id:[0-9]+}/results/settle",  lobbyController.SettleResult)
 	app.Post(  API_PREFIX + "/lobbies/my/leave",              lobbyController.LeaveLobby)
+	app.Post(  API_PREFIX + "/lobbies/my/ready",              lobbyController.SetReady)
+
+	app.Get(   API_PREFIX + "/tournaments",                   tournamentController.GetAllTournaments)
+	app.Post(  API_PREFIX + "/tournaments",                   tournamentController.CreateTournament)
+	app.Get(   API_PREFIX + "/tournaments/{id:[0-9]+}",       tournamentController.GetTournament)
+	app.Post(  API_PREFIX + "/tournaments/{id:[0-9]+}/teams", tournamentController.RegisterTeam)
+	app.Post(  API_PREFIX + "/tournaments/{id:[0-9]+}/start", tournamentController.StartTournament)
+	app.Post(  API_PREFIX + "/tournaments/{id:[0-9]+}/spawn", tournamentController.SpawnLobbies)
+	app.Get(   API_PREFIX + "/tournaments/{id:[0-9]+}/standings", tournamentController.Standings)
+
+	app.Get(   API_PREFIX + "/notifications",                 notificationController.GetInbox)
+	app.Post(  API_PREFIX + "/notifications/read",            notificationController.MarkRead)
+	app.Get(   API_PREFIX + "/notifications/preferences",     notificationController.GetPreferences)
+	app.Patch( API_PREFIX + "/notifications/preferences",     notificationController.UpdatePreferences)
+	app.Get(   API_PREFIX + "/notifications/push/key",        notificationController.PushKey)
+	app.Post(  API_PREFIX + "/notifications/push/subscriptions",   notificationController.SubscribeToPush)
+	app.Delete(API_PREFIX + "/notifications/push/subscriptions",   notificationController.UnsubscribeFromPush)
 
 	app.Get(   API_PREFIX + "/images/{id:[0-9]+}",			   imageController.GetImageById)
 	app.Post(  API_PREFIX + "/images/my",			  		   imageController.UploadImage)
 	app.Get(   API_PREFIX + "/images/my",			  		   imageController.GetOwnerImage)
 
-	app.Post(  API_PREFIX + "/remember_password",			   accountController.ResetPasswordRequest)
+	app.Post(  API_PREFIX + "/remember_password",			   app.Limiter.Limit(config.RememberPasswordRoute, ratelimit.EmailAccount, accountController.ResetPasswordRequest))
 	app.Post(  API_PREFIX + "/reset_password",			       accountController.ResetPassword)
 
-	var measure bool
-	if env ,ok := os.LookupEnv("MEASURE_REQUEST_TIME"); ok && env == "true" {
-		measure = true
-	} else {
-		measure = false
-	}
+	app.Get(   API_PREFIX + "/admin/jobs",                    adminController.GetJobs)
+
+	app.Get(   API_PREFIX + "/openapi.json",                  app.OpenAPIHandler)
+	// not wrapped with request logging, metrics are scraped every few seconds
+	app.Router.Handle(API_PREFIX + "/metrics", metrics.Handler()).Methods("GET")
+	// probes aren't logged either
+	healthChecker := app.HealthChecker()
+	app.Router.HandleFunc(API_PREFIX + "/healthz", healthChecker.Liveness).Methods("GET")
+	app.Router.HandleFunc(API_PREFIX + "/readyz", healthChecker.Readiness).Methods("GET")
+	app.Router.HandleFunc(API_PREFIX + "/status", healthChecker.StatusPage).Methods("GET")
+
+	// routing is set up before Initialize in tests, without app's config
+	measure := app.AppConfig != nil && app.AppConfig.MeasureRequestTime
 	requestTimer := NewRequestTimer(app.Logger, measure)
-	app.Router.Use(mux.CORSMethodMiddleware(app.Router), Oauth2Authentication, requestTimer.RequestTimeMiddleware) //attach JWT auth middleware
+	app.Router.Use(mux.CORSMethodMiddleware(app.Router), tracing.Middleware, metrics.RequestMetricsMiddleware, LanguageMiddleware, app.DatabaseAvailabilityMiddleware, Oauth2Authentication, app.OwnerActivityMiddleware, requestTimer.RequestTimeMiddleware) //attach JWT auth middleware
 }
 
 func (app *App) SetLogger(logger *logrus.Logger) {
@@ -175,15 +326,18 @@ func (app *App) SetOauth2Config(cfg *oauth2.Config) {
 	app.AuthCfg = cfg
 }
 
-func (app *App) Run(enableSSL bool) error {
+// time given to requests being served to finish when the app is shutting down
+var ShutdownTimeout = 15 * time.Second
 
-	defer app.GetDatabaseInstance().Close()
+// Run serves the app until it fails or SIGTERM (or interrupt) is received,
+// then it shuts down gracefully giving requests being served ShutdownTimeout to finish
+func (app *App) Run(enableSSL bool) error {
 
 	c := cors.New(cors.Options{
 		AllowedMethods: []string{"GET","POST","DELETE","PATCH"},
 		AllowedHeaders: []string{"Authorization", "Content-Type"},
 	})
-	var err error
+	serveErr := make(chan error, 1)
 
 	server := &http.Server{
 		ReadTimeout:  5 * time.Second,
@@ -202,11 +356,7 @@ func (app *App) Run(enableSSL bool) error {
 		}
 		*/
 
-		if val, ok := os.LookupEnv("SSL_PORT"); ok {
-			server.Addr = ":" + val
-		} else {
-			server.Addr = ":443"
-		}
+		server.Addr = ":" + app.AppConfig.SSLPort
 
 
 		// saw this here: https://stackoverflow.com/questions/37321760/how-to-set-up-lets-encrypt-for-a-go-server-application
@@ -214,10 +364,47 @@ func (app *App) Run(enableSSL bool) error {
 		//go http.ListenAndServe(":http", m.HTTPHandler(nil))
 
 		app.Logger.Info("Application is listening on port " + server.Addr)
-		err = server.ListenAndServeTLS("/ssl_certs/cert.pem", "/ssl_certs/privkey.pem")
+		go func() { serveErr <- server.ListenAndServeTLS("/ssl_certs/cert.pem", "/ssl_certs/privkey.pem") }()
 	}  else {
 		app.Logger.Info("Application is listening on port " + server.Addr)
-		err = server.ListenAndServe()
+		go func() { serveErr <- server.ListenAndServe() }()
+	}
+	app.server = server
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serveErr:
+		_ = app.Shutdown(context.Background())
+		return err
+	case sig := <-signals:
+		app.Logger.Info("Received ", sig, ", shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		return app.Shutdown(ctx)
+	}
+}
+
+// Shutdown stops accepting new connections and waits until requests being served are finished or ctx is done,
+// then it stops reconnecting to the database and closes it
+func (app *App) Shutdown(ctx context.Context) error {
+	var err error
+	if app.server != nil {
+		err = app.server.Shutdown(ctx)
+	}
+	if app.dbTicker != nil {
+		app.dbTicker.Stop()
+	}
+	if app.stopJobs != nil {
+		close(app.stopJobs)
+		app.stopJobs = nil
+	}
+	if app.GetDatabaseInstance().DB() != nil {
+		if closeErr := app.GetDatabaseInstance().Close(); err == nil {
+			err = closeErr
+		}
 	}
 	return err
 }
@@ -4,6 +4,8 @@ import (
 	"FlankiRest/config"
 	"FlankiRest/errors"
 	"FlankiRest/logger"
+	"FlankiRest/metrics"
+	"FlankiRest/tracing"
 	u "FlankiRest/utils"
 	"bytes"
 	"context"
@@ -12,6 +14,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type AuthTokenResponse struct {
@@ -22,17 +25,20 @@ type AuthTokenResponse struct {
 
 
 
+//List of endpoints that doesn't require auth, each pattern is matched against the whole path
+var notAuth = []string{"/user/create", "/user/login", "/user/restore", "/players/[0-9]+", "/players/[0-9]+/summary", "/players/ranking", "/images/[0-9]+", `/lobbies/[0-9]+/calendar\.ics`, `/calendar/[0-9a-f-]+\.ics`, "/remember_password", "/reset_password", "/openapi.json", "/metrics", "/healthz", "/readyz", "/status" }
+
 func Oauth2Authentication(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		notAuth := []string{"/user/create", "/user/login", "players/[0-9]+", "/players/ranking", "/players/ranking/[0-9]+", "/images/[0-9]+", "/remember_password", "/reset_password" } //List of endpoints that doesn't require auth
 		requestPath := r.URL.Path //current request path
 
 		//check if request does not need authentication, serve the request if it doesn't need it
 		for _, pattern := range notAuth {
 
-			if match, _ := regexp.MatchString(config.API_PREFIX + pattern, requestPath); match {
+			// patterns have to match the whole path, otherwise routes nested under public ones would be public too
+			if match, _ := regexp.MatchString("^" + config.API_PREFIX + pattern + "$", requestPath); match {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -42,11 +48,12 @@ func Oauth2Authentication(next http.Handler) http.Handler {
 		logEntry := logger.GetGlobalLogger().WithField("prefix", "[OAUTH MIDDLEWARE]")
 
 		url := authcfg.Domain + ":" + authcfg.Port + "/authorize"
-		request, err := http.NewRequest("POST", url, bytes.NewBuffer(nil))
-		request.Header = r.Header
-		client := &http.Client{}
-		resp, err := client.Do(request)
+		request, err := http.NewRequestWithContext(r.Context(), "POST", url, bytes.NewBuffer(nil))
+		request.Header = r.Header.Clone()
+		start := time.Now()
+		resp, err := tracing.HTTPClient.Do(request)
 		if err != nil {
+			metrics.AuthRequestDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
 			logEntry.Error("Couldn't connect with authorization server")
 			u.ApiErrorResponse(w, errors.New("Couldn't reach authorization server", 500))
 			return
@@ -54,11 +61,16 @@ func Oauth2Authentication(next http.Handler) http.Handler {
 		defer resp.Body.Close()
 
 		body, _ := ioutil.ReadAll(resp.Body)
+		metrics.AuthRequestDuration.WithLabelValues(authResult(resp.StatusCode)).Observe(time.Since(start).Seconds())
 
 
 
-
-		if resp.StatusCode == 500 {
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", resp.Header.Get("Retry-After"))
+			u.ApiErrorResponse(w, errors.AuthServerUnavailable)
+			logEntry.Warn("Authorization server is unavailable: ", strings.TrimSpace(string(body)))
+			return
+		} else if resp.StatusCode == 500 {
 			u.ApiErrorResponse(w, errors.New("Authorization server internal error", 500))
 			logEntry.Error("Authorization server: ", strings.TrimSpace(string(body)))
 			return
@@ -83,5 +95,13 @@ func Oauth2Authentication(next http.Handler) http.Handler {
 
 }
 
-
-
+// label of authorization server's response in metrics
+func authResult(statusCode int) string {
+	switch {
+	case statusCode == 200:
+		return "ok"
+	case statusCode >= 500:
+		return "error"
+	}
+	return "unauthorized"
+}
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/logger"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// stubDriver accepts connections so the database looks available, the app itself uses memory repositories
+type stubDriver struct{}
+type stubConn struct{}
+
+var errStubDatabase = errors.New("stub database can't run queries")
+
+func (stubDriver) Open(string) (driver.Conn, error)  { return stubConn{}, nil }
+func (stubConn) Prepare(string) (driver.Stmt, error) { return nil, errStubDatabase }
+func (stubConn) Close() error                        { return nil }
+func (stubConn) Begin() (driver.Tx, error)           { return nil, errStubDatabase }
+
+func init() {
+	sql.Register("flanki-stub", stubDriver{})
+}
+
+// stubAuthServer authorizes tokens "token-<id>" as player <id> until the test ends
+func stubAuthServer(t *testing.T) {
+	t.Helper()
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id uint
+		if _, err := fmt.Sscanf(r.Header.Get("Authorization"), "Bearer token-%d", &id); err != nil {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"user_id": %d}`, id)
+	}))
+	t.Cleanup(authServer.Close)
+
+	authURL, _ := url.Parse(authServer.URL)
+	authcfg := config.GetAuthServerConfig()
+	domain, port := authcfg.Domain, authcfg.Port
+	authcfg.Domain, authcfg.Port = authURL.Scheme+"://"+authURL.Hostname(), authURL.Port()
+	t.Cleanup(func() { authcfg.Domain, authcfg.Port = domain, port })
+}
+
+// newTestApp routes requests through the whole middleware chain
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	stubAuthServer(t)
+	sqlDB, err := sql.Open("flanki-stub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := NewApp(&oauth2.Config{})
+	app.Repos = repositories.NewMemoryRepositories()
+	app.ApiDB.Use(db)
+	app.SetLogger(logger.GetGlobalLogger())
+	app.SetRouting(config.API_PREFIX)
+	return app
+}
+
+func serve(app *App, method string, path string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, config.API_PREFIX+path, strings.NewReader(""))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	app.Router.ServeHTTP(w, r)
+	return w
+}
+
+func TestFriendRequestsRequireAuthorization(t *testing.T) {
+	app := newTestApp(t)
+	var players []*models.Account
+	for _, nickname := range []string{"alice", "bobby"} {
+		account := &models.Account{Nickname: nickname, Email: nickname + "@flanki.pl", Password: "secret123", Sex: "male"}
+		if err := account.Create(app.Repos.Accounts); err != nil {
+			t.Fatalf("creating account %s: %v", nickname, err)
+		}
+		players = append(players, account)
+	}
+	alice, bob := players[0], players[1]
+	aliceToken, bobToken := fmt.Sprintf("token-%d", alice.ID), fmt.Sprintf("token-%d", bob.ID)
+
+	for _, path := range []string{"/friend", "/friend/accept", "/friend/decline", "/follow"} {
+		if w := serve(app, "POST", fmt.Sprintf("/players/%d%s", bob.ID, path), ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("POST %s without token should be unauthorized, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(app, "POST", fmt.Sprintf("/players/%d/friend", bob.ID), aliceToken); w.Code != http.StatusOK {
+		t.Fatalf("sending friend request: %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(app, "POST", fmt.Sprintf("/players/%d/friend/accept", alice.ID), bobToken); w.Code != http.StatusOK {
+		t.Fatalf("accepting friend request: %d %s", w.Code, w.Body.String())
+	}
+	if friendship, err := app.Repos.Friends.GetFriendship(alice.ID, bob.ID); err != nil || friendship.Status != models.FriendAccepted {
+		t.Errorf("expected accepted friendship, got %+v, %v", friendship, err)
+	}
+
+	for _, path := range []string{"", "/summary"} {
+		if w := serve(app, "GET", fmt.Sprintf("/players/%d%s", bob.ID, path), ""); w.Code != http.StatusOK {
+			t.Errorf("GET /players/{id}%s should be public, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+var pathVariable = regexp.MustCompile(`{(\w+)(:[^}]*)?}`)
+
+// samplePath fills variables of documented path with values their patterns accept
+func samplePath(path string) string {
+	return pathVariable.ReplaceAllStringFunc(path, func(variable string) string {
+		switch pathVariable.FindStringSubmatch(variable)[1] {
+		case "id":
+			return "5"
+		case "token":
+			return "0f8fad5b-d9cb-469f-a165-70867728950e"
+		}
+		return "sample"
+	})
+}
+
+func TestOnlyPublicEndpointsSkipAuthorization(t *testing.T) {
+	stubAuthServer(t)
+	handler := Oauth2Authentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for route, endpoint := range apiEndpoints {
+		method, path := route[:strings.Index(route, " ")], samplePath(route[strings.Index(route, " ")+1:])
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, config.API_PREFIX+path, nil))
+		if public := w.Code == http.StatusOK; public != endpoint.Public {
+			t.Errorf("%s %s: documented as public %v, but responded with %d without token", method, path, endpoint.Public, w.Code)
+		}
+	}
+}
+
+func TestPlayersRoutesWithoutTokenAreUnauthorized(t *testing.T) {
+	app := newTestApp(t)
+	owner := &models.Account{Nickname: "owner", Email: "owner@flanki.pl", Password: "secret123", Sex: "male"}
+	if err := owner.Create(app.Repos.Accounts); err != nil {
+		t.Fatal(err)
+	}
+	public := map[string]bool{}
+	for route, endpoint := range apiEndpoints {
+		public[route] = endpoint.Public
+	}
+
+	checked := 0
+	err := app.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(template, config.API_PREFIX+"/players") {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			documented := strings.TrimPrefix(template, config.API_PREFIX)
+			path := strings.Replace(samplePath(documented), "5", fmt.Sprint(owner.ID), 1)
+			w := serve(app, method, path, "")
+			if unauthorized := w.Code == http.StatusUnauthorized; unauthorized == public[method+" "+documented] {
+				t.Errorf("%s %s: public %v, but responded with %d without token", method, path, public[method+" "+documented], w.Code)
+			}
+			checked++
+		}
+		return nil
+	})
+	if err != nil || checked == 0 {
+		t.Fatalf("expected routes of players to be checked, got %d, %v", checked, err)
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/errors"
+	"FlankiRest/health"
+	"FlankiRest/services"
+	"FlankiRest/tracing"
+	u "FlankiRest/utils"
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// interval of reconnecting to database, clients are told to retry after it when database is down
+const databaseReconnectInterval = 30 * time.Second
+
+// endpoints served without touching the database
+var noDatabase = []string{"/user/login", "/images/", "/openapi.json", "/metrics", "/healthz", "/readyz", "/status"}
+
+// HealthChecker checks everything the app needs to serve all of its endpoints
+func (app *App) HealthChecker() *health.Checker {
+	checker := health.NewChecker("flanki-app")
+	checker.Add("database", func(ctx context.Context) error {
+		return app.GetDatabaseInstance().Ping(ctx)
+	})
+	checker.Add("auth_server", health.Reachable(tracing.HTTPClient, func() string {
+		cfg := config.GetAuthServerConfig()
+		return cfg.Domain + ":" + cfg.Port + "/healthz"
+	}))
+	checker.Add("image_server", health.Reachable(tracing.HTTPClient, func() string {
+		cfg := config.GetImageServerConfig()
+		return cfg.Domain + ":" + cfg.Port + "/healthz"
+	}))
+	checker.Add("templates", health.Files(services.TemplateFiles))
+	return checker
+}
+
+// DatabaseAvailabilityMiddleware responds with 503 to requests which need the database while it is down,
+// instead of letting them hit closed connection
+func (app *App) DatabaseAvailabilityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !needsDatabase(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !app.GetDatabaseInstance().Available() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(databaseReconnectInterval.Seconds())))
+			u.ApiErrorResponse(w, errors.DatabaseUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func needsDatabase(path string) bool {
+	for _, pattern := range noDatabase {
+		if match, _ := regexp.MatchString("^"+config.API_PREFIX+pattern, path); match {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/database"
+	"FlankiRest/errors"
+	"FlankiRest/jobs"
+	"FlankiRest/models"
+	"FlankiRest/services"
+	"FlankiRest/tracing"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// how often lobbies nobody has touched for lobbies.expire_after are looked for
+	staleLobbiesInterval = 10 * time.Minute
+	// how often expired password reset codes are deleted
+	passwordResetsInterval = time.Hour
+	// how often playing status of accounts is checked against teams of open lobbies
+	reconcilePlayingInterval = 10 * time.Minute
+	// how often accounts deleted for longer than accounts.erasure_grace_period are looked for
+	eraseAccountsInterval = time.Hour
+)
+
+// newScheduler creates scheduler of the app's background jobs, leases kept in the database let
+// only one instance of the app run each of them
+func (app *App) newScheduler() *jobs.Scheduler {
+	scheduler := jobs.NewScheduler(jobs.NewPostgresStore(app.ApiDB), jobs.Instance())
+	scheduler.Add(jobs.Job{Name: "confirm_expired_results", Interval: resultConfirmationInterval, Run: app.confirmExpiredResults})
+	scheduler.Add(jobs.Job{Name: "remind_scheduled_lobbies", Interval: lobbyReminderInterval, Run: app.remindScheduledLobbies})
+	scheduler.Add(jobs.Job{Name: "hand_over_idle_lobbies", Interval: idleOwnersInterval, Run: app.handOverIdleLobbies})
+	scheduler.Add(jobs.Job{Name: "expire_stale_lobbies", Interval: staleLobbiesInterval, Run: app.expireStaleLobbies})
+	scheduler.Add(jobs.Job{Name: "purge_password_resets", Interval: passwordResetsInterval, Run: app.purgePasswordResets})
+	scheduler.Add(jobs.Job{Name: "reconcile_playing", Interval: reconcilePlayingInterval, Run: app.reconcilePlaying})
+	scheduler.Add(jobs.Job{Name: "erase_deleted_accounts", Interval: eraseAccountsInterval, Run: app.eraseDeletedAccounts})
+	return scheduler
+}
+
+// expireStaleLobbies cancels open lobbies nobody has touched for lobbies.expire_after
+func (app *App) expireStaleLobbies(ctx context.Context, now time.Time) (string, error) {
+	expireAfter := config.GetLobbiesConfig().ExpireAfter
+	if expireAfter == 0 {
+		return "", nil
+	}
+	repos := app.Repos.WithContext(ctx)
+	expired, err := models.ExpireStaleLobbies(repos.Lobbies, repos.Accounts, expireAfter, now)
+	if len(expired) > 0 {
+		return fmt.Sprintf("Expired %d stale lobbies", len(expired)), err
+	}
+	return "", err
+}
+
+// purgePasswordResets deletes password reset codes which have expired
+func (app *App) purgePasswordResets(ctx context.Context, now time.Time) (string, error) {
+	db := tracing.WithContext(app.GetDatabaseInstance().DB(), ctx)
+	if db == nil {
+		return "", database.ErrNotConnected
+	}
+	purged, err := services.PurgeExpiredPasswordResets(db, now)
+	if purged > 0 {
+		return fmt.Sprintf("Deleted %d expired password reset codes", purged), err
+	}
+	return "", err
+}
+
+// reconcilePlaying fixes playing status of accounts which doesn't match teams of open lobbies
+func (app *App) reconcilePlaying(ctx context.Context, now time.Time) (string, error) {
+	stopped, started, err := models.ReconcilePlaying(app.Repos.WithContext(ctx).Accounts)
+	if stopped > 0 || started > 0 {
+		return fmt.Sprintf("Fixed playing status of %d accounts which weren't in any open lobby and %d which were", stopped, started), err
+	}
+	return "", err
+}
+
+// eraseDeletedAccounts erases accounts which haven't been restored within accounts.erasure_grace_period,
+// the avatar and authorization data go first so that an account is never erased while any of them is left.
+// Account which can't be erased doesn't hold up the others, failures are logged and reported together
+func (app *App) eraseDeletedAccounts(ctx context.Context, now time.Time) (string, error) {
+	db := tracing.WithContext(app.GetDatabaseInstance().DB(), ctx)
+	if db == nil {
+		return "", database.ErrNotConnected
+	}
+	repos := app.Repos.WithContext(ctx)
+	accounts, err := models.GetAccountsToErase(repos.Accounts, config.GetAccountsConfig().ErasureGracePeriod, now)
+	if err != nil {
+		return "", err
+	}
+	erased := 0
+	var failed []string
+	for _, account := range accounts {
+		err := services.GetImageService().DeleteUserImageById(ctx, int(account.ID))
+		if apiErr, ok := err.(*errors.ApiError); ok && apiErr.HttpCode == http.StatusNotFound {
+			err = nil
+		}
+		if err == nil {
+			err = services.EraseAuthData(db, account)
+		}
+		if err == nil {
+			_, err = account.Erase(repos.Accounts, repos.Lobbies, repos.Notifications, now)
+		}
+		if err != nil {
+			app.Logger.WithField("prefix", "[JOBS]").Error("Couldn't erase account ", account.ID, ": ", err.Error())
+			failed = append(failed, fmt.Sprintf("account %d: %s", account.ID, err.Error()))
+			continue
+		}
+		erased++
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("%d of %d accounts couldn't be erased: %s", len(failed), len(accounts), strings.Join(failed, "; "))
+	}
+	if erased > 0 {
+		return fmt.Sprintf("Erased %d deleted accounts", erased), err
+	}
+	return "", err
+}
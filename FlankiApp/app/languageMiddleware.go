@@ -0,0 +1,16 @@
+package app
+
+import (
+	u "FlankiRest/utils"
+	"FlankiRest/validation"
+	"net/http"
+)
+
+// LanguageMiddleware picks language of error messages from Accept-Language header
+func LanguageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		language := validation.PreferredLanguage(r.Header.Get("Accept-Language"))
+		w.Header().Set("Content-Language", language)
+		next.ServeHTTP(&u.LocalizedResponseWriter{ResponseWriter: w, Language: language}, r)
+	})
+}
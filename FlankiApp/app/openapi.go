@@ -0,0 +1,253 @@
+package app
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/controllers"
+	"FlankiRest/errors"
+	"FlankiRest/health"
+	"FlankiRest/jobs"
+	"FlankiRest/models"
+	"FlankiRest/openapi"
+	"FlankiRest/services"
+	u "FlankiRest/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Bodies of requests and responses which don't have their own model structs, used only for documentation
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+// body of every error response, errors are listed only when request failed validation
+type ErrorResponse struct {
+	Message string              `json:"message"`
+	Errors  []errors.FieldError `json:"errors,omitempty"`
+}
+
+type Probe struct {
+	Status  string   `json:"status"`
+	Failing []string `json:"failing,omitempty"`
+}
+
+type LoginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type AccountResponse struct {
+	Summary *models.QuickSummary `json:"summary"`
+	Account models.Account       `json:"account"`
+}
+
+type PlayerResponse struct {
+	Summary      *models.QuickSummary        `json:"summary"`
+	Player       models.Player               `json:"player"`
+	Scorekeeping *models.ScorekeepingSummary `json:"scorekeeping"`
+	Achievements []models.Achievement        `json:"achievements"`
+}
+
+type StandingsResponse struct {
+	State     models.TournamentState   `json:"state"`
+	WinnerID  *uint                    `json:"winner_id"`
+	Matches   []models.TournamentMatch `json:"matches"`
+	Standings []models.Standing        `json:"standings"`
+}
+
+type CalendarResponse struct {
+	Path string `json:"path"`
+}
+
+type MarkedResponse struct {
+	Marked int `json:"marked"`
+}
+
+type PushKeyResponse struct {
+	PublicKey string `json:"public_key"`
+}
+
+type UnsubscribeRequest struct {
+	Endpoint string `json:"endpoint"`
+}
+
+type SubmitResultsRequest struct {
+	Winner models.TeamColor `json:"winner"`
+}
+
+type KickPlayerRequest struct {
+	PlayerID uint `json:"player_id"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+// chat is a separate service, its types are mirrored here not to make the app depend on it
+
+type ChatUser struct {
+	Token string `json:"token"`
+}
+
+type ChatMessage struct {
+	Nickname string      `json:"nickname"`
+	Action   string      `json:"action,omitempty"`
+	Text     string      `json:"text"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data"`
+}
+
+// documentation of routes registered in SetRouting, keyed by method and path template without API_PREFIX
+var apiEndpoints = map[string]openapi.Endpoint{
+	"POST /user/create":       {Tag: "account", Summary: "Creates new account", Public: true, Request: models.Account{}, Response: Message{}},
+	"POST /user/login":        {Tag: "account", Summary: "Logs in with email and password", Description: "Rate limited by address and email, responds with 429 and Retry-After when throttled or when the account is locked after repeated failed logins", Public: true, Request: controllers.Credentials{}, Response: LoginResponse{}},
+	"PATCH /user/me":          {Tag: "account", Summary: "Updates only given fields of user's account", Request: models.UpdateAccount{}, Response: Message{}},
+	"POST /user/restore":      {Tag: "account", Summary: "Restores deleted account", Description: "Deleted account can't log in, so it is identified by email and password. It can be restored until accounts.erasure_grace_period passes, then it responds with 410. Rate limited just like logging in", Public: true, Request: controllers.Credentials{}, Response: Message{}},
+	"DELETE /user/me":         {Tag: "account", Summary: "Deletes user's account", Description: "Chat messages the user has sent are deleted right away and the user is logged out everywhere. The account can be restored until accounts.erasure_grace_period passes, then it is erased: nickname is anonymized, avatar is deleted and only aggregate statistics are kept under a new id, detached from matches", Response: Message{}},
+	"GET /user/me":            {Tag: "account", Summary: "Returns user's account with quick summary of his matches", Response: AccountResponse{}},
+	"GET /user/me/calendar":   {Tag: "account", Summary: "Returns path of the user's calendar feed", Description: "The feed lists lobbies the user is coming or might come to, its path works without authorization so that calendar apps can subscribe to it", Response: CalendarResponse{}},
+	"POST /user/me/calendar":  {Tag: "account", Summary: "Generates new path of the user's calendar feed", Description: "The old path stops working", Response: CalendarResponse{}},
+	"GET /user/me/export":     {Tag: "account", Summary: "Exports everything the app keeps about the user", Description: "ZIP archive with profile, statistics of all matches, matches, match events, achievements, friendships, follows, RSVPs, notifications and push subscriptions as JSON files together with chat messages the user has sent and the avatar. Chat messages are fetched from the chat with the user's token", Response: []byte{}, ResponseContentType: "application/zip"},
+	"POST /remember_password": {Tag: "account", Summary: "Sends email with password reset link", Description: "Rate limited by address and email, responds with 429 and Retry-After when throttled", Public: true, Request: EmailRequest{}, Response: Message{}},
+	"POST /reset_password":    {Tag: "account", Summary: "Sets new password using code from password reset email", Public: true, Request: services.ResetRequest{}, Response: Message{}},
+
+	"GET /players":                     {Tag: "players", Summary: "Lists all players", Response: []models.Player{}},
+	"GET /players/{id:[0-9]+}":         {Tag: "players", Summary: "Returns player with quick summary of his matches", Description: "Scorekeeping sums up events recorded in his finished matches, achievements list badges awarded to the player", Public: true, Response: PlayerResponse{}},
+	"GET /players/{id:[0-9]+}/summary": {Tag: "players", Summary: "Returns player's summary", Public: true, Response: models.PlayerSummary{}},
+	"GET /players/ranking":             {Tag: "players", Summary: "Returns players' summaries ordered by points", Public: true, Response: []models.PlayerSummary{}},
+	"GET /players/me/friends":          {Tag: "players", Summary: "Lists user's friends", Description: "Every friend comes with lobby_id of the lobby they are playing in or own right now", Response: []models.Friend{}},
+	"GET /players/me/friends/requests": {Tag: "players", Summary: "Lists players who asked the user for friendship and those the user asked", Response: models.FriendRequests{}},
+	"GET /players/me/follows":          {Tag: "players", Summary: "Lists players the user follows and those following the user", Response: models.Follows{}},
+	"POST /players/{id:[0-9]+}/friend": {Tag: "players", Summary: "Asks the player for friendship", Description: "When the player has already asked the user both become friends right away", Response: models.Friendship{}},
+	"DELETE /players/{id:[0-9]+}/friend":         {Tag: "players", Summary: "Ends friendship with the player or withdraws request sent to them", Response: Message{}},
+	"POST /players/{id:[0-9]+}/friend/accept":    {Tag: "players", Summary: "Accepts friend request of the player", Response: models.Friendship{}},
+	"POST /players/{id:[0-9]+}/friend/decline":   {Tag: "players", Summary: "Declines friend request of the player", Description: "The player can send another one later", Response: Message{}},
+	"POST /players/{id:[0-9]+}/follow":   {Tag: "players", Summary: "Follows the player", Description: "Followed player doesn't have to agree, following them again changes nothing", Response: Message{}},
+	"DELETE /players/{id:[0-9]+}/follow": {Tag: "players", Summary: "Stops following the player", Response: Message{}},
+
+	"GET /lobbies/owner":              {Tag: "lobbies", Summary: "Returns opened lobby owned by the user", Description: "Co-owners and the referee get the lobby they manage. Players managing several lobbies choose one with lobby_id query parameter on every /lobbies/owner route, otherwise they get 409", Response: models.Lobby{}},
+	"DELETE /lobbies/owner":           {Tag: "lobbies", Summary: "Deletes owner's lobby", Response: Message{}},
+	"PATCH /lobbies/owner":            {Tag: "lobbies", Summary: "Updates owner's lobby", Description: "Co-owners and the referee can update the lobby too, but only the owner can change referee_id and confirm_results", Request: models.UpdateLobby{}, Response: Message{}},
+	"POST /lobbies/owner/create":      {Tag: "lobbies", Summary: "Creates new lobby owned by the user", Description: "Lobby announced ahead has starts_at in the future and timezone with IANA name, UTC by default. Lobby with friends visibility can be seen and joined only by friends of its owner", Request: models.Lobby{}, Response: models.Lobby{}},
+	"POST /lobbies/owner/submit":      {Tag: "lobbies", Summary: "Submits the winner and closes owner's lobby", Description: "Only started matches can be finished. Results of lobbies with confirm_results stay pending until a member of the other team confirms them or the confirmation timeout passes, statistics don't count until then. Co-owners and the referee can submit results too", Request: SubmitResultsRequest{}, Response: Message{}},
+	"POST /lobbies/owner/close":       {Tag: "lobbies", Summary: "Closes owner's lobby without submitting results", Description: "The lobby becomes cancelled", Response: Message{}},
+	"POST /lobbies/owner/ready_check": {Tag: "lobbies", Summary: "Asks players of owner's lobby to confirm they are ready", Description: "Ready flags set before are cleared, players can still join and leave", Response: Message{}},
+	"POST /lobbies/owner/start":       {Tag: "lobbies", Summary: "Starts the match when all players are ready", Description: "Teams are locked until the match is finished or cancelled", Response: Message{}},
+	"POST /lobbies/owner/transfer":    {Tag: "lobbies", Summary: "Hands owner's lobby over to one of its players or co-owners", Description: "The new owner can't own another opened lobby and is notified about the lobby. Lobby whose owner hasn't sent any request for lobbies.owner_idle_timeout is handed over to the player present the longest", Request: KickPlayerRequest{}, Response: models.Lobby{}},
+	"POST /lobbies/owner/co_owners":   {Tag: "lobbies", Summary: "Makes the player a co-owner of owner's lobby", Description: "Co-owners, just like the referee, can kick players, update the lobby, run its match and submit its results. Lobby can have 5 co-owners at most", Request: KickPlayerRequest{}, Response: []models.LobbyCoOwner{}},
+	"DELETE /lobbies/owner/co_owners/{id:[0-9]+}": {Tag: "lobbies", Summary: "Removes the co-owner from owner's lobby", Response: Message{}},
+	"POST /lobbies/owner/kick_player": {Tag: "lobbies", Summary: "Removes player from owner's lobby", Description: "Co-owners and the referee can kick players other than the owner", Request: KickPlayerRequest{}, Response: Message{}},
+	"POST /lobbies/owner/kick_spectator": {Tag: "lobbies", Summary: "Removes spectator from owner's lobby", Request: KickPlayerRequest{}, Response: Message{}},
+	"GET /lobbies/owner/invites":      {Tag: "lobbies", Summary: "Lists invites to owner's lobby", Description: "Revoked and used up invites are listed too", Response: []models.LobbyInvite{}},
+	"POST /lobbies/owner/invites":     {Tag: "lobbies", Summary: "Creates invite to owner's lobby", Description: "Invite never expires and can be used any number of times unless expires_in (minutes) or max_uses are given. Invite with player_id can be used once and only by that player, who is notified about it", Request: models.InviteRequest{}, Response: models.LobbyInvite{}},
+	"DELETE /lobbies/owner/invites/{code}": {Tag: "lobbies", Summary: "Revokes invite to owner's lobby", Description: "Players who have joined with the invite stay in the lobby", Response: models.LobbyInvite{}},
+	"POST /lobbies/owner/shuffle":     {Tag: "lobbies", Summary: "Rebalances teams of owner's lobby by players' ratings", Description: "Rating is the sum of player's points, teams differ by at most one player", Response: models.Lobby{}},
+	"GET /lobbies/my":                 {Tag: "lobbies", Summary: "Returns lobby in which the user is playing", Response: models.Lobby{}},
+	"GET /lobbies":                    {Tag: "lobbies", Summary: "Lists opened lobbies", Description: "Lobbies with friends visibility are listed only to friends of their owners", Response: []models.LobbyListing{}},
+	"GET /lobbies/results":            {Tag: "lobbies", Summary: "Lists finished matches", Description: "Cancelled lobbies aren't listed, players' stats are given for matches with recorded events", Response: []models.MatchResult{}},
+	"GET /lobbies/upcoming":           {Tag: "lobbies", Summary: "Lists lobbies scheduled ahead which haven't started yet", Description: "The soonest lobbies go first, lobbies with friends visibility are listed only to friends of their owners", Response: []models.LobbyListing{}},
+	"GET /lobbies/{id:[0-9]+}":        {Tag: "lobbies", Summary: "Returns lobby by its id", Description: "Spectators watching the lobby are listed apart from its teams. Friends only lobbies respond with 404 to players who aren't friends of the owner nor members of the lobby", Response: models.Lobby{}},
+	"POST /lobbies/{id:[0-9]+}/join":  {Tag: "lobbies", Summary: "Joins given team of the lobby", Description: "Joining with auto picks the smaller team or the weaker one by rating, a team can't exceed lobby's team_limit. Joining as a spectator watches the lobby without playing in it, the password of private lobby is needed as well, and responds with the lobby instead of a message. Lobby's spectator_limit caps its spectators. Only friends of the owner can join lobbies with friends visibility. Rate limited by address and account, responds with 429 and Retry-After when throttled", Request: models.LobbyRequest{}, Response: Message{}},
+	"GET /lobbies/{id:[0-9]+}/events":  {Tag: "lobbies", Summary: "Returns event log of the match with stats of its players", Response: models.MatchLog{}},
+	"POST /lobbies/join/{code}":       {Tag: "lobbies", Summary: "Joins the lobby of the invite", Description: "Invite replaces the password and visibility of the lobby. The lobby chooses the team when team_color isn't given. Responds with 410 when the invite has been revoked, has expired or has been used up. Rate limited like joining by id", Request: models.InviteJoinRequest{}, Response: models.Lobby{}},
+	"DELETE /lobbies/{id:[0-9]+}/spectators": {Tag: "lobbies", Summary: "Stops the user watching the lobby", Response: Message{}},
+	"POST /lobbies/{id:[0-9]+}/events": {Tag: "lobbies", Summary: "Records event of the started match", Description: "Only the owner, co-owners and the lobby's referee can record events, hit is required for throws only", Request: models.MatchEventRequest{}, Response: models.MatchEvent{}},
+	"GET /lobbies/{id:[0-9]+}/rsvp":   {Tag: "lobbies", Summary: "Lists responses of players whether they are coming to the scheduled lobby", Response: []models.RSVP{}},
+	"POST /lobbies/{id:[0-9]+}/rsvp":  {Tag: "lobbies", Summary: "Responds whether the user is coming to the scheduled lobby", Description: "Response replaces the previous one, it can be changed until the match starts. Players who are coming or might come are reminded by email before the start", Request: models.RSVPRequest{}, Response: models.RSVP{}},
+	"GET /lobbies/{id:[0-9]+}/calendar.ics": {Tag: "lobbies", Summary: "Returns iCalendar feed with the scheduled lobby", Description: "Only lobbies visible to everyone have public feeds, friends only lobbies respond with 404", Public: true, Response: "", ResponseContentType: "text/calendar"},
+	"GET /calendar/{token:[0-9a-f-]+}.ics":  {Tag: "lobbies", Summary: "Returns iCalendar feed of lobbies the player is coming or might come to", Description: "Path of the feed is given by /user/me/calendar", Public: true, Response: "", ResponseContentType: "text/calendar"},
+	"POST /lobbies/{id:[0-9]+}/results/confirm": {Tag: "lobbies", Summary: "Confirms pending results on behalf of the other team", Description: "Only members of the team the submitter didn't play in can confirm. Confirmed results of tournament's lobbies advance its bracket", Response: Message{}},
+	"POST /lobbies/{id:[0-9]+}/results/dispute": {Tag: "lobbies", Summary: "Disputes results of the match", Description: "Players of the match can dispute pending results or confirmed ones until the confirmation timeout passes, statistics of the match are frozen until a moderator settles the dispute", Request: models.DisputeRequest{}, Response: Message{}},
+	"POST /lobbies/{id:[0-9]+}/results/settle":  {Tag: "lobbies", Summary: "Settles disputed results with the winner chosen by a moderator", Description: "Only accounts listed in results.moderators can settle disputes. Winner of a tournament match can be changed only until its bracket advances", Request: models.SettleRequest{}, Response: Message{}},
+	"POST /lobbies/my/leave":          {Tag: "lobbies", Summary: "Leaves lobby in which the user is playing", Description: "Lobby left by its owner is handed over to the player present the longest", Response: Message{}},
+	"POST /lobbies/my/ready":          {Tag: "lobbies", Summary: "Sets whether the user is ready to play", Request: models.ReadyRequest{}, Response: Message{}},
+
+	"GET /tournaments":                         {Tag: "tournaments", Summary: "Lists the latest tournaments", Response: []models.Tournament{}},
+	"POST /tournaments":                        {Tag: "tournaments", Summary: "Creates new tournament owned by the user", Description: "Format is single_elimination, double_elimination or round_robin, teams register until the owner starts it", Request: models.Tournament{}, Response: models.Tournament{}},
+	"GET /tournaments/{id:[0-9]+}":             {Tag: "tournaments", Summary: "Returns tournament with its teams and matches", Response: models.Tournament{}},
+	"POST /tournaments/{id:[0-9]+}/teams":      {Tag: "tournaments", Summary: "Registers team of the user", Description: "The user becomes the captain and has to be one of the players, every player can play in one team of the tournament only", Request: models.TeamRegistration{}, Response: models.TournamentTeam{}},
+	"POST /tournaments/{id:[0-9]+}/start":      {Tag: "tournaments", Summary: "Closes registration and generates the bracket", Description: "Only the owner can start the tournament. Teams are seeded by ratings of their players, every pairing whose teams are known gets a lobby owned by one of the captains. Confirmed results of those lobbies advance the bracket", Response: models.Tournament{}},
+	"POST /tournaments/{id:[0-9]+}/spawn":      {Tag: "tournaments", Summary: "Creates lobbies of matches which couldn't get them before", Description: "Only the owner can do that, matches keep spawn_error when their lobby can't be created, e.g. because players are still playing elsewhere. Lobbies which have been cancelled are created again", Response: models.Tournament{}},
+	"GET /tournaments/{id:[0-9]+}/standings":   {Tag: "tournaments", Summary: "Returns bracket of the tournament with standings of its teams", Description: "Walkovers aren't counted, winner of finished tournament goes first", Response: StandingsResponse{}},
+
+	"GET /notifications":                         {Tag: "notifications", Summary: "Lists the latest notifications of the user with the number of unread ones", Description: "Query parameter unread=true leaves out notifications which have been read", Response: models.Inbox{}},
+	"POST /notifications/read":                    {Tag: "notifications", Summary: "Marks notifications of the user as read", Description: "All unread notifications are marked when no ids are given", Request: models.ReadRequest{}, Response: MarkedResponse{}},
+	"GET /notifications/preferences":              {Tag: "notifications", Summary: "Returns channels delivering notifications of every type", Response: []models.NotificationPreference{}},
+	"PATCH /notifications/preferences":            {Tag: "notifications", Summary: "Changes channels delivering notifications of given types", Description: "Channels which are left out keep their settings, the response lists preferences of every type", Request: []models.NotificationPreference{}, Response: []models.NotificationPreference{}},
+	"GET /notifications/push/key":                 {Tag: "notifications", Summary: "Returns public VAPID key browsers subscribe to Web Push notifications with", Description: "Responds with 404 when push notifications are not enabled", Response: PushKeyResponse{}},
+	"POST /notifications/push/subscriptions":      {Tag: "notifications", Summary: "Subscribes the browser to Web Push notifications of the user", Description: "Takes the subscription as PushManager serializes it, the endpoint has to be an https url of a public push service, IP addresses, ports other than 443 and local names are rejected", Request: models.PushSubscriptionRequest{}, Response: models.PushSubscription{}},
+	"DELETE /notifications/push/subscriptions":    {Tag: "notifications", Summary: "Unsubscribes the browser from Web Push notifications", Request: UnsubscribeRequest{}, Response: Message{}},
+
+	"GET /images/{id:[0-9]+}": {Tag: "images", Summary: "Returns player's avatar", Public: true, Response: []byte{}, ResponseContentType: "image/*"},
+	"POST /images/my":         {Tag: "images", Summary: "Uploads user's avatar, only jpeg and png images up to 3MB are accepted", Request: []byte{}, RequestContentType: "image/*", Response: Message{}},
+	"GET /images/my":          {Tag: "images", Summary: "Returns user's avatar", Response: []byte{}, ResponseContentType: "image/*"},
+
+	"GET /openapi.json": {Tag: "documentation", Summary: "Returns this specification", Public: true, Response: map[string]interface{}{}},
+	"GET /metrics":      {Tag: "monitoring", Summary: "Returns prometheus metrics", Description: "Chat, authorization and image servers serve their own metrics under the same path", Public: true, Response: "", ResponseContentType: "text/plain"},
+	"GET /healthz":      {Tag: "monitoring", Summary: "Responds as long as the service is alive", Description: "Chat, authorization and image servers serve their own probes under the same paths", Public: true, Response: Probe{}},
+	"GET /readyz":       {Tag: "monitoring", Summary: "Checks database, authorization and image servers and email templates", Description: "Responds with 503 and names of failing checks when the app can't serve all of its endpoints", Public: true, Response: Probe{}},
+	"GET /status":       {Tag: "monitoring", Summary: "Returns results of all readiness checks", Public: true, Response: health.Report{}},
+	"GET /admin/jobs":   {Tag: "monitoring", Summary: "Lists background jobs with their recent runs and last failure", Description: "Only accounts listed in app.admins can see them, each run names the instance of the app which has done it", Response: []jobs.Status{}},
+}
+
+var chatServer = []openapi.Server{{
+	URL:         "{chat}",
+	Description: "Chat service",
+	Variables:   map[string]openapi.ServerVariable{"chat": {Default: "http://localhost:8000", Description: "address of the chat service"}},
+}}
+
+// documentation of routes registered in chat's NewChatServer, keyed by method and path template
+var ChatEndpoints = map[string]openapi.Endpoint{
+	"GET /chat/join/{name}": {
+		Tag:     "chat",
+		Summary: "Upgrades connection to web socket and joins the chat room",
+		Description: "The first message sent by the client has to be ChatUser with 'Bearer <access token>', " +
+			"after that client sends and receives ChatMessage values with action 'message', 'users' or 'kick'. " +
+			"Room lobby-{id} is created once its first member is authorized and lets in only the owner, co-owners, the referee, players and spectators of the lobby, " +
+			"it is closed when the last of them leaves or, after 'lobby_closed' message, when the lobby is closed",
+		Public:       true,
+		Response:     ChatMessage{},
+		ResponseCode: "101",
+		Servers:      chatServer,
+	},
+	"GET /chat/rooms":          {Tag: "chat", Summary: "Lists names of opened chat rooms", Public: true, Response: []string{}, Servers: chatServer},
+	"POST /chat/create/{name}": {Tag: "chat", Summary: "Creates chat room owned by the user", Description: "Names of lobby rooms can't be used", Response: Message{}, Servers: chatServer},
+	"POST /chat/close/{name}":  {Tag: "chat", Summary: "Closes chat room owned by the user", Response: Message{}, Servers: chatServer},
+	"GET /chat/messages":       {Tag: "chat", Summary: "Lists messages the user has sent", Description: "Messages are kept for their senders until they delete their accounts, the oldest first", Response: []models.ChatMessage{}, Servers: chatServer},
+	"DELETE /chat/messages":    {Tag: "chat", Summary: "Deletes all messages the user has sent", Description: "The app calls it when the user deletes the account", Response: Message{}, Servers: chatServer},
+}
+
+// OpenAPI generates specification of all documented routes registered in app's router and of chat's routes,
+// routes lacking documentation are left out
+func (app *App) OpenAPI() *openapi.Document {
+	doc := openapi.NewDocument("Flanki", "1.0", "REST api of Flanki app together with its chat")
+	_ = app.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			endpoint, ok := apiEndpoints[method+" "+strings.TrimPrefix(path, config.API_PREFIX)]
+			if !ok {
+				app.Logger.WithField("prefix", "[OPENAPI]").Warn("Route is not documented: ", method, " ", path)
+				continue
+			}
+			doc.AddEndpoint(path, method, endpoint, ErrorResponse{})
+		}
+		return nil
+	})
+	doc.SchemaOf(ChatUser{})
+	for route, endpoint := range ChatEndpoints {
+		parts := strings.SplitN(route, " ", 2)
+		doc.AddEndpoint(parts[1], parts[0], endpoint, Message{})
+	}
+	return doc
+}
+
+func (app *App) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	u.SimpleRespond(w, app.OpenAPI())
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/models"
+	"FlankiRest/notifications"
+	u "FlankiRest/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// how often lobbies of idle owners are looked for
+const idleOwnersInterval = time.Minute
+
+// activity of an account is recorded at most once in this period, owners are handed lobbies over after much longer
+const ownerSeenInterval = time.Minute
+
+// seenAccounts remembers when activity of accounts has been recorded last so that most requests don't touch the database
+type seenAccounts struct {
+	mutex sync.Mutex
+	seen  map[uint]time.Time
+}
+
+func newSeenAccounts() *seenAccounts {
+	return &seenAccounts{seen: map[uint]time.Time{}}
+}
+
+// due tells whether activity of the account should be recorded now and remembers it, accounts seen
+// before the interval are forgotten now and then so the map doesn't keep every account ever seen
+func (accounts *seenAccounts) due(accountID uint, now time.Time) bool {
+	accounts.mutex.Lock()
+	defer accounts.mutex.Unlock()
+
+	if seenAt, found := accounts.seen[accountID]; found && now.Sub(seenAt) < ownerSeenInterval {
+		return false
+	}
+	if len(accounts.seen) >= 10000 {
+		for id, seenAt := range accounts.seen {
+			if now.Sub(seenAt) >= ownerSeenInterval {
+				delete(accounts.seen, id)
+			}
+		}
+	}
+	accounts.seen[accountID] = now
+	return true
+}
+
+// OwnerActivityMiddleware records that owners of open lobbies are still around whenever they send an authenticated request,
+// requests served without the database don't count. Each account is recorded at most once per ownerSeenInterval
+func (app *App) OwnerActivityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ownerID, err := u.GetUserIdFromContext(r.Context()); err == nil && needsDatabase(r.URL.Path) && app.ownersSeen.due(ownerID, time.Now()) {
+			if err := models.SeeOwner(app.Repos.WithContext(r.Context()).Lobbies, ownerID, time.Now()); err != nil {
+				app.Logger.WithField("prefix", "[OWNERS]").Error("Error while recording owner's activity: ", err.Error())
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handOverIdleLobbies gives lobbies of owners idle for lobbies.owner_idle_timeout to their players
+func (app *App) handOverIdleLobbies(ctx context.Context, now time.Time) (string, error) {
+	idle := config.GetLobbiesConfig().OwnerIdleTimeout
+	if idle == 0 {
+		return "", nil
+	}
+	handedOver, err := models.HandOverIdleLobbies(app.Repos.WithContext(ctx).Lobbies, idle, now)
+	for _, lobby := range handedOver {
+		notifications.Notify(app.Repos, lobby.OwnerID, models.OwnershipNotice(lobby))
+	}
+	if len(handedOver) > 0 {
+		return fmt.Sprintf("Handed over %d lobbies of idle owners", len(handedOver)), err
+	}
+	return "", err
+}
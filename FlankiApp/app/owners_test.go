@@ -0,0 +1,23 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOwnersActivityIsRecordedOncePerInterval(t *testing.T) {
+	seen := newSeenAccounts()
+	now := time.Now()
+	if !seen.due(1, now) {
+		t.Fatal("expected activity of a new account to be recorded")
+	}
+	if seen.due(1, now.Add(ownerSeenInterval/2)) {
+		t.Error("expected activity not to be recorded again within the interval")
+	}
+	if !seen.due(2, now) {
+		t.Error("expected activity of another account to be recorded")
+	}
+	if !seen.due(1, now.Add(ownerSeenInterval)) {
+		t.Error("expected activity to be recorded once the interval has passed")
+	}
+}
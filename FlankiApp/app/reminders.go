@@ -0,0 +1,27 @@
+package app
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/models"
+	"FlankiRest/notifications"
+	"context"
+	"fmt"
+	"time"
+)
+
+// how often lobbies starting soon are looked for
+const lobbyReminderInterval = time.Minute
+
+// remindScheduledLobbies notifies players of lobbies starting within schedule.reminder_before
+func (app *App) remindScheduledLobbies(ctx context.Context, now time.Time) (string, error) {
+	repos := app.Repos.WithContext(ctx)
+	reminders, err := models.TakeDueReminders(repos.Lobbies, repos.RSVPs, now, config.GetScheduleConfig().ReminderBefore)
+	if len(reminders) == 0 {
+		return "", err
+	}
+	sent := 0
+	for _, reminder := range reminders {
+		sent += notifications.NotifyAll(app.Repos, reminder.PlayerIDs, models.ReminderNotice(reminder.Lobby))
+	}
+	return fmt.Sprintf("Reminded players of %d lobbies with %d notifications", len(reminders), sent), err
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/notifications"
+	"context"
+	"fmt"
+	"time"
+)
+
+// how often results nobody has confirmed are looked for
+const resultConfirmationInterval = time.Minute
+
+// confirmExpiredResults confirms pending results after their deadline passes
+func (app *App) confirmExpiredResults(ctx context.Context, now time.Time) (string, error) {
+	repos := app.Repos.WithContext(ctx)
+	confirmed, err := models.ConfirmExpiredResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, now)
+	for _, lobby := range confirmed {
+		if playerIDs, err := lobby.GetLobbyPlayersIds(); err == nil {
+			notifications.AwardAchievements(app.Repos, playerIDs)
+		}
+	}
+	if len(confirmed) > 0 {
+		return fmt.Sprintf("Confirmed %d results after their deadline", len(confirmed)), err
+	}
+	return "", err
+}
@@ -0,0 +1,129 @@
+// Package calendar writes iCalendar feeds (RFC 5545) which calendar apps can subscribe to
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentType = "text/calendar; charset=utf-8"
+
+// events without an end last this long
+const DefaultDuration = 2 * time.Hour
+
+// lines longer than this many octets are folded
+const lineLength = 75
+
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time // DefaultDuration after the start when zero
+	Updated     time.Time
+	Latitude    float64
+	Longitude   float64
+	HasLocation bool
+	Cancelled   bool
+}
+
+// Feed is a calendar of events, Timezone is the IANA name calendar apps display it in
+type Feed struct {
+	Name     string
+	Timezone string
+	Events   []Event
+}
+
+// Write writes the feed, times are written in UTC so that the feed doesn't need timezone definitions
+func Write(w io.Writer, feed *Feed) error {
+	writer := &lineWriter{w: bufio.NewWriter(w)}
+	writer.line("BEGIN:VCALENDAR")
+	writer.line("VERSION:2.0")
+	writer.line("PRODID:-//Flanki//Lobbies//EN")
+	writer.line("CALSCALE:GREGORIAN")
+	writer.line("METHOD:PUBLISH")
+	writer.line("X-WR-CALNAME:" + escape(feed.Name))
+	if feed.Timezone != "" {
+		writer.line("X-WR-TIMEZONE:" + feed.Timezone)
+	}
+	for _, event := range feed.Events {
+		end := event.End
+		if end.IsZero() {
+			end = event.Start.Add(DefaultDuration)
+		}
+		writer.line("BEGIN:VEVENT")
+		writer.line("UID:" + event.UID)
+		writer.line("DTSTAMP:" + utc(event.Updated))
+		writer.line("DTSTART:" + utc(event.Start))
+		writer.line("DTEND:" + utc(end))
+		writer.line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			writer.line("DESCRIPTION:" + escape(event.Description))
+		}
+		if event.HasLocation {
+			writer.line("GEO:" + coordinate(event.Latitude) + ";" + coordinate(event.Longitude))
+		}
+		if event.Cancelled {
+			writer.line("STATUS:CANCELLED")
+		} else {
+			writer.line("STATUS:CONFIRMED")
+		}
+		writer.line("END:VEVENT")
+	}
+	writer.line("END:VCALENDAR")
+	if writer.err != nil {
+		return writer.err
+	}
+	return writer.w.Flush()
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func coordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(text string) string {
+	return escaper.Replace(text)
+}
+
+// lineWriter ends lines with CRLF and folds long ones without splitting UTF-8 characters, it keeps the first error
+type lineWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (writer *lineWriter) line(content string) {
+	if writer.err != nil {
+		return
+	}
+	limit := lineLength
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !startsCharacter(content[cut]) {
+			cut--
+		}
+		writer.write(content[:cut] + "\r\n ")
+		content = content[cut:]
+		limit = lineLength - 1 // continuation lines start with a space
+	}
+	writer.write(content + "\r\n")
+}
+
+func (writer *lineWriter) write(s string) {
+	if writer.err == nil {
+		_, writer.err = writer.w.WriteString(s)
+	}
+}
+
+// continuation bytes of UTF-8 characters look like 10xxxxxx
+func startsCharacter(b byte) bool {
+	return b&0xC0 != 0x80
+}
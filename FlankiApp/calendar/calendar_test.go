@@ -0,0 +1,63 @@
+package calendar_test
+
+import (
+	"FlankiRest/calendar"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFeed(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Skip("tz database is not available")
+	}
+	start := time.Date(2019, 6, 7, 18, 0, 0, 0, warsaw)
+	feed := &calendar.Feed{Name: "Flanki", Timezone: "Europe/Warsaw", Events: []calendar.Event{{
+		UID:         "lobby-5@flanki",
+		Summary:     "Friday, at the park; bring cans",
+		Start:       start,
+		Updated:     start,
+		Latitude:    50.061947,
+		Longitude:   19.936856,
+		HasLocation: true,
+	}}}
+
+	var output bytes.Buffer
+	if err := calendar.Write(&output, feed); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-TIMEZONE:Europe/Warsaw\r\n",
+		"DTSTART:20190607T160000Z\r\n",
+		"DTEND:20190607T180000Z\r\n",
+		`SUMMARY:Friday\, at the park\; bring cans` + "\r\n",
+		"GEO:50.061947;19.936856\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("expected %q in feed:\n%s", line, output.String())
+		}
+	}
+}
+
+func TestLongLinesAreFolded(t *testing.T) {
+	feed := &calendar.Feed{Name: strings.Repeat("ż", 60)}
+	var output bytes.Buffer
+	if err := calendar.Write(&output, feed); err != nil {
+		t.Fatal(err)
+	}
+
+	unfolded := strings.Replace(output.String(), "\r\n ", "", -1)
+	if !strings.Contains(unfolded, "X-WR-CALNAME:"+strings.Repeat("ż", 60)+"\r\n") {
+		t.Errorf("folding changed the content:\n%s", output.String())
+	}
+	for _, line := range strings.Split(output.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+}
@@ -1,68 +1,221 @@
 package config
 
 import (
-	"FlankiRest/logger"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/joeshaw/envdecode"
 	"github.com/joho/godotenv"
 	"gopkg.in/oauth2.v3/models"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Config of the app, values are read from sources in this order, every one overriding the previous ones:
+// defaults, YAML file given by -config flag or CONFIG_FILE variable, .env file, environment variables, command line flags
+type Config struct {
+	App    AppConfig         `yaml:"app"`
+	Auth   AuthServerConfig  `yaml:"authorization_server"`
+	Images ImageServerConfig `yaml:"image_server"`
+	Chat   ChatServerConfig  `yaml:"chat_server"`
+	Email  EmailConfig       `yaml:"email"`
+
+	RateLimits RateLimitConfig `yaml:"rate_limits"`
+	Results    ResultsConfig   `yaml:"results"`
+	Schedule   ScheduleConfig  `yaml:"schedule"`
+	Lobbies    LobbiesConfig   `yaml:"lobbies"`
+	Accounts   AccountsConfig  `yaml:"accounts"`
+	Push       PushConfig      `yaml:"push"`
+
+	// file the config was read from, empty when there was none
+	File string `yaml:"-"`
+	// set by -print-config, the config should be printed instead of starting the app
+	PrintConfig bool `yaml:"-"`
+}
+
 type AppConfig struct {
-	DBUsername string `env:"DB_USER,required"`
-	DBPassword string `env:"DB_PASS,required"`
-	DBName     string `env:"DB_NAME,required"`
-	DBHost     string `env:"DB_HOST,required"`
-	Port       string `env:"SERVER_PORT,default=8080"`
+	DBUsername string `yaml:"db_user" env:"DB_USER"`
+	DBPassword string `yaml:"db_password" env:"DB_PASS"`
+	DBName     string `yaml:"db_name" env:"DB_NAME"`
+	DBHost     string `yaml:"db_host" env:"DB_HOST"`
+	DBPort     string `yaml:"db_port" env:"DB_PORT"`
+	Port       string `yaml:"port" env:"SERVER_PORT"`
+
+	EnableSSL bool   `yaml:"enable_ssl" env:"ENABLE_SSL,strict"`
+	SSLPort   string `yaml:"ssl_port" env:"SSL_PORT"`
+
+	MeasureRequestTime bool `yaml:"measure_request_time" env:"MEASURE_REQUEST_TIME,strict"`
+	DatabaseDebug      bool `yaml:"database_debug" env:"DATABASE_DEBUG,strict"`
+	DatabaseAPILogger  bool `yaml:"database_api_logger" env:"DATABASE_API_LOGGER,strict"`
+
+	// accounts allowed to look into the app's internals like background jobs, separated with ';' in the variable
+	Admins []uint `yaml:"admins" env:"ADMINS,strict"`
 }
 
+func (cfg *AppConfig) IsAdmin(accountID uint) bool {
+	for _, id := range cfg.Admins {
+		if id == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// Client is the app registered at the authorization server
 type Client struct {
-	ID     string `env:"CLIENT_ID,required"`
-	Secret string `env:"CLIENT_SECRET,required"`
-	Domain string `env:"CLIENT_DOMAIN,required"`
-	UserID string
+	ID     string `yaml:"id" env:"CLIENT_ID"`
+	Secret string `yaml:"secret" env:"CLIENT_SECRET"`
+	Domain string `yaml:"domain" env:"CLIENT_DOMAIN"`
+	UserID string `yaml:"-"`
 }
 
 type AuthServerConfig struct {
-	Domain  string `env:"AUTHORIZATION_SERVER_DOMAIN,required"`
-	Port    string `env:"AUTHORIZATION_SERVER_PORT,required"`
-	Clients []models.Client
+	Domain string `yaml:"domain" env:"AUTHORIZATION_SERVER_DOMAIN"`
+	Port   string `yaml:"port" env:"AUTHORIZATION_SERVER_PORT"`
+	Client Client `yaml:"client"`
 }
 
 type ImageServerConfig struct {
-	Domain string `env:"IMAGE_SERVER_DOMAIN,required"`
-	Port   string `env:"IMAGE_SERVER_PORT,required"`
+	Domain string `yaml:"domain" env:"IMAGE_SERVER_DOMAIN"`
+	Port   string `yaml:"port" env:"IMAGE_SERVER_PORT"`
 }
 
-var API_PREFIX string
+// ChatServerConfig of the chat, messages players have sent are exported and deleted through it
+type ChatServerConfig struct {
+	Domain string `yaml:"domain" env:"CHAT_SERVER_DOMAIN"`
+	Port   string `yaml:"port" env:"CHAT_SERVER_PORT"`
+}
 
-var appInstance *AppConfig
-var authInstance *AuthServerConfig
-var imgInstance *ImageServerConfig
+// EmailConfig of the account password reset emails are sent from
+type EmailConfig struct {
+	Address    string `yaml:"address" env:"APP_EMAIL"`
+	Password   string `yaml:"password" env:"APP_EMAIL_PASSWORD"`
+	SMTPServer string `yaml:"smtp_server" env:"SMTP_SERVER"`
+	SMTPPort   int    `yaml:"smtp_port" env:"SMTP_PORT,strict"`
+	// front end page reset links lead to, the code is appended to it
+	ResetPasswordDomain string `yaml:"reset_password_domain" env:"RESET_PASSWORD_DOMAIN"`
+}
 
+// RateLimitConfig of routes which are throttled to stop guessing of passwords and flooding of emails
+type RateLimitConfig struct {
+	// where token buckets are kept, "memory" is enough for a single instance of the app
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE"`
+	// client's address is taken from X-Forwarded-For, enable only behind a proxy which sets it
+	TrustProxy bool `yaml:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY,strict"`
+	// limits by route name, a route given in the file replaces all of its defaults
+	Routes map[string]RouteLimits `yaml:"routes"`
+}
 
+const (
+	MemoryRateLimitStore   = "memory"
+	PostgresRateLimitStore = "postgres"
+)
 
-func init() {
-	if value, exists := os.LookupEnv("ENV_INITIALIZED"); !exists || value == "false" {
-		err := godotenv.Load() //Load .env file
-		if err != nil {
-			logger.GetGlobalLogger().Fatal("Error while loading .env file: " + err.Error())
+// names of throttled routes
+const (
+	LoginRoute            = "login"
+	RememberPasswordRoute = "remember_password"
+	JoinLobbyRoute        = "join_lobby"
+)
+
+// RouteLimits are checked separately for client's address and for the account the request is about,
+// zero limit isn't checked at all
+type RouteLimits struct {
+	IP      Limit `yaml:"ip"`
+	Account Limit `yaml:"account"`
+}
+
+// Limit lets Burst requests through at once and then Requests per Per, Burst defaults to Requests
+type Limit struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst,omitempty"`
+}
+
+func (limit Limit) Enabled() bool {
+	return limit.Requests > 0
+}
+
+func (limit Limit) Capacity() int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return limit.Requests
+}
+
+// ResultsConfig of confirming and disputing results of matches
+type ResultsConfig struct {
+	// results waiting for confirmation are confirmed after it passes, confirmed results can be disputed until then
+	ConfirmationTimeout time.Duration `yaml:"confirmation_timeout" env:"RESULTS_CONFIRMATION_TIMEOUT,strict"`
+	// accounts settling disputes, separated with ';' in the variable
+	Moderators []uint `yaml:"moderators" env:"MODERATORS,strict"`
+}
+
+func (cfg *ResultsConfig) IsModerator(accountID uint) bool {
+	for _, id := range cfg.Moderators {
+		if id == accountID {
+			return true
 		}
-		logger.GetGlobalLogger().Info("Using .env file")
-	} else {
-		logger.GetGlobalLogger().Info("Using environmental variables")
 	}
-	appInstance  = new(AppConfig)
-	authInstance = new(AuthServerConfig)
-	imgInstance  = new(ImageServerConfig)
-	logger.GetGlobalLogger().Info("Initializing configs")
-	appInstance.LoadFromEnvVariables()
-	authInstance.LoadFromEnvVariables()
-	imgInstance.LoadFromEnvVariables()
+	return false
+}
+
+// ScheduleConfig of lobbies announced ahead of their start
+type ScheduleConfig struct {
+	// players who have responded they are coming are reminded by email this long before the start
+	ReminderBefore time.Duration `yaml:"reminder_before" env:"LOBBY_REMINDER_BEFORE,strict"`
+}
+
+// LobbiesConfig of managing lobbies
+type LobbiesConfig struct {
+	// lobby whose owner hasn't sent any request for this long is handed over to the player present the longest, 0 turns it off
+	OwnerIdleTimeout time.Duration `yaml:"owner_idle_timeout" env:"LOBBY_OWNER_IDLE_TIMEOUT,strict"`
+	// open lobby nobody has touched for this long is closed and its players are freed, 0 turns it off
+	ExpireAfter time.Duration `yaml:"expire_after" env:"LOBBY_EXPIRE_AFTER,strict"`
+}
+
+// AccountsConfig of accounts deleted by their players
+type AccountsConfig struct {
+	// deleted account can be restored for this long, then it is erased and only anonymous statistics are kept
+	ErasureGracePeriod time.Duration `yaml:"erasure_grace_period" env:"ACCOUNT_ERASURE_GRACE_PERIOD,strict"`
+}
 
-	API_PREFIX = ""
+// PushConfig of Web Push notifications, they are sent only when both VAPID keys are set
+type PushConfig struct {
+	// P-256 key pair encoded with unpadded base64url, the public key is given to browsers subscribing to notifications
+	VAPIDPublicKey  string `yaml:"vapid_public_key" env:"VAPID_PUBLIC_KEY"`
+	VAPIDPrivateKey string `yaml:"vapid_private_key" env:"VAPID_PRIVATE_KEY"`
+	// contact of the app given to push services, mailto: or https: url
+	Subject string `yaml:"subject" env:"VAPID_SUBJECT"`
 }
 
+func (cfg *PushConfig) Enabled() bool {
+	return cfg.VAPIDPublicKey != "" && cfg.VAPIDPrivateKey != ""
+}
+
+var API_PREFIX string
+
+// configs of running app, they are empty until Load succeeds
+var appInstance = &AppConfig{}
+var authInstance = &AuthServerConfig{}
+var imgInstance = &ImageServerConfig{}
+var chatInstance = &ChatServerConfig{}
+var emailInstance = &EmailConfig{}
+var rateLimitInstance = &RateLimitConfig{}
+var resultsInstance = &ResultsConfig{}
+var scheduleInstance = &ScheduleConfig{}
+var lobbiesInstance = &LobbiesConfig{}
+var accountsInstance = &AccountsConfig{}
+var pushInstance = &PushConfig{}
+
 func GetAuthServerConfig() *AuthServerConfig {
 	return authInstance
 }
@@ -75,37 +228,284 @@ func GetImageServerConfig() *ImageServerConfig {
 	return imgInstance
 }
 
+func GetChatServerConfig() *ChatServerConfig {
+	return chatInstance
+}
+
+func GetEmailConfig() *EmailConfig {
+	return emailInstance
+}
+
+func GetRateLimitConfig() *RateLimitConfig {
+	return rateLimitInstance
+}
+
+func GetResultsConfig() *ResultsConfig {
+	return resultsInstance
+}
+
+func GetScheduleConfig() *ScheduleConfig {
+	return scheduleInstance
+}
+
+func GetLobbiesConfig() *LobbiesConfig {
+	return lobbiesInstance
+}
+
+func GetAccountsConfig() *AccountsConfig {
+	return accountsInstance
+}
+
+func GetPushConfig() *PushConfig {
+	return pushInstance
+}
 
 func (client *Client) GetOauthClient() models.Client {
-	return models.Client{client.ID,client.Secret, client.Domain,""}
+	return models.Client{ID: client.ID, Secret: client.Secret, Domain: client.Domain}
 }
 
-func (cfg *AuthServerConfig) LoadFromEnvVariables() {
-	logEntry := logger.GetGlobalLogger().WithField("prefix","[CONFIG]")
-	err := envdecode.Decode(cfg)
-	if err != nil {
-		logEntry.Fatal("Failed to load authorization server config: ", err.Error())
+// Default returns config with values used when no source sets them
+func Default() *Config {
+	return &Config{
+		App:   AppConfig{DBPort: "5432", Port: "8080", SSLPort: "443"},
+		Email: EmailConfig{SMTPServer: "smtp.gmail.com", SMTPPort: 587},
+		RateLimits: RateLimitConfig{
+			Store: MemoryRateLimitStore,
+			Routes: map[string]RouteLimits{
+				LoginRoute: {
+					IP:      Limit{Requests: 20, Per: time.Minute},
+					Account: Limit{Requests: 10, Per: time.Minute},
+				},
+				RememberPasswordRoute: {
+					IP:      Limit{Requests: 5, Per: 15 * time.Minute},
+					Account: Limit{Requests: 3, Per: time.Hour},
+				},
+				JoinLobbyRoute: {
+					IP:      Limit{Requests: 60, Per: time.Minute},
+					Account: Limit{Requests: 10, Per: time.Minute},
+				},
+			},
+		},
+		Results:  ResultsConfig{ConfirmationTimeout: 24 * time.Hour},
+		Schedule: ScheduleConfig{ReminderBefore: time.Hour},
+		Lobbies:  LobbiesConfig{OwnerIdleTimeout: 30 * time.Minute, ExpireAfter: 12 * time.Hour},
+		Accounts: AccountsConfig{ErasureGracePeriod: 30 * 24 * time.Hour},
 	}
-	client := Client{}
-	err = envdecode.Decode(&client)
-	if err != nil {
-		logEntry.Fatal("Failed to load app client oauth config: ", err.Error())
+}
+
+// Load reads config from all sources, args are command line arguments without program name.
+// Valid config becomes the one returned by getters, invalid one is returned together with the error
+// so that it can still be printed
+func Load(args []string) (*Config, error) {
+	// flags are parsed twice, at first only to find the file, then to override values read from other sources
+	scratch := Default()
+	if err := newFlagSet(scratch, os.Stderr).Parse(args); err != nil {
+		return nil, err
+	}
+	path := scratch.File
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
 	}
-	cfg.Clients = []models.Client{client.GetOauthClient()}
+	if err := newFlagSet(cfg, ioutil.Discard).Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.File = path
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	appInstance, authInstance, imgInstance, emailInstance = &cfg.App, &cfg.Auth, &cfg.Images, &cfg.Email
+	rateLimitInstance, resultsInstance, scheduleInstance, pushInstance = &cfg.RateLimits, &cfg.Results, &cfg.Schedule, &cfg.Push
+	lobbiesInstance, accountsInstance, chatInstance = &cfg.Lobbies, &cfg.Accounts, &cfg.Chat
+	return cfg, nil
 }
 
-func (cfg *AppConfig) LoadFromEnvVariables() {
-	err := envdecode.Decode(cfg)
+// secrets aren't accepted as flags, command line is visible to other users of the machine
+func newFlagSet(cfg *Config, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("flanki", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.File, "config", cfg.File, "YAML file with config, CONFIG_FILE variable is used when not given")
+	flags.BoolVar(&cfg.PrintConfig, "print-config", cfg.PrintConfig, "print effective config with secrets redacted and exit")
+
+	flags.StringVar(&cfg.App.Port, "port", cfg.App.Port, "port the app listens on")
+	flags.BoolVar(&cfg.App.EnableSSL, "ssl", cfg.App.EnableSSL, "serve over TLS")
+	flags.StringVar(&cfg.App.SSLPort, "ssl-port", cfg.App.SSLPort, "port the app listens on when serving over TLS")
+	flags.StringVar(&cfg.App.DBHost, "db-host", cfg.App.DBHost, "database host")
+	flags.StringVar(&cfg.App.DBPort, "db-port", cfg.App.DBPort, "database port")
+	flags.StringVar(&cfg.App.DBName, "db-name", cfg.App.DBName, "database name")
+	flags.StringVar(&cfg.App.DBUsername, "db-user", cfg.App.DBUsername, "database user")
+
+	flags.StringVar(&cfg.Auth.Domain, "auth-server-domain", cfg.Auth.Domain, "authorization server url without port")
+	flags.StringVar(&cfg.Auth.Port, "auth-server-port", cfg.Auth.Port, "authorization server port")
+	flags.StringVar(&cfg.Images.Domain, "image-server-domain", cfg.Images.Domain, "image server url without port")
+	flags.StringVar(&cfg.Images.Port, "image-server-port", cfg.Images.Port, "image server port")
+	flags.StringVar(&cfg.Chat.Domain, "chat-server-domain", cfg.Chat.Domain, "chat url without port")
+	flags.StringVar(&cfg.Chat.Port, "chat-server-port", cfg.Chat.Port, "chat port")
+
+	flags.StringVar(&cfg.Email.SMTPServer, "smtp-server", cfg.Email.SMTPServer, "SMTP server emails are sent through")
+	flags.IntVar(&cfg.Email.SMTPPort, "smtp-port", cfg.Email.SMTPPort, "SMTP server port")
+	flags.StringVar(&cfg.RateLimits.Store, "rate-limit-store", cfg.RateLimits.Store, "where rate limits are kept, memory or postgres")
+	return flags
+}
+
+func (cfg *Config) readFile(path string) error {
+	file, err := os.Open(path)
 	if err != nil {
-		logger.GetGlobalLogger().WithField("prefix","[CONFIG]").Fatal("Failed to load application config: ", err.Error())
+		return fmt.Errorf("reading config file: %s", err)
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	// misspelled keys would be silently ignored otherwise
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("reading config file %s: %s", path, err)
 	}
+	return nil
 }
 
-func (cfg *ImageServerConfig) LoadFromEnvVariables() {
-	err := envdecode.Decode(cfg)
-	if err != nil {
-		logger.GetGlobalLogger().WithField("prefix","[CONFIG]").Fatal("Failed to image server config: ", err.Error())
+// variables from .env file don't override the ones set in the environment,
+// the file is skipped when ENV_INITIALIZED is set as it is in containers
+func loadEnv(cfg *Config) error {
+	if value, exists := os.LookupEnv("ENV_INITIALIZED"); !exists || value == "false" {
+		if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading .env file: %s", err)
+		}
+	}
+	if err := envdecode.Decode(cfg); err != nil && err != envdecode.ErrNoTargetFieldsAreSet {
+		return fmt.Errorf("reading environment variables: %s", err)
+	}
+	return nil
+}
+
+// Validate reports all missing and malformed values at once
+func (cfg *Config) Validate() error {
+	var problems []string
+	required := func(name string, value string) {
+		if value == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+	port := func(name string, value string) {
+		if number, err := strconv.Atoi(value); err != nil || number <= 0 || number > 65535 {
+			problems = append(problems, fmt.Sprintf("%s '%s' is not a valid port", name, value))
+		}
+	}
+	domain := func(name string, value string) {
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s '%s' should be an http(s) url", name, value))
+		}
 	}
+
+	required("app.db_user", cfg.App.DBUsername)
+	required("app.db_password", cfg.App.DBPassword)
+	required("app.db_name", cfg.App.DBName)
+	required("app.db_host", cfg.App.DBHost)
+	port("app.db_port", cfg.App.DBPort)
+	port("app.port", cfg.App.Port)
+	if cfg.App.EnableSSL {
+		port("app.ssl_port", cfg.App.SSLPort)
+	}
+
+	domain("authorization_server.domain", cfg.Auth.Domain)
+	port("authorization_server.port", cfg.Auth.Port)
+	required("authorization_server.client.id", cfg.Auth.Client.ID)
+	required("authorization_server.client.secret", cfg.Auth.Client.Secret)
+	required("authorization_server.client.domain", cfg.Auth.Client.Domain)
+
+	domain("image_server.domain", cfg.Images.Domain)
+	port("image_server.port", cfg.Images.Port)
+	domain("chat_server.domain", cfg.Chat.Domain)
+	port("chat_server.port", cfg.Chat.Port)
+
+	required("email.address", cfg.Email.Address)
+	required("email.password", cfg.Email.Password)
+	required("email.smtp_server", cfg.Email.SMTPServer)
+	port("email.smtp_port", strconv.Itoa(cfg.Email.SMTPPort))
+
+	if cfg.RateLimits.Store != MemoryRateLimitStore && cfg.RateLimits.Store != PostgresRateLimitStore {
+		problems = append(problems, fmt.Sprintf("rate_limits.store '%s' should be %s or %s", cfg.RateLimits.Store, MemoryRateLimitStore, PostgresRateLimitStore))
+	}
+	routes := make([]string, 0, len(cfg.RateLimits.Routes))
+	for route := range cfg.RateLimits.Routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	limit := func(name string, limit Limit) {
+		if limit.Requests < 0 || limit.Burst < 0 || (limit.Enabled() && limit.Per <= 0) {
+			problems = append(problems, name+" should have positive requests and period")
+		}
+	}
+	for _, route := range routes {
+		limit("rate_limits.routes."+route+".ip", cfg.RateLimits.Routes[route].IP)
+		limit("rate_limits.routes."+route+".account", cfg.RateLimits.Routes[route].Account)
+	}
+	if cfg.Results.ConfirmationTimeout <= 0 {
+		problems = append(problems, "results.confirmation_timeout should be positive")
+	}
+	if cfg.Schedule.ReminderBefore <= 0 {
+		problems = append(problems, "schedule.reminder_before should be positive")
+	}
+	if cfg.Lobbies.OwnerIdleTimeout < 0 {
+		problems = append(problems, "lobbies.owner_idle_timeout shouldn't be negative")
+	}
+	if cfg.Lobbies.ExpireAfter < 0 {
+		problems = append(problems, "lobbies.expire_after shouldn't be negative")
+	}
+	if cfg.Accounts.ErasureGracePeriod < 0 {
+		problems = append(problems, "accounts.erasure_grace_period shouldn't be negative")
+	}
+	if cfg.Push.VAPIDPublicKey != "" || cfg.Push.VAPIDPrivateKey != "" {
+		key := func(name string, value string, length int) {
+			if b, err := base64.RawURLEncoding.DecodeString(value); err != nil || len(b) != length {
+				problems = append(problems, fmt.Sprintf("%s should be %d bytes encoded with unpadded base64url", name, length))
+			}
+		}
+		key("push.vapid_public_key", cfg.Push.VAPIDPublicKey, 65)
+		key("push.vapid_private_key", cfg.Push.VAPIDPrivateKey, 32)
+		if !strings.HasPrefix(cfg.Push.Subject, "mailto:") && !strings.HasPrefix(cfg.Push.Subject, "https:") {
+			problems = append(problems, "push.subject should be a mailto: or https: url")
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, ", "))
+	}
+	return nil
 }
 
+const redacted = "[REDACTED]"
+
+// Redacted returns copy of the config with secrets replaced, set secrets are distinguishable from missing ones
+func (cfg Config) Redacted() Config {
+	for _, secret := range []*string{&cfg.App.DBPassword, &cfg.Auth.Client.Secret, &cfg.Email.Password, &cfg.Push.VAPIDPrivateKey} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return cfg
+}
 
+// Print writes effective config as YAML with secrets redacted, it can be used as config file after filling them
+func (cfg *Config) Print(w io.Writer) error {
+	if cfg.File != "" {
+		if _, err := fmt.Fprintf(w, "# read from %s\n", cfg.File); err != nil {
+			return err
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,183 @@
+package config_test
+
+import (
+	"FlankiRest/config"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const configFile = `
+app:
+  db_user: postgres
+  db_password: toor
+  db_name: flanki
+  db_host: database
+  port: "8000"
+authorization_server:
+  domain: http://auth_server
+  port: "5000"
+  client:
+    id: client_id
+    secret: client_secret
+    domain: http://flanki
+image_server:
+  domain: http://image_service
+  port: "5555"
+chat_server:
+  domain: http://chat
+  port: "8081"
+email:
+  address: flanki@example.com
+  password: mail_password
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "flanki_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSourcesOverridePreviousOnes(t *testing.T) {
+	t.Setenv("ENV_INITIALIZED", "true")
+	t.Setenv("SERVER_PORT", "8001")
+	t.Setenv("DB_HOST", "db_from_env")
+	path := writeConfig(t, configFile)
+
+	cfg, err := config.Load([]string{"-config", path, "-port", "8002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.App.DBName != "flanki" {
+		t.Errorf("value from file was lost, got '%s'", cfg.App.DBName)
+	}
+	if cfg.App.DBHost != "db_from_env" {
+		t.Errorf("environment should override file, got '%s'", cfg.App.DBHost)
+	}
+	if cfg.App.Port != "8002" {
+		t.Errorf("flag should override environment, got '%s'", cfg.App.Port)
+	}
+	if cfg.Email.SMTPServer != "smtp.gmail.com" || cfg.App.DBPort != "5432" {
+		t.Errorf("defaults weren't applied: %+v", cfg)
+	}
+	if config.GetAppConfig() != &cfg.App || config.GetEmailConfig() != &cfg.Email {
+		t.Errorf("loaded config isn't returned by getters")
+	}
+}
+
+func TestValidationListsAllProblems(t *testing.T) {
+	t.Setenv("ENV_INITIALIZED", "true")
+	path := writeConfig(t, strings.Replace(configFile, `port: "5555"`, `port: "none"`, 1)+"\n")
+
+	cfg, err := config.Load([]string{"-config", path, "-auth-server-domain", "auth_server"})
+	if err == nil {
+		t.Fatal("invalid config was accepted")
+	}
+	if cfg == nil {
+		t.Fatal("invalid config should be returned for printing")
+	}
+	for _, problem := range []string{"image_server.port 'none'", "authorization_server.domain 'auth_server'"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error '%s' doesn't mention %s", err, problem)
+		}
+	}
+}
+
+func TestUnknownKeysAreRejected(t *testing.T) {
+	t.Setenv("ENV_INITIALIZED", "true")
+	path := writeConfig(t, configFile+"  pasword: typo\n")
+	if _, err := config.Load([]string{"-config", path}); err == nil {
+		t.Error("misspelled key was accepted")
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	t.Setenv("ENV_INITIALIZED", "true")
+	cfg, err := config.Load([]string{"-config", writeConfig(t, configFile), "-print-config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.PrintConfig {
+		t.Error("-print-config wasn't set")
+	}
+	var output bytes.Buffer
+	if err := cfg.Print(&output); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"toor", "client_secret", "mail_password"} {
+		if strings.Contains(output.String(), secret) {
+			t.Errorf("secret '%s' was printed:\n%s", secret, output.String())
+		}
+	}
+	if !strings.Contains(output.String(), "client_id") || cfg.Auth.Client.Secret != "client_secret" {
+		t.Errorf("redacting changed the config or removed other values:\n%s", output.String())
+	}
+}
+
+func TestModeratorsAreReadFromEnvironment(t *testing.T) {
+	t.Setenv("ENV_INITIALIZED", "true")
+	t.Setenv("MODERATORS", "3;7")
+	path := writeConfig(t, configFile)
+
+	cfg, err := config.Load([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := config.GetResultsConfig()
+	if !results.IsModerator(7) || results.IsModerator(5) {
+		t.Errorf("moderators weren't read, got %v", cfg.Results.Moderators)
+	}
+	if results.ConfirmationTimeout != 24*time.Hour {
+		t.Errorf("default confirmation timeout wasn't applied, got %s", results.ConfirmationTimeout)
+	}
+}
+
+func TestReminderBeforeMustBePositive(t *testing.T) {
+	t.Setenv("ENV_INITIALIZED", "true")
+	path := writeConfig(t, configFile)
+
+	if _, err := config.Load([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if before := config.GetScheduleConfig().ReminderBefore; before != time.Hour {
+		t.Errorf("default reminder time wasn't applied, got %s", before)
+	}
+
+	t.Setenv("LOBBY_REMINDER_BEFORE", "-30m")
+	_, err := config.Load([]string{"-config", path})
+	if err == nil || !strings.Contains(err.Error(), "schedule.reminder_before") {
+		t.Errorf("expected reminder_before problem, got %v", err)
+	}
+}
+
+func TestVAPIDKeysAreChecked(t *testing.T) {
+	t.Setenv("ENV_INITIALIZED", "true")
+	path := writeConfig(t, configFile)
+
+	t.Setenv("VAPID_PUBLIC_KEY", "dG9vIHNob3J0")
+	t.Setenv("VAPID_PRIVATE_KEY", "c2VjcmV0")
+	_, err := config.Load([]string{"-config", path})
+	for _, problem := range []string{"push.vapid_public_key", "push.vapid_private_key", "push.subject"} {
+		if err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %s problem, got %v", problem, err)
+		}
+	}
+
+	t.Setenv("VAPID_PUBLIC_KEY", "")
+	t.Setenv("VAPID_PRIVATE_KEY", "")
+	if _, err := config.Load([]string{"-config", path}); err != nil || config.GetPushConfig().Enabled() {
+		t.Errorf("expected push to be disabled without keys, got %v", err)
+	}
+}
@@ -1,27 +1,33 @@
 package controllers
 
 import (
+	"FlankiRest/config"
 	"FlankiRest/database"
 	"FlankiRest/errors"
 	"FlankiRest/models"
+	"FlankiRest/repositories"
 	"FlankiRest/services"
+	"FlankiRest/tracing"
 	u "FlankiRest/utils"
+	"bytes"
 	"context"
 	"encoding/json"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type AccountController struct {
-	DB *database.ApiDatabase
+	DB *database.ApiDatabase // needed for password resets
+	Repos *repositories.Repositories
 	Cfg *oauth2.Config
 	logger *logrus.Logger
 }
 
-func NewAccountController(db *database.ApiDatabase, cfg *oauth2.Config, logger *logrus.Logger) *AccountController {
-	return &AccountController{db, cfg, logger}
+func NewAccountController(db *database.ApiDatabase, repos *repositories.Repositories, cfg *oauth2.Config, logger *logrus.Logger) *AccountController {
+	return &AccountController{db, repos, cfg, logger}
 }
 
 func (controller *AccountController) Logger() *logrus.Logger {
@@ -34,7 +40,7 @@ type Credentials struct {
 }
 
 func (controller *AccountController) CreateAccount(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	account := &models.Account{}
 	err := json.NewDecoder(r.Body).Decode(&account)
 
@@ -43,7 +49,7 @@ func (controller *AccountController) CreateAccount(w http.ResponseWriter, r *htt
 		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
 		return
 	}
-	err = account.Create(db)
+	err = account.Create(repos.Accounts)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -61,13 +67,26 @@ func (controller *AccountController) LoginAccount(w http.ResponseWriter, r *http
 		return
 	}
 
-	token, err := controller.Cfg.PasswordCredentialsToken(context.Background(), creds.Email, creds.Password)
+	// token request made with traced client continues trace of served request
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, tracing.HTTPClient)
+	token, err := controller.Cfg.PasswordCredentialsToken(ctx, creds.Email, creds.Password)
 	if err != nil {
 		if serr, ok := err.(*oauth2.RetrieveError); ok {
 			if serr.Response.StatusCode == 401 {
 				u.ApiErrorResponse(w, errors.UnauthorizedAccount)
 				return
 			}
+			// account is locked by authorization server after repeated failed logins
+			if serr.Response.StatusCode == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", serr.Response.Header.Get("Retry-After"))
+				u.ApiErrorResponse(w, errors.AccountLocked)
+				return
+			}
+			if serr.Response.StatusCode == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", serr.Response.Header.Get("Retry-After"))
+				u.ApiErrorResponse(w, errors.AuthServerUnavailable)
+				return
+			}
 		}
 		controller.Logger().WithField("prefix", "[LOGIN]").Error(err.Error())
 		u.ApiErrorResponse(w, errors.New("Authentication server internal error",500))
@@ -85,7 +104,7 @@ func (controller *AccountController) LoginAccount(w http.ResponseWriter, r *http
 }
 
 func (controller *AccountController) UpdateAccount(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	requestedAccountChange := &models.UpdateAccount{}
 	err := json.NewDecoder(r.Body).Decode(requestedAccountChange)
 
@@ -101,7 +120,7 @@ func (controller *AccountController) UpdateAccount(w http.ResponseWriter, r *htt
 	}
 	requestedAccountChange.ID = id
 
-	err = requestedAccountChange.Update(db)
+	err = requestedAccountChange.Update(repos.Accounts)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -111,15 +130,14 @@ func (controller *AccountController) UpdateAccount(w http.ResponseWriter, r *htt
 }
 
 func (controller *AccountController) DeleteAccount(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-
+	repos := controller.Repos.WithContext(r.Context())
 	id, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
 
-	account, err := models.GetAccountById(db, id)
+	account, err := models.GetAccountById(repos.Accounts, id)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -130,31 +148,100 @@ func (controller *AccountController) DeleteAccount(w http.ResponseWriter, r *htt
 		u.ApiErrorResponse(w, errors.New("User is playing, can't delete this account", 400))
 		return
 	}
-	err = db.Delete(account).Error
+	// chat accepts only the user's own token, so messages are deleted now instead of when the account is erased
+	err = services.GetChatService().DeleteUserMessages(r.Context(), r.Header.Get("Authorization"))
 	if err != nil {
-		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		u.ApiErrorResponse(w, err)
 		return
 	}
-	u.SimpleRespond(w, u.TextMessage("Account has been deleted"))
+	err = account.Delete(repos.Accounts)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	// account is logged out everywhere, it is erased for good after the grace period unless it is restored
+	if db := tracing.WithContext(controller.DB.DB(), r.Context()); db != nil {
+		if err := services.RevokeTokens(db, account.ID); err != nil {
+			controller.Logger().WithField("prefix", "[DELETE ACCOUNT]").Error(err.Error())
+		}
+	}
+	restoreUntil := time.Now().Add(config.GetAccountsConfig().ErasureGracePeriod)
+	u.SimpleRespond(w, u.TextMessage("Account has been deleted, you can restore it until "+restoreUntil.Format(time.RFC3339)))
 	return
 }
 
-func (controller *AccountController) GetAccount(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+// brings back deleted account during the grace period, deleted account can't log in so it proves itself with credentials
+func (controller *AccountController) RestoreAccount(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	creds := &Credentials{}
+	err := json.NewDecoder(r.Body).Decode(&creds)
+	if err != nil || *creds == (Credentials{}) {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	_, err = models.RestoreAccount(repos.Accounts, creds.Email, creds.Password, config.GetAccountsConfig().ErasureGracePeriod, time.Now())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Account has been restored, you can now log in into your account"))
+	return
+}
 
+// ZIP archive of everything the app keeps about the user, built in memory so that errors can still be responded
+func (controller *AccountController) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	id, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	export, err := models.GetAccountExport(repos.Accounts, repos.Statistics, repos.Lobbies, repos.Events,
+		repos.Achievements, repos.Friends, repos.RSVPs, repos.Notifications, id, time.Now())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	export.ChatMessages, err = services.GetChatService().GetUserMessages(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	avatar, contentType, err := services.GetImageService().GetUserImageById(r.Context(), int(id))
+	if apiErr, ok := err.(*errors.ApiError); ok && apiErr.HttpCode == http.StatusNotFound {
+		avatar, err = nil, nil
+	}
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	archive := &bytes.Buffer{}
+	if err := services.WriteAccountExport(archive, export, avatar, contentType); err != nil {
+		u.ApiErrorResponse(w, errors.New("Failed to export account: "+err.Error(), 500))
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="flanki-account-`+strconv.Itoa(int(id))+`.zip"`)
+	if _, err := archive.WriteTo(w); err != nil {
+		controller.Logger().Error("Encountered error while sending account export: ", err.Error())
+	}
+}
+
+func (controller *AccountController) GetAccount(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
 	id, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
 
-	account, err := models.GetAccountById(db, id)
+	account, err := models.GetAccountById(repos.Accounts, id)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
 	account.Password = ""
-	userStatistics, err := models.GetPlayersSummary(db, id)
+	userStatistics, err := models.GetPlayersSummary(repos.Accounts, repos.Statistics, id)
 	var summary *models.QuickSummary
 	if userStatistics != nil {
 		summary = userStatistics.GetQuickSummary()
@@ -166,8 +253,31 @@ func (controller *AccountController) GetAccount(w http.ResponseWriter, r *http.R
 	return
 }
 
+// returns path of the user's calendar feed, POST generates a new one and the old path stops working
+func (controller *AccountController) CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	id, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	account, err := models.GetAccountById(repos.Accounts, id)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	token, err := account.CalendarFeedToken(repos.Accounts, r.Method == http.MethodPost)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, map[string] interface{} {"path": config.API_PREFIX + "/calendar/" + token + ".ics"})
+	return
+}
+
 func (controller *AccountController) ResetPasswordRequest(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
+	db := tracing.WithContext(controller.DB.DB(), r.Context())
 	type Email struct {
 		Email string `json:"email"`
 	}
@@ -178,7 +288,7 @@ func (controller *AccountController) ResetPasswordRequest(w http.ResponseWriter,
 		return
 	}
 
-	err = services.EmailPasswordResetRequest(db, email.Email)
+	err = services.EmailPasswordResetRequest(db, repos.Accounts, email.Email)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -189,7 +299,8 @@ func (controller *AccountController) ResetPasswordRequest(w http.ResponseWriter,
 }
 
 func (controller *AccountController) ResetPassword(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
+	db := tracing.WithContext(controller.DB.DB(), r.Context())
 	request := services.ResetRequest{}
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
@@ -197,7 +308,7 @@ func (controller *AccountController) ResetPassword(w http.ResponseWriter, r *htt
 		return
 	}
 
-	err = services.ResetPassword(db, request)
+	err = services.ResetPassword(db, repos.Accounts, request)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/errors"
+	"FlankiRest/jobs"
+	u "FlankiRest/utils"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+type AdminController struct {
+	Scheduler *jobs.Scheduler
+	logger    *logrus.Logger
+}
+
+func NewAdminController(scheduler *jobs.Scheduler, logger *logrus.Logger) *AdminController {
+	return &AdminController{scheduler, logger}
+}
+
+func (controller *AdminController) Logger() *logrus.Logger {
+	return controller.logger
+}
+
+// shows background jobs with their recent runs and last failure, only admins can see them
+func (controller *AdminController) GetJobs(w http.ResponseWriter, r *http.Request) {
+	adminID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if !config.GetAppConfig().IsAdmin(adminID) {
+		u.ApiErrorResponse(w, errors.NotAdmin)
+		return
+	}
+	statuses, err := controller.Scheduler.Status(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		return
+	}
+	u.SimpleRespond(w, statuses)
+}
@@ -20,7 +20,7 @@ func NewImageController(logg *logrus.Logger) *ImageController {
 func (controller *ImageController) GetImageById(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	imgID, _ := strconv.Atoi(vars["id"])
-	img, contentType, err := services.GetImageService().GetUserImageById(imgID)
+	img, contentType, err := services.GetImageService().GetUserImageById(r.Context(), imgID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -41,7 +41,7 @@ func (controller *ImageController) GetOwnerImage(w http.ResponseWriter, r *http.
 		return
 	}
 
-	img, contentType, err := services.GetImageService().GetUserImageById(int(id))
+	img, contentType, err := services.GetImageService().GetUserImageById(r.Context(), int(id))
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -61,7 +61,7 @@ func (controller *ImageController) UploadImage(w http.ResponseWriter, r *http.Re
 		return
 	}
 	contentType := r.Header.Get("Content-Type")
-	err = services.GetImageService().UploadImageById(int(id), contentType, r.Body)
+	err = services.GetImageService().UploadImageById(r.Context(), int(id), contentType, r.Body)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -1,25 +1,31 @@
 package controllers
 
 import (
-	"FlankiRest/database"
+	"FlankiRest/calendar"
+	"FlankiRest/config"
 	"FlankiRest/errors"
+	"FlankiRest/metrics"
 	"FlankiRest/models"
+	"FlankiRest/notifications"
+	"FlankiRest/repositories"
 	u "FlankiRest/utils"
 	"encoding/json"
 	"github.com/gorilla/mux"
-	"github.com/jinzhu/gorm"
 	"github.com/sirupsen/logrus"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type LobbyController struct {
-	DB *database.ApiDatabase
+	Repos *repositories.Repositories
 	logger *logrus.Logger
 }
 
-func NewLobbyController(db *database.ApiDatabase, logger *logrus.Logger) *LobbyController {
-	return &LobbyController{db, logger}
+func NewLobbyController(repos *repositories.Repositories, logger *logrus.Logger) *LobbyController {
+	return &LobbyController{repos, logger}
 }
 
 func (controller *LobbyController) Logger() *logrus.Logger {
@@ -27,7 +33,7 @@ func (controller *LobbyController) Logger() *logrus.Logger {
 }
 
 func (controller *LobbyController) CreateLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	lobby := &models.Lobby{}
 	err := json.NewDecoder(r.Body).Decode(&lobby)
 
@@ -42,32 +48,32 @@ func (controller *LobbyController) CreateLobby(w http.ResponseWriter, r *http.Re
 	}
 	lobby.OwnerID = id
 
-	err = lobby.Create(db)
+	err = lobby.Create(repos.Lobbies)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
+	metrics.LobbiesCreated.Inc()
 	lobby.Password = ""
 	u.SimpleRespond(w, lobby)
 	return
 }
 
 func (controller *LobbyController) DeleteLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-
+	repos := controller.Repos.WithContext(r.Context())
 	id, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
 
-	lobby, err := models.GetOwnersLobby(db, id, false)
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, id)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
 
-	err = lobby.Delete(db)
+	err = lobby.Delete(repos.Lobbies, repos.Accounts)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 	}
@@ -76,8 +82,20 @@ func (controller *LobbyController) DeleteLobby(w http.ResponseWriter, r *http.Re
 	return
 }
 
+// managedLobby returns lobby managed by the user, players managing several lobbies choose one with lobby_id query parameter
+func managedLobby(lobbies models.LobbyRepository, r *http.Request, managerID uint) (*models.Lobby, error) {
+	lobbyID := 0
+	if value := r.URL.Query().Get("lobby_id"); value != "" {
+		var err error
+		if lobbyID, err = strconv.Atoi(value); err != nil || lobbyID <= 0 {
+			return nil, errors.New("lobby_id should be an id of the lobby", 400)
+		}
+	}
+	return models.GetManagedLobby(lobbies, managerID, uint(lobbyID))
+}
+
 func (controller *LobbyController) UpdateLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	lobbyChange := &models.Lobby{}
 	err := json.NewDecoder(r.Body).Decode(lobbyChange)
 	if err != nil || lobbyChange.IsEmpty() {
@@ -89,9 +107,15 @@ func (controller *LobbyController) UpdateLobby(w http.ResponseWriter, r *http.Re
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	lobbyChange.OwnerID = id
+	// co-owners and the referee update the lobby on behalf of its owner
+	lobby, err := managedLobby(repos.Lobbies, r, id)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobbyChange.OwnerID = lobby.OwnerID
 
-	err = lobbyChange.Update(db)
+	err = lobbyChange.Update(repos.Lobbies, id)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -101,18 +125,18 @@ func (controller *LobbyController) UpdateLobby(w http.ResponseWriter, r *http.Re
 }
 
 func (controller *LobbyController) CloseLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	id, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	lobby, err := models.GetOwnersLobby(db, id, false)
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, id)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	err = lobby.Close(db)
+	err = lobby.Close(repos.Lobbies, repos.Accounts)
 	if err != nil {
 		u.ApiErrorResponse(w,err)
 		return
@@ -121,10 +145,16 @@ func (controller *LobbyController) CloseLobby(w http.ResponseWriter, r *http.Req
 }
 
 func (controller *LobbyController) GetLobbyById(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
-	lobby, err := models.GetLobbyByIdFunc(db, uint(id))
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	// friends only lobbies are hidden from players who aren't friends of the owner
+	lobby, err := models.GetVisibleLobby(repos.Lobbies, repos.Spectators, repos.Friends, uint(id), playerID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -135,23 +165,33 @@ func (controller *LobbyController) GetLobbyById(w http.ResponseWriter, r *http.R
 }
 
 func (controller *LobbyController) GetAllLobbies(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
 	// gets list of all opened lobbies
-	lobbies, err := models.GetAllLobbies(db, false)
+	lobbies, err := models.GetAllLobbies(repos.Lobbies, false)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobbies, err = models.VisibleLobbies(repos.Friends, lobbies, playerID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
 	lobbiesListing := []*models.LobbyListing{}
 	for _, l := range lobbies {
-		lobbiesListing = append(lobbiesListing, models.NewLobbyListing(l,db))
+		lobbiesListing = append(lobbiesListing, models.NewLobbyListing(l))
 	}
 	u.SimpleRespond(w, lobbiesListing)
 	return
 }
 
 func (controller *LobbyController) JoinLobbyTeam(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	vars := mux.Vars(r)
 	lobbyID, _ := strconv.Atoi(vars["id"])
 	playerID, err := u.GetUserIdFromContext(r.Context())
@@ -167,195 +207,276 @@ func (controller *LobbyController) JoinLobbyTeam(w http.ResponseWriter, r *http.
 		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
 		return
 	}
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if err = lobby.CheckVisibility(repos.Friends, playerID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	account, err := models.GetAccountById(repos.Accounts, playerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
 	if joinRequest.TeamColor == models.Spectator {
-		lobby, err := models.GetLobbyByIdFunc(db, uint(lobbyID))
-		if err != nil {
+		if err = lobby.AddSpectator(repos.Spectators, account, joinRequest); err != nil {
 			u.ApiErrorResponse(w, err)
 			return
 		}
-		u.SimpleRespond(w,lobby)
+		lobby.Password = ""
+		u.SimpleRespond(w, lobby)
 		return
 	}
 
-	account, err := models.GetAccountById(db, playerID)
+	err = lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, account, joinRequest)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	if account.Playing == true {
-		u.ApiErrorResponse(w, errors.New("User is already playing, can't join to new team", 400))
+	if err = lobby.StopWatching(repos.Spectators, playerID); err != nil {
+		u.ApiErrorResponse(w, err)
 		return
 	}
 
-	err = joinRequest.Validate()
+	u.SimpleRespond(w, u.TextMessage("Joining to the lobby has been successful!"))
+	return
+}
+
+func (controller *LobbyController) LeaveLobby(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	player, err := models.GetAccountById(repos.Accounts, playerID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
+	lobby, err := models.GetPlayersLobby(repos.Lobbies, playerID)
+
+	if err != nil {
+		if err == errors.PlayerNotActive {
+			// playing status which doesn't match open lobbies is fixed by reconcile_playing job,
+			// the player trying to leave doesn't have to wait for it
+			if player.Playing == true {
+				controller.Logger().WithField("prefix", "[BUG]").Error("Player is still playing but is not present in any opened lobby")
+				player.Playing = false
+				if saveErr := repos.Accounts.Save(player); saveErr != nil {
+					err = errors.DatabaseError(saveErr)
+				}
+			}
+		}
+		u.ApiErrorResponse(w,err)
+		return
+	}
 
-	lobby, err := models.GetLobbyByIdFunc(db, uint(lobbyID))
+	newOwner, err := lobby.Leave(repos.Lobbies, repos.Accounts, playerID, time.Now())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
+	if newOwner != nil {
+		go notifications.Notify(controller.Repos, newOwner.PlayerID, models.OwnershipNotice(lobby))
+	}
+	u.SimpleRespond(w, u.TextMessage("Left the lobby"))
+	return
+}
 
-	if *lobby.Private == true {
-		if ok := joinRequest.CheckPassword(lobby); !ok {
-			u.ApiErrorResponse(w, errors.UnauthorizedLobbyJoinRequest)
-			return
-		}
+func (controller *LobbyController) KickPlayerFromLobby(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	managerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
 	}
 
-	var teams []models.Team
-	err = db.Model(&lobby).Association("Teams").Find(&teams).Error
+	lobby, err := managedLobby(repos.Lobbies, r, managerID)
 	if err != nil {
-		u.ApiErrorResponse(w, errors.New("Database error while trying extract teams' data from lobby: " + err.Error(), 500))
+		u.ApiErrorResponse(w, err)
 		return
 	}
 
-	blueTeam := teams[0]
-	redTeam := teams[1]
+	type ID struct {
+		ID uint `json:"player_id"`
+	}
+	playerID := &ID{}
+	err = json.NewDecoder(r.Body).Decode(playerID)
 
-	if blueTeam.TeamEntriesCount(db) + redTeam.TeamEntriesCount(db) >= int(lobby.PlayerLimit) {
-		u.ApiErrorResponse(w, errors.LobbyIsFull)
+	if err != nil || playerID.ID == 0 {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
 		return
 	}
-	var team *models.Team
-	if joinRequest.TeamColor == models.Blue {
-		team = &blueTeam
-	}
-	if joinRequest.TeamColor == models.Red {
-		team = &redTeam
+	if playerID.ID == lobby.OwnerID && managerID != lobby.OwnerID {
+		u.ApiErrorResponse(w, errors.New("Owner can't be kicked out of their lobby", 403))
+		return
 	}
-	player := &models.Account{}
-	err = db.Model(&models.Account{}).Where("id = ?",playerID).Select("nickname").First(&player).Error
+
+	err = lobby.RemovePlayer(repos.Lobbies, repos.Accounts, playerID.ID)
 	if err != nil {
-		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		u.ApiErrorResponse(w, err)
 		return
 	}
-	entry := &models.TeamEntry{PlayerID: playerID,Nickname: player.Nickname}
-	err = team.AddNewEntry(db, entry)
+	go notifications.Notify(controller.Repos, playerID.ID, models.KickedNotice(lobby))
+	u.SimpleRespond(w, u.TextMessage("Player has been kicked out of lobby"))
+	return
+}
+
+// removes spectator from managed lobby, they can come back unless the lobby is private
+func (controller *LobbyController) KickSpectator(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	managerID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
-		u.ApiErrorResponse(w, errors.New("Database error while joining lobby: " + err.Error(), 500))
+		u.ApiErrorResponse(w, err)
 		return
 	}
-	err = db.Model(&account).Update("playing", true).Error
+	lobby, err := managedLobby(repos.Lobbies, r, managerID)
 	if err != nil {
-		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		u.ApiErrorResponse(w, err)
 		return
 	}
-
-	u.SimpleRespond(w, u.TextMessage("Joining to the lobby has been successful!"))
+	type ID struct {
+		ID uint `json:"player_id"`
+	}
+	playerID := &ID{}
+	if err = json.NewDecoder(r.Body).Decode(playerID); err != nil || playerID.ID == 0 {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	if err = lobby.RemoveSpectator(repos.Spectators, playerID.ID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Spectator has been removed from lobby"))
 	return
 }
 
-func (controller *LobbyController) LeaveLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-
-	lobby := &models.Lobby{}
+// stops the user watching the lobby
+func (controller *LobbyController) StopWatching(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
 	playerID, err := u.GetUserIdFromContext(r.Context())
-	player, err := models.GetAccountById(db, playerID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	err = db.Joins("JOIN teams on lobbies.id = teams.lobby_id").
-		Joins("JOIN team_entries on teams.id = team_entries.team_id").
-		Where("team_entries.player_id = ? and lobbies.closed = false", playerID).
-		First(&lobby).Error
-
+	lobbyID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// encountered bug where all lobbies were closed but player playing status was still true
-			// pray that it will happen again to solve this problem
-			if player.Playing == true {
-				controller.Logger().WithField("prefix", "[BUG]").Error("Player is still playing but is not present in any opened lobby")
-				player.Playing = false
-				err = db.Save(player).Error
-				if err != nil {
-					err = errors.DatabaseError(err)
-				}
-			} else {
-				err = errors.PlayerNotActive
-			}
-		} else {
-			err =  errors.DatabaseError(err)
-		}
-		u.ApiErrorResponse(w,err)
+		u.ApiErrorResponse(w, err)
 		return
 	}
+	if err = lobby.RemoveSpectator(repos.Spectators, playerID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Stopped watching the lobby"))
+	return
+}
 
-	var teams []models.Team
-	err = db.Model(&lobby).Association("Teams").Find(&teams).Error
+// rebalances teams of managed lobby by players' ratings and responds with the lobby
+func (controller *LobbyController) ShuffleTeams(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	managerID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
-		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		u.ApiErrorResponse(w, err)
 		return
 	}
-	for _, team := range teams {
-		if team.ContainsPlayerWithId(db, playerID) {
-			err := team.DeleteEntryWithPlayerId(db, playerID)
-			if err != nil {
-				u.ApiErrorResponse(w, err)
-				return
-			}
-			u.SimpleRespond(w, u.TextMessage("Left the lobby"))
-			return
-		}
+	lobby, err := managedLobby(repos.Lobbies, r, managerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
 	}
-	u.ApiErrorResponse(w, errors.PlayerNotFoundInAnyTeam)
+	err = lobby.Shuffle(repos.Lobbies, repos.Statistics)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby.Password = ""
+	u.SimpleRespond(w, lobby)
 	return
 }
 
-func (controller *LobbyController) KickPlayerFromLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-
-	ownerID, err := u.GetUserIdFromContext(r.Context())
+// asks players of managed lobby to confirm they are ready
+func (controller *LobbyController) StartReadyCheck(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	managerID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-
-	lobby, err := models.GetOwnersLobby(db, ownerID, true)
+	lobby, err := managedLobby(repos.Lobbies, r, managerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = lobby.StartReadyCheck(repos.Lobbies)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
+	u.SimpleRespond(w, u.TextMessage("Ready check has been started"))
+	return
+}
 
-	type ID struct {
-		ID uint `json:"player_id"`
+// starts the match in managed lobby when all players are ready, teams are locked since then
+func (controller *LobbyController) StartMatch(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	managerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
 	}
-	playerID := &ID{}
-	err = json.NewDecoder(r.Body).Decode(playerID)
+	lobby, err := managedLobby(repos.Lobbies, r, managerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = lobby.Start(repos.Lobbies)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Match has been started"))
+	return
+}
 
-	if err != nil || playerID.ID == 0 {
+// sets ready flag of the player in his current lobby
+func (controller *LobbyController) SetReady(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.ReadyRequest{}
+	err = json.NewDecoder(r.Body).Decode(request)
+	if err != nil || request.Ready == nil {
 		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
 		return
 	}
-
-	for _, team := range lobby.Teams {
-		err = team.DeleteEntryWithPlayerId(db, playerID.ID)
-		if err == nil {
-			u.SimpleRespond(w, u.TextMessage("Player has been kicked out of lobby"))
-			return
-		}
+	lobby, err := models.GetPlayersLobby(repos.Lobbies, playerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
 	}
-	if err == gorm.ErrRecordNotFound {
-		u.ApiErrorResponse(w, errors.PlayerNotFoundInAnyTeam)
+	err = lobby.SetReady(repos.Lobbies, playerID, *request.Ready)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
 		return
 	}
-	u.ApiErrorResponse(w, errors.DatabaseError(err))
+	u.SimpleRespond(w, u.TextMessage("Ready flag has been set"))
 	return
 }
 
 // submits lobby results and end the game
 func (controller *LobbyController) SubmitResults(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-	ownerID, err := u.GetUserIdFromContext(r.Context())
+	repos := controller.Repos.WithContext(r.Context())
+	managerID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	lobby, err := models.GetOwnersLobby(db, ownerID, false)
+	lobby, err := managedLobby(repos.Lobbies, r, managerID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -374,62 +495,210 @@ func (controller *LobbyController) SubmitResults(w http.ResponseWriter, r *http.
 		return
 	}
 
-	lobby.Winner = winner.TeamWin
+	playerIDs, err := lobby.GetLobbyPlayersIds()
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = lobby.SubmitResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, managerID, winner.TeamWin, config.GetResultsConfig().ConfirmationTimeout)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	go notifications.NotifyAll(controller.Repos, othersThan(managerID, playerIDs), models.ResultsNotice(lobby))
+	go notifications.AwardAchievements(controller.Repos, playerIDs)
+	metrics.MatchesSubmitted.WithLabelValues(string(lobby.Winner)).Inc()
+	metrics.MatchDuration.Observe(lobby.MatchDuration().Seconds())
+	if lobby.ResultStatus == models.ResultPending {
+		u.SimpleRespond(w, u.TextMessage("Results have been submitted, they wait for confirmation of the other team"))
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Results have been submitted"))
+	return
+}
 
-	err = models.SubmitMatch(db, lobby)
+// confirms pending results of the lobby on behalf of the other team
+func (controller *LobbyController) ConfirmResult(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = lobby.ConfirmResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, playerID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
+	controller.awardAchievements(lobby)
+	u.SimpleRespond(w, u.TextMessage("Results have been confirmed"))
+	return
+}
 
-	err = lobby.Close(db)
+// disputes results of the lobby, statistics of the match don't count until a moderator settles it
+func (controller *LobbyController) DisputeResult(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.DisputeRequest{}
+	err = json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = lobby.DisputeResult(repos.Lobbies, repos.Statistics, playerID, request)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
+	u.SimpleRespond(w, u.TextMessage("Results have been disputed"))
+	return
+}
 
-	err = db.Save(lobby).Error
+// settles disputed results of the lobby, only moderators can do that
+func (controller *LobbyController) SettleResult(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	moderatorID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
-		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		u.ApiErrorResponse(w, err)
 		return
 	}
-	u.SimpleRespond(w, u.TextMessage("Results have been submitted"))
+	if !config.GetResultsConfig().IsModerator(moderatorID) {
+		u.ApiErrorResponse(w, errors.NotModerator)
+		return
+	}
+	request := &models.SettleRequest{}
+	err = json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = lobby.SettleResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, moderatorID, request)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	controller.awardAchievements(lobby)
+	u.SimpleRespond(w, u.TextMessage("Dispute has been settled"))
 	return
 }
 
-// responds with list of closed lobbies which are said to be finished
+// records event of the started match, only the owner and the referee can do that
+func (controller *LobbyController) RecordEvent(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	recorderID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.MatchEventRequest{}
+	err = json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	event, err := lobby.RecordEvent(repos.Events, recorderID, request)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, event)
+	return
+}
+
+// responds with event log of the lobby's match and stats of its players
+func (controller *LobbyController) MatchLog(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	log, err := models.GetMatchLog(repos.Events, lobby.ID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, log)
+	return
+}
+
+// responds with list of closed lobbies which have been finished with results
 func (controller *LobbyController) Results(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	var lobbies []*models.Lobby
-	lobbies, err := models.GetAllLobbies(db, true)
+	lobbies, err := models.GetAllLobbies(repos.Lobbies, true)
 
 	if err != nil {
-		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		u.ApiErrorResponse(w, err)
 		return
 	}
 
 	results := []*models.MatchResult{} // it's like this to marshal empty slice to '[]' and not to 'null'
 	for _, lobby := range lobbies {
-		results = append(results, &models.MatchResult{ID: lobby.ID, Winner: lobby.Winner, Teams: &lobby.Teams, Submitted: lobby.UpdatedAt})
+		if lobby.State == models.Finished {
+			results = append(results, models.NewMatchResult(lobby))
+		}
+	}
+	if err = models.AddMatchStats(repos.Events, results); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
 	}
 
 	u.SimpleRespond(w, results)
 	return
 }
 
-// responds  with player's owner lobby
+// responds with the lobby the player owns or manages
 func (controller *LobbyController) OwnerLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-	ownerID, err := u.GetUserIdFromContext(r.Context())
+	repos := controller.Repos.WithContext(r.Context())
+	managerID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	lobby, err := models.GetOwnersLobby(db, ownerID, true)
+	lobby, err := managedLobby(repos.Lobbies, r, managerID)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
+	if err = lobby.LoadSpectators(repos.Spectators); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
 	lobby.Password = ""
 	u.SimpleRespond(w, lobby)
 	return
@@ -437,28 +706,321 @@ func (controller *LobbyController) OwnerLobby(w http.ResponseWriter, r *http.Req
 
 // responds with player's current lobby if he is a member of one
 func (controller *LobbyController) GetCurrentLobby(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	playerID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
-	lobby := &models.Lobby{}
+	lobby, err := models.GetPlayersLobby(repos.Lobbies, playerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if err = lobby.LoadSpectators(repos.Spectators); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby.Password = ""
+	u.SimpleRespond(w, lobby)
+	return
+}
 
-	err = db.Joins("JOIN teams on lobbies.id = teams.lobby_id").
-		Joins("JOIN team_entries on teams.id = team_entries.team_id").
-		Preload("Teams.TeamEntries").
-		Where("team_entries.player_id = ? and lobbies.closed = false", playerID).
-		First(lobby).Error
+// lists lobbies scheduled ahead which haven't started yet, the soonest first
+func (controller *LobbyController) UpcomingLobbies(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	playerID, err := u.GetUserIdFromContext(r.Context())
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			u.ApiErrorResponse(w, errors.PlayerNotActive)
-			return
-		}
-		u.ApiErrorResponse(w, errors.DatabaseError(err))
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobbies, err := models.GetUpcomingLobbies(repos.Lobbies, repos.Friends, playerID, time.Now())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, lobbies)
+	return
+}
+
+// records whether the user is coming to the scheduled lobby
+func (controller *LobbyController) RespondToLobby(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.RSVPRequest{}
+	err = json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	account, err := models.GetAccountById(repos.Accounts, playerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	rsvp, err := lobby.Respond(repos.RSVPs, account, request)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, rsvp)
+	return
+}
+
+func (controller *LobbyController) LobbyRSVPs(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	rsvps, err := models.GetRSVPs(repos.RSVPs, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, rsvps)
+	return
+}
+
+// iCalendar feed with the scheduled lobby, it doesn't require authorization
+func (controller *LobbyController) LobbyCalendar(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	lobbyID, _ := strconv.Atoi(vars["id"])
+	feed, err := models.GetLobbyCalendar(repos.Lobbies, uint(lobbyID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	respondCalendar(w, feed)
+}
+
+// iCalendar feed of lobbies the player is coming to, the token in the path authorizes it
+func (controller *LobbyController) PlayerCalendar(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	feed, err := models.GetPlayersCalendar(repos.Accounts, repos.Lobbies, repos.RSVPs, vars["token"])
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	respondCalendar(w, feed)
+}
+
+// hands owner's lobby over to one of its players or co-owners
+func (controller *LobbyController) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	type ID struct {
+		ID uint `json:"player_id"`
+	}
+	playerID := &ID{}
+	if err = json.NewDecoder(r.Body).Decode(playerID); err != nil || playerID.ID == 0 {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	if err = lobby.TransferOwnership(repos.Lobbies, playerID.ID, time.Now()); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	go notifications.Notify(controller.Repos, playerID.ID, models.OwnershipNotice(lobby))
+	lobby.Password = ""
+	u.SimpleRespond(w, lobby)
+	return
+}
+
+// shares management of owner's lobby with the player
+func (controller *LobbyController) AddCoOwner(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	type ID struct {
+		ID uint `json:"player_id"`
+	}
+	playerID := &ID{}
+	if err = json.NewDecoder(r.Body).Decode(playerID); err != nil || playerID.ID == 0 {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	if err = lobby.AddCoOwner(repos.Lobbies, repos.Accounts, playerID.ID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, lobby.CoOwners)
+	return
+}
+
+func (controller *LobbyController) RemoveCoOwner(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	playerID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err = lobby.RemoveCoOwner(repos.Lobbies, uint(playerID)); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Co-owner has been removed from lobby"))
+	return
+}
+
+func (controller *LobbyController) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.InviteRequest{}
+	if err = json.NewDecoder(r.Body).Decode(request); err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	invite, err := lobby.CreateInvite(repos.Invites, repos.Accounts, request, time.Now())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if invite.PlayerID != nil {
+		go notifications.Notify(controller.Repos, *invite.PlayerID, models.InviteNotice(lobby, invite))
+	}
+	u.SimpleRespond(w, invite)
+	return
+}
+
+func (controller *LobbyController) GetInvites(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	invites, err := models.GetInvites(repos.Invites, lobby.ID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, invites)
+	return
+}
+
+func (controller *LobbyController) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	invite, err := lobby.RevokeInvite(repos.Invites, mux.Vars(r)["code"], time.Now())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, invite)
+	return
+}
+
+// joins the lobby of invite's code without its password, body choosing the team is optional
+func (controller *LobbyController) JoinWithInvite(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	playerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.InviteJoinRequest{}
+	if err = json.NewDecoder(r.Body).Decode(request); err != nil && err != io.EOF {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	account, err := models.GetAccountById(repos.Accounts, playerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	lobby, err := models.JoinWithInvite(repos.Invites, repos.Lobbies, repos.Accounts, repos.Statistics, account, mux.Vars(r)["code"], request, time.Now())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if err = lobby.StopWatching(repos.Spectators, playerID); err != nil {
+		u.ApiErrorResponse(w, err)
 		return
 	}
 	lobby.Password = ""
 	u.SimpleRespond(w, lobby)
 	return
 }
+
+// evaluates achievements of the lobby's players in background once its statistics count
+func (controller *LobbyController) awardAchievements(lobby *models.Lobby) {
+	if playerIDs, err := lobby.GetLobbyPlayersIds(); err == nil {
+		go notifications.AwardAchievements(controller.Repos, playerIDs)
+	}
+}
+
+// ids without the player's one, they don't have to be notified of what they did themselves
+func othersThan(playerID uint, ids []uint) []uint {
+	others := []uint{}
+	for _, id := range ids {
+		if id != playerID {
+			others = append(others, id)
+		}
+	}
+	return others
+}
+
+func respondCalendar(w http.ResponseWriter, feed *calendar.Feed) {
+	w.Header().Set("Content-Type", calendar.ContentType)
+	if err := calendar.Write(w, feed); err != nil {
+		log.Println("Got error while responding: " + err.Error())
+	}
+}
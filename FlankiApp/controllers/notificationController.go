@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	u "FlankiRest/utils"
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+type NotificationController struct {
+	Repos *repositories.Repositories
+	logger *logrus.Logger
+}
+
+func NewNotificationController(repos *repositories.Repositories, logger *logrus.Logger) *NotificationController {
+	return &NotificationController{repos, logger}
+}
+
+func (controller *NotificationController) Logger() *logrus.Logger {
+	return controller.logger
+}
+
+// lists the latest notifications of the user, ?unread=true leaves out those already read
+func (controller *NotificationController) GetInbox(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	inbox, err := models.GetInbox(repos.Notifications, userID, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, inbox)
+	return
+}
+
+func (controller *NotificationController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.ReadRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+			return
+		}
+	}
+	marked, err := models.MarkNotificationsRead(repos.Notifications, userID, request)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, map[string]interface{}{"marked": marked})
+	return
+}
+
+func (controller *NotificationController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	preferences, err := models.GetNotificationPreferences(repos.Notifications, userID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, preferences)
+	return
+}
+
+func (controller *NotificationController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	updates := []models.NotificationPreference{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	preferences, err := models.UpdateNotificationPreferences(repos.Notifications, userID, updates)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, preferences)
+	return
+}
+
+// responds with the public VAPID key browsers need to subscribe to Web Push notifications
+func (controller *NotificationController) PushKey(w http.ResponseWriter, r *http.Request) {
+	cfg := config.GetPushConfig()
+	if !cfg.Enabled() {
+		u.ApiErrorResponse(w, errors.New("Push notifications are not enabled", 404))
+		return
+	}
+	u.SimpleRespond(w, map[string]interface{}{"public_key": cfg.VAPIDPublicKey})
+	return
+}
+
+func (controller *NotificationController) SubscribeToPush(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.PushSubscriptionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	subscription, err := models.SubscribeToPush(repos.Notifications, userID, request)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, subscription)
+	return
+}
+
+func (controller *NotificationController) UnsubscribeFromPush(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.PushSubscriptionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil || request.Endpoint == "" {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	if err := models.UnsubscribeFromPush(repos.Notifications, userID, request.Endpoint); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Push subscription has been removed"))
+	return
+}
@@ -1,8 +1,9 @@
 package controllers
 
 import (
-	"FlankiRest/database"
 	"FlankiRest/models"
+	"FlankiRest/notifications"
+	"FlankiRest/repositories"
 	u "FlankiRest/utils"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -11,12 +12,12 @@ import (
 )
 
 type PlayerController struct {
-	DB *database.ApiDatabase
+	Repos *repositories.Repositories
 	logger *logrus.Logger
 }
 
-func NewPlayerController(db *database.ApiDatabase, logger *logrus.Logger) *PlayerController {
-	return &PlayerController{db, logger}
+func NewPlayerController(repos *repositories.Repositories, logger *logrus.Logger) *PlayerController {
+	return &PlayerController{repos, logger}
 }
 
 func (controller *PlayerController) Logger() *logrus.Logger {
@@ -24,8 +25,8 @@ func (controller *PlayerController) Logger() *logrus.Logger {
 }
 
 func (controller *PlayerController) GetAllPlayers(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-	players, err := models.GetAllPlayersFunc(db)
+	repos := controller.Repos.WithContext(r.Context())
+	players, err := models.GetAllPlayersFunc(repos.Accounts)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
@@ -35,23 +36,196 @@ func (controller *PlayerController) GetAllPlayers(w http.ResponseWriter, r *http
 }
 
 func (controller *PlayerController) GetPlayerById(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
-	player, err := models.GetPlayerByIdFunc(db, uint(id))
+	player, err := models.GetPlayerByIdFunc(repos.Accounts, uint(id))
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
 	}
 	response := map[string] interface {} {}
 	response["player"] = player
-	summary, err := models.GetPlayersSummary(db, player.ID)
+	summary, err := models.GetPlayersSummary(repos.Accounts, repos.Statistics, player.ID)
 	if summary != nil {
 		response["summary"] = summary.GetQuickSummary()
 	} else {
 		response["summary"] = nil
 	}
+	scorekeeping, err := models.GetScorekeepingSummary(repos.Events, player.ID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	response["scorekeeping"] = scorekeeping
+	achievements, err := models.GetAchievements(repos.Achievements, player.ID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	response["achievements"] = achievements
 	u.SimpleRespond(w, response)
 	return
 }
 
+
+// id of the user together with id of the player from the path
+func playerIds(r *http.Request) (uint, uint, error) {
+	userID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		return 0, 0, err
+	}
+	otherID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return userID, uint(otherID), nil
+}
+
+// tells the other player about the friend request or its acceptance in background
+func (controller *PlayerController) notifyFriend(r *http.Request, userID uint, otherID uint, friendship *models.Friendship) {
+	user, err := models.GetPlayerByIdFunc(controller.Repos.WithContext(r.Context()).Accounts, userID)
+	if err != nil {
+		return
+	}
+	go notifications.Notify(controller.Repos, otherID, models.FriendshipNotice(friendship, user.Nickname))
+}
+
+func (controller *PlayerController) GetFriends(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	id, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	friends, err := models.GetFriends(repos.Friends, repos.Accounts, repos.Lobbies, id)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, friends)
+	return
+}
+
+func (controller *PlayerController) GetFriendRequests(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	id, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	requests, err := models.GetFriendRequests(repos.Friends, repos.Accounts, id)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, requests)
+	return
+}
+
+func (controller *PlayerController) SendFriendRequest(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, otherID, err := playerIds(r)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	friendship, err := models.SendFriendRequest(repos.Friends, repos.Accounts, userID, otherID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	controller.notifyFriend(r, userID, otherID, friendship)
+	u.SimpleRespond(w, friendship)
+	return
+}
+
+func (controller *PlayerController) AcceptFriendRequest(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, otherID, err := playerIds(r)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	friendship, err := models.AcceptFriendRequest(repos.Friends, userID, otherID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	controller.notifyFriend(r, userID, otherID, friendship)
+	u.SimpleRespond(w, friendship)
+	return
+}
+
+func (controller *PlayerController) DeclineFriendRequest(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, otherID, err := playerIds(r)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if err = models.DeclineFriendRequest(repos.Friends, userID, otherID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Friend request has been declined"))
+	return
+}
+
+func (controller *PlayerController) RemoveFriend(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, otherID, err := playerIds(r)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if err = models.RemoveFriend(repos.Friends, userID, otherID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Friend has been removed"))
+	return
+}
+
+func (controller *PlayerController) GetFollows(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	id, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	follows, err := models.GetFollows(repos.Friends, repos.Accounts, id)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, follows)
+	return
+}
+
+func (controller *PlayerController) FollowPlayer(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, otherID, err := playerIds(r)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if err = models.FollowPlayer(repos.Friends, repos.Accounts, userID, otherID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Player has been followed"))
+	return
+}
+
+func (controller *PlayerController) UnfollowPlayer(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	userID, otherID, err := playerIds(r)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	if err = models.UnfollowPlayer(repos.Friends, userID, otherID); err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, u.TextMessage("Player has been unfollowed"))
+	return
+}
@@ -1,9 +1,9 @@
 package controllers
 
 import (
-	"FlankiRest/database"
 	"FlankiRest/errors"
 	"FlankiRest/models"
+	"FlankiRest/repositories"
 	u "FlankiRest/utils"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -12,12 +12,12 @@ import (
 )
 
 type StatisticsController struct {
-	DB *database.ApiDatabase
+	Repos *repositories.Repositories
 	logger *logrus.Logger
 }
 
-func NewStatisticsController(db *database.ApiDatabase, logger *logrus.Logger) *StatisticsController {
-	return &StatisticsController{db, logger}
+func NewStatisticsController(repos *repositories.Repositories, logger *logrus.Logger) *StatisticsController {
+	return &StatisticsController{repos, logger}
 }
 
 func (controller *StatisticsController) Logger() *logrus.Logger {
@@ -25,14 +25,14 @@ func (controller *StatisticsController) Logger() *logrus.Logger {
 }
 
 func (controller *StatisticsController) GetPlayerSummary(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
+	repos := controller.Repos.WithContext(r.Context())
 	vars := mux.Vars(r)
 	playerID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		u.ApiErrorResponse(w, errors.New("Invalid player id", 400))
 		return
 	}
-	summary, err := models.GetPlayersSummary(db, uint(playerID))
+	summary, err := models.GetPlayersSummary(repos.Accounts, repos.Statistics, uint(playerID))
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 	}
@@ -41,8 +41,8 @@ func (controller *StatisticsController) GetPlayerSummary(w http.ResponseWriter,
 }
 
 func (controller *StatisticsController) GetPlayersRanking(w http.ResponseWriter, r *http.Request) {
-	db := controller.DB.DB()
-	ranking, err := models.GetPlayersRanking(db)
+	repos := controller.Repos.WithContext(r.Context())
+	ranking, err := models.GetPlayersRanking(repos.Statistics)
 	if err != nil {
 		u.ApiErrorResponse(w, err)
 		return
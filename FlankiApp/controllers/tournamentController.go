@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	u "FlankiRest/utils"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+)
+
+type TournamentController struct {
+	Repos *repositories.Repositories
+	logger *logrus.Logger
+}
+
+func NewTournamentController(repos *repositories.Repositories, logger *logrus.Logger) *TournamentController {
+	return &TournamentController{repos, logger}
+}
+
+func (controller *TournamentController) Logger() *logrus.Logger {
+	return controller.logger
+}
+
+func (controller *TournamentController) CreateTournament(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	tournament := &models.Tournament{}
+	err := json.NewDecoder(r.Body).Decode(tournament)
+	if err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	id, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	tournament.OwnerID = id
+
+	err = tournament.Create(repos.Tournaments)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, tournament)
+	return
+}
+
+func (controller *TournamentController) GetAllTournaments(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	tournaments, err := models.GetAllTournaments(repos.Tournaments)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, tournaments)
+	return
+}
+
+func (controller *TournamentController) GetTournament(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	tournament, err := models.GetTournament(repos.Tournaments, uint(id))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, tournament)
+	return
+}
+
+// registers team of the requesting captain
+func (controller *TournamentController) RegisterTeam(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	tournamentID, _ := strconv.Atoi(vars["id"])
+	captainID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	request := &models.TeamRegistration{}
+	err = json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		u.ApiErrorResponse(w, errors.BadJsonRequestFormat)
+		return
+	}
+	tournament, err := models.GetTournament(repos.Tournaments, uint(tournamentID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	team, err := tournament.RegisterTeam(repos.Tournaments, repos.Accounts, captainID, request)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, team)
+	return
+}
+
+// closes registration and generates the bracket, only the owner can do that
+func (controller *TournamentController) StartTournament(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	tournamentID, _ := strconv.Atoi(vars["id"])
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	tournament, err := models.GetTournament(repos.Tournaments, uint(tournamentID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = tournament.Start(repos.Tournaments, repos.Lobbies, repos.Accounts, repos.Statistics, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, tournament)
+	return
+}
+
+// creates lobbies of matches which couldn't get them before
+func (controller *TournamentController) SpawnLobbies(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	tournamentID, _ := strconv.Atoi(vars["id"])
+	ownerID, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	tournament, err := models.GetTournament(repos.Tournaments, uint(tournamentID))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	err = tournament.RetrySpawns(repos.Tournaments, repos.Lobbies, repos.Accounts, ownerID)
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	u.SimpleRespond(w, tournament)
+	return
+}
+
+// bracket of the tournament with standings of its teams
+func (controller *TournamentController) Standings(w http.ResponseWriter, r *http.Request) {
+	repos := controller.Repos.WithContext(r.Context())
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	tournament, err := models.GetTournament(repos.Tournaments, uint(id))
+	if err != nil {
+		u.ApiErrorResponse(w, err)
+		return
+	}
+	response := map[string] interface {} {}
+	response["state"] = tournament.State
+	response["winner_id"] = tournament.WinnerID
+	response["matches"] = tournament.Matches
+	response["standings"] = tournament.Standings()
+	u.SimpleRespond(w, response)
+	return
+}
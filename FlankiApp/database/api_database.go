@@ -1,11 +1,26 @@
 package database
 
-import "github.com/jinzhu/gorm"
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"sync"
+	"time"
+)
+
+// how long result of checking database availability is trusted before pinging it again
+const availabilityTTL = 2 * time.Second
+
+var ErrNotConnected = errors.New("database connection has not been established")
 
 // this struct is just a dummy wrapper for db connection pointer
 // its main goal is to keep db pointer for reconnecting goroutine
 type ApiDatabase struct {
 	db *gorm.DB
+
+	mutex     sync.Mutex
+	checkedAt time.Time
+	pingErr   error
 }
 
 func (apidb *ApiDatabase) DB() *gorm.DB {
@@ -14,10 +29,49 @@ func (apidb *ApiDatabase) DB() *gorm.DB {
 
 func (apidb *ApiDatabase) NewConnection(uri string) (err error) {
 	apidb.db, err = gorm.Open("postgres", uri)
+	apidb.remember(err)
 	return
 }
 
+// Use sets already opened connection, e.g. one made by tests
+func (apidb *ApiDatabase) Use(db *gorm.DB) {
+	apidb.db = db
+	apidb.remember(nil)
+}
+
 func (apidb *ApiDatabase) Close() error {
 	return apidb.db.Close()
 }
 
+// Ping checks whether database can be used right now
+func (apidb *ApiDatabase) Ping(ctx context.Context) error {
+	db := apidb.DB()
+	if db == nil {
+		return ErrNotConnected
+	}
+	err := db.DB().PingContext(ctx)
+	apidb.remember(err)
+	return err
+}
+
+// Available tells whether database could be used recently, it pings database at most once per availabilityTTL
+// so it can be checked before serving every request
+func (apidb *ApiDatabase) Available() bool {
+	apidb.mutex.Lock()
+	fresh := time.Since(apidb.checkedAt) < availabilityTTL
+	err := apidb.pingErr
+	apidb.mutex.Unlock()
+	if fresh {
+		return err == nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return apidb.Ping(ctx) == nil
+}
+
+func (apidb *ApiDatabase) remember(pingErr error) {
+	apidb.mutex.Lock()
+	apidb.checkedAt, apidb.pingErr = time.Now(), pingErr
+	apidb.mutex.Unlock()
+}
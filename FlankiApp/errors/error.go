@@ -3,29 +3,75 @@ package errors
 type ApiError struct {
 	Message string
 	HttpCode int
+
+	// fields of the request which failed validation, empty for other errors
+	Fields []FieldError
+}
+
+// FieldError describes single failed validation rule, Code and Params are meant for machines
+// while Message is filled in the language of the request just before responding
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	Message string                 `json:"message,omitempty"`
 }
 
 func New(message string, code int) error {
-	return &ApiError{message, code}
+	return &ApiError{Message: message, HttpCode: code}
+}
+
+// Invalid returns error listing all fields which failed validation
+func Invalid(fields []FieldError) error {
+	return &ApiError{Message: ValidationFailedMessage, HttpCode: 400, Fields: fields}
 }
 
 func (error *ApiError) Error() string {
-	return error.Message
+	if len(error.Fields) == 0 {
+		return error.Message
+	}
+	message := error.Message + ":"
+	for _, field := range error.Fields {
+		message += " " + field.Field + "(" + field.Code + ")"
+	}
+	return message
 }
 
+const ValidationFailedMessage = "Request contains invalid fields"
 
 var (
-	BadJsonRequestFormat         = &ApiError{"Invalid request, check your json", 400}
-	UnauthorizedAccount          = &ApiError{"Invalid credentials or user doesn't exist", 401}
-	UnauthorizedLobbyJoinRequest = &ApiError{"Invalid lobby password", 401}
-	LobbyIsFull                  = &ApiError{"Lobby is already full", 403}
-	PlayerNotFoundInAnyTeam      = &ApiError{"Player was not a member of any team", 404}
-	PlayerNotActive              = &ApiError{"Player was not present in any active lobby", 401}
-	CryptoError					 = &ApiError{"Cryptography error", 500}
-	InvalidToken				 = &ApiError{"Invalid access token", 401}
-	PlayerNotFound				 = &ApiError{ "Player has not been found", 404}
+	BadJsonRequestFormat         = &ApiError{Message: "Invalid request, check your json", HttpCode: 400}
+	UnauthorizedAccount          = &ApiError{Message: "Invalid credentials or user doesn't exist", HttpCode: 401}
+	UnauthorizedLobbyJoinRequest = &ApiError{Message: "Invalid lobby password", HttpCode: 401}
+	LobbyIsFull                  = &ApiError{Message: "Lobby is already full", HttpCode: 403}
+	TeamIsFull                   = &ApiError{Message: "Team is already full", HttpCode: 403}
+	FriendsOnlyLobby             = &ApiError{Message: "Only friends of the owner can join this lobby", HttpCode: 403}
+	RosterLocked                 = &ApiError{Message: "Teams can't be changed after the match has started", HttpCode: 409}
+	PlayersNotReady              = &ApiError{Message: "Not all players are ready", HttpCode: 409}
+	TeamsNotComplete             = &ApiError{Message: "Both teams need at least one player to start the match", HttpCode: 409}
+	ResultNotPending             = &ApiError{Message: "Result is not waiting for confirmation", HttpCode: 409}
+	NotModerator                 = &ApiError{Message: "Only moderators can settle disputes", HttpCode: 403}
+	NotAdmin                     = &ApiError{Message: "Only admins can do that", HttpCode: 403}
+	NotScorekeeper               = &ApiError{Message: "Only the owner, co-owners or the referee can record match events", HttpCode: 403}
+	TournamentNotFound           = &ApiError{Message: "Tournament has not been found", HttpCode: 404}
+	NotTournamentOwner           = &ApiError{Message: "Only the owner of the tournament can do that", HttpCode: 403}
+	InviteNotFound               = &ApiError{Message: "Invite has not been found", HttpCode: 404}
+	InviteRevoked                = &ApiError{Message: "Invite has been revoked", HttpCode: 410}
+	InviteExpired                = &ApiError{Message: "Invite has expired", HttpCode: 410}
+	InviteUsedUp                 = &ApiError{Message: "Invite has already been used up", HttpCode: 410}
+	PlayerNotFoundInAnyTeam      = &ApiError{Message: "Player was not a member of any team", HttpCode: 404}
+	PlayerNotActive              = &ApiError{Message: "Player was not present in any active lobby", HttpCode: 401}
+	CryptoError                  = &ApiError{Message: "Cryptography error", HttpCode: 500}
+	InvalidToken                 = &ApiError{Message: "Invalid access token", HttpCode: 401}
+	PlayerNotFound               = &ApiError{Message: "Player has not been found", HttpCode: 404}
+	RecordNotFound               = &ApiError{Message: "Record has not been found", HttpCode: 404}
+	DatabaseUnavailable          = &ApiError{Message: "Database is unavailable, try again later", HttpCode: 503}
+	AuthServerUnavailable        = &ApiError{Message: "Authorization server is unavailable, try again later", HttpCode: 503}
+	TooManyRequests              = &ApiError{Message: "Too many requests, try again later", HttpCode: 429}
+	AccountLocked                = &ApiError{Message: "Account is locked after too many failed logins, try again later", HttpCode: 429}
+	AccountErased                = &ApiError{Message: "Account has been erased and can't be restored", HttpCode: 410}
 )
 
 func DatabaseError(err error) error {
 	return New("Database error: " + err.Error(),500)
-}
\ No newline at end of file
+}
@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// time given to every check, checks are run concurrently so readiness is known within this time
+const CheckTimeout = 2 * time.Second
+
+// Check reports why a dependency of the service can't be used, nil when it can
+type Check func(ctx context.Context) error
+
+// Checker runs checks of service's dependencies and serves their results
+type Checker struct {
+	service string
+	started time.Time
+	names   []string
+	checks  map[string]Check
+}
+
+func NewChecker(service string) *Checker {
+	return &Checker{service: service, started: time.Now(), checks: map[string]Check{}}
+}
+
+// Add registers check of named dependency, service is ready only when all of its checks pass
+func (checker *Checker) Add(name string, check Check) {
+	if _, exists := checker.checks[name]; !exists {
+		checker.names = append(checker.names, name)
+	}
+	checker.checks[name] = check
+}
+
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Report of all checks served by the status page
+type Report struct {
+	Service       string                 `json:"service"`
+	Status        string                 `json:"status"`
+	UptimeSeconds int64                  `json:"uptime_seconds"`
+	Checks        map[string]CheckResult `json:"checks"`
+}
+
+// Failing lists names of failed checks in alphabetical order
+func (report *Report) Failing() []string {
+	failing := []string{}
+	for name, result := range report.Checks {
+		if result.Status != StatusOK {
+			failing = append(failing, name)
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// Run runs all checks concurrently, each one with CheckTimeout
+func (checker *Checker) Run(ctx context.Context) *Report {
+	report := &Report{
+		Service:       checker.service,
+		Status:        StatusOK,
+		UptimeSeconds: int64(time.Since(checker.started).Seconds()),
+		Checks:        make(map[string]CheckResult, len(checker.names)),
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range checker.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, CheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := check(checkCtx)
+			result := CheckResult{Status: StatusOK, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status, result.Error = StatusUnavailable, err.Error()
+			}
+			mutex.Lock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = StatusUnavailable
+			}
+			mutex.Unlock()
+		}(name, checker.checks[name])
+	}
+	wg.Wait()
+	return report
+}
+
+// Liveness responds as long as the process is able to serve requests, it doesn't check any dependencies
+func (checker *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// Readiness responds with 503 and names of failing checks when any of them fails
+func (checker *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := checker.Run(r.Context())
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	respond(w, code, map[string]interface{}{"status": report.Status, "failing": report.Failing()})
+}
+
+// StatusPage responds with results of all checks, it succeeds even when some of them fail
+func (checker *Checker) StatusPage(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, checker.Run(r.Context()))
+}
+
+func respond(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+var ErrNotConnected = errors.New("not connected")
+
+// Database pings database returned by db, nil means that there is no connection yet
+func Database(db func() *sql.DB) Check {
+	return func(ctx context.Context) error {
+		conn := db()
+		if conn == nil {
+			return ErrNotConnected
+		}
+		return conn.PingContext(ctx)
+	}
+}
+
+// Reachable checks whether service at url responds, any response other than server error counts,
+// e.g. authorization server responds to GET of its endpoints with 404 or 405
+func Reachable(client *http.Client, url func() string) Check {
+	return func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("responded with %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// Files checks that all files exist and are readable
+func Files(paths func() []string) Check {
+	return func(ctx context.Context) error {
+		for _, path := range paths() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			f.Close()
+		}
+		return nil
+	}
+}
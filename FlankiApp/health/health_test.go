@@ -0,0 +1,128 @@
+package health_test
+
+import (
+	"FlankiRest/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newChecker() *health.Checker {
+	checker := health.NewChecker("test")
+	checker.Add("passing", func(ctx context.Context) error { return nil })
+	checker.Add("failing", func(ctx context.Context) error { return errors.New("broken") })
+	checker.Add("hanging", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	return checker
+}
+
+func TestRunReportsEveryCheck(t *testing.T) {
+	start := time.Now()
+	report := newChecker().Run(context.Background())
+	if time.Since(start) > health.CheckTimeout+time.Second {
+		t.Errorf("checks weren't cut at their timeout")
+	}
+	if report.Status != health.StatusUnavailable {
+		t.Errorf("expected status %s, got %s", health.StatusUnavailable, report.Status)
+	}
+	if report.Checks["passing"].Status != health.StatusOK {
+		t.Errorf("passing check reported as %s", report.Checks["passing"].Status)
+	}
+	if report.Checks["failing"].Error != "broken" {
+		t.Errorf("expected error of failing check, got '%s'", report.Checks["failing"].Error)
+	}
+	if !reflect.DeepEqual(report.Failing(), []string{"failing", "hanging"}) {
+		t.Errorf("unexpected failing checks %v", report.Failing())
+	}
+}
+
+func TestReadinessRespondsWithUnavailable(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newChecker().Readiness(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", recorder.Code)
+	}
+	body := struct {
+		Status  string   `json:"status"`
+		Failing []string `json:"failing"`
+	}{}
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != health.StatusUnavailable || len(body.Failing) != 2 {
+		t.Errorf("unexpected readiness %+v", body)
+	}
+
+	recorder = httptest.NewRecorder()
+	health.NewChecker("test").Readiness(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("service without failing checks should be ready, got %d", recorder.Code)
+	}
+}
+
+func TestStatusPageSucceedsWithFailingChecks(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	checker := health.NewChecker("test")
+	checker.Add("failing", func(ctx context.Context) error { return errors.New("broken") })
+	checker.StatusPage(recorder, httptest.NewRequest("GET", "/status", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", recorder.Code)
+	}
+	report := health.Report{}
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Service != "test" || report.Checks["failing"].Status != health.StatusUnavailable {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	code := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+	check := health.Reachable(http.DefaultClient, func() string { return server.URL })
+
+	if err := check(context.Background()); err != nil {
+		t.Errorf("responding server should be reachable: %s", err)
+	}
+	code = http.StatusBadGateway
+	if err := check(context.Background()); err == nil {
+		t.Errorf("server error should fail the check")
+	}
+	server.Close()
+	if err := check(context.Background()); err == nil {
+		t.Errorf("closed server shouldn't be reachable")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	present := filepath.Join(dir, "template.txt")
+	if err := ioutil.WriteFile(present, []byte("template"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := health.Files(func() []string { return []string{present} })(context.Background()); err != nil {
+		t.Errorf("present file failed the check: %s", err)
+	}
+	if err := health.Files(func() []string { return []string{present, filepath.Join(dir, "missing.txt")} })(context.Background()); err == nil {
+		t.Errorf("missing file should fail the check")
+	}
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/tracing"
+	"context"
+	"sync"
+	"time"
+)
+
+// PostgresStore keeps leases and runs in the app's database so that all instances of the app share them,
+// it always uses current connection kept by ApiDatabase
+type PostgresStore struct {
+	db *database.ApiDatabase
+
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *database.ApiDatabase) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Acquire inserts the lease or takes over the one which has passed in a single statement,
+// of instances racing for the same lease only one gets the row updated
+func (store *PostgresStore) Acquire(ctx context.Context, job string, instance string, now time.Time, until time.Time) (bool, error) {
+	db := tracing.WithContext(store.db.DB(), ctx)
+	if db == nil {
+		return false, database.ErrNotConnected
+	}
+	result := db.Exec(`INSERT INTO job_leases (job, instance, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (job) DO UPDATE SET instance = EXCLUDED.instance, expires_at = EXCLUDED.expires_at
+		WHERE job_leases.instance = EXCLUDED.instance OR job_leases.expires_at <= ?`, job, instance, until, now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (store *PostgresStore) Record(ctx context.Context, run *Run) error {
+	db := tracing.WithContext(store.db.DB(), ctx)
+	if db == nil {
+		return database.ErrNotConnected
+	}
+	if err := db.Create(run).Error; err != nil {
+		return err
+	}
+	store.sweep(ctx, run.StartedAt)
+	return nil
+}
+
+func (store *PostgresStore) Runs(ctx context.Context, job string, failed bool, limit int) ([]Run, error) {
+	db := tracing.WithContext(store.db.DB(), ctx)
+	if db == nil {
+		return nil, database.ErrNotConnected
+	}
+	query := db.Where("job = ?", job)
+	if failed {
+		query = query.Where("error <> ''")
+	}
+	runs := []Run{}
+	err := query.Order("started_at desc, id desc").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// sweep deletes old runs at most once an hour, failing to do so doesn't matter for the run
+func (store *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	store.mutex.Lock()
+	if now.Sub(store.lastSweep) < time.Hour {
+		store.mutex.Unlock()
+		return
+	}
+	store.lastSweep = now
+	store.mutex.Unlock()
+	if db := tracing.WithContext(store.db.DB(), ctx); db != nil {
+		db.Where("started_at < ?", now.Add(-runRetention)).Delete(&Run{})
+	}
+}
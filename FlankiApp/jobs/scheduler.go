@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/metrics"
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+// how many recent runs of every job are shown in its status
+const statusRuns = 10
+
+// Job is run by a single instance of the app every interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+
+	// Run does the job and describes what has been done, empty result means there was nothing to do
+	Run func(ctx context.Context, now time.Time) (string, error)
+}
+
+// Status of a job with its recent runs
+type Status struct {
+	Name        string `json:"name"`
+	Interval    string `json:"interval"`
+	LastRun     *Run   `json:"last_run"`
+	LastFailure *Run   `json:"last_failure"`
+	Runs        []Run  `json:"runs"`
+}
+
+// Scheduler runs jobs on every instance of the app, the store makes sure only one of them does each run
+type Scheduler struct {
+	store    Store
+	instance string
+
+	mutex sync.Mutex
+	jobs  []Job
+}
+
+func NewScheduler(store Store, instance string) *Scheduler {
+	return &Scheduler{store: store, instance: instance}
+}
+
+// Instance identifies this process among instances of the app
+func Instance() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+func (scheduler *Scheduler) Add(job Job) {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	scheduler.jobs = append(scheduler.jobs, job)
+}
+
+func (scheduler *Scheduler) Jobs() []Job {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	return append([]Job{}, scheduler.jobs...)
+}
+
+// Start runs every job in its own goroutine until stop is closed
+func (scheduler *Scheduler) Start(stop <-chan struct{}, log *logrus.Entry) {
+	for _, job := range scheduler.Jobs() {
+		go scheduler.loop(job, stop, log.WithField("job", job.Name))
+	}
+}
+
+func (scheduler *Scheduler) loop(job Job, stop <-chan struct{}, log *logrus.Entry) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			run, err := scheduler.Run(context.Background(), job, now)
+			if err != nil {
+				log.Error("Error while scheduling job: ", err.Error())
+			} else if run != nil && run.Failed() {
+				log.Error("Job has failed: ", run.Error)
+			} else if run != nil && run.Result != "" {
+				log.Info(run.Result)
+			}
+		}
+	}
+}
+
+// Run does the job if this instance gets its lease, the lease lasts until the job's next run so the instance
+// which got it keeps running the job until it stops. Returned run is nil when another instance holds the lease
+// or the database isn't connected
+func (scheduler *Scheduler) Run(ctx context.Context, job Job, now time.Time) (*Run, error) {
+	acquired, err := scheduler.store.Acquire(ctx, job.Name, scheduler.instance, now, now.Add(job.Interval))
+	if err == database.ErrNotConnected {
+		return nil, nil
+	}
+	if err != nil || !acquired {
+		return nil, err
+	}
+	run := &Run{Job: job.Name, Instance: scheduler.instance, StartedAt: now}
+	run.Result, err = runJob(ctx, job, now)
+	run.FinishedAt = time.Now()
+	result := "ok"
+	if err != nil {
+		run.Error = err.Error()
+		result = "error"
+	}
+	metrics.JobRuns.WithLabelValues(job.Name, result).Inc()
+	if err := scheduler.store.Record(ctx, run); err != nil {
+		return run, err
+	}
+	return run, nil
+}
+
+// runJob turns job's panic into an error so that it is recorded and doesn't stop the app
+func runJob(ctx context.Context, job Job, now time.Time) (result string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return job.Run(ctx, now)
+}
+
+// Status of every job in the order they were added
+func (scheduler *Scheduler) Status(ctx context.Context) ([]Status, error) {
+	statuses := []Status{}
+	for _, job := range scheduler.Jobs() {
+		runs, err := scheduler.store.Runs(ctx, job.Name, false, statusRuns)
+		if err != nil {
+			return nil, err
+		}
+		failures, err := scheduler.store.Runs(ctx, job.Name, true, 1)
+		if err != nil {
+			return nil, err
+		}
+		status := Status{Name: job.Name, Interval: job.Interval.String(), Runs: runs}
+		if len(runs) > 0 {
+			status.LastRun = &runs[0]
+		}
+		if len(failures) > 0 {
+			status.LastFailure = &failures[0]
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
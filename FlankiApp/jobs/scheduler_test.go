@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOnlyInstanceHoldingLeaseRunsJob(t *testing.T) {
+	store := NewMemoryStore()
+	first, second := NewScheduler(store, "first"), NewScheduler(store, "second")
+	runs := 0
+	job := Job{Name: "count", Interval: time.Minute, Run: func(ctx context.Context, now time.Time) (string, error) {
+		runs++
+		return "", nil
+	}}
+	start := time.Now()
+
+	if run, err := first.Run(context.Background(), job, start); err != nil || run == nil {
+		t.Fatalf("first instance should get the lease, got %v, %v", run, err)
+	}
+	if run, err := second.Run(context.Background(), job, start.Add(30*time.Second)); err != nil || run != nil {
+		t.Fatalf("second instance shouldn't run the job while the lease lasts, got %v, %v", run, err)
+	}
+	if run, _ := first.Run(context.Background(), job, start.Add(time.Minute)); run == nil {
+		t.Fatalf("instance holding the lease should renew it")
+	}
+	// the first instance has stopped, its lease passes
+	if run, _ := second.Run(context.Background(), job, start.Add(3*time.Minute)); run == nil || run.Instance != "second" {
+		t.Fatalf("second instance should take over the passed lease, got %v", run)
+	}
+	if runs != 3 {
+		t.Errorf("expected 3 runs, got %d", runs)
+	}
+}
+
+func TestFailuresAreRecorded(t *testing.T) {
+	scheduler := NewScheduler(NewMemoryStore(), "instance")
+	failing := true
+	scheduler.Add(Job{Name: "flaky", Interval: time.Minute, Run: func(ctx context.Context, now time.Time) (string, error) {
+		if failing {
+			return "", errors.New("broken")
+		}
+		return "done", nil
+	}})
+	scheduler.Add(Job{Name: "panicking", Interval: time.Minute, Run: func(ctx context.Context, now time.Time) (string, error) {
+		panic("oops")
+	}})
+	start := time.Now()
+	for i, job := range scheduler.Jobs() {
+		if _, err := scheduler.Run(context.Background(), job, start); err != nil {
+			t.Fatalf("running job %d: %v", i, err)
+		}
+	}
+	failing = false
+	scheduler.Run(context.Background(), scheduler.Jobs()[0], start.Add(time.Minute))
+
+	statuses, err := scheduler.Status(context.Background())
+	if err != nil || len(statuses) != 2 {
+		t.Fatalf("expected status of 2 jobs, got %v, %v", statuses, err)
+	}
+	flaky := statuses[0]
+	if len(flaky.Runs) != 2 || flaky.LastRun.Result != "done" || flaky.LastFailure == nil || flaky.LastFailure.Error != "broken" {
+		t.Errorf("unexpected status of flaky job: %+v", flaky)
+	}
+	if panicking := statuses[1]; panicking.LastFailure == nil || panicking.LastFailure.Error != "panic: oops" {
+		t.Errorf("panic should be recorded as failure, got %+v", panicking)
+	}
+}
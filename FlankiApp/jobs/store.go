@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// runs are kept for a week, long enough to notice a job which has been failing for a while
+const runRetention = 7 * 24 * time.Hour
+
+// Lease lets a single instance of the app run the job until it passes
+type Lease struct {
+	Job       string `gorm:"primary_key"`
+	Instance  string
+	ExpiresAt time.Time
+}
+
+func (Lease) TableName() string {
+	return "job_leases"
+}
+
+// Run of a job by one of the instances, failed runs have their error
+type Run struct {
+	ID         uint      `json:"-" gorm:"primary_key"`
+	Job        string    `json:"job"`
+	Instance   string    `json:"instance"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Result     string    `json:"result,omitempty"` // what the job has done, empty when there was nothing to do
+	Error      string    `json:"error,omitempty"`
+}
+
+func (Run) TableName() string {
+	return "job_runs"
+}
+
+func (run *Run) Failed() bool {
+	return run.Error != ""
+}
+
+// Store keeps leases and runs of jobs, acquiring a lease has to be atomic so that two instances can't run the same job
+type Store interface {
+	// Acquire leases the job to the instance until given time, unless another instance holds a lease which hasn't passed by now.
+	// The instance holding the lease can always renew it
+	Acquire(ctx context.Context, job string, instance string, now time.Time, until time.Time) (bool, error)
+
+	// Record saves the finished run, runs older than runRetention are dropped
+	Record(ctx context.Context, run *Run) error
+
+	// Runs of the job from the newest one, only failed ones when failed is set
+	Runs(ctx context.Context, job string, failed bool, limit int) ([]Run, error)
+}
+
+// MemoryStore keeps jobs of a single instance of the app
+type MemoryStore struct {
+	mutex  sync.Mutex
+	lastID uint
+	leases map[string]Lease
+	runs   []Run
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{leases: map[string]Lease{}}
+}
+
+func (store *MemoryStore) Acquire(ctx context.Context, job string, instance string, now time.Time, until time.Time) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if lease, ok := store.leases[job]; ok && lease.Instance != instance && lease.ExpiresAt.After(now) {
+		return false, nil
+	}
+	store.leases[job] = Lease{Job: job, Instance: instance, ExpiresAt: until}
+	return true, nil
+}
+
+func (store *MemoryStore) Record(ctx context.Context, run *Run) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.lastID++
+	run.ID = store.lastID
+	kept := store.runs[:0]
+	for _, recorded := range store.runs {
+		if recorded.StartedAt.After(run.StartedAt.Add(-runRetention)) {
+			kept = append(kept, recorded)
+		}
+	}
+	store.runs = append(kept, *run)
+	return nil
+}
+
+func (store *MemoryStore) Runs(ctx context.Context, job string, failed bool, limit int) ([]Run, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	runs := []Run{}
+	for _, run := range store.runs {
+		if run.Job == job && (!failed || run.Failed()) {
+			runs = append(runs, run)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
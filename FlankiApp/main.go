@@ -4,6 +4,9 @@ import (
 	"FlankiRest/app"
 	"FlankiRest/config"
 	"FlankiRest/logger"
+	"FlankiRest/tracing"
+	"context"
+	"flag"
 	"golang.org/x/oauth2"
 	"log"
 	"os"
@@ -12,11 +15,26 @@ import (
 
 func main() {
 
-	appConfig := config.GetAppConfig()
-	authConfig := config.GetAuthServerConfig()
+	appConfig, err := config.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if appConfig != nil && appConfig.PrintConfig {
+		if printErr := appConfig.Print(os.Stdout); printErr != nil {
+			log.Fatal(printErr)
+		}
+		if err == nil {
+			return
+		}
+	}
+	if err != nil {
+		logger.GetGlobalLogger().WithField("prefix", "[CONFIG]").Fatal(err)
+	}
+
+	authConfig := appConfig.Auth
 	cfg := &oauth2.Config{
-		ClientID:     "8245d6e94963dcf75fd285958721341e",
-		ClientSecret: "e9943005b6c456250d3b771783b941af",
+		ClientID:     authConfig.Client.ID,
+		ClientSecret: authConfig.Client.Secret,
 		Scopes:       []string{"all"},
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authConfig.Domain + ":" + authConfig.Port + "/authorize",
@@ -24,15 +42,19 @@ func main() {
 		},
 	}
 
+	shutdownTracing, err := tracing.Setup("flanki-app")
+	if err != nil {
+		log.Fatal("Couldn't set up tracing: ", err)
+	}
+
 	application := app.NewApp(cfg)
 	application.SetLogger(logger.GetGlobalLogger())
-	application.Initialize(appConfig)
+	application.Initialize(config.GetAppConfig())
 
-	enableSSL := false
-	if val, ok := os.LookupEnv("ENABLE_SSL"); ok {
-		if val == "true" {
-			enableSSL = true
-		}
+	err = application.Run(appConfig.App.EnableSSL)	// application server, returns after shutting down on SIGTERM
+	shutdownTracing(context.Background())		// flush spans of last requests, log.Fatal skips deferred calls
+	if err != nil {
+		log.Fatal(err)
 	}
-	log.Fatal(application.Run(enableSSL))			// application server
-}
\ No newline at end of file
+	application.Logger.Info("Application has been shut down")
+}
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	namespace = "flanki"
+	subsystem = "app"
+)
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name: "http_requests_total",
+		Help: "Number of served requests by route template, method and status",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name:    "http_request_duration_seconds",
+		Help:    "Time of serving requests by route template, method and status",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// AuthRequestDuration measures round trips to authorization server, result is "ok", "unauthorized" or "error"
+	AuthRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name:    "auth_request_duration_seconds",
+		Help:    "Time of validating access tokens with authorization server",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"result"})
+
+	LobbiesCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name: "lobbies_created_total",
+		Help: "Number of created lobbies",
+	})
+
+	MatchesSubmitted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name: "matches_submitted_total",
+		Help: "Number of matches with submitted results by winning team",
+	}, []string{"winner"})
+
+	// MatchDuration measures time from starting the match to submitting its results
+	MatchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name:    "match_duration_seconds",
+		Help:    "Duration of matches from their start to submitting results",
+		Buckets: []float64{60, 300, 600, 900, 1200, 1800, 2700, 3600, 5400, 7200},
+	})
+
+	// EmailsSent counts emails by their kind and result, "ok" or "error"
+	EmailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name: "emails_sent_total",
+		Help: "Number of emails sent by kind and result",
+	}, []string{"kind", "result"})
+
+	// RateLimited counts throttled requests by route and by key they were throttled by, "ip" or "account"
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name: "rate_limited_requests_total",
+		Help: "Number of requests rejected by rate limits",
+	}, []string{"route", "key"})
+
+	// JobRuns counts runs of background jobs by job and result, "ok" or "error"
+	JobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: subsystem,
+		Name: "job_runs_total",
+		Help: "Number of background job runs by job and result",
+	}, []string{"job", "result"})
+
+	database = &databaseSource{}
+)
+
+// Registry contains all app's metrics, it is separate from prometheus' default one
+// so that services running in the same process (e.g. in integration tests) don't mix their metrics
+var Registry = newRegistry()
+
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		requests, requestDuration, AuthRequestDuration,
+		LobbiesCreated, MatchesSubmitted, MatchDuration, EmailsSent, RateLimited, JobRuns,
+	)
+	registerDatabaseStats(registry, database.stats)
+	return registry
+}
+
+// Handler serves metrics in prometheus' text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// SetDatabase sets function returning current connection pool, pool stats are read from it on every scrape
+// because the connection is replaced when the app reconnects to the database
+func SetDatabase(db func() *sql.DB) {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+	database.db = db
+}
+
+type databaseSource struct {
+	db    func() *sql.DB
+	mutex sync.Mutex
+}
+
+func (source *databaseSource) stats() sql.DBStats {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if source.db == nil {
+		return sql.DBStats{}
+	}
+	if db := source.db(); db != nil {
+		return db.Stats()
+	}
+	return sql.DBStats{}
+}
+
+func registerDatabaseStats(registry *prometheus.Registry, stats func() sql.DBStats) {
+	gauge := func(name string, help string, value func(s sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help},
+			func() float64 { return value(stats()) })
+	}
+	counter := func(name string, help string, value func(s sql.DBStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help},
+			func() float64 { return value(stats()) })
+	}
+	registry.MustRegister(
+		gauge("db_max_open_connections", "Maximum number of open connections to the database",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("db_open_connections", "Number of established connections both in use and idle",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("db_in_use_connections", "Number of connections currently in use",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("db_idle_connections", "Number of idle connections",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("db_wait_count_total", "Number of connections waited for",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("db_wait_duration_seconds_total", "Time spent waiting for new connections",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+	)
+}
+
+// RequestMetricsMiddleware counts served requests and measures their time, requests are labeled with route's
+// template instead of the path so that ids don't create new series
+func RequestMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		status := strconv.Itoa(recorder.status)
+		requests.WithLabelValues(route, r.Method, status).Inc()
+		requestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(code int) {
+	recorder.status = code
+	recorder.ResponseWriter.WriteHeader(code)
+}
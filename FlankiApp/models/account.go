@@ -2,15 +2,14 @@ package models
 
 import (
 	"FlankiRest/errors"
+	"FlankiRest/validation"
 	"encoding/json"
 	"fmt"
-	"github.com/badoux/checkmail"
 	"github.com/evanphx/json-patch"
 	"reflect"
 	"time"
 
 	//"strings"
-	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -24,20 +23,24 @@ type AccountModel struct {
 
 type Account struct {
 	AccountModel
-	Nickname    string `json:"nickname"`
-	Email       string `json:"email"`
-	Password    string `json:"password,omitempty"`
-	Sex         string `json:"sex"`
-	Description string `json:"description"`
+	Nickname    string `json:"nickname" validate:"min=4,max=20"`
+	Email       string `json:"email" validate:"email"`
+	Password    string `json:"password,omitempty" validate:"min=6,max=32"`
+	Sex         string `json:"sex" validate:"oneof=male female"`
+	Description string `json:"description" validate:"max=200"`
 	Playing     bool   `json:"playing"`
+	// secret part of the address of player's calendar feed, calendar apps can't authorize themselves
+	CalendarToken string `json:"-"`
+	// set only on tombstones, deleted accounts which keep statistics and match history of erased ones
+	ErasedAt *time.Time `json:"-"`
 }
 
 type UpdateAccount struct {
 	ID          uint
-	Nickname    string          `json:"nickname,omitempty"`
-	Email       string          `json:"email,omitempty"`
-	Password    string          `json:"password,omitempty"`
-	Sex         string          `json:"sex,omitempty"`
+	Nickname    string          `json:"nickname,omitempty" validate:"omitempty,min=4,max=20"`
+	Email       string          `json:"email,omitempty" validate:"omitempty,email"`
+	Password    string          `json:"password,omitempty" validate:"omitempty,min=6,max=32"`
+	Sex         string          `json:"sex,omitempty" validate:"omitempty,oneof=male female"`
 	Description json.RawMessage `json:"description,omitempty"` // to enable updating description to be empty again json has to distinguish between empty string vs non existent, here empty string is of length 2 and non-set is 0
 }
 
@@ -47,68 +50,49 @@ func (m UpdateAccount ) IsEmpty() bool {
 }
 
 func (account *Account) ValidateFieldsRequirements() error {
-
-	if size := len(account.Nickname); size < 4 || size > 20 {
-		return errors.New("Wrong nickname size, should be from 4 to 20 letters", 400)
-	}
-
-	if account.Sex != "male" && account.Sex != "female" {
-		return errors.New("Unknown sex, should be either male or female", 400)
-	}
-
-	if err := checkmail.ValidateFormat(account.Email); err != nil {
-		return errors.New("Incorrect email format", 400)
-	}
-
-	if size := len(account.Password); size < 6 || size > 32 {
-		if size < 6 {
-			return errors.New("Password is too short(6 to 32 signs)", 400)
-		} else {
-			return errors.New("Password is too long(6 to 32 signs)", 400)
-		}
-	}
-
-	if len(account.Description) > 200 {
-		return errors.New("Description is too long, up to signs", 400)
-	}
-	return nil
+	return validation.Check(account)
 }
 
-func IsAccountFieldUnique(db *gorm.DB, fieldName string, fieldValue string) error {
-	var count int
-	account := &Account{}
-	err := db.Model(&account).Where(fmt.Sprintf("%s = ?", fieldName), fieldValue).Count(&count).Error
+func IsAccountFieldUnique(accounts AccountRepository, fieldName string, fieldValue string) error {
+	count, err := accounts.CountByField(fieldName, fieldValue)
 	if err != nil {
 		return errors.New(fmt.Sprintf("database connection error: %s", err.Error()), 500)
 	}
-	if count != 0 {
-		return errors.New(fmt.Sprintf("Field '%s' is not unique", fieldName), 400)
+	// nobody can pretend to be one of erased players
+	if count != 0 || fieldName == "nickname" && fieldValue == ErasedNickname {
+		return validation.Merge(validation.Field(fieldName, validation.NotUnique, nil))
 	}
 	return nil
 }
 
-func (account *Account) Validate(db *gorm.DB) error {
+// Validate reports all invalid fields together, uniqueness is checked only for otherwise valid fields
+func (account *Account) Validate(accounts AccountRepository) error {
 
-	err := account.ValidateFieldsRequirements()
-	if err != nil {
-		return err
+	fields := validation.Fields(account)
+	invalid := map[string]bool{}
+	for _, field := range fields {
+		invalid[field.Field] = true
 	}
 
-	err = IsAccountFieldUnique(db,"nickname", account.Nickname)
-	if err != nil {
-		return err
+	var err error
+	if !invalid["nickname"] {
+		fields, err = validation.Append(fields, IsAccountFieldUnique(accounts, "nickname", account.Nickname))
+		if err != nil {
+			return err
+		}
 	}
-
-	err= IsAccountFieldUnique(db,"email", account.Email)
-	if err != nil {
-		return err
+	if !invalid["email"] {
+		fields, err = validation.Append(fields, IsAccountFieldUnique(accounts, "email", account.Email))
+		if err != nil {
+			return err
+		}
 	}
-	return nil
+	return validation.Merge(fields...)
 }
 
-func (account *Account) Create(db *gorm.DB) error {
+func (account *Account) Create(accounts AccountRepository) error {
 
-	err := account.Validate(db)
+	err := account.Validate(accounts)
 	if err != nil {
 		return err
 	}
@@ -116,15 +100,16 @@ func (account *Account) Create(db *gorm.DB) error {
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(account.Password), bcrypt.DefaultCost)
 	account.Password = string(hashedPassword)
 
-	db.Create(&account)
-	if account.ID <= 0 {
+	err = accounts.Create(account)
+	if err != nil || account.ID <= 0 {
 		return errors.New("Failed to create account, connection error.", 500)
 	}
 	return nil
 }
 
-func (account *Account) Delete(db *gorm.DB) error {
-	err := db.Delete(account).Error
+// Delete soft deletes the account, it can be restored until it is erased after the grace period
+func (account *Account) Delete(accounts AccountRepository) error {
+	err := accounts.Delete(account)
 	if err != nil {
 		return errors.New("Encountered error in database while trying to delete account", 500)
 	}
@@ -142,25 +127,31 @@ func (account *Account) Delete(db *gorm.DB) error {
 
  */
 
-func (toUpdate *UpdateAccount) Update(db *gorm.DB) error {
+func (toUpdate *UpdateAccount) Update(accounts AccountRepository) error {
 
-	account := &Account{}
-	err := db.First(&account, toUpdate.ID).Error
-	if err == gorm.ErrRecordNotFound {
+	account, err := accounts.GetById(toUpdate.ID)
+	if err == errors.RecordNotFound {
 		return errors.New("Account has not been found", 400)
 	}
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	fields := validation.Fields(toUpdate)
 	if toUpdate.Nickname != "" && toUpdate.Nickname != account.Nickname {
-		err = IsAccountFieldUnique(db,"nickname", toUpdate.Nickname)
+		fields, err = validation.Append(fields, IsAccountFieldUnique(accounts,"nickname", toUpdate.Nickname))
 		if err != nil {
 			return err
 		}
 	}
 	if toUpdate.Email != "" && toUpdate.Email != account.Email {
-		err = IsAccountFieldUnique(db,"email", toUpdate.Email)
+		fields, err = validation.Append(fields, IsAccountFieldUnique(accounts,"email", toUpdate.Email))
 		if err != nil {
 			return err
 		}
 	}
+	if err = validation.Merge(fields...); err != nil {
+		return err
+	}
 	if toUpdate.Password != "" {
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(toUpdate.Password), bcrypt.DefaultCost)
 		toUpdate.Password = string(hashedPassword)
@@ -182,16 +173,18 @@ func (toUpdate *UpdateAccount) Update(db *gorm.DB) error {
 		return err
 	}
 	account.Password = hash
-	db.Save(account)
+	err = accounts.Save(account)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
 
 	return nil
 }
 
-func GetAccountById(db *gorm.DB, id uint) (*Account, error) {
-	account := &Account{}
-	err := db.Where("id = ?", id).First(&account).Error
+func GetAccountById(accounts AccountRepository, id uint) (*Account, error) {
+	account, err := accounts.GetById(id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if err == errors.RecordNotFound {
 			err = errors.New("Account has not been found", 400)
 		} else {
 			err = errors.New(fmt.Sprintf("Database error: %s", err.Error()), 500)
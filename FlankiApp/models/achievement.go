@@ -0,0 +1,203 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"time"
+)
+
+type Badge string
+
+const (
+	FirstWinBadge  Badge = "first_win"
+	WinStreakBadge Badge = "win_streak"
+	RegularBadge   Badge = "five_lobbies"
+	ChampionBadge  Badge = "tournament_champion"
+)
+
+const (
+	// wins in a row earning WinStreakBadge
+	winStreakLength = 10
+	// different lobbies the player has to play in to earn RegularBadge
+	regularLobbies = 5
+)
+
+// Achievement is a badge awarded to the player, every badge is awarded once and never taken back
+type Achievement struct {
+	ID           uint      `json:"-" gorm:"primary_key"`
+	CreatedAt    time.Time `json:"awarded_at"`
+	PlayerID     uint      `json:"-"`
+	Badge        Badge     `json:"badge"`
+	Title        string    `json:"title" gorm:"-"`
+	Description  string    `json:"description" gorm:"-"`
+	LobbyID      *uint     `json:"lobby_id,omitempty"`      // match which earned the badge
+	TournamentID *uint     `json:"tournament_id,omitempty"` // tournament which earned the badge
+}
+
+// PlayerRecord is what achievement rules are evaluated against
+type PlayerRecord struct {
+	PlayerID uint
+	// counted matches of the player, the oldest first
+	Matches []PlayerStatisticsEntry
+	// finished tournaments won by a team of the player
+	Championships []*Tournament
+}
+
+// AchievementRule awards its badge when Earned returns an achievement, which tells the match or tournament that earned it
+type AchievementRule struct {
+	Badge       Badge
+	Title       string
+	Description string
+	Earned      func(record *PlayerRecord) *Achievement
+}
+
+// AchievementRules are evaluated in this order, new badges need only a rule added here
+var AchievementRules = []AchievementRule{
+	{Badge: FirstWinBadge, Title: "First win", Description: "Won a match for the first time", Earned: firstWin},
+	{Badge: WinStreakBadge, Title: "Unstoppable", Description: "Won 10 matches in a row", Earned: winStreak},
+	{Badge: RegularBadge, Title: "Regular", Description: "Played in 5 different lobbies", Earned: regular},
+	{Badge: ChampionBadge, Title: "Tournament champion", Description: "Won a tournament", Earned: champion},
+}
+
+func firstWin(record *PlayerRecord) *Achievement {
+	for _, match := range record.Matches {
+		if match.Win {
+			return matchAchievement(match)
+		}
+	}
+	return nil
+}
+
+func winStreak(record *PlayerRecord) *Achievement {
+	streak := 0
+	for _, match := range record.Matches {
+		if !match.Win {
+			streak = 0
+			continue
+		}
+		if streak++; streak == winStreakLength {
+			return matchAchievement(match)
+		}
+	}
+	return nil
+}
+
+func regular(record *PlayerRecord) *Achievement {
+	lobbies := map[uint]bool{}
+	for _, match := range record.Matches {
+		lobbies[match.LobbyId] = true
+		if len(lobbies) == regularLobbies {
+			return matchAchievement(match)
+		}
+	}
+	return nil
+}
+
+func champion(record *PlayerRecord) *Achievement {
+	if len(record.Championships) == 0 {
+		return nil
+	}
+	id := record.Championships[0].ID
+	return &Achievement{TournamentID: &id}
+}
+
+func matchAchievement(match PlayerStatisticsEntry) *Achievement {
+	id := match.LobbyId
+	return &Achievement{LobbyID: &id}
+}
+
+func achievementRule(badge Badge) *AchievementRule {
+	for i := range AchievementRules {
+		if AchievementRules[i].Badge == badge {
+			return &AchievementRules[i]
+		}
+	}
+	return nil
+}
+
+// fills title and description of the achievement's badge
+func (achievement *Achievement) describe() {
+	if rule := achievementRule(achievement.Badge); rule != nil {
+		achievement.Title, achievement.Description = rule.Title, rule.Description
+	}
+}
+
+// GetAchievements lists badges of the player in order they have been awarded
+func GetAchievements(achievements AchievementRepository, playerID uint) ([]Achievement, error) {
+	list, err := achievements.GetByPlayer(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	for i := range list {
+		list[i].describe()
+	}
+	return list, nil
+}
+
+func getPlayerRecord(statistics StatisticsRepository, tournaments TournamentRepository, playerID uint) (*PlayerRecord, error) {
+	matches, err := statistics.GetByPlayer(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	championships, err := tournaments.GetWonBy(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return &PlayerRecord{PlayerID: playerID, Matches: matches, Championships: championships}, nil
+}
+
+// EvaluateAchievements awards the player badges earned by their record which they don't have yet,
+// evaluating it again doesn't award anything twice. Returns newly awarded achievements
+func EvaluateAchievements(achievements AchievementRepository, statistics StatisticsRepository, tournaments TournamentRepository, playerID uint) ([]Achievement, error) {
+	record, err := getPlayerRecord(statistics, tournaments, playerID)
+	if err != nil {
+		return nil, err
+	}
+	held, err := achievements.GetByPlayer(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	hasBadge := map[Badge]bool{}
+	for _, achievement := range held {
+		hasBadge[achievement.Badge] = true
+	}
+
+	awarded := []Achievement{}
+	for _, rule := range AchievementRules {
+		if hasBadge[rule.Badge] {
+			continue
+		}
+		achievement := rule.Earned(record)
+		if achievement == nil {
+			continue
+		}
+		achievement.PlayerID, achievement.Badge = playerID, rule.Badge
+		created, err := achievements.Award(achievement)
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		// the badge could have been awarded in the meantime by evaluation of another match
+		if created {
+			achievement.describe()
+			awarded = append(awarded, *achievement)
+		}
+	}
+	return awarded, nil
+}
+
+// BackfillAchievements evaluates records of all players, so that badges are awarded for matches played before
+// the rules existed. Returns number of awarded achievements
+func BackfillAchievements(achievements AchievementRepository, accounts AccountRepository, statistics StatisticsRepository, tournaments TournamentRepository) (int, error) {
+	players, err := accounts.GetAllPlayers()
+	if err != nil {
+		return 0, errors.DatabaseError(err)
+	}
+	awarded := 0
+	for _, player := range players {
+		list, err := EvaluateAchievements(achievements, statistics, tournaments, player.ID)
+		if err != nil {
+			return awarded, err
+		}
+		awarded += len(list)
+	}
+	return awarded, nil
+}
@@ -0,0 +1,101 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+)
+
+// records match of the player in the lobby without playing it
+func played(t *testing.T, repos *repositories.Repositories, player *models.Account, lobbyID uint, win bool) {
+	t.Helper()
+	if err := repos.Statistics.Create(&models.PlayerStatisticsEntry{PlayerID: player.ID, LobbyId: lobbyID, Win: win, Points: 10}); err != nil {
+		t.Fatalf("creating statistics entry: %v", err)
+	}
+}
+
+func evaluate(t *testing.T, repos *repositories.Repositories, player *models.Account) map[models.Badge]models.Achievement {
+	t.Helper()
+	awarded, err := models.EvaluateAchievements(repos.Achievements, repos.Statistics, repos.Tournaments, player.ID)
+	if err != nil {
+		t.Fatalf("evaluating achievements: %v", err)
+	}
+	badges := map[models.Badge]models.Achievement{}
+	for _, achievement := range awarded {
+		badges[achievement.Badge] = achievement
+	}
+	return badges
+}
+
+func TestBadgesAreAwardedOnce(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+
+	played(t, repos, player, 1, false)
+	if badges := evaluate(t, repos, player); len(badges) != 0 {
+		t.Fatalf("expected no badges after a loss, got %+v", badges)
+	}
+	// nine wins in a row spread over lobbies 2-5 and 2 again, then the streak breaks
+	for i := uint(0); i < 9; i++ {
+		played(t, repos, player, 2+i%4, true)
+	}
+	played(t, repos, player, 3, false)
+	badges := evaluate(t, repos, player)
+	if first, ok := badges[models.FirstWinBadge]; !ok || *first.LobbyID != 2 || first.Title == "" {
+		t.Errorf("expected first win in lobby 2, got %+v", badges)
+	}
+	if regular, ok := badges[models.RegularBadge]; !ok || *regular.LobbyID != 5 {
+		t.Errorf("expected fifth lobby to earn the badge, got %+v", badges)
+	}
+	if _, ok := badges[models.WinStreakBadge]; ok {
+		t.Errorf("expected nine wins not to be a streak")
+	}
+	if badges := evaluate(t, repos, player); len(badges) != 0 {
+		t.Fatalf("expected badges to be awarded once, got %+v", badges)
+	}
+
+	for i := uint(0); i < 10; i++ {
+		played(t, repos, player, 6+i, true)
+	}
+	if streak, ok := evaluate(t, repos, player)[models.WinStreakBadge]; !ok || *streak.LobbyID != 15 {
+		t.Errorf("expected tenth win in a row to earn the streak, got %+v", streak)
+	}
+	achievements, err := models.GetAchievements(repos.Achievements, player.ID)
+	if err != nil || len(achievements) != 3 || achievements[0].Badge != models.FirstWinBadge || achievements[2].Description == "" {
+		t.Errorf("expected three described badges in order they were awarded, got %+v, %v", achievements, err)
+	}
+}
+
+func TestTournamentWinnersBecomeChampions(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	winner, loser := newAccount(t, repos, "winner"), newAccount(t, repos, "loser")
+	tournament := newTournament(t, repos, models.SingleElimination, winner, loser)
+	final := tournamentMatch(t, tournament, 1)
+	color := models.Blue
+	if teamName(tournament, final.RedTeamID) == "Team winner" {
+		color = models.Red
+	}
+	playTournamentMatch(t, repos, tournament, 1, color)
+
+	if champion, ok := evaluate(t, repos, winner)[models.ChampionBadge]; !ok || *champion.TournamentID != tournament.ID {
+		t.Errorf("expected winner to become champion, got %+v", champion)
+	}
+	if _, ok := evaluate(t, repos, loser)[models.ChampionBadge]; ok {
+		t.Errorf("expected loser not to become champion")
+	}
+}
+
+func TestBackfillAwardsBadgesOfPastMatches(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	winner, loser := newAccount(t, repos, "winner"), newAccount(t, repos, "loser")
+	played(t, repos, winner, 1, true)
+	played(t, repos, loser, 1, false)
+
+	awarded, err := models.BackfillAchievements(repos.Achievements, repos.Accounts, repos.Statistics, repos.Tournaments)
+	if err != nil || awarded != 1 {
+		t.Fatalf("expected only the first win to be awarded, got %d, %v", awarded, err)
+	}
+	if awarded, _ = models.BackfillAchievements(repos.Achievements, repos.Accounts, repos.Statistics, repos.Tournaments); awarded != 0 {
+		t.Errorf("expected backfill to award nothing twice, got %d", awarded)
+	}
+}
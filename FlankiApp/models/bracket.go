@@ -0,0 +1,180 @@
+package models
+
+// Brackets are generated as a whole when the tournament starts. Matches refer to each other by their numbers,
+// winner (and in double elimination loser) of a match goes to the given slot of another one. Slots which will never
+// be filled, because of byes, are resolved as walkovers so the bracket always moves on.
+
+type BracketType string
+
+const (
+	WinnersBracket BracketType = "winners"
+	LosersBracket  BracketType = "losers"
+	GrandFinal     BracketType = "final"
+	RoundRobin     BracketType = "round_robin"
+)
+
+type bracketBuilder struct {
+	matches []TournamentMatch
+}
+
+// adds match and returns its number
+func (builder *bracketBuilder) add(bracket BracketType, round uint) uint {
+	number := uint(len(builder.matches) + 1)
+	builder.matches = append(builder.matches, TournamentMatch{Number: number, Bracket: bracket, Round: round})
+	return number
+}
+
+func (builder *bracketBuilder) at(number uint) *TournamentMatch {
+	return &builder.matches[number-1]
+}
+
+func (builder *bracketBuilder) winnerTo(from uint, to uint, slot TeamColor) {
+	builder.at(from).WinnerTo, builder.at(from).WinnerSlot = to, slot
+}
+
+func (builder *bracketBuilder) loserTo(from uint, to uint, slot TeamColor) {
+	builder.at(from).LoserTo, builder.at(from).LoserSlot = to, slot
+}
+
+// slot of the next match taken by the i-th match of a round
+func slotOf(i int) TeamColor {
+	if i%2 == 0 {
+		return Blue
+	}
+	return Red
+}
+
+// bracketSize is the smallest power of two fitting all teams
+func bracketSize(teams int) int {
+	size := 2
+	for size < teams {
+		size *= 2
+	}
+	return size
+}
+
+// seedOrder places seeds so that the best ones meet as late as possible, 1 plays size, 2 plays size-1 and so on
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, len(order)*2)
+		for _, seed := range order {
+			next = append(next, seed, len(order)*2+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// winnersBracket adds rounds of the winners bracket with teams placed by their seeds, seeds without a team are byes.
+// Returns numbers of matches of each round, starting from the first one
+func (builder *bracketBuilder) winnersBracket(teams []*TournamentTeam) [][]uint {
+	size := bracketSize(len(teams))
+	rounds := [][]uint{}
+	for count := size / 2; count >= 1; count /= 2 {
+		round := make([]uint, count)
+		for i := range round {
+			round[i] = builder.add(WinnersBracket, uint(len(rounds)+1))
+		}
+		rounds = append(rounds, round)
+	}
+
+	order := seedOrder(size)
+	for i, number := range rounds[0] {
+		match := builder.at(number)
+		if seed := order[2*i]; seed <= len(teams) {
+			match.BlueTeamID = &teams[seed-1].ID
+		}
+		if seed := order[2*i+1]; seed <= len(teams) {
+			match.RedTeamID = &teams[seed-1].ID
+		}
+	}
+	for r := 0; r < len(rounds)-1; r++ {
+		for i, number := range rounds[r] {
+			builder.winnerTo(number, rounds[r+1][i/2], slotOf(i))
+		}
+	}
+	return rounds
+}
+
+// singleElimination pairs seeded teams, winners move on until the final
+func singleElimination(teams []*TournamentTeam) []TournamentMatch {
+	builder := &bracketBuilder{}
+	builder.winnersBracket(teams)
+	return builder.matches
+}
+
+// doubleElimination gives losers of the winners bracket another chance in the losers bracket,
+// winners of both brackets meet in the grand final which is played once
+func doubleElimination(teams []*TournamentTeam) []TournamentMatch {
+	builder := &bracketBuilder{}
+	winners := builder.winnersBracket(teams)
+
+	// losers bracket alternates rounds of its own winners with rounds where losers of the winners bracket drop in
+	var losers [][]uint
+	if len(winners) > 1 {
+		round := func(count int) []uint {
+			numbers := make([]uint, count)
+			for i := range numbers {
+				numbers[i] = builder.add(LosersBracket, uint(len(losers)+1))
+			}
+			losers = append(losers, numbers)
+			return numbers
+		}
+		first := round(len(winners[0]) / 2)
+		for i, number := range first {
+			builder.loserTo(winners[0][2*i], number, Blue)
+			builder.loserTo(winners[0][2*i+1], number, Red)
+		}
+		for r := 1; r < len(winners); r++ {
+			previous := losers[len(losers)-1]
+			dropIn := round(len(winners[r]))
+			for i, number := range dropIn {
+				builder.winnerTo(previous[i], number, Blue)
+				builder.loserTo(winners[r][i], number, Red)
+			}
+			if r < len(winners)-1 {
+				merge := round(len(dropIn) / 2)
+				for i, number := range dropIn {
+					builder.winnerTo(number, merge[i/2], slotOf(i))
+				}
+			}
+		}
+	}
+
+	final := builder.add(GrandFinal, 1)
+	lastWinners := winners[len(winners)-1][0]
+	builder.winnerTo(lastWinners, final, Blue)
+	if len(losers) == 0 {
+		builder.loserTo(lastWinners, final, Red)
+	} else {
+		builder.winnerTo(losers[len(losers)-1][0], final, Red)
+	}
+	return builder.matches
+}
+
+// roundRobin pairs every team with every other one using the circle method, a team pausing in a round
+// is the one paired with nobody
+func roundRobin(teams []*TournamentTeam) []TournamentMatch {
+	builder := &bracketBuilder{}
+	circle := make([]*TournamentTeam, len(teams))
+	copy(circle, teams)
+	if len(circle)%2 == 1 {
+		circle = append(circle, nil)
+	}
+	for round := 1; round < len(circle); round++ {
+		for i := 0; i < len(circle)/2; i++ {
+			blue, red := circle[i], circle[len(circle)-1-i]
+			if blue == nil || red == nil {
+				continue
+			}
+			match := builder.at(builder.add(RoundRobin, uint(round)))
+			match.BlueTeamID, match.RedTeamID = &blue.ID, &red.ID
+		}
+		// the first team stays, the others rotate
+		last := circle[len(circle)-1]
+		copy(circle[2:], circle[1:len(circle)-1])
+		circle[1] = last
+	}
+	return builder.matches
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+// ErasedNickname replaces nickname of erased account everywhere it has been shown to other players
+const ErasedNickname = "Deleted player"
+
+// RestoreAccount brings back the account deleted by its player as long as the grace period hasn't passed,
+// nickname and email have to be still free as other players could have taken them in the meantime
+func RestoreAccount(accounts AccountRepository, email string, password string, grace time.Duration, now time.Time) (*Account, error) {
+	account, err := accounts.GetDeletedByEmail(email)
+	if err == errors.RecordNotFound {
+		return nil, errors.UnauthorizedAccount
+	}
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password)) != nil {
+		return nil, errors.UnauthorizedAccount
+	}
+	if !account.DeletedAt.Add(grace).After(now) {
+		return nil, errors.AccountErased
+	}
+	if err := IsAccountFieldUnique(accounts, "nickname", account.Nickname); err != nil {
+		return nil, err
+	}
+	if err := IsAccountFieldUnique(accounts, "email", account.Email); err != nil {
+		return nil, err
+	}
+	if err := accounts.Restore(account); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return account, nil
+}
+
+// GetAccountsToErase returns deleted accounts whose grace period has passed by now
+func GetAccountsToErase(accounts AccountRepository, grace time.Duration, now time.Time) ([]*Account, error) {
+	toErase, err := accounts.GetToErase(now.Add(-grace))
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return toErase, nil
+}
+
+// Erase anonymizes the deleted account for good, only its aggregate statistics are kept under a tombstone.
+// Matches it has played keep its anonymous entry so that results of other players don't change.
+// Open lobbies the account still owns are closed and it leaves the one it has joined first,
+// notifications of other players stop mentioning its nickname. Returns the tombstone
+func (account *Account) Erase(accounts AccountRepository, lobbies LobbyRepository, notifications NotificationRepository, now time.Time) (*Account, error) {
+	for {
+		lobby, err := lobbies.GetOpenByOwner(account.ID)
+		if err == errors.RecordNotFound {
+			break
+		}
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		if err := lobby.Close(lobbies, accounts); err != nil {
+			return nil, err
+		}
+	}
+	lobby, err := lobbies.GetOpenByPlayer(account.ID)
+	if err != nil && err != errors.RecordNotFound {
+		return nil, errors.DatabaseError(err)
+	}
+	if err == nil && lobby.RosterIsOpen() {
+		if err := lobby.RemovePlayer(lobbies, accounts, account.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	tombstone := &Account{Nickname: ErasedNickname, ErasedAt: &now}
+	tombstone.DeletedAt = &now
+	if err := accounts.Erase(account, tombstone); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+
+	for _, status := range []FriendshipStatus{FriendRequested, FriendAccepted} {
+		notice := FriendshipNotice(&Friendship{Status: status}, account.Nickname)
+		erased := FriendshipNotice(&Friendship{Status: status}, ErasedNickname)
+		if _, err := notifications.ReplaceBody(notice.Type, notice.Body, erased.Body); err != nil {
+			return tombstone, errors.DatabaseError(err)
+		}
+	}
+	return tombstone, nil
+}
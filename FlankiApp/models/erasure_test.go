@@ -0,0 +1,187 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// plays a match which counts in statistics and closes its lobby, winner plays blue
+func playedMatch(t *testing.T, repos *repositories.Repositories, winner *models.Account, loser *models.Account) *models.Lobby {
+	t.Helper()
+	// every match has its own owner
+	played, _ := repos.Statistics.GetAllByPlayer(winner.ID)
+	owner := newAccount(t, repos, fmt.Sprintf("owner%d", len(played)))
+	// players could have played before, their playing status is fetched again
+	winner, _ = repos.Accounts.GetById(winner.ID)
+	loser, _ = repos.Accounts.GetById(loser.ID)
+	lobby := newLobby(t, repos, owner.ID, 4)
+	join(t, repos, lobby.ID, winner, models.Blue)
+	join(t, repos, lobby.ID, loser, models.Red)
+	lobby, _ = models.GetOwnersLobby(repos.Lobbies, owner.ID)
+	lobby.Winner = models.Blue
+	if err := models.SubmitMatch(repos.Statistics, lobby); err != nil {
+		t.Fatalf("submitting match: %v", err)
+	}
+	if err := lobby.Close(repos.Lobbies, repos.Accounts); err != nil {
+		t.Fatalf("closing lobby: %v", err)
+	}
+	return lobby
+}
+
+func TestDeletedAccountCanBeRestoredWithinGracePeriod(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	if err := player.Delete(repos.Accounts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Accounts.GetById(player.ID); err != errors.RecordNotFound {
+		t.Fatalf("deleted account shouldn't be found, got %v", err)
+	}
+
+	now := time.Now()
+	if _, err := models.RestoreAccount(repos.Accounts, "player@flanki.pl", "wrong password", time.Hour, now); err != errors.UnauthorizedAccount {
+		t.Fatalf("expected wrong password to be rejected, got %v", err)
+	}
+	if _, err := models.RestoreAccount(repos.Accounts, "player@flanki.pl", "secret123", time.Hour, now.Add(2*time.Hour)); err != errors.AccountErased {
+		t.Fatalf("expected account past grace period not to be restored, got %v", err)
+	}
+	if _, err := models.RestoreAccount(repos.Accounts, "player@flanki.pl", "secret123", time.Hour, now); err != nil {
+		t.Fatalf("restoring account: %v", err)
+	}
+	if account, err := repos.Accounts.GetById(player.ID); err != nil || account.Nickname != "player" {
+		t.Fatalf("restored account should be found, got %v", err)
+	}
+
+	// nickname of deleted account is free for others, then the account can't be restored
+	if err := player.Delete(repos.Accounts); err != nil {
+		t.Fatal(err)
+	}
+	newAccount(t, repos, "player")
+	if _, err := models.RestoreAccount(repos.Accounts, "player@flanki.pl", "secret123", time.Hour, now); httpCode(err) != 400 {
+		t.Fatalf("expected taken nickname to prevent restoring, got %v", err)
+	}
+}
+
+func TestErasedAccountKeepsOnlyAnonymousStatistics(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	winner := newAccount(t, repos, "winner")
+	loser := newAccount(t, repos, "loser")
+	lobby := playedMatch(t, repos, winner, loser)
+	hit := true
+	for _, event := range []*models.MatchEvent{
+		{LobbyID: lobby.ID, Round: 1, Type: models.Throw, PlayerID: winner.ID, Hit: &hit, RecordedBy: lobby.OwnerID},
+		{LobbyID: lobby.ID, Round: 1, Type: models.Throw, PlayerID: loser.ID, Hit: &hit, RecordedBy: winner.ID},
+	} {
+		if err := repos.Events.Create(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.Lobbies.AddResultRecord(lobby, &models.ResultRecord{LobbyID: lobby.ID, Event: models.ResultSubmitted, PlayerID: winner.ID, Winner: models.Blue}); err != nil {
+		t.Fatal(err)
+	}
+	befriend(t, repos, winner, loser)
+	notify(t, repos, loser.ID, models.FriendshipNotice(&models.Friendship{Status: models.FriendAccepted}, winner.Nickname))
+	if err := winner.Delete(repos.Accounts); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if toErase, err := models.GetAccountsToErase(repos.Accounts, time.Hour, now); err != nil || len(toErase) != 0 {
+		t.Fatalf("account within grace period shouldn't be erased, got %d, %v", len(toErase), err)
+	}
+	toErase, err := models.GetAccountsToErase(repos.Accounts, time.Hour, now.Add(2*time.Hour))
+	if err != nil || len(toErase) != 1 || toErase[0].ID != winner.ID {
+		t.Fatalf("expected deleted account to be erased, got %d, %v", len(toErase), err)
+	}
+	tombstone, err := toErase[0].Erase(repos.Accounts, repos.Lobbies, repos.Notifications, now)
+	if err != nil {
+		t.Fatalf("erasing account: %v", err)
+	}
+
+	if entries, _ := repos.Statistics.GetByPlayer(winner.ID); len(entries) != 0 {
+		t.Errorf("statistics shouldn't be linked to erased account, got %d entries", len(entries))
+	}
+	ranking, _ := models.GetPlayersRanking(repos.Statistics)
+	if len(ranking) != 2 || ranking[0].PlayerID != tombstone.ID || ranking[0].Nickname != models.ErasedNickname || ranking[0].Wins != 1 {
+		t.Errorf("statistics should be kept under the tombstone, got %+v", ranking)
+	}
+	if entries, _ := repos.Statistics.GetAllByPlayer(tombstone.ID); len(entries) != 1 || entries[0].LobbyId != 0 {
+		t.Errorf("statistics of the tombstone shouldn't be linked to matches, got %+v", entries)
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	for _, team := range lobby.Teams {
+		for _, entry := range team.TeamEntries {
+			if entry.PlayerID == winner.ID || entry.PlayerID == tombstone.ID || entry.Nickname == winner.Nickname {
+				t.Errorf("match history should be anonymized, got %+v", entry)
+			}
+		}
+	}
+	if players, _ := lobby.GetLobbyPlayersIds(); len(players) != 1 || players[0] != loser.ID {
+		t.Errorf("only the remaining player should be linked to the match, got %v", players)
+	}
+	for _, record := range lobby.ResultHistory {
+		if record.PlayerID != 0 {
+			t.Errorf("result history shouldn't point at anybody, got %+v", record)
+		}
+	}
+	log, _ := models.GetMatchLog(repos.Events, lobby.ID)
+	if len(log.Events) != 1 || log.Events[0].PlayerID != loser.ID || log.Events[0].RecordedBy != 0 {
+		t.Errorf("only events of other players should be kept without their recorder, got %+v", log.Events)
+	}
+	if _, err := repos.Friends.GetFriendship(loser.ID, winner.ID); err != errors.RecordNotFound {
+		t.Errorf("friendship with erased account should be deleted, got %v", err)
+	}
+	inbox, _ := repos.Notifications.GetByAccount(loser.ID, false, 10)
+	if len(inbox) != 1 || inbox[0].Body != models.ErasedNickname+" is your friend now" {
+		t.Errorf("notifications shouldn't mention erased nickname, got %+v", inbox)
+	}
+	if _, err := models.RestoreAccount(repos.Accounts, "winner@flanki.pl", "secret123", time.Hour, now); err != errors.UnauthorizedAccount {
+		t.Errorf("erased account shouldn't be restored, got %v", err)
+	}
+	if toErase, _ := models.GetAccountsToErase(repos.Accounts, time.Hour, now.Add(2*time.Hour)); len(toErase) != 0 {
+		t.Errorf("tombstone shouldn't be erased again, got %d accounts", len(toErase))
+	}
+	impostor := &models.Account{Nickname: models.ErasedNickname, Email: "impostor@flanki.pl", Password: "secret123", Sex: "male"}
+	if err := impostor.Validate(repos.Accounts); httpCode(err) != 400 {
+		t.Errorf("nickname of erased players shouldn't be taken, got %v", err)
+	}
+}
+
+func TestAccountExportHasProfileAndMatchesWithoutPasswords(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	winner := newAccount(t, repos, "winner")
+	loser := newAccount(t, repos, "loser")
+	lobby := playedMatch(t, repos, winner, loser)
+	// results of the second match wait for confirmation
+	pending := playedMatch(t, repos, winner, loser)
+	if err := repos.Statistics.SetFrozen(pending.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	befriend(t, repos, winner, loser)
+	notify(t, repos, loser.ID, models.FriendshipNotice(&models.Friendship{Status: models.FriendAccepted}, winner.Nickname))
+
+	export, err := models.GetAccountExport(repos.Accounts, repos.Statistics, repos.Lobbies, repos.Events,
+		repos.Achievements, repos.Friends, repos.RSVPs, repos.Notifications, loser.ID, time.Now())
+	if err != nil {
+		t.Fatalf("exporting account: %v", err)
+	}
+	if export.Account.Nickname != "loser" || export.Account.Password != "" {
+		t.Errorf("unexpected exported profile %+v", export.Account)
+	}
+	if export.Summary.Loses != 1 || len(export.Statistics) != 2 || !export.Statistics[1].Frozen {
+		t.Errorf("expected counted and frozen statistics to be exported, got %+v, %+v", export.Summary, export.Statistics)
+	}
+	if len(export.Matches) != 2 || export.Matches[0].ID != lobby.ID || export.Matches[1].ID != pending.ID {
+		t.Errorf("expected both played matches to be exported, got %d matches", len(export.Matches))
+	}
+	if len(export.Friendships) != 1 || export.Friendships[0].Status != models.FriendAccepted {
+		t.Errorf("expected the friendship to be exported, got %+v", export.Friendships)
+	}
+	if len(export.Notifications) != 1 || export.PushSubscriptions == nil {
+		t.Errorf("expected notifications and push subscriptions to be exported, got %+v, %+v", export.Notifications, export.PushSubscriptions)
+	}
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"math"
+	"time"
+)
+
+// AccountExport is everything the app keeps about the player, handed to them on request
+type AccountExport struct {
+	ExportedAt        time.Time
+	Account           *Account
+	Summary           *PlayerSummary
+	Statistics        []PlayerStatisticsEntry // entries of all matches, frozen ones are pending or disputed
+	Matches           []*Lobby                // lobbies of the matches in statistics, the oldest first
+	Events            []MatchEvent            // events of the player from finished matches
+	Achievements      []Achievement
+	Friendships       []Friendship // friends and requests in both directions
+	Following         []Follow
+	Followers         []Follow
+	RSVPs             []RSVP
+	Notifications     []Notification // the whole inbox from the newest one
+	PushSubscriptions []PushSubscription
+	ChatMessages      []ChatMessage // kept by the chat, they aren't gathered from the app's repositories
+}
+
+// ChatMessage is a message the player has sent in one of the chat's rooms
+type ChatMessage struct {
+	Room string    `json:"room"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// notifications are never deleted, the export isn't limited like the inbox
+const allNotifications = math.MaxInt32
+
+// GetAccountExport gathers the player's profile, statistics, matches and everything tied to the account,
+// passwords aren't exported
+func GetAccountExport(accounts AccountRepository, statistics StatisticsRepository, lobbies LobbyRepository, events MatchEventRepository,
+	achievements AchievementRepository, friends FriendRepository, rsvps RSVPRepository, notifications NotificationRepository,
+	playerID uint, now time.Time) (*AccountExport, error) {
+	account, err := GetAccountById(accounts, playerID)
+	if err != nil {
+		return nil, err
+	}
+	account.Password = ""
+
+	summary, err := GetPlayersSummary(accounts, statistics, playerID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := statistics.GetAllByPlayer(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	matches := []*Lobby{}
+	for _, entry := range entries {
+		lobby, err := lobbies.GetById(entry.LobbyId)
+		if err == errors.RecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		lobby.Password = ""
+		matches = append(matches, lobby)
+	}
+	export := &AccountExport{ExportedAt: now, Account: account, Summary: summary, Statistics: entries, Matches: matches}
+
+	if export.Events, err = events.GetByPlayer(playerID); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if export.Achievements, err = GetAchievements(achievements, playerID); err != nil {
+		return nil, err
+	}
+	if export.Friendships, err = friends.GetFriendships(playerID); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if export.Following, err = friends.GetFollowing(playerID); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if export.Followers, err = friends.GetFollowers(playerID); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if export.RSVPs, err = rsvps.GetByPlayer(playerID); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if export.Notifications, err = notifications.GetByAccount(playerID, false, allNotifications); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if export.PushSubscriptions, err = notifications.GetPushSubscriptions(playerID); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return export, nil
+}
@@ -0,0 +1,367 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"time"
+)
+
+type FriendshipStatus string
+
+const (
+	FriendRequested FriendshipStatus = "requested"
+	FriendAccepted  FriendshipStatus = "accepted"
+)
+
+type LobbyVisibility string
+
+const (
+	Everyone       LobbyVisibility = "everyone"
+	FriendsOfOwner LobbyVisibility = "friends"
+)
+
+// Friendship is mutual, it starts as a request of one player which the other one accepts.
+// There is at most one friendship between two players whichever of them sent the request
+type Friendship struct {
+	ID          uint             `json:"-" gorm:"primary_key"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"-"`
+	RequesterID uint             `json:"requester_id"`
+	AddresseeID uint             `json:"addressee_id"`
+	Status      FriendshipStatus `json:"status"`
+}
+
+// Follow is one-way, followed player doesn't have to agree to it
+type Follow struct {
+	ID         uint      `json:"-" gorm:"primary_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	FollowerID uint      `json:"follower_id"`
+	FolloweeID uint      `json:"followee_id"`
+}
+
+// Friend is a player together with the lobby they are playing in or own right now
+type Friend struct {
+	Player
+	LobbyID *uint     `json:"lobby_id,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+type FriendRequests struct {
+	Incoming []*Player `json:"incoming"`
+	Outgoing []*Player `json:"outgoing"`
+}
+
+type Follows struct {
+	Following []*Player `json:"following"`
+	Followers []*Player `json:"followers"`
+}
+
+var friendRequestNotFound = errors.New("Friend request has not been found", 404)
+
+// other player of the friendship
+func (friendship *Friendship) Other(playerID uint) uint {
+	if friendship.RequesterID == playerID {
+		return friendship.AddresseeID
+	}
+	return friendship.RequesterID
+}
+
+func findFriendship(friends FriendRepository, playerID uint, otherID uint) (*Friendship, error) {
+	friendship, err := friends.GetFriendship(playerID, otherID)
+	if err == errors.RecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return friendship, nil
+}
+
+// other player has to exist and can't be the same one
+func checkOtherPlayer(accounts AccountRepository, playerID uint, otherID uint) error {
+	if playerID == otherID {
+		return errors.New("Players can't befriend nor follow themselves", 400)
+	}
+	_, err := GetPlayerByIdFunc(accounts, otherID)
+	return err
+}
+
+// SendFriendRequest asks the other player for friendship, when they have already asked the player both become friends right away
+func SendFriendRequest(friends FriendRepository, accounts AccountRepository, playerID uint, otherID uint) (*Friendship, error) {
+	if err := checkOtherPlayer(accounts, playerID, otherID); err != nil {
+		return nil, err
+	}
+	friendship, err := findFriendship(friends, playerID, otherID)
+	if err != nil {
+		return nil, err
+	}
+	if friendship == nil {
+		friendship = &Friendship{RequesterID: playerID, AddresseeID: otherID, Status: FriendRequested}
+	} else if friendship.Status == FriendAccepted {
+		return nil, errors.New("Players are already friends", 409)
+	} else if friendship.RequesterID == playerID {
+		return nil, errors.New("Friend request has already been sent", 409)
+	} else {
+		friendship.Status = FriendAccepted
+	}
+	if err := friends.SaveFriendship(friendship); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return friendship, nil
+}
+
+// AcceptFriendRequest accepts request the other player sent to the player
+func AcceptFriendRequest(friends FriendRepository, playerID uint, otherID uint) (*Friendship, error) {
+	friendship, err := findFriendship(friends, playerID, otherID)
+	if err != nil {
+		return nil, err
+	}
+	if friendship == nil || friendship.Status != FriendRequested || friendship.AddresseeID != playerID {
+		return nil, friendRequestNotFound
+	}
+	friendship.Status = FriendAccepted
+	if err := friends.SaveFriendship(friendship); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return friendship, nil
+}
+
+// DeclineFriendRequest drops request the other player sent to the player, they can send another one later
+func DeclineFriendRequest(friends FriendRepository, playerID uint, otherID uint) error {
+	friendship, err := findFriendship(friends, playerID, otherID)
+	if err != nil {
+		return err
+	}
+	if friendship == nil || friendship.Status != FriendRequested || friendship.AddresseeID != playerID {
+		return friendRequestNotFound
+	}
+	if err := friends.DeleteFriendship(friendship); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// RemoveFriend ends the friendship or withdraws request the player has sent
+func RemoveFriend(friends FriendRepository, playerID uint, otherID uint) error {
+	friendship, err := findFriendship(friends, playerID, otherID)
+	if err != nil {
+		return err
+	}
+	if friendship == nil || (friendship.Status == FriendRequested && friendship.RequesterID != playerID) {
+		return errors.New("Players are not friends", 404)
+	}
+	if err := friends.DeleteFriendship(friendship); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+func AreFriends(friends FriendRepository, playerID uint, otherID uint) (bool, error) {
+	friendship, err := findFriendship(friends, playerID, otherID)
+	if err != nil {
+		return false, err
+	}
+	return friendship != nil && friendship.Status == FriendAccepted, nil
+}
+
+// GetFriends lists accepted friends of the player with the lobbies they are in, deleted accounts are skipped
+func GetFriends(friends FriendRepository, accounts AccountRepository, lobbies LobbyRepository, playerID uint) ([]*Friend, error) {
+	friendships, err := friends.GetFriendships(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	list := []*Friend{}
+	for _, friendship := range friendships {
+		if friendship.Status != FriendAccepted {
+			continue
+		}
+		player, err := accounts.GetPlayerById(friendship.Other(playerID))
+		if err == errors.RecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		friend := &Friend{Player: *player, Since: friendship.UpdatedAt}
+		if friend.LobbyID, err = currentLobbyID(lobbies, player.ID); err != nil {
+			return nil, err
+		}
+		list = append(list, friend)
+	}
+	return list, nil
+}
+
+// lobby the player is playing in, or the one they own when they aren't playing
+func currentLobbyID(lobbies LobbyRepository, playerID uint) (*uint, error) {
+	lobby, err := lobbies.GetOpenByPlayer(playerID)
+	if err == errors.RecordNotFound {
+		lobby, err = lobbies.GetOpenByOwner(playerID)
+	}
+	if err == errors.RecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return &lobby.ID, nil
+}
+
+// GetFriendRequests lists players who asked the player for friendship and those the player asked
+func GetFriendRequests(friends FriendRepository, accounts AccountRepository, playerID uint) (*FriendRequests, error) {
+	friendships, err := friends.GetFriendships(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	incoming, outgoing := []uint{}, []uint{}
+	for _, friendship := range friendships {
+		if friendship.Status != FriendRequested {
+			continue
+		}
+		if friendship.AddresseeID == playerID {
+			incoming = append(incoming, friendship.RequesterID)
+		} else {
+			outgoing = append(outgoing, friendship.AddresseeID)
+		}
+	}
+	requests := &FriendRequests{}
+	if requests.Incoming, err = getPlayers(accounts, incoming); err != nil {
+		return nil, err
+	}
+	if requests.Outgoing, err = getPlayers(accounts, outgoing); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// players with given ids, deleted accounts are skipped
+func getPlayers(accounts AccountRepository, ids []uint) ([]*Player, error) {
+	players := []*Player{}
+	for _, id := range ids {
+		player, err := accounts.GetPlayerById(id)
+		if err == errors.RecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		players = append(players, player)
+	}
+	return players, nil
+}
+
+// FollowPlayer makes the player follow the other one, following them again changes nothing
+func FollowPlayer(friends FriendRepository, accounts AccountRepository, playerID uint, otherID uint) error {
+	if err := checkOtherPlayer(accounts, playerID, otherID); err != nil {
+		return err
+	}
+	following, err := friends.GetFollowing(playerID)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	for _, follow := range following {
+		if follow.FolloweeID == otherID {
+			return nil
+		}
+	}
+	if err := friends.AddFollow(&Follow{FollowerID: playerID, FolloweeID: otherID}); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+func UnfollowPlayer(friends FriendRepository, playerID uint, otherID uint) error {
+	err := friends.DeleteFollow(playerID, otherID)
+	if err == errors.RecordNotFound {
+		return errors.New("Player is not followed", 404)
+	}
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// GetFollows lists players the player follows and those following them
+func GetFollows(friends FriendRepository, accounts AccountRepository, playerID uint) (*Follows, error) {
+	following, err := friends.GetFollowing(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	followers, err := friends.GetFollowers(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	followingIDs, followerIDs := make([]uint, len(following)), make([]uint, len(followers))
+	for i, follow := range following {
+		followingIDs[i] = follow.FolloweeID
+	}
+	for i, follow := range followers {
+		followerIDs[i] = follow.FollowerID
+	}
+	follows := &Follows{}
+	if follows.Following, err = getPlayers(accounts, followingIDs); err != nil {
+		return nil, err
+	}
+	if follows.Followers, err = getPlayers(accounts, followerIDs); err != nil {
+		return nil, err
+	}
+	return follows, nil
+}
+
+// CheckVisibility lets only friends of the owner into lobbies visible to them, the owner and players
+// who are already in the lobby can always see it
+func (lobby *Lobby) CheckVisibility(friends FriendRepository, playerID uint) error {
+	if lobby.Visibility != FriendsOfOwner || lobby.OwnerID == playerID || lobby.isMember(playerID) {
+		return nil
+	}
+	friendsOfOwner, err := AreFriends(friends, lobby.OwnerID, playerID)
+	if err != nil {
+		return err
+	}
+	if !friendsOfOwner {
+		return errors.FriendsOnlyLobby
+	}
+	return nil
+}
+
+// GetVisibleLobby returns the lobby with its spectators unless it is hidden from the player, hidden lobbies look
+// like they don't exist. Members and managers of the lobby can always see it
+func GetVisibleLobby(lobbies LobbyRepository, spectators SpectatorRepository, friends FriendRepository, lobbyID uint, playerID uint) (*Lobby, error) {
+	lobby, err := GetLobbyByIdFunc(lobbies, lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	if err = lobby.LoadSpectators(spectators); err != nil {
+		return nil, err
+	}
+	if lobby.IsMember(playerID) || lobby.IsManager(playerID) {
+		return lobby, nil
+	}
+	err = lobby.CheckVisibility(friends, playerID)
+	if err == errors.FriendsOnlyLobby {
+		return nil, errors.New("Lobby has not been found", 404)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// VisibleLobbies leaves out lobbies of owners who aren't friends of the player and don't let anybody else in
+func VisibleLobbies(friends FriendRepository, lobbies []*Lobby, playerID uint) ([]*Lobby, error) {
+	friendships, err := friends.GetFriendships(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	friendIDs := map[uint]bool{}
+	for _, friendship := range friendships {
+		if friendship.Status == FriendAccepted {
+			friendIDs[friendship.Other(playerID)] = true
+		}
+	}
+	visible := []*Lobby{}
+	for _, lobby := range lobbies {
+		if lobby.Visibility != FriendsOfOwner || lobby.OwnerID == playerID || friendIDs[lobby.OwnerID] || lobby.isMember(playerID) {
+			visible = append(visible, lobby)
+		}
+	}
+	return visible, nil
+}
@@ -0,0 +1,174 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+)
+
+func befriend(t *testing.T, repos *repositories.Repositories, player *models.Account, other *models.Account) {
+	t.Helper()
+	if _, err := models.SendFriendRequest(repos.Friends, repos.Accounts, player.ID, other.ID); err != nil {
+		t.Fatalf("sending friend request: %v", err)
+	}
+	if _, err := models.AcceptFriendRequest(repos.Friends, other.ID, player.ID); err != nil {
+		t.Fatalf("accepting friend request: %v", err)
+	}
+}
+
+func TestFriendRequestsGoBothWays(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	alice := newAccount(t, repos, "alice")
+	bob := newAccount(t, repos, "bobby")
+
+	if _, err := models.SendFriendRequest(repos.Friends, repos.Accounts, alice.ID, alice.ID); httpCode(err) != 400 {
+		t.Fatalf("expected befriending oneself to be rejected, got %v", err)
+	}
+	if _, err := models.SendFriendRequest(repos.Friends, repos.Accounts, alice.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.SendFriendRequest(repos.Friends, repos.Accounts, alice.ID, bob.ID); httpCode(err) != 409 {
+		t.Fatalf("expected repeated request to be rejected, got %v", err)
+	}
+	if _, err := models.AcceptFriendRequest(repos.Friends, alice.ID, bob.ID); httpCode(err) != 404 {
+		t.Fatalf("expected requester not to accept own request, got %v", err)
+	}
+	requests, err := models.GetFriendRequests(repos.Friends, repos.Accounts, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests.Incoming) != 1 || requests.Incoming[0].ID != alice.ID || len(requests.Outgoing) != 0 {
+		t.Fatalf("expected alice's request to be incoming, got %+v", requests)
+	}
+
+	// asking back accepts the request
+	friendship, err := models.SendFriendRequest(repos.Friends, repos.Accounts, bob.ID, alice.ID)
+	if err != nil || friendship.Status != models.FriendAccepted {
+		t.Fatalf("expected players to become friends, got %+v, %v", friendship, err)
+	}
+	if err := models.RemoveFriend(repos.Friends, alice.ID, bob.ID); err != nil {
+		t.Fatalf("removing friend: %v", err)
+	}
+	if friends, _ := models.AreFriends(repos.Friends, bob.ID, alice.ID); friends {
+		t.Errorf("expected friendship to be removed for both players")
+	}
+
+	if _, err := models.SendFriendRequest(repos.Friends, repos.Accounts, bob.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DeclineFriendRequest(repos.Friends, alice.ID, bob.ID); err != nil {
+		t.Fatalf("declining request: %v", err)
+	}
+	if requests, _ := models.GetFriendRequests(repos.Friends, repos.Accounts, alice.ID); len(requests.Incoming) != 0 {
+		t.Errorf("expected declined request to be dropped, got %+v", requests)
+	}
+}
+
+func TestFriendsShowTheirLobbies(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	owner := newAccount(t, repos, "owner")
+	idle := newAccount(t, repos, "idle")
+	befriend(t, repos, player, owner)
+	befriend(t, repos, idle, player)
+	lobby := newLobby(t, repos, owner.ID, 4)
+
+	friends, err := models.GetFriends(repos.Friends, repos.Accounts, repos.Lobbies, player.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(friends) != 2 {
+		t.Fatalf("expected both friends, got %+v", friends)
+	}
+	for _, friend := range friends {
+		switch friend.ID {
+		case owner.ID:
+			if friend.LobbyID == nil || *friend.LobbyID != lobby.ID {
+				t.Errorf("expected owner's lobby, got %v", friend.LobbyID)
+			}
+		case idle.ID:
+			if friend.LobbyID != nil || friend.Playing {
+				t.Errorf("expected idle friend without lobby, got %+v", friend)
+			}
+		}
+	}
+}
+
+func TestFollowsAreOneWay(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	fan := newAccount(t, repos, "fanboy")
+	star := newAccount(t, repos, "star")
+
+	for i := 0; i < 2; i++ {
+		if err := models.FollowPlayer(repos.Friends, repos.Accounts, fan.ID, star.ID); err != nil {
+			t.Fatalf("following player: %v", err)
+		}
+	}
+	follows, err := models.GetFollows(repos.Friends, repos.Accounts, star.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(follows.Followers) != 1 || follows.Followers[0].ID != fan.ID || len(follows.Following) != 0 {
+		t.Fatalf("expected single follower, got %+v", follows)
+	}
+	if err := models.UnfollowPlayer(repos.Friends, star.ID, fan.ID); httpCode(err) != 404 {
+		t.Fatalf("expected unfollowing not followed player to fail, got %v", err)
+	}
+	if err := models.UnfollowPlayer(repos.Friends, fan.ID, star.ID); err != nil {
+		t.Fatalf("unfollowing player: %v", err)
+	}
+}
+
+func TestFriendsOnlyLobbyIsHiddenFromStrangers(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	friend := newAccount(t, repos, "friend")
+	stranger := newAccount(t, repos, "stranger")
+	befriend(t, repos, owner, friend)
+
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Friends only", PlayerLimit: 4, Visibility: models.FriendsOfOwner}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating lobby: %v", err)
+	}
+	newLobby(t, repos, newAccount(t, repos, "other").ID, 4)
+
+	all, err := models.GetAllLobbies(repos.Lobbies, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	visible, err := models.VisibleLobbies(repos.Friends, all, stranger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(visible) != 1 || visible[0].ID == lobby.ID {
+		t.Errorf("expected friends only lobby to be hidden from stranger, got %d lobbies", len(visible))
+	}
+	if visible, _ = models.VisibleLobbies(repos.Friends, all, friend.ID); len(visible) != 2 {
+		t.Errorf("expected friend to see both lobbies, got %d", len(visible))
+	}
+
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if err := lobby.CheckVisibility(repos.Friends, stranger.ID); err != errors.FriendsOnlyLobby {
+		t.Fatalf("expected stranger not to join, got %v", err)
+	}
+	if err := lobby.CheckVisibility(repos.Friends, friend.ID); err != nil {
+		t.Fatalf("expected friend to join, got %v", err)
+	}
+	if _, err := models.GetVisibleLobby(repos.Lobbies, repos.Spectators, repos.Friends, lobby.ID, stranger.ID); httpCode(err) != 404 {
+		t.Errorf("expected friends only lobby not to be found by stranger, got %v", err)
+	}
+	for _, viewer := range []*models.Account{owner, friend} {
+		if found, err := models.GetVisibleLobby(repos.Lobbies, repos.Spectators, repos.Friends, lobby.ID, viewer.ID); err != nil || found.ID != lobby.ID {
+			t.Errorf("expected %s to see the lobby, got %v", viewer.Nickname, err)
+		}
+	}
+
+	// opening the lobby to everyone lets strangers in
+	if err := (&models.Lobby{OwnerID: owner.ID, Visibility: models.Everyone}).Update(repos.Lobbies, owner.ID); err != nil {
+		t.Fatalf("updating lobby: %v", err)
+	}
+	if err := ownersLobby(t, repos, owner.ID).CheckVisibility(repos.Friends, stranger.ID); err != nil {
+		t.Errorf("expected public lobby to let stranger in, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"time"
+)
+
+// ExpireStaleLobbies cancels open lobbies nobody has touched for given time so that their players can join other ones,
+// lobbies scheduled to start later are kept
+func ExpireStaleLobbies(lobbies LobbyRepository, accounts AccountRepository, idle time.Duration, now time.Time) ([]*Lobby, error) {
+	staleLobbies, err := lobbies.GetStale(now.Add(-idle))
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	expired := []*Lobby{}
+	for _, lobby := range staleLobbies {
+		if err := lobby.Close(lobbies, accounts); err != nil {
+			return expired, err
+		}
+		expired = append(expired, lobby)
+	}
+	return expired, nil
+}
+
+// ReconcilePlaying fixes playing status of accounts which doesn't match their membership in teams of open lobbies,
+// e.g. when closing the lobby has failed half way through
+func ReconcilePlaying(accounts AccountRepository) (stopped int64, started int64, err error) {
+	stopped, started, err = accounts.ReconcilePlaying()
+	if err != nil {
+		return stopped, started, errors.DatabaseError(err)
+	}
+	return stopped, started, nil
+}
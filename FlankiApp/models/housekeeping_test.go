@@ -0,0 +1,66 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+	"time"
+)
+
+func TestStaleLobbiesExpireAndFreeTheirPlayers(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+	scheduledOwner := newAccount(t, repos, "scheduled")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	join(t, repos, lobby.ID, player, models.Blue)
+	startsAt := time.Now().Add(24 * time.Hour)
+	scheduled := &models.Lobby{OwnerID: scheduledOwner.ID, Name: "Scheduled lobby", PlayerLimit: 4, StartsAt: &startsAt}
+	if err := scheduled.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating scheduled lobby: %v", err)
+	}
+
+	expired, err := models.ExpireStaleLobbies(repos.Lobbies, repos.Accounts, 12*time.Hour, time.Now())
+	if err != nil || len(expired) != 0 {
+		t.Fatalf("fresh lobbies shouldn't expire, got %d, %v", len(expired), err)
+	}
+	expired, err = models.ExpireStaleLobbies(repos.Lobbies, repos.Accounts, 12*time.Hour, time.Now().Add(13*time.Hour))
+	if err != nil || len(expired) != 1 || expired[0].ID != lobby.ID {
+		t.Fatalf("expected only the unscheduled lobby expired, got %d, %v", len(expired), err)
+	}
+	if expired[0].State != models.Cancelled {
+		t.Errorf("expired lobby should be cancelled, got %s", expired[0].State)
+	}
+	if account, _ := repos.Accounts.GetById(player.ID); account.Playing {
+		t.Errorf("player of expired lobby should stop playing")
+	}
+}
+
+func TestPlayingStatusIsReconciledWithOpenLobbies(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+	stuck := newAccount(t, repos, "stuck")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	join(t, repos, lobby.ID, player, models.Red)
+	if err := repos.Accounts.SetPlaying(stuck.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Accounts.SetPlaying(player.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped, started, err := models.ReconcilePlaying(repos.Accounts)
+	if err != nil || stopped != 1 || started != 1 {
+		t.Fatalf("expected 1 account stopped and 1 started playing, got %d, %d, %v", stopped, started, err)
+	}
+	if account, _ := repos.Accounts.GetById(stuck.ID); account.Playing {
+		t.Errorf("account outside of open lobbies should stop playing")
+	}
+	if account, _ := repos.Accounts.GetById(player.ID); !account.Playing {
+		t.Errorf("player of open lobby should be playing")
+	}
+	if stopped, started, _ := models.ReconcilePlaying(repos.Accounts); stopped != 0 || started != 0 {
+		t.Errorf("reconciled accounts shouldn't change again, got %d, %d", stopped, started)
+	}
+}
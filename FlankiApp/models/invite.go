@@ -0,0 +1,169 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/validation"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// LobbyInvite lets players join the lobby with its code instead of the password, e.g. from a link posted in a group chat.
+// Invites sent to a single player are used once and only by them
+type LobbyInvite struct {
+	ID        uint       `json:"-" gorm:"primary_key"`
+	CreatedAt time.Time  `json:"created_at"`
+	Code      string     `json:"code"`
+	LobbyID   uint       `json:"lobby_id"`
+	PlayerID  *uint      `json:"player_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	MaxUses   *uint      `json:"max_uses,omitempty"` // no limit when not given
+	Uses      uint       `json:"uses"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// InviteRequest creates an invite which never expires and can be used any number of times unless told otherwise
+type InviteRequest struct {
+	ExpiresIn *uint `json:"expires_in,omitempty" validate:"omitempty,min=1,max=43200"` // minutes, 30 days at most
+	MaxUses   *uint `json:"max_uses,omitempty" validate:"omitempty,min=1,max=1000"`
+	PlayerID  *uint `json:"player_id,omitempty"` // the only player who can use the invite, once
+}
+
+// InviteJoinRequest chooses the team of the invited player, the lobby chooses it when not given
+type InviteJoinRequest struct {
+	TeamColor TeamColor `json:"team_color" validate:"omitempty,oneof=red blue auto"`
+}
+
+// length of random part of invite codes in bytes, they are encoded with 12 url safe characters
+const inviteCodeLength = 9
+
+func newInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(code); err != nil {
+		return "", errors.New("Error while generating invite code: "+err.Error(), 500)
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+// CreateInvite creates an invite to the lobby, invites of single players are used once
+func (lobby *Lobby) CreateInvite(invites InviteRepository, accounts AccountRepository, request *InviteRequest, now time.Time) (*LobbyInvite, error) {
+	if err := validation.Check(request); err != nil {
+		return nil, err
+	}
+	if lobby.TournamentMatchID != nil {
+		return nil, errors.New("Players of tournament matches are put into lobbies with their teams", 403)
+	}
+	code, err := newInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	invite := &LobbyInvite{Code: code, LobbyID: lobby.ID, MaxUses: request.MaxUses}
+	if request.ExpiresIn != nil {
+		expiresAt := now.Add(time.Duration(*request.ExpiresIn) * time.Minute)
+		invite.ExpiresAt = &expiresAt
+	}
+	if request.PlayerID != nil {
+		if *request.PlayerID == lobby.OwnerID {
+			return nil, errors.New("Owner can't invite themselves", 400)
+		}
+		if _, err := GetPlayerByIdFunc(accounts, *request.PlayerID); err != nil {
+			return nil, err
+		}
+		once := uint(1)
+		invite.PlayerID, invite.MaxUses = request.PlayerID, &once
+	}
+	if err := invites.Create(invite); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return invite, nil
+}
+
+// GetInvites lists invites to the lobby in order they were created, revoked ones included
+func GetInvites(invites InviteRepository, lobbyID uint) ([]LobbyInvite, error) {
+	list, err := invites.GetByLobby(lobbyID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return list, nil
+}
+
+func getInvite(invites InviteRepository, code string) (*LobbyInvite, error) {
+	invite, err := invites.GetByCode(code)
+	if err == errors.RecordNotFound {
+		return nil, errors.InviteNotFound
+	}
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return invite, nil
+}
+
+// RevokeInvite stops the lobby's invite from letting anybody else join, players who have joined with it stay
+func (lobby *Lobby) RevokeInvite(invites InviteRepository, code string, now time.Time) (*LobbyInvite, error) {
+	invite, err := getInvite(invites, code)
+	if err != nil {
+		return nil, err
+	}
+	if invite.LobbyID != lobby.ID {
+		return nil, errors.InviteNotFound
+	}
+	if invite.RevokedAt != nil {
+		return nil, errors.InviteRevoked
+	}
+	invite.RevokedAt = &now
+	if err := invites.Save(invite); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return invite, nil
+}
+
+// Check tells why the player can't join with the invite, it doesn't tell whether it has been used up
+// as uses are counted only when joining
+func (invite *LobbyInvite) Check(playerID uint, now time.Time) error {
+	if invite.RevokedAt != nil {
+		return errors.InviteRevoked
+	}
+	if invite.ExpiresAt != nil && !invite.ExpiresAt.After(now) {
+		return errors.InviteExpired
+	}
+	if invite.PlayerID != nil && *invite.PlayerID != playerID {
+		return errors.New("Invite has been sent to another player", 403)
+	}
+	return nil
+}
+
+// JoinWithInvite puts the player into a team of the invite's lobby, the invite replaces both the password
+// and visibility of the lobby. Returns the joined lobby
+func JoinWithInvite(invites InviteRepository, lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository,
+	account *Account, code string, request *InviteJoinRequest, now time.Time) (*Lobby, error) {
+	invite, err := getInvite(invites, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := invite.Check(account.ID, now); err != nil {
+		return nil, err
+	}
+	lobby, err := GetLobbyByIdFunc(lobbies, invite.LobbyID)
+	if err != nil {
+		return nil, err
+	}
+	if request.TeamColor == NoneTeam {
+		request.TeamColor = Auto
+	}
+
+	// use is counted before joining so that concurrent players can't exceed the limit, failed join gives it back
+	used, err := invites.Use(invite)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if !used {
+		return nil, errors.InviteUsedUp
+	}
+	if err := lobby.addPlayer(lobbies, accounts, statistics, account, &LobbyRequest{TeamColor: request.TeamColor}, true); err != nil {
+		if releaseErr := invites.Release(invite); releaseErr != nil {
+			return nil, errors.DatabaseError(releaseErr)
+		}
+		return nil, err
+	}
+	return lobby, nil
+}
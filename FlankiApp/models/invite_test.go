@@ -0,0 +1,107 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+	"time"
+)
+
+func privateLobby(t *testing.T, repos *repositories.Repositories, owner *models.Account) *models.Lobby {
+	t.Helper()
+	private := true
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Private lobby", PlayerLimit: 4, Private: &private, Password: "flanki"}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating lobby: %v", err)
+	}
+	return ownersLobby(t, repos, owner.ID)
+}
+
+func invite(t *testing.T, repos *repositories.Repositories, lobby *models.Lobby, request models.InviteRequest) *models.LobbyInvite {
+	t.Helper()
+	invite, err := lobby.CreateInvite(repos.Invites, repos.Accounts, &request, time.Now())
+	if err != nil {
+		t.Fatalf("creating invite: %v", err)
+	}
+	return invite
+}
+
+func joinWithInvite(repos *repositories.Repositories, player *models.Account, code string, now time.Time) error {
+	_, err := models.JoinWithInvite(repos.Invites, repos.Lobbies, repos.Accounts, repos.Statistics, player, code, &models.InviteJoinRequest{}, now)
+	return err
+}
+
+func TestInviteReplacesPasswordUntilUsedUp(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	first, second, third := newAccount(t, repos, "first"), newAccount(t, repos, "second"), newAccount(t, repos, "third")
+	lobby := privateLobby(t, repos, owner)
+
+	twice := uint(2)
+	code := invite(t, repos, lobby, models.InviteRequest{MaxUses: &twice}).Code
+	if len(code) != 12 {
+		t.Fatalf("expected 12 characters long code, got %q", code)
+	}
+	if err := joinWithInvite(repos, first, "unknown", time.Now()); httpCode(err) != 404 {
+		t.Fatalf("expected unknown code to be rejected, got %v", err)
+	}
+	if err := joinWithInvite(repos, first, code, time.Now()); err != nil {
+		t.Fatalf("expected invite to replace password, got %v", err)
+	}
+	// joining fails for the player already playing, which doesn't use the invite up
+	if err := joinWithInvite(repos, first, code, time.Now()); httpCode(err) != 400 {
+		t.Fatalf("expected playing player to be rejected, got %v", err)
+	}
+	if err := joinWithInvite(repos, second, code, time.Now()); err != nil {
+		t.Fatalf("expected second use to be allowed, got %v", err)
+	}
+	if err := joinWithInvite(repos, third, code, time.Now()); httpCode(err) != 410 {
+		t.Fatalf("expected used up invite to be rejected, got %v", err)
+	}
+	if lobby = ownersLobby(t, repos, owner.ID); lobby.PlayersCount() != 2 {
+		t.Errorf("expected invited players in teams, got %+v", lobby.Teams)
+	}
+
+	expiring := uint(60)
+	code = invite(t, repos, lobby, models.InviteRequest{ExpiresIn: &expiring}).Code
+	if err := joinWithInvite(repos, third, code, time.Now().Add(2*time.Hour)); httpCode(err) != 410 {
+		t.Errorf("expected expired invite to be rejected, got %v", err)
+	}
+	if _, err := lobby.RevokeInvite(repos.Invites, code, time.Now()); err != nil {
+		t.Fatalf("revoking invite: %v", err)
+	}
+	if err := joinWithInvite(repos, third, code, time.Now()); httpCode(err) != 410 {
+		t.Errorf("expected revoked invite to be rejected, got %v", err)
+	}
+	if invites, _ := models.GetInvites(repos.Invites, lobby.ID); len(invites) != 2 || invites[0].Uses != 2 || invites[1].RevokedAt == nil {
+		t.Errorf("expected both invites listed with their uses, got %+v", invites)
+	}
+}
+
+func TestPersonalInviteIsUsedOnceByThePlayer(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner, invited, other := newAccount(t, repos, "owner"), newAccount(t, repos, "invited"), newAccount(t, repos, "other")
+	lobby := privateLobby(t, repos, owner)
+
+	plenty := uint(10)
+	if _, err := lobby.CreateInvite(repos.Invites, repos.Accounts, &models.InviteRequest{PlayerID: &owner.ID}, time.Now()); httpCode(err) != 400 {
+		t.Fatalf("expected owner not to invite themselves, got %v", err)
+	}
+	personal := invite(t, repos, lobby, models.InviteRequest{PlayerID: &invited.ID, MaxUses: &plenty})
+	if *personal.MaxUses != 1 {
+		t.Fatalf("expected personal invite to be used once, got %d", *personal.MaxUses)
+	}
+	if err := joinWithInvite(repos, other, personal.Code, time.Now()); httpCode(err) != 403 {
+		t.Fatalf("expected other player to be rejected, got %v", err)
+	}
+	if err := joinWithInvite(repos, invited, personal.Code, time.Now()); err != nil {
+		t.Fatalf("expected invited player to join, got %v", err)
+	}
+	lobby = ownersLobby(t, repos, owner.ID)
+	if err := lobby.RemovePlayer(repos.Lobbies, repos.Accounts, invited.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := joinWithInvite(repos, invited, personal.Code, time.Now()); httpCode(err) != 410 {
+		t.Errorf("expected personal invite to be used once, got %v", err)
+	}
+}
@@ -2,9 +2,9 @@ package models
 
 import (
 	"FlankiRest/errors"
+	"FlankiRest/validation"
 	"encoding/json"
 	"github.com/evanphx/json-patch"
-	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 	"reflect"
 	"strconv"
@@ -23,15 +23,41 @@ type LobbyModel struct {
 type Lobby struct {
 	LobbyModel
 	OwnerID     uint      `json:"lobby_owner"`
-	Name        string    `json:"name"`
-	PlayerLimit uint      `json:"player_limit"`
-	Password    string    `json:"password,omitempty"`
+	OwnerSeenAt *time.Time `json:"-"` // lobby is handed over to its players when the owner is idle for too long
+	CoOwners    []LobbyCoOwner `json:"co_owners,omitempty"` // manage the lobby together with the owner
+	Name        string    `json:"name" validate:"min=4,max=50"`
+	PlayerLimit uint      `json:"player_limit" validate:"min=4,max=20"`
+	TeamLimit   *uint     `json:"team_limit,omitempty" validate:"max=10"` // optional cap of players in each team, 0 means no cap
+	SpectatorLimit *uint  `json:"spectator_limit,omitempty" validate:"max=100"` // optional cap of spectators, 0 means no cap
+	Password    string    `json:"password,omitempty" validate:"when=Private,min=4,max=20"`
 	Private     *bool     `json:"private"`
-	Closed      *bool     `json:"closed"`
+	Visibility  LobbyVisibility `json:"visibility,omitempty" validate:"omitempty,oneof=everyone friends"` // who can see and join the lobby, everyone when not given
+	Closed      *bool     `json:"closed"` // lobby is finished or cancelled
 	Teams       []Team    `json:"teams"`
+	Spectators  []LobbySpectator `json:"spectators,omitempty" gorm:"-"` // kept apart from teams, filled by LoadSpectators
 	Winner      TeamColor `json:"winner,omitempty"`
-	Longitude   float64   `json:"longitude"`
-	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude" validate:"min=-180,max=180"`
+	Latitude    float64   `json:"latitude" validate:"min=-90,max=90"`
+	RefereeID   *uint     `json:"referee_id,omitempty"` // records match events together with the owner, 0 means none
+	TournamentMatchID *uint `json:"tournament_match_id,omitempty"` // lobbies of tournaments are created by them
+
+	// lobby can be announced ahead of the match, players respond whether they are coming and are reminded before it starts
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	Timezone   string     `json:"timezone,omitempty" validate:"max=64"` // IANA name, UTC when not given
+	RemindedAt *time.Time `json:"-"`
+
+	// state is changed only by transitions, each of them records when it happened
+	State        LobbyState `json:"state"`
+	ReadyCheckAt *time.Time `json:"ready_check_at,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+
+	// results of lobbies confirming them are pending until a member of the other team confirms them
+	ConfirmResults *bool          `json:"confirm_results,omitempty"`
+	ResultStatus   ResultStatus   `json:"result_status,omitempty"`
+	ResultDeadline *time.Time     `json:"result_deadline,omitempty"` // pending result is confirmed, confirmed one can be disputed until then
+	ResultHistory  []ResultRecord `json:"result_history,omitempty"`
 }
 
 type LobbyListing struct {
@@ -40,33 +66,56 @@ type LobbyListing struct {
 	Name        string    `json:"name"`
 	PlayerLimit uint      `json:"player_limit"`
 	Private     bool      `json:"private"`
+	Visibility  LobbyVisibility `json:"visibility"`
 	Players     int       `json:"players"`
 	CreatedAt   time.Time `json:"created_at"`
 	Longitude   float64   `json:"longitude"`
 	Latitude    float64   `json:"latitude"`
+	State       LobbyState `json:"state"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	Timezone    string     `json:"timezone,omitempty"`
 }
 
-func NewLobbyListing(lobby *Lobby, db *gorm.DB) *LobbyListing {
-	players := lobby.Teams[0].TeamEntriesCount(db) + lobby.Teams[1].TeamEntriesCount(db)
-	return &LobbyListing{lobby.ID,lobby.OwnerID, lobby.Name, lobby.PlayerLimit, *lobby.Private, players, lobby.CreatedAt, lobby.Longitude, lobby.Latitude}
+func NewLobbyListing(lobby *Lobby) *LobbyListing {
+	players := lobby.PlayersCount()
+	return &LobbyListing{lobby.ID,lobby.OwnerID, lobby.Name, lobby.PlayerLimit, *lobby.Private, lobby.Visibility, players, lobby.CreatedAt, lobby.Longitude, lobby.Latitude, lobby.State, lobby.StartsAt, lobby.Timezone}
 }
 
 
 
 type UpdateLobby struct {
-	Name 		string 	`json:"name,omitempty"`
-	PlayerLimit uint   `json:"player_limit"`
-	Password    string `json:"password,omitempty"`
+	Name 		string 	`json:"name,omitempty" validate:"omitempty,min=4,max=50"`
+	PlayerLimit uint   `json:"player_limit" validate:"min=4,max=20"`
+	TeamLimit   *uint  `json:"team_limit,omitempty" validate:"max=10"`
+	SpectatorLimit *uint `json:"spectator_limit,omitempty" validate:"max=100"`
+	ConfirmResults *bool `json:"confirm_results,omitempty"`
+	RefereeID   *uint  `json:"referee_id,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	Timezone    string `json:"timezone,omitempty" validate:"max=64"`
+	Password    string `json:"password,omitempty" validate:"when=Private,required,min=4,max=20"`
 	Private     *bool `json:"private"`
-	Longitude   float64   `json:"longitude"`
-	Latitude    float64   `json:"latitude"`
+	Visibility  LobbyVisibility `json:"visibility,omitempty" validate:"omitempty,oneof=everyone friends"`
+	Longitude   float64   `json:"longitude" validate:"min=-180,max=180"`
+	Latitude    float64   `json:"latitude" validate:"min=-90,max=90"`
 }
 
 type MatchResult struct {
-	ID        uint      `json:"lobby_id"`
-	Winner    TeamColor `json:"winner"`
-	Teams     *[]Team   `json:"teams"`
-	Submitted time.Time `json:"finished"`
+	ID        uint       `json:"lobby_id"`
+	Winner    TeamColor  `json:"winner"`
+	Teams     *[]Team    `json:"teams"`
+	Started   *time.Time `json:"started,omitempty"`
+	Submitted time.Time  `json:"finished"`
+	Status    ResultStatus `json:"status"`
+	Players   []PlayerMatchStats `json:"players,omitempty"` // only when events of the match have been recorded
+}
+
+// NewMatchResult describes finished lobby, lobbies finished before states were recorded have only their last update time
+func NewMatchResult(lobby *Lobby) *MatchResult {
+	result := &MatchResult{ID: lobby.ID, Winner: lobby.Winner, Teams: &lobby.Teams, Started: lobby.StartedAt, Submitted: lobby.UpdatedAt, Status: lobby.ResultStatus}
+	if lobby.FinishedAt != nil {
+		result.Submitted = *lobby.FinishedAt
+	}
+	return result
 }
 
 func (lobby *Lobby) IsEmpty() bool {
@@ -74,35 +123,58 @@ func (lobby *Lobby) IsEmpty() bool {
 }
 
 
-func (lobby *Lobby) Validate(db *gorm.DB) error {
-	_, err := GetOwnersLobby(db, lobby.OwnerID, false)
-	if err == nil {
-		return errors.New("Player is already an owner of a lobby", 400)
+// changesUint tells whether the update sets a value other than the current one, missing values don't change anything
+func changesUint(update *uint, current *uint) bool {
+	if update == nil {
+		return false
+	}
+	if current == nil {
+		return *update != 0
 	}
+	return *update != *current
+}
 
-	if lobby.PlayerLimit < 4 || lobby.PlayerLimit > 20  {
-		return errors.New("Wrong player limit, should be from 4 to 20 players", 400)
+func changesBool(update *bool, current *bool) bool {
+	if update == nil {
+		return false
 	}
+	if current == nil {
+		return *update
+	}
+	return *update != *current
+}
 
-	if size := len(lobby.Name); size < 4 || size > 50 {
-		return errors.New("Wrong lobby's name size, should be from 4 to 50 characters", 400)
+func (lobby *Lobby) Validate(lobbies LobbyRepository) error {
+	_, err := GetOwnersLobby(lobbies, lobby.OwnerID)
+	if err == nil {
+		return errors.New("Player is already an owner of a lobby", 400)
 	}
 
 	if lobby.Private == nil {
 		private := false
 		lobby.Private = &private
 	}
+	if lobby.Visibility == "" {
+		lobby.Visibility = Everyone
+	}
 
-	if size := len(lobby.Password); *lobby.Private == true && (size < 4 || size > 20) {
-		return errors.New("Wrong password size, should be from 4 to 20 characters", 400)
+	if err := validation.Check(lobby); err != nil {
+		return err
 	}
-	return nil
+	return lobby.checkSchedule(true, time.Now())
 }
 
 func (lobby* Lobby) GetUpdateStruct() *UpdateLobby {
 	updateLobby := &UpdateLobby{}
 	updateLobby.PlayerLimit = lobby.PlayerLimit
+	updateLobby.TeamLimit   = lobby.TeamLimit
+	updateLobby.SpectatorLimit = lobby.SpectatorLimit
+	updateLobby.ConfirmResults = lobby.ConfirmResults
+	updateLobby.RefereeID   = lobby.RefereeID
+	updateLobby.StartsAt    = lobby.StartsAt
+	updateLobby.Timezone    = lobby.Timezone
 	updateLobby.Private     = lobby.Private
+	updateLobby.Visibility  = lobby.Visibility
 	updateLobby.Password    = lobby.Password
 	updateLobby.Name        = lobby.Name
 	updateLobby.Longitude   = lobby.Longitude
@@ -110,13 +182,16 @@ func (lobby* Lobby) GetUpdateStruct() *UpdateLobby {
 	return updateLobby
 }
 
-func (lobby *Lobby) Create(db *gorm.DB) error {
+func (lobby *Lobby) Create(lobbies LobbyRepository) error {
 	check := false
-	err := lobby.Validate(db)
+	err := lobby.Validate(lobbies)
 	if err != nil {
 		return err
 	}
 	lobby.Closed = &check
+	lobby.State = Open
+	now := time.Now()
+	lobby.OwnerSeenAt = &now
 	lobby.Winner = NoneTeam
 	if *lobby.Private == true {
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(lobby.Password), bcrypt.MinCost)
@@ -129,7 +204,7 @@ func (lobby *Lobby) Create(db *gorm.DB) error {
 	redTeam := Team{TeamColor: Red, TeamEntries: []TeamEntry{}}
 	lobby.Teams = []Team{blueTeam, redTeam}
 
-	err = db.Create(lobby).Error
+	err = lobbies.Create(lobby)
 	if err != nil {
 		return errors.New("Failed to create lobby, connection error.", 500)
 	}
@@ -138,15 +213,15 @@ func (lobby *Lobby) Create(db *gorm.DB) error {
 	return nil
 }
 
-func (lobby *Lobby) Delete(db *gorm.DB) error {
+func (lobby *Lobby) Delete(lobbies LobbyRepository, accounts AccountRepository) error {
 	if *lobby.Closed == true {
 		return errors.New("Cannot delete closed lobby", 400)
 	}
-	err := lobby.Close(db)
+	err := lobby.Close(lobbies, accounts)
 	if err != nil {
 		return err
 	}
-	err = db.Unscoped().Delete(lobby).Error // player entries and teams are set to delete on CASCADE
+	err = lobbies.Delete(lobby) // player entries and teams are set to delete on CASCADE
 	if err != nil {
 		return errors.DatabaseError(err)
 	}
@@ -154,24 +229,63 @@ func (lobby *Lobby) Delete(db *gorm.DB) error {
 }
 
 
-func (lobby *Lobby) Close(db *gorm.DB) error {
+// Close cancels the lobby without results, lobby has to be fetched together with its teams
+func (lobby *Lobby) Close(lobbies LobbyRepository, accounts AccountRepository) error {
 	if *lobby.Closed == true {
 		return errors.New("Last lobby has been already closed", 400)
 	}
+	return lobby.end(lobbies, accounts, Cancelled)
+}
+
+// SubmitResults finishes started match, players' statistics are saved before the lobby is closed.
+// Results of lobbies confirming them stay pending with frozen statistics until confirmed or until the timeout passes,
+// other results can be disputed until then. Confirmed results of tournament's lobbies advance its bracket,
+// pending ones advance it once they are confirmed.
+// Lobby has to be fetched together with its teams
+func (lobby *Lobby) SubmitResults(lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, tournaments TournamentRepository,
+	submitterID uint, winner TeamColor, timeout time.Duration) error {
+	if err := lobby.CheckTransition(Finished); err != nil {
+		return err
+	}
+	lobby.Winner = winner
+	lobby.ResultStatus = ResultConfirmed
+	if lobby.ConfirmResults != nil && *lobby.ConfirmResults {
+		lobby.ResultStatus = ResultPending
+	}
+	deadline := time.Now().Add(timeout)
+	lobby.ResultDeadline = &deadline
+	if err := SubmitMatch(statistics, lobby); err != nil {
+		return err
+	}
+	if err := lobby.end(lobbies, accounts, Finished); err != nil {
+		return err
+	}
+	if err := lobby.record(lobbies, &ResultRecord{Event: ResultSubmitted, PlayerID: submitterID, Winner: winner}); err != nil {
+		return err
+	}
+	if lobby.ResultStatus == ResultConfirmed && lobby.TournamentMatchID != nil {
+		return lobby.advanceTournament(tournaments, lobbies, accounts)
+	}
+	return nil
+}
 
-	playersToBeStoppedPlaying, err  := lobby.GetLobbyPlayersIds(db)
+// end closes lobby in the final state and stops its players playing
+func (lobby *Lobby) end(lobbies LobbyRepository, accounts AccountRepository, state LobbyState) error {
+	playersToBeStoppedPlaying, err  := lobby.GetLobbyPlayersIds()
 	if err!= nil {
 		return err
 	}
 
-	*lobby.Closed = true
-	err = db.Save(lobby).Error
+	if err = lobby.transition(state); err != nil {
+		return err
+	}
+	err = lobbies.Save(lobby)
 	if err != nil {
 		return errors.New("Database error while closing lobby", 500)
 	}
 
 	for _, id := range playersToBeStoppedPlaying {
-		err = db.Model(&Account{}).Where("id = ?", id).Update("playing", false).Error
+		err = accounts.SetPlaying(id, false)
 		if err != nil {
 			return errors.New("Database error while changing players' playing status: " + err.Error() , 500)
 		}
@@ -179,15 +293,20 @@ func (lobby *Lobby) Close(db *gorm.DB) error {
 	return nil
 }
 
-// this lobby have to contain owner's id beside date to be updated
-func (lobby *Lobby) Update(db *gorm.DB) error {
+// this lobby have to contain owner's id beside date to be updated, updaterID is the owner or one of lobby's managers.
+// Only the owner can change the referee and whether results have to be confirmed, managers would otherwise
+// swap the referee or skip the confirmation of results they submit
+func (lobby *Lobby) Update(lobbies LobbyRepository, updaterID uint) error {
 
-	ownersLobby, err := GetOwnersLobby(db, lobby.OwnerID, false)
-	if err == gorm.ErrRecordNotFound {
-		return errors.New("Player was not an owner of any ownersLobby", 400)
-	}
+	ownersLobby, err := GetOwnersLobby(lobbies, lobby.OwnerID)
 	if err != nil {
-		return errors.New("Database error while trying to get ownersLobby: " + err.Error(), 500)
+		return err
+	}
+	if !ownersLobby.RosterIsOpen() {
+		return errors.RosterLocked
+	}
+	if updaterID != ownersLobby.OwnerID && (changesUint(lobby.RefereeID, ownersLobby.RefereeID) || changesBool(lobby.ConfirmResults, ownersLobby.ConfirmResults)) {
+		return errors.New("Only the owner can change the referee and confirmation of results", 403)
 	}
 
 	// have to assign those fields because json probably assigned them defaults when they were not present in the request
@@ -197,23 +316,35 @@ func (lobby *Lobby) Update(db *gorm.DB) error {
 	if lobby.Private == nil {
 		lobby.Private = ownersLobby.Private
 	}
-
-	if  lobby.PlayerLimit < 4 || lobby.PlayerLimit > 20 {
-		return errors.New("Wrong player limit, should be from 4 to 20 players", 400)
+	if lobby.Visibility == "" {
+		lobby.Visibility = ownersLobby.Visibility
 	}
-
-	if *lobby.Private == true && lobby.Password == "" {
-		return errors.New("Password was not given while ownersLobby is set to private", 400)
+	if lobby.TeamLimit == nil {
+		lobby.TeamLimit = ownersLobby.TeamLimit
 	}
-
-	if size := len(lobby.Password); *lobby.Private == true && (size < 4 || size > 20) {
-		return errors.New("Wrong password size, should be from 4 to 20 characters", 400)
+	if lobby.SpectatorLimit == nil {
+		lobby.SpectatorLimit = ownersLobby.SpectatorLimit
+	}
+	if lobby.ConfirmResults == nil {
+		lobby.ConfirmResults = ownersLobby.ConfirmResults
+	}
+	if lobby.RefereeID == nil {
+		lobby.RefereeID = ownersLobby.RefereeID
+	}
+	rescheduled := lobby.StartsAt != nil && (ownersLobby.StartsAt == nil || !lobby.StartsAt.Equal(*ownersLobby.StartsAt))
+	if lobby.StartsAt == nil {
+		lobby.StartsAt = ownersLobby.StartsAt
+	}
+	if lobby.Timezone == "" {
+		lobby.Timezone = ownersLobby.Timezone
 	}
 
-	if lobby.Name != "" {
-		if size := len(lobby.Name); size < 4 || size > 50 {
-			return errors.New("Wrong ownersLobby's name size, should be from 4 to 50 characters", 400)
-		}
+	if err = validation.Check(lobby.GetUpdateStruct()); err != nil {
+		return err
+	}
+	// start already announced doesn't have to be in the future anymore
+	if err = lobby.checkSchedule(rescheduled, time.Now()); err != nil {
+		return err
 	}
 
 	// such a hack, no one ever lived on either coordinate 0 I guess, not in Poland at least
@@ -235,6 +366,11 @@ func (lobby *Lobby) Update(db *gorm.DB) error {
 	if err != nil {
 		return errors.New("Failed to update ownersLobby information", 500)
 	}
+	// players are reminded again about the new start
+	if !rescheduled {
+		newLobby.RemindedAt = ownersLobby.RemindedAt
+	}
+	newLobby.OwnerSeenAt = ownersLobby.OwnerSeenAt
 
 	if *newLobby.Private == true {
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(newLobby.Password), bcrypt.DefaultCost)
@@ -242,50 +378,126 @@ func (lobby *Lobby) Update(db *gorm.DB) error {
 	} else {
 		newLobby.Password = ""
 	}
-	err = db.Save(newLobby).Error
+	err = lobbies.Save(newLobby)
 	if err != nil {
 		return errors.New("Database error: " + err.Error(), 500)
 	}
 	return nil
 }
 
-func (lobby *Lobby) GetLobbyPlayersIds(db *gorm.DB) ([]uint, error) {
-	var teams []Team
-	err := db.Model(&lobby).Related(&teams).Error
-	if err != nil {
-		return nil, errors.New("Cannot find lobby's teams: " + err.Error(), 500)
+// lobby has to be fetched together with its teams
+func (lobby *Lobby) GetLobbyPlayersIds() ([]uint, error) {
+	if len(lobby.Teams) != 2 {
+		return nil, errors.New("Fetched only " + strconv.Itoa(len(lobby.Teams)) + " teams but should be 2", 500)
 	}
-	if len(teams) != 2 {
-		return nil, errors.New("Fetched only " + strconv.Itoa(len(teams)) + " teams but should be 2", 500)
+
+	ids := make([]uint, 0, lobby.PlayersCount())
+	for _, team := range lobby.Teams {
+		for _, entry := range team.TeamEntries {
+			// entries of erased players aren't linked to any account
+			if entry.PlayerID != 0 {
+				ids = append(ids, entry.PlayerID)
+			}
+		}
 	}
+	return ids, nil
+}
 
-	var blueEntries []TeamEntry
-	var redEntries []TeamEntry
-	err = db.Model(&teams[0]).Related(&blueEntries).Error
-	err = db.Model(&teams[1]).Related(&redEntries).Error
+func (lobby *Lobby) PlayersCount() int {
+	count := 0
+	for _, team := range lobby.Teams {
+		count += team.TeamEntriesCount()
+	}
+	return count
+}
+
+func (lobby *Lobby) GetTeam(color TeamColor) *Team {
+	for i := range lobby.Teams {
+		if lobby.Teams[i].TeamColor == color {
+			return &lobby.Teams[i]
+		}
+	}
+	return nil
+}
+
+// AddPlayer puts account's owner into the team chosen in the join request or by AutoTeam
+func (lobby *Lobby) AddPlayer(lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, account *Account, request *LobbyRequest) error {
+	return lobby.addPlayer(lobbies, accounts, statistics, account, request, false)
+}
+
+// invited players don't need the password of private lobby
+func (lobby *Lobby) addPlayer(lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, account *Account, request *LobbyRequest, invited bool) error {
+	if account.Playing == true {
+		return errors.New("User is already playing, can't join to new team", 400)
+	}
+	if !lobby.RosterIsOpen() {
+		return errors.RosterLocked
+	}
+	if lobby.TournamentMatchID != nil {
+		return errors.New("Players of tournament matches are put into lobbies with their teams", 403)
+	}
+
+	err := request.Validate()
 	if err != nil {
-		return nil, errors.New("Error while fetching players from team: " + err.Error(), 500)
+		return err
 	}
-	ids := make([]uint, 0, len(blueEntries)+len(redEntries))
-	for _, id := range blueEntries {
-		ids = append(ids, id.PlayerID)
+
+	if *lobby.Private == true && !invited {
+		if ok := request.CheckPassword(lobby); !ok {
+			return errors.UnauthorizedLobbyJoinRequest
+		}
 	}
-	for _, id := range redEntries {
-		ids = append(ids, id.PlayerID)
+
+	if lobby.PlayersCount() >= int(lobby.PlayerLimit) {
+		return errors.LobbyIsFull
 	}
-	return ids, nil
+
+	color := request.TeamColor
+	if color == Auto {
+		color, err = lobby.AutoTeam(statistics)
+		if err != nil {
+			return err
+		}
+	}
+	team := lobby.GetTeam(color)
+	if team == nil {
+		return errors.New("Lobby doesn't have team with color " + string(color), 500)
+	}
+	if lobby.TeamIsFull(team) {
+		return errors.TeamIsFull
+	}
+
+	entry := &TeamEntry{PlayerID: account.ID, Nickname: account.Nickname}
+	err = team.AddNewEntry(lobbies, entry)
+	if err != nil {
+		return errors.New("Database error while joining lobby: " + err.Error(), 500)
+	}
+	err = accounts.SetPlaying(account.ID, true)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	account.Playing = true
+	return nil
 }
-func GetOwnersLobby(db *gorm.DB, id uint, preloadTeamEntries bool) (*Lobby, error) {
 
-	lobby := &Lobby{}
-	var dbcon *gorm.DB
-	dbcon = db
-	if preloadTeamEntries {
-		dbcon = db.Preload("Teams.TeamEntries")
+// RemovePlayer deletes player from whichever lobby's team he belongs to
+func (lobby *Lobby) RemovePlayer(lobbies LobbyRepository, accounts AccountRepository, playerID uint) error {
+	if !lobby.RosterIsOpen() {
+		return errors.RosterLocked
+	}
+	for i := range lobby.Teams {
+		team := &lobby.Teams[i]
+		if team.ContainsPlayerWithId(playerID) {
+			return team.DeleteEntryWithPlayerId(lobbies, accounts, playerID)
+		}
 	}
-	err := dbcon.Where("owner_id = ? AND closed = ?", id, false).First(&lobby).Error
+	return errors.PlayerNotFoundInAnyTeam
+}
+
+func GetOwnersLobby(lobbies LobbyRepository, id uint) (*Lobby, error) {
+	lobby, err := lobbies.GetOpenByOwner(id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if err == errors.RecordNotFound {
 			return nil, errors.New("Player is not an owner of any opened lobby", 404)
 		}
 		return nil, errors.New("Error while getting owner's lobby: " + err.Error(),500)
@@ -293,11 +505,21 @@ func GetOwnersLobby(db *gorm.DB, id uint, preloadTeamEntries bool) (*Lobby, erro
 	return lobby, nil
 }
 
-func GetLobbyByIdFunc(db *gorm.DB, id uint) (*Lobby, error) {
-	lobby := &Lobby{}
-	err := db.Preload("Teams.TeamEntries").First(&lobby, id).Error
+func GetPlayersLobby(lobbies LobbyRepository, playerID uint) (*Lobby, error) {
+	lobby, err := lobbies.GetOpenByPlayer(playerID)
+	if err != nil {
+		if err == errors.RecordNotFound {
+			return nil, errors.PlayerNotActive
+		}
+		return nil, errors.DatabaseError(err)
+	}
+	return lobby, nil
+}
+
+func GetLobbyByIdFunc(lobbies LobbyRepository, id uint) (*Lobby, error) {
+	lobby, err := lobbies.GetById(id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if err == errors.RecordNotFound {
 			return nil, errors.New("Lobby has not been found", 404)
 		}
 		return nil, errors.New("Database error while getting lobby by id: " + err.Error(), 500)
@@ -305,16 +527,15 @@ func GetLobbyByIdFunc(db *gorm.DB, id uint) (*Lobby, error) {
 	return lobby, nil
 }
 
-func GetAllLobbies(db *gorm.DB, closed bool) ([]*Lobby, error) {
-	var lobbies []*Lobby
-	err := db.Preload("Teams.TeamEntries").Where("closed = ?", closed).Order("updated_at desc").Limit(100).Find(&lobbies).Error
+func GetAllLobbies(lobbies LobbyRepository, closed bool) ([]*Lobby, error) {
+	list, err := lobbies.GetAll(closed, 100)
 
 	if err != nil {
 		return nil, errors.New("Database error while getting list of lobbies: " + err.Error(), 500)
 	}
-	for _, lobby := range lobbies {
+	for _, lobby := range list {
 		lobby.Password = ""
 	}
-	return lobbies, nil
+	return list, nil
 }
 
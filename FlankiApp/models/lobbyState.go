@@ -0,0 +1,147 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"time"
+)
+
+type LobbyState string
+
+const (
+	// players join and leave freely
+	Open LobbyState = "open"
+	// owner waits for all players to be ready, players can still join and leave
+	ReadyCheck LobbyState = "ready_check"
+	// teams are locked until results are submitted
+	InProgress LobbyState = "in_progress"
+	Finished   LobbyState = "finished"
+	// closed or deleted without results
+	Cancelled LobbyState = "cancelled"
+)
+
+type ReadyRequest struct {
+	Ready *bool `json:"ready"`
+}
+
+// states lobby can go to from each state, finished and cancelled lobbies stay as they are
+var lobbyTransitions = map[LobbyState][]LobbyState{
+	Open:       {ReadyCheck, Cancelled},
+	ReadyCheck: {InProgress, Cancelled},
+	InProgress: {Finished, Cancelled},
+}
+
+// CheckTransition returns error when lobby can't go from its current state to the given one
+func (lobby *Lobby) CheckTransition(to LobbyState) error {
+	for _, state := range lobbyTransitions[lobby.State] {
+		if state == to {
+			return nil
+		}
+	}
+	return errors.New("Lobby can't go from "+string(lobby.State)+" to "+string(to)+" state", 409)
+}
+
+// transition changes state of the lobby and records when it happened, lobby isn't saved
+func (lobby *Lobby) transition(to LobbyState) error {
+	if err := lobby.CheckTransition(to); err != nil {
+		return err
+	}
+	now := time.Now()
+	switch to {
+	case ReadyCheck:
+		lobby.ReadyCheckAt = &now
+	case InProgress:
+		lobby.StartedAt = &now
+	case Finished:
+		lobby.FinishedAt = &now
+	case Cancelled:
+		lobby.CancelledAt = &now
+	}
+	lobby.State = to
+	closed := to == Finished || to == Cancelled
+	lobby.Closed = &closed
+	return nil
+}
+
+// RosterIsOpen tells whether players can still join, leave or be moved between teams
+func (lobby *Lobby) RosterIsOpen() bool {
+	return lobby.State == Open || lobby.State == ReadyCheck
+}
+
+// MatchDuration is time from starting the match to submitting its results, zero for unfinished matches
+func (lobby *Lobby) MatchDuration() time.Duration {
+	if lobby.StartedAt == nil || lobby.FinishedAt == nil {
+		return 0
+	}
+	return lobby.FinishedAt.Sub(*lobby.StartedAt)
+}
+
+// StartReadyCheck asks all players to confirm they are ready, ready flags set before are cleared.
+// Lobby has to be fetched together with its teams
+func (lobby *Lobby) StartReadyCheck(lobbies LobbyRepository) error {
+	if err := lobby.transition(ReadyCheck); err != nil {
+		return err
+	}
+	for i := range lobby.Teams {
+		for j := range lobby.Teams[i].TeamEntries {
+			entry := &lobby.Teams[i].TeamEntries[j]
+			if !entry.Ready {
+				continue
+			}
+			entry.Ready = false
+			if err := lobbies.SaveTeamEntry(entry); err != nil {
+				return errors.DatabaseError(err)
+			}
+		}
+	}
+	if err := lobbies.Save(lobby); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// SetReady marks player as ready to play or not, it can be changed until the match starts
+func (lobby *Lobby) SetReady(lobbies LobbyRepository, playerID uint, ready bool) error {
+	if !lobby.RosterIsOpen() {
+		return errors.RosterLocked
+	}
+	for i := range lobby.Teams {
+		for j := range lobby.Teams[i].TeamEntries {
+			entry := &lobby.Teams[i].TeamEntries[j]
+			if entry.PlayerID != playerID {
+				continue
+			}
+			entry.Ready = ready
+			if err := lobbies.SaveTeamEntry(entry); err != nil {
+				return errors.DatabaseError(err)
+			}
+			return nil
+		}
+	}
+	return errors.PlayerNotFoundInAnyTeam
+}
+
+// Start begins the match when all players have confirmed the ready check, teams can't be changed since then
+func (lobby *Lobby) Start(lobbies LobbyRepository) error {
+	if err := lobby.CheckTransition(InProgress); err != nil {
+		return err
+	}
+	for _, team := range lobby.Teams {
+		if team.TeamEntriesCount() == 0 {
+			return errors.TeamsNotComplete
+		}
+	}
+	for _, team := range lobby.Teams {
+		for _, entry := range team.TeamEntries {
+			if !entry.Ready {
+				return errors.PlayersNotReady
+			}
+		}
+	}
+	if err := lobby.transition(InProgress); err != nil {
+		return err
+	}
+	if err := lobbies.Save(lobby); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+	"time"
+)
+
+func ownersLobby(t *testing.T, repos *repositories.Repositories, ownerID uint) *models.Lobby {
+	t.Helper()
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, ownerID)
+	if err != nil {
+		t.Fatalf("fetching owner's lobby: %v", err)
+	}
+	return lobby
+}
+
+func setReady(t *testing.T, repos *repositories.Repositories, player *models.Account) {
+	t.Helper()
+	lobby, err := models.GetPlayersLobby(repos.Lobbies, player.ID)
+	if err != nil {
+		t.Fatalf("fetching player's lobby: %v", err)
+	}
+	if err = lobby.SetReady(repos.Lobbies, player.ID, true); err != nil {
+		t.Fatalf("setting ready flag of %s: %v", player.Nickname, err)
+	}
+}
+
+func TestMatchGoesThroughStates(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	blue := newAccount(t, repos, "blueplayer")
+	red := newAccount(t, repos, "redplayer")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	if lobby.State != models.Open {
+		t.Fatalf("new lobby should be open, got %s", lobby.State)
+	}
+	join(t, repos, lobby.ID, blue, models.Blue)
+
+	lobby = ownersLobby(t, repos, owner.ID)
+	if err := lobby.SubmitResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, owner.ID, models.Blue, time.Hour); httpCode(err) != 409 {
+		t.Fatalf("expected results of open lobby to be rejected, got %v", err)
+	}
+	if err := lobby.StartReadyCheck(repos.Lobbies); err != nil {
+		t.Fatalf("starting ready check: %v", err)
+	}
+	if err := ownersLobby(t, repos, owner.ID).Start(repos.Lobbies); err != errors.TeamsNotComplete {
+		t.Fatalf("expected match without red players not to start, got %v", err)
+	}
+
+	// players can still join during ready check
+	join(t, repos, lobby.ID, red, models.Red)
+	setReady(t, repos, blue)
+	if err := ownersLobby(t, repos, owner.ID).Start(repos.Lobbies); err != errors.PlayersNotReady {
+		t.Fatalf("expected match not to start before all players are ready, got %v", err)
+	}
+	setReady(t, repos, red)
+	lobby = ownersLobby(t, repos, owner.ID)
+	if err := lobby.Start(repos.Lobbies); err != nil {
+		t.Fatalf("starting match: %v", err)
+	}
+
+	lobby = ownersLobby(t, repos, owner.ID)
+	if lobby.State != models.InProgress || lobby.ReadyCheckAt == nil || lobby.StartedAt == nil {
+		t.Fatalf("expected started match with timestamps, got %+v", lobby)
+	}
+	if err := lobby.RemovePlayer(repos.Lobbies, repos.Accounts, red.ID); err != errors.RosterLocked {
+		t.Fatalf("expected teams to be locked, got %v", err)
+	}
+	late := newAccount(t, repos, "late")
+	if err := lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, late, &models.LobbyRequest{TeamColor: models.Red}); err != errors.RosterLocked {
+		t.Fatalf("expected joining started match to be rejected, got %v", err)
+	}
+
+	if err := lobby.SubmitResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, owner.ID, models.Red, time.Hour); err != nil {
+		t.Fatalf("submitting results: %v", err)
+	}
+	finished, _ := models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if finished.State != models.Finished || !*finished.Closed || finished.Winner != models.Red || finished.FinishedAt == nil {
+		t.Fatalf("expected finished lobby, got %+v", finished)
+	}
+	if finished.MatchDuration() < 0 || finished.FinishedAt.Before(*finished.StartedAt) {
+		t.Fatalf("match should finish after it has started")
+	}
+	if summary, _ := repos.Statistics.GetSummary(red.ID); summary.Wins != 1 {
+		t.Fatalf("expected red player's win to be recorded, got %+v", summary)
+	}
+	if err := finished.Close(repos.Lobbies, repos.Accounts); httpCode(err) != 400 {
+		t.Fatalf("expected finished lobby not to be closed again, got %v", err)
+	}
+}
+
+func TestReadyCheckClearsReadyFlags(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	join(t, repos, lobby.ID, player, models.Blue)
+	setReady(t, repos, player)
+
+	if err := ownersLobby(t, repos, owner.ID).StartReadyCheck(repos.Lobbies); err != nil {
+		t.Fatalf("starting ready check: %v", err)
+	}
+	lobby = ownersLobby(t, repos, owner.ID)
+	if lobby.State != models.ReadyCheck || lobby.GetTeam(models.Blue).TeamEntries[0].Ready {
+		t.Fatalf("expected ready check with cleared flags, got %+v", lobby)
+	}
+	if err := lobby.StartReadyCheck(repos.Lobbies); httpCode(err) != 409 {
+		t.Fatalf("expected second ready check to be rejected, got %v", err)
+	}
+
+	if err := lobby.Close(repos.Lobbies, repos.Accounts); err != nil {
+		t.Fatalf("closing lobby: %v", err)
+	}
+	cancelled, _ := models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if cancelled.State != models.Cancelled || cancelled.CancelledAt == nil {
+		t.Fatalf("expected cancelled lobby, got %+v", cancelled)
+	}
+}
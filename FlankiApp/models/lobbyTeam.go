@@ -2,8 +2,7 @@ package models
 
 import (
 	"FlankiRest/errors"
-	"fmt"
-	"github.com/jinzhu/gorm"
+	"FlankiRest/validation"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -12,6 +11,7 @@ type TeamEntry struct {
 	PlayerID uint `json:"player_id"`
 	Nickname string `json:"nickname,omitempty"`
 	TeamID uint `json:"-"`
+	Ready bool `json:"ready"`
 }
 
 type TeamColor string
@@ -21,6 +21,8 @@ const (
 	Red TeamColor = "red"
 	NoneTeam TeamColor = ""
 	Spectator TeamColor = "spectator"
+	// joining with auto puts the player into the team chosen by the lobby
+	Auto TeamColor = "auto"
 )
 
 type Team struct {
@@ -31,15 +33,12 @@ type Team struct {
 }
 
 type LobbyRequest struct {
-	TeamColor TeamColor `json:"team_color"`
+	TeamColor TeamColor `json:"team_color" validate:"oneof=red blue auto"`
 	Password string `json:"password"`
 }
 
 func (request *LobbyRequest) Validate() error {
-	if request.TeamColor != Red && request.TeamColor != Blue {
-		return errors.New("Invalid team color", 400)
-	}
-	return nil
+	return validation.Check(request)
 }
 
 func (request *LobbyRequest) CheckPassword(lobby *Lobby) bool {
@@ -50,32 +49,28 @@ func (request *LobbyRequest) CheckPassword(lobby *Lobby) bool {
 	return true
 }
 
-func (team *Team) AddNewEntry(db *gorm.DB, entry * TeamEntry) error {
-	return db.Model(&team).Association("TeamEntries").Append(entry).Error
+func (team *Team) AddNewEntry(lobbies LobbyRepository, entry * TeamEntry) error {
+	return lobbies.AddTeamEntry(team, entry)
 }
 
-func (team *Team) ContainsPlayerWithId(db *gorm.DB, id uint) bool {
-	var count []uint
-	err := db.Raw(fmt.Sprintf("SELECT count(team_entries.player_id) FROM teams INNER JOIN team_entries ON teams.id = team_entries.team_id WHERE teams.id = %d AND team_entries.player_id = %d", team.ID, id)).Pluck("id",&count).Error
-	if err != nil {
-		return false
+func (team *Team) ContainsPlayerWithId(id uint) bool {
+	for _, entry := range team.TeamEntries {
+		if entry.PlayerID == id {
+			return true
+		}
 	}
-	return count[0] > 0
+	return false
 }
 
-func (team *Team) DeleteEntryWithPlayerId(db *gorm.DB, id uint) error {
-	var entries []TeamEntry
-	err := db.Model(&team).Association("TeamEntries").Find(&entries).Error
-	if err != nil {
-		return errors.New("Database error while fetching team's players: " + err.Error(), 500)
-	}
-	for _, entry := range entries {
+func (team *Team) DeleteEntryWithPlayerId(lobbies LobbyRepository, accounts AccountRepository, id uint) error {
+	for i, entry := range team.TeamEntries {
 		if entry.PlayerID == id {
-			err = db.Delete(&entry).Error
+			err := lobbies.DeleteTeamEntry(&entry)
 			if err != nil {
 				return errors.New("Database error while deleting entry from team: " + err.Error(), 500)
 			}
-			err = db.Model(&Account{}).Where("id = ?", id).Update("playing", false).Error
+			team.TeamEntries = append(team.TeamEntries[:i], team.TeamEntries[i+1:]...)
+			err = accounts.SetPlaying(id, false)
 			if err != nil {
 				return errors.New("Database error while updating player's playing status: " + err.Error(), 500)
 			}
@@ -85,8 +80,7 @@ func (team *Team) DeleteEntryWithPlayerId(db *gorm.DB, id uint) error {
 	return errors.New("Player as not been found in a team", 404)
 }
 
-func (team *Team) TeamEntriesCount(db *gorm.DB) int {
-	return db.Model(&team).Association("TeamEntries").Count()
+func (team *Team) TeamEntriesCount() int {
+	return len(team.TeamEntries)
 }
 
-
@@ -0,0 +1,240 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+)
+
+func newAccount(t *testing.T, repos *repositories.Repositories, nickname string) *models.Account {
+	t.Helper()
+	account := &models.Account{Nickname: nickname, Email: nickname + "@flanki.pl", Password: "secret123", Sex: "male"}
+	if err := account.Create(repos.Accounts); err != nil {
+		t.Fatalf("creating account %s: %v", nickname, err)
+	}
+	return account
+}
+
+func newLobby(t *testing.T, repos *repositories.Repositories, ownerID uint, limit uint) *models.Lobby {
+	t.Helper()
+	lobby := &models.Lobby{OwnerID: ownerID, Name: "Test lobby", PlayerLimit: limit}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating lobby: %v", err)
+	}
+	fetched, err := models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if err != nil {
+		t.Fatalf("fetching lobby: %v", err)
+	}
+	return fetched
+}
+
+func join(t *testing.T, repos *repositories.Repositories, lobbyID uint, account *models.Account, color models.TeamColor) {
+	t.Helper()
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, lobbyID)
+	if err != nil {
+		t.Fatalf("fetching lobby: %v", err)
+	}
+	if err = lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, account, &models.LobbyRequest{TeamColor: color}); err != nil {
+		t.Fatalf("joining lobby: %v", err)
+	}
+}
+
+func httpCode(err error) int {
+	if apiErr, ok := err.(*errors.ApiError); ok {
+		return apiErr.HttpCode
+	}
+	return 0
+}
+
+func TestLobbyCreateAddsBothTeams(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	lobby := newLobby(t, repos, owner.ID, 4)
+
+	if len(lobby.Teams) != 2 {
+		t.Fatalf("expected 2 teams, got %d", len(lobby.Teams))
+	}
+	if lobby.GetTeam(models.Blue) == nil || lobby.GetTeam(models.Red) == nil {
+		t.Fatalf("expected blue and red teams, got %+v", lobby.Teams)
+	}
+	if *lobby.Closed || *lobby.Private {
+		t.Fatalf("new lobby should be public and opened")
+	}
+}
+
+func TestLobbyValidate(t *testing.T) {
+	private := true
+	tests := []struct {
+		name  string
+		lobby models.Lobby
+		valid bool
+	}{
+		{"valid", models.Lobby{Name: "Park lobby", PlayerLimit: 4}, true},
+		{"too few players", models.Lobby{Name: "Park lobby", PlayerLimit: 3}, false},
+		{"too many players", models.Lobby{Name: "Park lobby", PlayerLimit: 21}, false},
+		{"short name", models.Lobby{Name: "abc", PlayerLimit: 4}, false},
+		{"private without password", models.Lobby{Name: "Park lobby", PlayerLimit: 4, Private: &private}, false},
+		{"private with password", models.Lobby{Name: "Park lobby", PlayerLimit: 4, Private: &private, Password: "pass"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repos := repositories.NewMemoryRepositories()
+			err := test.lobby.Validate(repos.Lobbies)
+			if test.valid && err != nil {
+				t.Fatalf("expected lobby to be valid, got %v", err)
+			}
+			if !test.valid && httpCode(err) != 400 {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLobbyCreateRejectsSecondOpenedLobby(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	newLobby(t, repos, owner.ID, 4)
+
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Second lobby", PlayerLimit: 4}
+	if err := lobby.Create(repos.Lobbies); httpCode(err) != 400 {
+		t.Fatalf("expected owner to be rejected, got %v", err)
+	}
+}
+
+func TestAddPlayerJoinsTeam(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+	lobby := newLobby(t, repos, owner.ID, 4)
+
+	join(t, repos, lobby.ID, player, models.Red)
+
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if !lobby.GetTeam(models.Red).ContainsPlayerWithId(player.ID) {
+		t.Fatalf("player should be in the red team")
+	}
+	if entry := lobby.GetTeam(models.Red).TeamEntries[0]; entry.Nickname != "player" {
+		t.Fatalf("entry should keep player's nickname, got %q", entry.Nickname)
+	}
+	stored, _ := models.GetAccountById(repos.Accounts, player.ID)
+	if !stored.Playing {
+		t.Fatalf("player should be marked as playing")
+	}
+	current, err := models.GetPlayersLobby(repos.Lobbies, player.ID)
+	if err != nil || current.ID != lobby.ID {
+		t.Fatalf("expected player's lobby to be %d, got %v %v", lobby.ID, current, err)
+	}
+}
+
+func TestAddPlayerRejections(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	lobby := newLobby(t, repos, owner.ID, 4)
+
+	playing := newAccount(t, repos, "playing")
+	playing.Playing = true
+	err := lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, playing, &models.LobbyRequest{TeamColor: models.Blue})
+	if httpCode(err) != 400 {
+		t.Fatalf("expected playing account to be rejected, got %v", err)
+	}
+
+	player := newAccount(t, repos, "player")
+	err = lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, player, &models.LobbyRequest{TeamColor: "green"})
+	if httpCode(err) != 400 {
+		t.Fatalf("expected invalid team color to be rejected, got %v", err)
+	}
+
+	for _, nickname := range []string{"first", "second", "third", "fourth"} {
+		join(t, repos, lobby.ID, newAccount(t, repos, nickname), models.Blue)
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	err = lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, player, &models.LobbyRequest{TeamColor: models.Red})
+	if err != errors.LobbyIsFull {
+		t.Fatalf("expected full lobby error, got %v", err)
+	}
+}
+
+func TestAddPlayerChecksPrivateLobbyPassword(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+	private := true
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Private lobby", PlayerLimit: 4, Private: &private, Password: "flanki"}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating lobby: %v", err)
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+
+	err := lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, player, &models.LobbyRequest{TeamColor: models.Blue, Password: "wrong"})
+	if err != errors.UnauthorizedLobbyJoinRequest {
+		t.Fatalf("expected wrong password to be rejected, got %v", err)
+	}
+	err = lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, player, &models.LobbyRequest{TeamColor: models.Blue, Password: "flanki"})
+	if err != nil {
+		t.Fatalf("expected correct password to be accepted, got %v", err)
+	}
+}
+
+func TestRemovePlayer(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	join(t, repos, lobby.ID, player, models.Blue)
+
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if err := lobby.RemovePlayer(repos.Lobbies, repos.Accounts, player.ID); err != nil {
+		t.Fatalf("removing player: %v", err)
+	}
+	if err := lobby.RemovePlayer(repos.Lobbies, repos.Accounts, player.ID); err != errors.PlayerNotFoundInAnyTeam {
+		t.Fatalf("expected player to be already removed, got %v", err)
+	}
+
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if lobby.PlayersCount() != 0 {
+		t.Fatalf("expected empty lobby, got %d players", lobby.PlayersCount())
+	}
+	stored, _ := models.GetAccountById(repos.Accounts, player.ID)
+	if stored.Playing {
+		t.Fatalf("removed player should not be playing")
+	}
+	if _, err := models.GetPlayersLobby(repos.Lobbies, player.ID); err != errors.PlayerNotActive {
+		t.Fatalf("expected player not to be in any lobby, got %v", err)
+	}
+}
+
+func TestCloseLobbyStopsPlayersPlaying(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	blue := newAccount(t, repos, "blueplayer")
+	red := newAccount(t, repos, "redplayer")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	join(t, repos, lobby.ID, blue, models.Blue)
+	join(t, repos, lobby.ID, red, models.Red)
+
+	lobby, err := models.GetOwnersLobby(repos.Lobbies, owner.ID)
+	if err != nil {
+		t.Fatalf("fetching owner's lobby: %v", err)
+	}
+	if err = lobby.Close(repos.Lobbies, repos.Accounts); err != nil {
+		t.Fatalf("closing lobby: %v", err)
+	}
+	if err = lobby.Close(repos.Lobbies, repos.Accounts); httpCode(err) != 400 {
+		t.Fatalf("expected second close to fail, got %v", err)
+	}
+
+	for _, id := range []uint{blue.ID, red.ID} {
+		account, _ := models.GetAccountById(repos.Accounts, id)
+		if account.Playing {
+			t.Fatalf("player %d should not be playing after lobby has been closed", id)
+		}
+	}
+	if _, err = models.GetOwnersLobby(repos.Lobbies, owner.ID); httpCode(err) != 404 {
+		t.Fatalf("closed lobby should not be returned as owner's lobby, got %v", err)
+	}
+	closed, _ := models.GetAllLobbies(repos.Lobbies, true)
+	if len(closed) != 1 || closed[0].PlayersCount() != 2 {
+		t.Fatalf("closed lobby should keep its teams, got %+v", closed)
+	}
+}
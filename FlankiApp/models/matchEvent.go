@@ -0,0 +1,222 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/validation"
+	"sort"
+	"time"
+)
+
+type MatchEventType string
+
+const (
+	// player has thrown at the can, hit tells whether it was knocked over
+	Throw MatchEventType = "throw"
+	// player has finished his drink and is out of the match
+	CanFinished MatchEventType = "finished"
+	Foul        MatchEventType = "foul"
+)
+
+// MatchEvent is a single entry of the match's log, recorded live by lobby's owner or referee
+type MatchEvent struct {
+	ID         uint           `json:"id" gorm:"primary_key"`
+	LobbyID    uint           `json:"lobby_id"`
+	Round      uint           `json:"round"`
+	Type       MatchEventType `json:"type"`
+	PlayerID   uint           `json:"player_id"`
+	Hit        *bool          `json:"hit,omitempty"`
+	Note       string         `json:"note,omitempty"`
+	Second     uint           `json:"second"` // seconds since the match has started
+	RecordedBy uint           `json:"recorded_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type MatchEventRequest struct {
+	Round    uint           `json:"round" validate:"min=1,max=200"`
+	Type     MatchEventType `json:"type" validate:"oneof=throw finished foul"`
+	PlayerID uint           `json:"player_id"`
+	Hit      *bool          `json:"hit,omitempty"` // required for throws only
+	Note     string         `json:"note,omitempty" validate:"max=200"`
+}
+
+// PlayerMatchStats are derived from events of a single match
+type PlayerMatchStats struct {
+	PlayerID uint    `json:"player_id"`
+	Throws   int     `json:"throws"`
+	Hits     int     `json:"hits"`
+	Accuracy float64 `json:"accuracy"` // hits per throw
+	Fouls    int     `json:"fouls"`
+	// seconds from the start of the match, none when player hasn't finished
+	FinishedAfter *uint `json:"finished_after,omitempty"`
+}
+
+// MatchLog is the whole event log of a match together with stats derived from it
+type MatchLog struct {
+	Events  []MatchEvent       `json:"events"`
+	Players []PlayerMatchStats `json:"players"`
+}
+
+// ScorekeepingSummary sums up player's events over all his finished matches
+type ScorekeepingSummary struct {
+	Matches       int     `json:"matches"` // finished matches with recorded events
+	Throws        int     `json:"throws"`
+	Hits          int     `json:"hits"`
+	Accuracy      float64 `json:"accuracy"`
+	Fouls         int     `json:"fouls"`
+	Finishes      int     `json:"finishes"`
+	AverageFinish float64 `json:"average_finish"` // seconds, averaged over finishes only
+}
+
+// IsScorekeeper tells whether account can record events of the lobby's match, managers of the lobby can
+func (lobby *Lobby) IsScorekeeper(accountID uint) bool {
+	return lobby.IsManager(accountID)
+}
+
+// RecordEvent appends event to the log of started match, lobby has to be fetched together with its teams
+func (lobby *Lobby) RecordEvent(events MatchEventRepository, recorderID uint, request *MatchEventRequest) (*MatchEvent, error) {
+	if err := validation.Check(request); err != nil {
+		return nil, err
+	}
+	if !lobby.IsScorekeeper(recorderID) {
+		return nil, errors.NotScorekeeper
+	}
+	if lobby.State != InProgress || lobby.StartedAt == nil {
+		return nil, errors.New("Events can be recorded only during the match", 409)
+	}
+	if !lobby.isMember(request.PlayerID) {
+		return nil, errors.PlayerNotFoundInAnyTeam
+	}
+	if (request.Type == Throw) != (request.Hit != nil) {
+		return nil, errors.New("Hit has to be given for throws and only for them", 400)
+	}
+
+	if request.Type == CanFinished {
+		logged, err := events.GetByLobbies(lobby.ID)
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		for _, event := range logged {
+			if event.PlayerID == request.PlayerID && event.Type == CanFinished {
+				return nil, errors.New("Player has already finished", 409)
+			}
+		}
+	}
+
+	event := &MatchEvent{
+		LobbyID:    lobby.ID,
+		Round:      request.Round,
+		Type:       request.Type,
+		PlayerID:   request.PlayerID,
+		Hit:        request.Hit,
+		Note:       request.Note,
+		Second:     uint(time.Since(*lobby.StartedAt).Seconds()),
+		RecordedBy: recorderID,
+	}
+	if err := events.Create(event); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return event, nil
+}
+
+// GetMatchLog returns events of the lobby's match with stats of every player who has any
+func GetMatchLog(events MatchEventRepository, lobbyID uint) (*MatchLog, error) {
+	logged, err := events.GetByLobbies(lobbyID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return &MatchLog{Events: logged, Players: MatchStats(logged)}, nil
+}
+
+// MatchStats derives stats of players from events of a single match, ordered by player's id
+func MatchStats(events []MatchEvent) []PlayerMatchStats {
+	byPlayer := map[uint]*PlayerMatchStats{}
+	for _, event := range events {
+		stats, found := byPlayer[event.PlayerID]
+		if !found {
+			stats = &PlayerMatchStats{PlayerID: event.PlayerID}
+			byPlayer[event.PlayerID] = stats
+		}
+		switch event.Type {
+		case Throw:
+			stats.Throws++
+			if event.Hit != nil && *event.Hit {
+				stats.Hits++
+			}
+		case Foul:
+			stats.Fouls++
+		case CanFinished:
+			second := event.Second
+			stats.FinishedAfter = &second
+		}
+	}
+
+	players := make([]PlayerMatchStats, 0, len(byPlayer))
+	for _, stats := range byPlayer {
+		stats.Accuracy = accuracy(stats.Hits, stats.Throws)
+		players = append(players, *stats)
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].PlayerID < players[j].PlayerID })
+	return players
+}
+
+// AddMatchStats fills stats of players into results of matches which have events recorded
+func AddMatchStats(events MatchEventRepository, results []*MatchResult) error {
+	ids := make([]uint, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	logged, err := events.GetByLobbies(ids...)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	byLobby := map[uint][]MatchEvent{}
+	for _, event := range logged {
+		byLobby[event.LobbyID] = append(byLobby[event.LobbyID], event)
+	}
+	for _, result := range results {
+		if lobbyEvents, found := byLobby[result.ID]; found {
+			result.Players = MatchStats(lobbyEvents)
+		}
+	}
+	return nil
+}
+
+// GetScorekeepingSummary sums up events of the player from all his finished matches
+func GetScorekeepingSummary(events MatchEventRepository, playerID uint) (*ScorekeepingSummary, error) {
+	logged, err := events.GetByPlayer(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+
+	summary := &ScorekeepingSummary{}
+	matches := map[uint]bool{}
+	var finishTime uint
+	for _, event := range logged {
+		matches[event.LobbyID] = true
+		switch event.Type {
+		case Throw:
+			summary.Throws++
+			if event.Hit != nil && *event.Hit {
+				summary.Hits++
+			}
+		case Foul:
+			summary.Fouls++
+		case CanFinished:
+			summary.Finishes++
+			finishTime += event.Second
+		}
+	}
+	summary.Matches = len(matches)
+	summary.Accuracy = accuracy(summary.Hits, summary.Throws)
+	if summary.Finishes > 0 {
+		summary.AverageFinish = float64(finishTime) / float64(summary.Finishes)
+	}
+	return summary, nil
+}
+
+func accuracy(hits int, throws int) float64 {
+	if throws == 0 {
+		return 0
+	}
+	return float64(hits) / float64(throws)
+}
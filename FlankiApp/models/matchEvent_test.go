@@ -0,0 +1,114 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+	"time"
+)
+
+// starts a match of the lobby between one blue and one red player
+func startMatch(t *testing.T, repos *repositories.Repositories, lobby *models.Lobby, blue *models.Account, red *models.Account) *models.Lobby {
+	t.Helper()
+	join(t, repos, lobby.ID, blue, models.Blue)
+	join(t, repos, lobby.ID, red, models.Red)
+	if err := ownersLobby(t, repos, lobby.OwnerID).StartReadyCheck(repos.Lobbies); err != nil {
+		t.Fatalf("starting ready check: %v", err)
+	}
+	setReady(t, repos, blue)
+	setReady(t, repos, red)
+	if err := ownersLobby(t, repos, lobby.OwnerID).Start(repos.Lobbies); err != nil {
+		t.Fatalf("starting match: %v", err)
+	}
+	return ownersLobby(t, repos, lobby.OwnerID)
+}
+
+func record(t *testing.T, repos *repositories.Repositories, lobby *models.Lobby, recorderID uint, request models.MatchEventRequest) {
+	t.Helper()
+	if _, err := lobby.RecordEvent(repos.Events, recorderID, &request); err != nil {
+		t.Fatalf("recording %s of player %d: %v", request.Type, request.PlayerID, err)
+	}
+}
+
+func TestOnlyOwnerAndRefereeRecordEvents(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	referee := newAccount(t, repos, "referee")
+	blue := newAccount(t, repos, "blueplayer")
+	red := newAccount(t, repos, "redplayer")
+
+	lobby := newLobby(t, repos, owner.ID, 4)
+	hit := true
+	if _, err := lobby.RecordEvent(repos.Events, owner.ID, &models.MatchEventRequest{Round: 1, Type: models.Throw, PlayerID: blue.ID, Hit: &hit}); httpCode(err) != 409 {
+		t.Fatalf("expected events of not started match to be rejected, got %v", err)
+	}
+	if err := (&models.Lobby{OwnerID: owner.ID, RefereeID: &referee.ID}).Update(repos.Lobbies, owner.ID); err != nil {
+		t.Fatalf("assigning referee: %v", err)
+	}
+
+	lobby = startMatch(t, repos, lobby, blue, red)
+	if _, err := lobby.RecordEvent(repos.Events, blue.ID, &models.MatchEventRequest{Round: 1, Type: models.Throw, PlayerID: blue.ID, Hit: &hit}); err != errors.NotScorekeeper {
+		t.Fatalf("expected players not to record events, got %v", err)
+	}
+	if _, err := lobby.RecordEvent(repos.Events, owner.ID, &models.MatchEventRequest{Round: 1, Type: models.Throw, PlayerID: blue.ID}); httpCode(err) != 400 {
+		t.Fatalf("expected throw without hit to be rejected, got %v", err)
+	}
+	if _, err := lobby.RecordEvent(repos.Events, owner.ID, &models.MatchEventRequest{Round: 1, Type: models.Foul, PlayerID: owner.ID}); err != errors.PlayerNotFoundInAnyTeam {
+		t.Fatalf("expected events of players outside the match to be rejected, got %v", err)
+	}
+	record(t, repos, lobby, referee.ID, models.MatchEventRequest{Round: 1, Type: models.Foul, PlayerID: red.ID, Note: "Ran too early"})
+}
+
+func TestStatsAreDerivedFromEvents(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	blue := newAccount(t, repos, "blueplayer")
+	red := newAccount(t, repos, "redplayer")
+	lobby := startMatch(t, repos, newLobby(t, repos, owner.ID, 4), blue, red)
+
+	hit, miss := true, false
+	record(t, repos, lobby, owner.ID, models.MatchEventRequest{Round: 1, Type: models.Throw, PlayerID: blue.ID, Hit: &hit})
+	record(t, repos, lobby, owner.ID, models.MatchEventRequest{Round: 1, Type: models.Throw, PlayerID: red.ID, Hit: &miss})
+	record(t, repos, lobby, owner.ID, models.MatchEventRequest{Round: 2, Type: models.Throw, PlayerID: blue.ID, Hit: &miss})
+	record(t, repos, lobby, owner.ID, models.MatchEventRequest{Round: 2, Type: models.Foul, PlayerID: red.ID})
+	record(t, repos, lobby, owner.ID, models.MatchEventRequest{Round: 3, Type: models.CanFinished, PlayerID: blue.ID})
+	if _, err := lobby.RecordEvent(repos.Events, owner.ID, &models.MatchEventRequest{Round: 3, Type: models.CanFinished, PlayerID: blue.ID}); httpCode(err) != 409 {
+		t.Fatalf("expected player to finish only once, got %v", err)
+	}
+
+	log, err := models.GetMatchLog(repos.Events, lobby.ID)
+	if err != nil {
+		t.Fatalf("fetching match log: %v", err)
+	}
+	if len(log.Events) != 5 || len(log.Players) != 2 {
+		t.Fatalf("expected 5 events of 2 players, got %+v", log)
+	}
+	blueStats, redStats := log.Players[0], log.Players[1]
+	if blueStats.PlayerID != blue.ID || blueStats.Throws != 2 || blueStats.Hits != 1 || blueStats.Accuracy != 0.5 || blueStats.FinishedAfter == nil {
+		t.Errorf("unexpected stats of blue player: %+v", blueStats)
+	}
+	if redStats.Throws != 1 || redStats.Hits != 0 || redStats.Fouls != 1 || redStats.FinishedAfter != nil {
+		t.Errorf("unexpected stats of red player: %+v", redStats)
+	}
+
+	// profiles count only finished matches
+	if summary, _ := models.GetScorekeepingSummary(repos.Events, blue.ID); summary.Matches != 0 {
+		t.Fatalf("expected unfinished match not to be summed up, got %+v", summary)
+	}
+	if err := lobby.SubmitResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, owner.ID, models.Blue, time.Hour); err != nil {
+		t.Fatalf("submitting results: %v", err)
+	}
+	summary, err := models.GetScorekeepingSummary(repos.Events, blue.ID)
+	if err != nil {
+		t.Fatalf("summing up events: %v", err)
+	}
+	if summary.Matches != 1 || summary.Throws != 2 || summary.Hits != 1 || summary.Finishes != 1 {
+		t.Fatalf("unexpected scorekeeping summary: %+v", summary)
+	}
+
+	results := []*models.MatchResult{models.NewMatchResult(lobby)}
+	if err := models.AddMatchStats(repos.Events, results); err != nil || len(results[0].Players) != 2 {
+		t.Fatalf("expected stats in match history, got %+v, %v", results[0].Players, err)
+	}
+}
@@ -0,0 +1,222 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/validation"
+	"time"
+)
+
+type ResultStatus string
+
+const (
+	// waits for confirmation of the other team, statistics are frozen
+	ResultPending ResultStatus = "pending"
+	// statistics count, the result can still be disputed until its deadline
+	ResultConfirmed ResultStatus = "confirmed"
+	// waits for a moderator, statistics are frozen
+	ResultDisputed ResultStatus = "disputed"
+)
+
+type ResultEvent string
+
+const (
+	ResultSubmitted         ResultEvent = "submitted"
+	ResultConfirmedByPlayer ResultEvent = "confirmed"
+	ResultTimedOut          ResultEvent = "timed_out"
+	ResultDisputedByPlayer  ResultEvent = "disputed"
+	ResultSettled           ResultEvent = "settled"
+)
+
+// ResultRecord is a single entry of lobby's result history, records are never changed or deleted
+type ResultRecord struct {
+	ID        uint        `json:"-" gorm:"primary_key"`
+	LobbyID   uint        `json:"-"`
+	Event     ResultEvent `json:"event"`
+	PlayerID  uint        `json:"player_id,omitempty"` // none when confirmed by timeout
+	Winner    TeamColor   `json:"winner,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type DisputeRequest struct {
+	Reason string `json:"reason" validate:"min=4,max=200"`
+}
+
+type SettleRequest struct {
+	Winner TeamColor `json:"winner" validate:"oneof=blue red"`
+	Reason string    `json:"reason" validate:"max=200"`
+}
+
+// StatisticsFrozen tells whether statistics of the match don't count until the result is confirmed or settled
+func (lobby *Lobby) StatisticsFrozen() bool {
+	return lobby.ResultStatus == ResultPending || lobby.ResultStatus == ResultDisputed
+}
+
+// team the submitter isn't in, the losing one when the submitter hasn't played
+func (lobby *Lobby) opposingTeam(submitterID uint) *Team {
+	for i := range lobby.Teams {
+		if lobby.Teams[i].ContainsPlayerWithId(submitterID) {
+			return lobby.GetTeam(otherColor(lobby.Teams[i].TeamColor))
+		}
+	}
+	return lobby.GetTeam(otherColor(lobby.Winner))
+}
+
+func otherColor(color TeamColor) TeamColor {
+	if color == Blue {
+		return Red
+	}
+	return Blue
+}
+
+func (lobby *Lobby) isMember(playerID uint) bool {
+	for _, team := range lobby.Teams {
+		if team.ContainsPlayerWithId(playerID) {
+			return true
+		}
+	}
+	return false
+}
+
+// submitter of the current result
+func (lobby *Lobby) resultSubmitter() uint {
+	for i := len(lobby.ResultHistory) - 1; i >= 0; i-- {
+		if lobby.ResultHistory[i].Event == ResultSubmitted {
+			return lobby.ResultHistory[i].PlayerID
+		}
+	}
+	return lobby.OwnerID
+}
+
+func (lobby *Lobby) record(lobbies LobbyRepository, record *ResultRecord) error {
+	record.LobbyID = lobby.ID
+	if err := lobbies.AddResultRecord(lobby, record); err != nil {
+		return errors.DatabaseError(err)
+	}
+	if err := lobbies.Save(lobby); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// ConfirmResult accepts pending result on behalf of the other team, only its members can do that
+func (lobby *Lobby) ConfirmResult(lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, tournaments TournamentRepository,
+	playerID uint) error {
+	if lobby.ResultStatus != ResultPending {
+		return errors.ResultNotPending
+	}
+	team := lobby.opposingTeam(lobby.resultSubmitter())
+	if team == nil || !team.ContainsPlayerWithId(playerID) {
+		return errors.New("Only a member of the other team can confirm the result", 403)
+	}
+	return lobby.confirm(lobbies, accounts, statistics, tournaments, &ResultRecord{Event: ResultConfirmedByPlayer, PlayerID: playerID})
+}
+
+// confirm unfreezes statistics of pending result, tournament's bracket advances only now
+func (lobby *Lobby) confirm(lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, tournaments TournamentRepository,
+	record *ResultRecord) error {
+	if err := statistics.SetFrozen(lobby.ID, false); err != nil {
+		return errors.DatabaseError(err)
+	}
+	lobby.ResultStatus = ResultConfirmed
+	if err := lobby.record(lobbies, record); err != nil {
+		return err
+	}
+	if lobby.TournamentMatchID != nil {
+		return lobby.advanceTournament(tournaments, lobbies, accounts)
+	}
+	return nil
+}
+
+// DisputeResult freezes statistics of the match until a moderator settles it, any player of the match
+// can dispute pending result or confirmed one until its deadline
+func (lobby *Lobby) DisputeResult(lobbies LobbyRepository, statistics StatisticsRepository, playerID uint, request *DisputeRequest) error {
+	if err := validation.Check(request); err != nil {
+		return err
+	}
+	if !lobby.isMember(playerID) {
+		return errors.New("Only players of the match can dispute its result", 403)
+	}
+	switch {
+	case lobby.ResultStatus == ResultPending:
+	case lobby.ResultStatus == ResultConfirmed && lobby.ResultDeadline != nil && time.Now().Before(*lobby.ResultDeadline):
+	default:
+		return errors.New("Result can't be disputed anymore", 409)
+	}
+	if err := statistics.SetFrozen(lobby.ID, true); err != nil {
+		return errors.DatabaseError(err)
+	}
+	lobby.ResultStatus = ResultDisputed
+	return lobby.record(lobbies, &ResultRecord{Event: ResultDisputedByPlayer, PlayerID: playerID, Reason: request.Reason})
+}
+
+// SettleResult ends the dispute with the winner chosen by a moderator, statistics are recomputed when the winner changes.
+// Tournament's bracket advances with the settled winner unless it has advanced before the dispute.
+// Lobby has to be fetched together with its teams
+func (lobby *Lobby) SettleResult(lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, tournaments TournamentRepository,
+	moderatorID uint, request *SettleRequest) error {
+	if err := validation.Check(request); err != nil {
+		return err
+	}
+	if lobby.ResultStatus != ResultDisputed {
+		return errors.New("Result is not disputed", 409)
+	}
+	if lobby.TournamentMatchID != nil && request.Winner != lobby.Winner {
+		advanced, err := lobby.bracketAdvanced(tournaments)
+		if err != nil {
+			return err
+		}
+		if advanced {
+			return errors.New("Winner of a tournament match can't be changed after its bracket has advanced", 409)
+		}
+	}
+	lobby.ResultStatus = ResultConfirmed
+	if request.Winner != lobby.Winner {
+		if err := statistics.DeleteByLobby(lobby.ID); err != nil {
+			return errors.DatabaseError(err)
+		}
+		lobby.Winner = request.Winner
+		if err := SubmitMatch(statistics, lobby); err != nil {
+			return err
+		}
+	} else if err := statistics.SetFrozen(lobby.ID, false); err != nil {
+		return errors.DatabaseError(err)
+	}
+	if err := lobby.record(lobbies, &ResultRecord{Event: ResultSettled, PlayerID: moderatorID, Winner: request.Winner, Reason: request.Reason}); err != nil {
+		return err
+	}
+	if lobby.TournamentMatchID != nil {
+		return lobby.advanceTournament(tournaments, lobbies, accounts)
+	}
+	return nil
+}
+
+// whether the tournament's match of the lobby has already been decided
+func (lobby *Lobby) bracketAdvanced(tournaments TournamentRepository) (bool, error) {
+	tournament, err := tournaments.GetByMatch(*lobby.TournamentMatchID)
+	if err != nil {
+		return false, errors.DatabaseError(err)
+	}
+	for _, match := range tournament.Matches {
+		if match.ID == *lobby.TournamentMatchID {
+			return match.Done, nil
+		}
+	}
+	return false, nil
+}
+
+// ConfirmExpiredResults confirms pending results nobody has confirmed before their deadline,
+// returns lobbies whose results have been confirmed
+func ConfirmExpiredResults(lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, tournaments TournamentRepository,
+	now time.Time) ([]*Lobby, error) {
+	expired, err := lobbies.GetPendingResults(now)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	for i, lobby := range expired {
+		if err := lobby.confirm(lobbies, accounts, statistics, tournaments, &ResultRecord{Event: ResultTimedOut}); err != nil {
+			return expired[:i], err
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,136 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+	"time"
+)
+
+// plays a match between blue and red players of lobby confirming its results, blue wins
+func playConfirmedMatch(t *testing.T, repos *repositories.Repositories, blue *models.Account, red *models.Account) *models.Lobby {
+	t.Helper()
+	owner := newAccount(t, repos, "owner")
+	confirm := true
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Confirmed lobby", PlayerLimit: 4, ConfirmResults: &confirm}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating lobby: %v", err)
+	}
+	join(t, repos, lobby.ID, blue, models.Blue)
+	join(t, repos, lobby.ID, red, models.Red)
+	if err := ownersLobby(t, repos, owner.ID).StartReadyCheck(repos.Lobbies); err != nil {
+		t.Fatalf("starting ready check: %v", err)
+	}
+	setReady(t, repos, blue)
+	setReady(t, repos, red)
+	if err := ownersLobby(t, repos, owner.ID).Start(repos.Lobbies); err != nil {
+		t.Fatalf("starting match: %v", err)
+	}
+	lobby = ownersLobby(t, repos, owner.ID)
+	if err := lobby.SubmitResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, blue.ID, models.Blue, time.Hour); err != nil {
+		t.Fatalf("submitting results: %v", err)
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	return lobby
+}
+
+func wins(t *testing.T, repos *repositories.Repositories, player *models.Account) int {
+	t.Helper()
+	summary, err := repos.Statistics.GetSummary(player.ID)
+	if err != nil {
+		t.Fatalf("fetching summary of %s: %v", player.Nickname, err)
+	}
+	return summary.Wins
+}
+
+func TestPendingResultIsConfirmedByOtherTeam(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	blue := newAccount(t, repos, "blueplayer")
+	red := newAccount(t, repos, "redplayer")
+	lobby := playConfirmedMatch(t, repos, blue, red)
+
+	if lobby.ResultStatus != models.ResultPending || wins(t, repos, blue) != 0 {
+		t.Fatalf("expected pending result without counted statistics, got %s", lobby.ResultStatus)
+	}
+	if err := lobby.ConfirmResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, blue.ID); httpCode(err) != 403 {
+		t.Fatalf("expected submitter's team not to confirm the result, got %v", err)
+	}
+	if err := lobby.ConfirmResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, red.ID); err != nil {
+		t.Fatalf("confirming result: %v", err)
+	}
+	if wins(t, repos, blue) != 1 {
+		t.Fatalf("expected confirmed win to count")
+	}
+	if err := lobby.ConfirmResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, red.ID); err != errors.ResultNotPending {
+		t.Fatalf("expected result to be confirmed only once, got %v", err)
+	}
+
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	history := lobby.ResultHistory
+	if len(history) != 2 || history[0].Event != models.ResultSubmitted || history[1].Event != models.ResultConfirmedByPlayer || history[1].PlayerID != red.ID {
+		t.Fatalf("expected submission and confirmation in history, got %+v", history)
+	}
+}
+
+func TestPendingResultIsConfirmedAfterDeadline(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	blue := newAccount(t, repos, "blueplayer")
+	red := newAccount(t, repos, "redplayer")
+	lobby := playConfirmedMatch(t, repos, blue, red)
+
+	if confirmed, err := models.ConfirmExpiredResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, time.Now()); err != nil || len(confirmed) != 0 {
+		t.Fatalf("expected nothing to be confirmed before deadline, got %d, %v", len(confirmed), err)
+	}
+	confirmed, err := models.ConfirmExpiredResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, time.Now().Add(2*time.Hour))
+	if err != nil || len(confirmed) != 1 || confirmed[0].ID != lobby.ID {
+		t.Fatalf("expected result to be confirmed after deadline, got %d, %v", len(confirmed), err)
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if lobby.ResultStatus != models.ResultConfirmed || lobby.ResultHistory[len(lobby.ResultHistory)-1].Event != models.ResultTimedOut {
+		t.Fatalf("expected result confirmed by timeout, got %+v", lobby.ResultHistory)
+	}
+	if wins(t, repos, blue) != 1 {
+		t.Fatalf("expected win to count after timeout")
+	}
+}
+
+func TestDisputeIsSettledByModerator(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	blue := newAccount(t, repos, "blueplayer")
+	red := newAccount(t, repos, "redplayer")
+	lobby := playConfirmedMatch(t, repos, blue, red)
+	if err := lobby.ConfirmResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, red.ID); err != nil {
+		t.Fatalf("confirming result: %v", err)
+	}
+
+	outsider := newAccount(t, repos, "outsider")
+	if err := lobby.DisputeResult(repos.Lobbies, repos.Statistics, outsider.ID, &models.DisputeRequest{Reason: "I saw it"}); httpCode(err) != 403 {
+		t.Fatalf("expected only players to dispute, got %v", err)
+	}
+	if err := lobby.DisputeResult(repos.Lobbies, repos.Statistics, red.ID, &models.DisputeRequest{Reason: "Blue spilled the beer"}); err != nil {
+		t.Fatalf("disputing result: %v", err)
+	}
+	if wins(t, repos, blue) != 0 {
+		t.Fatalf("expected disputed win to be frozen")
+	}
+	if err := lobby.ConfirmResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, red.ID); err != errors.ResultNotPending {
+		t.Fatalf("expected disputed result not to be confirmed, got %v", err)
+	}
+
+	moderator := newAccount(t, repos, "moderator")
+	if err := lobby.SettleResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, moderator.ID, &models.SettleRequest{Winner: models.Red, Reason: "Foul confirmed"}); err != nil {
+		t.Fatalf("settling dispute: %v", err)
+	}
+	if wins(t, repos, blue) != 0 || wins(t, repos, red) != 1 {
+		t.Fatalf("expected statistics to be recomputed for the new winner")
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	last := lobby.ResultHistory[len(lobby.ResultHistory)-1]
+	if lobby.Winner != models.Red || lobby.ResultStatus != models.ResultConfirmed || last.Event != models.ResultSettled || last.PlayerID != moderator.ID {
+		t.Fatalf("expected settled result, got %+v", lobby)
+	}
+	if len(lobby.ResultHistory) != 4 {
+		t.Fatalf("expected full history to be kept, got %+v", lobby.ResultHistory)
+	}
+}
@@ -0,0 +1,322 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/validation"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type NotificationType string
+
+const (
+	KickedNotification        NotificationType = "kicked"
+	ResultsNotification       NotificationType = "results_posted"
+	ReminderNotification      NotificationType = "lobby_reminder"
+	FriendRequestNotification NotificationType = "friend_request"
+	AchievementNotification   NotificationType = "achievement"
+	InviteNotification        NotificationType = "lobby_invite"
+	OwnershipNotification     NotificationType = "lobby_ownership"
+)
+
+// NotificationTypes lists every type users can set preferences of
+var NotificationTypes = []NotificationType{KickedNotification, ResultsNotification, ReminderNotification, FriendRequestNotification, AchievementNotification, InviteNotification, OwnershipNotification}
+
+type NotificationChannel string
+
+const (
+	InAppChannel NotificationChannel = "in_app"
+	EmailChannel NotificationChannel = "email"
+	PushChannel  NotificationChannel = "push"
+)
+
+// Notification is kept in account's inbox when in-app channel delivers it, other channels only send it
+type Notification struct {
+	ID        uint             `json:"id" gorm:"primary_key"`
+	CreatedAt time.Time        `json:"created_at"`
+	AccountID uint             `json:"-"`
+	Type      NotificationType `json:"type"`
+	Title     string           `json:"title"`
+	Body      string           `json:"body"`
+	LobbyID   *uint            `json:"lobby_id,omitempty"`
+	ReadAt    *time.Time       `json:"read_at,omitempty"`
+}
+
+// NotificationPreference tells which channels deliver notifications of one type, channels which are not set keep their defaults
+type NotificationPreference struct {
+	ID        uint             `json:"-" gorm:"primary_key"`
+	AccountID uint             `json:"-"`
+	Type      NotificationType `json:"type" validate:"oneof=kicked results_posted lobby_reminder friend_request achievement lobby_invite lobby_ownership"`
+	InApp     *bool            `json:"in_app"`
+	Email     *bool            `json:"email"`
+	Push      *bool            `json:"push"`
+}
+
+// PushSubscription is a browser subscribed to Web Push notifications of the account
+type PushSubscription struct {
+	ID        uint      `json:"-" gorm:"primary_key"`
+	CreatedAt time.Time `json:"-"`
+	AccountID uint      `json:"-"`
+	Endpoint  string    `json:"endpoint"`
+	P256dh    string    `json:"-"`
+	Auth      string    `json:"-"`
+}
+
+// PushSubscriptionRequest is the subscription as browsers' PushManager serializes it
+type PushSubscriptionRequest struct {
+	Endpoint string   `json:"endpoint" validate:"required,max=500"`
+	Keys     PushKeys `json:"keys"`
+}
+
+// PushKeys encrypt notifications so that only the subscribed browser can read them
+type PushKeys struct {
+	P256dh string `json:"p256dh" validate:"required,max=100"`
+	Auth   string `json:"auth" validate:"required,max=50"`
+}
+
+type Inbox struct {
+	Unread        int            `json:"unread"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// ReadRequest lists notifications to mark as read, all of them are marked when it's empty
+type ReadRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// how many notifications the inbox lists at most
+const inboxLimit = 100
+
+// channels used when the account hasn't chosen them, emails are sent only to remind of scheduled lobbies as they were before
+func defaultPreference(notificationType NotificationType) NotificationPreference {
+	inApp, email, push := true, notificationType == ReminderNotification, true
+	return NotificationPreference{Type: notificationType, InApp: &inApp, Email: &email, Push: &push}
+}
+
+// Allows tells whether the channel delivers notifications of the preference's type
+func (preference *NotificationPreference) Allows(channel NotificationChannel) bool {
+	var allowed *bool
+	switch channel {
+	case InAppChannel:
+		allowed = preference.InApp
+	case EmailChannel:
+		allowed = preference.Email
+	case PushChannel:
+		allowed = preference.Push
+	}
+	return allowed != nil && *allowed
+}
+
+// preference with channels which are not set taken from the other one
+func (preference NotificationPreference) merge(other NotificationPreference) NotificationPreference {
+	if preference.InApp == nil {
+		preference.InApp = other.InApp
+	}
+	if preference.Email == nil {
+		preference.Email = other.Email
+	}
+	if preference.Push == nil {
+		preference.Push = other.Push
+	}
+	return preference
+}
+
+// GetNotificationPreferences returns preferences of every notification type, defaults fill in what the account hasn't chosen
+func GetNotificationPreferences(notifications NotificationRepository, accountID uint) ([]NotificationPreference, error) {
+	stored, err := notifications.GetPreferences(accountID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	preferences := make([]NotificationPreference, len(NotificationTypes))
+	for i, notificationType := range NotificationTypes {
+		preferences[i] = defaultPreference(notificationType)
+		for _, preference := range stored {
+			if preference.Type == notificationType {
+				preferences[i] = preference.merge(preferences[i])
+			}
+		}
+	}
+	return preferences, nil
+}
+
+// GetNotificationPreference returns preference of one notification type
+func GetNotificationPreference(notifications NotificationRepository, accountID uint, notificationType NotificationType) (*NotificationPreference, error) {
+	preferences, err := GetNotificationPreferences(notifications, accountID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range preferences {
+		if preferences[i].Type == notificationType {
+			return &preferences[i], nil
+		}
+	}
+	preference := defaultPreference(notificationType)
+	return &preference, nil
+}
+
+// UpdateNotificationPreferences changes only channels set in given preferences, it returns preferences of every type
+func UpdateNotificationPreferences(notifications NotificationRepository, accountID uint, updates []NotificationPreference) ([]NotificationPreference, error) {
+	fields := []errors.FieldError{}
+	for i := range updates {
+		var err error
+		if fields, err = validation.Append(fields, validation.Check(&updates[i])); err != nil {
+			return nil, err
+		}
+	}
+	if err := validation.Merge(fields...); err != nil {
+		return nil, err
+	}
+
+	stored, err := notifications.GetPreferences(accountID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	for _, update := range updates {
+		preference := NotificationPreference{AccountID: accountID, Type: update.Type}
+		for _, existing := range stored {
+			if existing.Type == update.Type {
+				preference = existing
+			}
+		}
+		preference = NotificationPreference{ID: preference.ID, AccountID: accountID, Type: update.Type,
+			InApp: update.InApp, Email: update.Email, Push: update.Push}.merge(preference)
+		if err := notifications.SavePreference(&preference); err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+	}
+	return GetNotificationPreferences(notifications, accountID)
+}
+
+// GetInbox lists the latest notifications of the account together with the number of unread ones
+func GetInbox(notifications NotificationRepository, accountID uint, unreadOnly bool) (*Inbox, error) {
+	list, err := notifications.GetByAccount(accountID, unreadOnly, inboxLimit)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	unread, err := notifications.CountUnread(accountID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return &Inbox{Unread: unread, Notifications: list}, nil
+}
+
+// MarkNotificationsRead marks given notifications of the account as read, or all of them when none are given.
+// Returns how many notifications have been marked
+func MarkNotificationsRead(notifications NotificationRepository, accountID uint, request *ReadRequest) (int, error) {
+	marked, err := notifications.MarkRead(accountID, request.IDs, time.Now())
+	if err != nil {
+		return 0, errors.DatabaseError(err)
+	}
+	return marked, nil
+}
+
+// names which never belong to public push services, they could only reach hosts of the app's own network
+var internalHostSuffixes = []string{".localhost", ".local", ".internal", ".lan", ".home.arpa"}
+
+// isPublicPushEndpoint tells whether the endpoint could belong to a push service, it has to be an https url
+// on the default port with a public domain name. Addresses the name resolves to are checked when pushing
+func isPublicPushEndpoint(endpoint string) bool {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" || parsed.User != nil {
+		return false
+	}
+	if port := parsed.Port(); port != "" && port != "443" {
+		return false
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if net.ParseIP(host) != nil || host == "localhost" || !strings.Contains(host, ".") {
+		return false
+	}
+	for _, suffix := range internalHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// SubscribeToPush saves browser's subscription, push services accept notifications only over https
+// and the app doesn't send anything to its own network
+func SubscribeToPush(notifications NotificationRepository, accountID uint, request *PushSubscriptionRequest) (*PushSubscription, error) {
+	fields, err := validation.Append(nil, validation.Check(request))
+	if err != nil {
+		return nil, err
+	}
+	if fields, err = validation.Append(fields, validation.Check(&request.Keys)); err != nil {
+		return nil, err
+	}
+	if err := validation.Merge(fields...); err != nil {
+		return nil, err
+	}
+	if !isPublicPushEndpoint(request.Endpoint) {
+		return nil, errors.New("Push subscription endpoint should be an https url of a public push service", 400)
+	}
+	subscription := &PushSubscription{AccountID: accountID, Endpoint: request.Endpoint, P256dh: request.Keys.P256dh, Auth: request.Keys.Auth}
+	if err := notifications.SavePushSubscription(subscription); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return subscription, nil
+}
+
+func UnsubscribeFromPush(notifications NotificationRepository, accountID uint, endpoint string) error {
+	err := notifications.DeletePushSubscription(accountID, endpoint)
+	if err == errors.RecordNotFound {
+		return errors.New("Push subscription has not been found", 404)
+	}
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// KickedNotice tells the player they have been kicked out of the lobby
+func KickedNotice(lobby *Lobby) Notification {
+	return Notification{Type: KickedNotification, Title: "You have been kicked out of the lobby",
+		Body: fmt.Sprintf("The owner of %s has removed you from the lobby", lobby.Name), LobbyID: &lobby.ID}
+}
+
+// ResultsNotice tells players of the lobby its results have been submitted, pending ones can be confirmed or disputed
+func ResultsNotice(lobby *Lobby) Notification {
+	body := fmt.Sprintf("Team %s has won", lobby.Winner)
+	if lobby.ResultStatus == ResultPending && lobby.ResultDeadline != nil {
+		body += fmt.Sprintf(", confirm or dispute the results until %s", lobby.ResultDeadline.UTC().Format("02.01.2006 15:04 MST"))
+	}
+	return Notification{Type: ResultsNotification, Title: fmt.Sprintf("Results of %s have been posted", lobby.Name), Body: body, LobbyID: &lobby.ID}
+}
+
+// ReminderNotice reminds of the scheduled lobby starting soon
+func ReminderNotice(lobby *Lobby) Notification {
+	body := fmt.Sprintf("The match starts at %s (%s)", lobby.StartsAt.In(lobby.Location()).Format("02.01.2006 15:04"), lobby.Location())
+	if lobby.Latitude != 0 || lobby.Longitude != 0 {
+		body += fmt.Sprintf(", place: %f, %f", lobby.Latitude, lobby.Longitude)
+	}
+	return Notification{Type: ReminderNotification, Title: "Reminder: " + lobby.Name, Body: body, LobbyID: &lobby.ID}
+}
+
+// FriendshipNotice tells the other player of the friendship about the request or its acceptance
+func FriendshipNotice(friendship *Friendship, nickname string) Notification {
+	if friendship.Status == FriendAccepted {
+		return Notification{Type: FriendRequestNotification, Title: "Friend request accepted", Body: nickname + " is your friend now"}
+	}
+	return Notification{Type: FriendRequestNotification, Title: "New friend request", Body: nickname + " wants to be your friend"}
+}
+
+// AchievementNotice congratulates the player on the badge they have been awarded
+func AchievementNotice(achievement *Achievement) Notification {
+	return Notification{Type: AchievementNotification, Title: "New badge: " + achievement.Title, Body: achievement.Description, LobbyID: achievement.LobbyID}
+}
+
+// InviteNotice tells the player they have been invited to the lobby, the code joins it
+func InviteNotice(lobby *Lobby, invite *LobbyInvite) Notification {
+	return Notification{Type: InviteNotification, Title: "You have been invited to " + lobby.Name,
+		Body: "Join the lobby with invite code " + invite.Code, LobbyID: &lobby.ID}
+}
+
+// OwnershipNotice tells the player they own the lobby now
+func OwnershipNotice(lobby *Lobby) Notification {
+	return Notification{Type: OwnershipNotification, Title: "You are the owner of " + lobby.Name + " now",
+		Body: "The lobby has been handed over to you, you can kick players, start the match and submit its results", LobbyID: &lobby.ID}
+}
@@ -0,0 +1,120 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+)
+
+func notify(t *testing.T, repos *repositories.Repositories, accountID uint, notification models.Notification) uint {
+	t.Helper()
+	notification.AccountID = accountID
+	if err := repos.Notifications.Create(&notification); err != nil {
+		t.Fatalf("creating notification: %v", err)
+	}
+	return notification.ID
+}
+
+func TestInboxListsNewestFirstUntilRead(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	other := newAccount(t, repos, "other")
+	lobby := newLobby(t, repos, other.ID, 4)
+
+	kicked := notify(t, repos, player.ID, models.KickedNotice(lobby))
+	results := notify(t, repos, player.ID, models.ResultsNotice(lobby))
+	notify(t, repos, other.ID, models.ResultsNotice(lobby))
+
+	inbox, err := models.GetInbox(repos.Notifications, player.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inbox.Unread != 2 || len(inbox.Notifications) != 2 || inbox.Notifications[0].ID != results {
+		t.Fatalf("expected player's notifications newest first, got %+v", inbox)
+	}
+
+	marked, err := models.MarkNotificationsRead(repos.Notifications, player.ID, &models.ReadRequest{IDs: []uint{kicked}})
+	if err != nil || marked != 1 {
+		t.Fatalf("expected single notification to be marked, got %d, %v", marked, err)
+	}
+	inbox, _ = models.GetInbox(repos.Notifications, player.ID, true)
+	if inbox.Unread != 1 || len(inbox.Notifications) != 1 || inbox.Notifications[0].ID != results {
+		t.Fatalf("expected only results to stay unread, got %+v", inbox)
+	}
+
+	// empty request marks everything, notifications of other accounts stay untouched
+	if marked, _ = models.MarkNotificationsRead(repos.Notifications, player.ID, &models.ReadRequest{}); marked != 1 {
+		t.Errorf("expected remaining notification to be marked, got %d", marked)
+	}
+	if inbox, _ = models.GetInbox(repos.Notifications, other.ID, false); inbox.Unread != 1 {
+		t.Errorf("expected other's notification to stay unread, got %+v", inbox)
+	}
+}
+
+func TestPreferencesKeepChannelsWhichAreNotChanged(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+
+	reminder, err := models.GetNotificationPreference(repos.Notifications, player.ID, models.ReminderNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reminder.Allows(models.InAppChannel) || !reminder.Allows(models.EmailChannel) {
+		t.Fatalf("expected reminders to be emailed by default, got %+v", reminder)
+	}
+
+	off, on := false, true
+	if _, err := models.UpdateNotificationPreferences(repos.Notifications, player.ID,
+		[]models.NotificationPreference{{Type: "spam", Email: &on}}); httpCode(err) != 400 {
+		t.Fatalf("expected unknown type to be rejected, got %v", err)
+	}
+	if _, err := models.UpdateNotificationPreferences(repos.Notifications, player.ID,
+		[]models.NotificationPreference{{Type: models.ReminderNotification, Email: &off}}); err != nil {
+		t.Fatal(err)
+	}
+	preferences, err := models.UpdateNotificationPreferences(repos.Notifications, player.ID,
+		[]models.NotificationPreference{{Type: models.ReminderNotification, Push: &off}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preferences) != len(models.NotificationTypes) {
+		t.Fatalf("expected preferences of every type, got %+v", preferences)
+	}
+	reminder, _ = models.GetNotificationPreference(repos.Notifications, player.ID, models.ReminderNotification)
+	if !reminder.Allows(models.InAppChannel) || reminder.Allows(models.EmailChannel) || reminder.Allows(models.PushChannel) {
+		t.Errorf("expected both changes to be kept, got %+v", reminder)
+	}
+}
+
+func TestPushSubscriptionsNeedHttpsEndpoint(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	keys := models.PushKeys{P256dh: "BNc", Auth: "tBH"}
+
+	if _, err := models.SubscribeToPush(repos.Notifications, player.ID, &models.PushSubscriptionRequest{Endpoint: "https://push.example.com/1"}); httpCode(err) != 400 {
+		t.Fatalf("expected subscription without keys to be rejected, got %v", err)
+	}
+	if _, err := models.SubscribeToPush(repos.Notifications, player.ID, &models.PushSubscriptionRequest{Endpoint: "http://push.example.com/1", Keys: keys}); httpCode(err) != 400 {
+		t.Fatalf("expected plain http endpoint to be rejected, got %v", err)
+	}
+	for _, endpoint := range []string{"https://127.0.0.1/1", "https://[::1]/1", "https://10.0.0.5/1", "https://169.254.169.254/latest",
+		"https://localhost/1", "https://metadata.google.internal/1", "https://push/1", "https://push.example.com:8443/1"} {
+		if _, err := models.SubscribeToPush(repos.Notifications, player.ID, &models.PushSubscriptionRequest{Endpoint: endpoint, Keys: keys}); httpCode(err) != 400 {
+			t.Errorf("expected endpoint %s of a private host to be rejected, got %v", endpoint, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := models.SubscribeToPush(repos.Notifications, player.ID, &models.PushSubscriptionRequest{Endpoint: "https://push.example.com/1", Keys: keys}); err != nil {
+			t.Fatalf("subscribing: %v", err)
+		}
+	}
+	if subscriptions, _ := repos.Notifications.GetPushSubscriptions(player.ID); len(subscriptions) != 1 {
+		t.Fatalf("expected subscribing again to replace subscription, got %+v", subscriptions)
+	}
+	if err := models.UnsubscribeFromPush(repos.Notifications, player.ID, "https://push.example.com/1"); err != nil {
+		t.Fatalf("unsubscribing: %v", err)
+	}
+	if err := models.UnsubscribeFromPush(repos.Notifications, player.ID, "https://push.example.com/1"); httpCode(err) != 404 {
+		t.Errorf("expected unknown subscription to be reported, got %v", err)
+	}
+}
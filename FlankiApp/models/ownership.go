@@ -0,0 +1,219 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LobbyCoOwner manages the lobby together with its owner so that the match goes on when the owner's phone dies
+type LobbyCoOwner struct {
+	ID        uint      `json:"-" gorm:"primary_key"`
+	CreatedAt time.Time `json:"since"`
+	LobbyID   uint      `json:"-"`
+	PlayerID  uint      `json:"player_id"`
+}
+
+// lobby can't have more co-owners
+const coOwnerLimit = 5
+
+// activity of owners is recorded at most once in this period
+const ownerSeenPrecision = time.Minute
+
+var coOwnerNotFound = errors.New("Player is not a co-owner of the lobby", 404)
+
+// IsManager tells whether account can kick players, update the lobby and run its match,
+// the owner shares those rights with co-owners and the referee
+func (lobby *Lobby) IsManager(accountID uint) bool {
+	if lobby.OwnerID == accountID || lobby.isCoOwner(accountID) {
+		return true
+	}
+	return lobby.RefereeID != nil && *lobby.RefereeID != 0 && *lobby.RefereeID == accountID
+}
+
+func (lobby *Lobby) isCoOwner(playerID uint) bool {
+	for _, coOwner := range lobby.CoOwners {
+		if coOwner.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// ManagedLobbyAmbiguous is returned when the player manages several lobbies and hasn't chosen one of them
+var ManagedLobbyAmbiguous = errors.New("Player manages more than one lobby, it has to be chosen with lobby_id", 409)
+
+// GetManagedLobby returns the open lobby with lobbyID the player owns, co-owns or referees. When lobbyID is 0
+// it is the lobby the player owns, otherwise the only one they co-own or referee
+func GetManagedLobby(lobbies LobbyRepository, playerID uint, lobbyID uint) (*Lobby, error) {
+	notManaged := errors.New("Player doesn't own or manage any opened lobby", 404)
+	if lobbyID != 0 {
+		lobby, err := lobbies.GetById(lobbyID)
+		if err == errors.RecordNotFound {
+			return nil, notManaged
+		}
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		if (lobby.Closed != nil && *lobby.Closed) || !lobby.IsManager(playerID) {
+			return nil, notManaged
+		}
+		return lobby, nil
+	}
+
+	lobby, err := lobbies.GetOpenByOwner(playerID)
+	if err == nil {
+		return lobby, nil
+	}
+	if err != errors.RecordNotFound {
+		return nil, errors.DatabaseError(err)
+	}
+	managed, err := lobbies.GetOpenByManager(playerID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	switch len(managed) {
+	case 0:
+		return nil, notManaged
+	case 1:
+		return managed[0], nil
+	}
+	return nil, ManagedLobbyAmbiguous
+}
+
+// AddCoOwner shares management of the lobby with the player
+func (lobby *Lobby) AddCoOwner(lobbies LobbyRepository, accounts AccountRepository, playerID uint) error {
+	if playerID == lobby.OwnerID {
+		return errors.New("Owner can't be a co-owner of their own lobby", 400)
+	}
+	if lobby.isCoOwner(playerID) {
+		return errors.New("Player is already a co-owner of the lobby", 409)
+	}
+	if len(lobby.CoOwners) >= coOwnerLimit {
+		return errors.New(fmt.Sprintf("Lobby can't have more than %d co-owners", coOwnerLimit), 403)
+	}
+	if _, err := GetPlayerByIdFunc(accounts, playerID); err != nil {
+		return err
+	}
+	if err := lobbies.AddCoOwner(lobby, &LobbyCoOwner{PlayerID: playerID}); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// RemoveCoOwner takes management of the lobby away from the co-owner
+func (lobby *Lobby) RemoveCoOwner(lobbies LobbyRepository, playerID uint) error {
+	for i, coOwner := range lobby.CoOwners {
+		if coOwner.PlayerID == playerID {
+			if err := lobbies.DeleteCoOwner(&coOwner); err != nil {
+				return errors.DatabaseError(err)
+			}
+			lobby.CoOwners = append(lobby.CoOwners[:i], lobby.CoOwners[i+1:]...)
+			return nil
+		}
+	}
+	return coOwnerNotFound
+}
+
+// TransferOwnership hands the lobby over to one of its players or co-owners, the previous owner keeps only their place in a team
+func (lobby *Lobby) TransferOwnership(lobbies LobbyRepository, playerID uint, now time.Time) error {
+	if playerID == lobby.OwnerID {
+		return errors.New("Player already owns the lobby", 400)
+	}
+	if !lobby.isMember(playerID) && !lobby.isCoOwner(playerID) {
+		return errors.New("Lobby can be handed over only to its players and co-owners", 400)
+	}
+	if _, err := GetOwnersLobby(lobbies, playerID); err == nil {
+		return errors.New("Player is already an owner of a lobby", 409)
+	}
+	return lobby.transferTo(lobbies, playerID, now)
+}
+
+func (lobby *Lobby) transferTo(lobbies LobbyRepository, playerID uint, now time.Time) error {
+	// owner doesn't need to co-own the lobby anymore
+	if err := lobby.RemoveCoOwner(lobbies, playerID); err != nil && err != coOwnerNotFound {
+		return err
+	}
+	lobby.OwnerID = playerID
+	lobby.OwnerSeenAt = &now
+	if err := lobbies.Save(lobby); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// successor is the player present in the lobby the longest, ids of team entries grow as players join.
+// Players who own other open lobbies are skipped as nobody can own two of them. Nil when nobody can take the lobby
+func (lobby *Lobby) successor(lobbies LobbyRepository) (*TeamEntry, error) {
+	entries := []TeamEntry{}
+	for _, team := range lobby.Teams {
+		entries = append(entries, team.TeamEntries...)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	for i := range entries {
+		if entries[i].PlayerID == lobby.OwnerID {
+			continue
+		}
+		_, err := lobbies.GetOpenByOwner(entries[i].PlayerID)
+		if err == errors.RecordNotFound {
+			return &entries[i], nil
+		}
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+	}
+	return nil, nil
+}
+
+// handOver passes the lobby to its successor, returns the new owner or nil when nobody could take the lobby
+func (lobby *Lobby) handOver(lobbies LobbyRepository, now time.Time) (*TeamEntry, error) {
+	successor, err := lobby.successor(lobbies)
+	if err != nil || successor == nil {
+		return nil, err
+	}
+	if err := lobby.transferTo(lobbies, successor.PlayerID, now); err != nil {
+		return nil, err
+	}
+	return successor, nil
+}
+
+// Leave removes the player from the lobby, the owner leaving hands the lobby over to the player present the longest.
+// Returns the new owner when the lobby has changed hands
+func (lobby *Lobby) Leave(lobbies LobbyRepository, accounts AccountRepository, playerID uint, now time.Time) (*TeamEntry, error) {
+	if err := lobby.RemovePlayer(lobbies, accounts, playerID); err != nil {
+		return nil, err
+	}
+	if playerID != lobby.OwnerID {
+		return nil, nil
+	}
+	return lobby.handOver(lobbies, now)
+}
+
+// SeeOwner records that the owner of an open lobby is still around
+func SeeOwner(lobbies LobbyRepository, ownerID uint, now time.Time) error {
+	if err := lobbies.SeeOwner(ownerID, now, now.Add(-ownerSeenPrecision)); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// HandOverIdleLobbies hands lobbies whose owners haven't been seen for idle time over to their players present the longest.
+// Returns the lobbies which have changed hands, lobbies nobody could take are left to their owners
+func HandOverIdleLobbies(lobbies LobbyRepository, idle time.Duration, now time.Time) ([]*Lobby, error) {
+	idleLobbies, err := lobbies.GetIdleOwned(now.Add(-idle))
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	handedOver := []*Lobby{}
+	for _, lobby := range idleLobbies {
+		successor, err := lobby.handOver(lobbies, now)
+		if err != nil {
+			return handedOver, err
+		}
+		if successor != nil {
+			handedOver = append(handedOver, lobby)
+		}
+	}
+	return handedOver, nil
+}
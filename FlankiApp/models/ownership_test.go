@@ -0,0 +1,176 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+	"time"
+)
+
+func TestCoOwnersManageLobbyUntilRemoved(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	coOwner := newAccount(t, repos, "coowner")
+	lobby := newLobby(t, repos, owner.ID, 4)
+
+	if err := lobby.AddCoOwner(repos.Lobbies, repos.Accounts, owner.ID); httpCode(err) != 400 {
+		t.Fatalf("owner can't co-own their lobby, got %v", err)
+	}
+	if err := lobby.AddCoOwner(repos.Lobbies, repos.Accounts, coOwner.ID); err != nil {
+		t.Fatalf("adding co-owner: %v", err)
+	}
+	if err := lobby.AddCoOwner(repos.Lobbies, repos.Accounts, coOwner.ID); httpCode(err) != 409 {
+		t.Fatalf("expected 409 when adding co-owner twice, got %v", err)
+	}
+
+	managed, err := models.GetManagedLobby(repos.Lobbies, coOwner.ID, 0)
+	if err != nil || managed.ID != lobby.ID {
+		t.Fatalf("co-owner should manage the lobby, got %v, %v", managed, err)
+	}
+	if !managed.IsScorekeeper(coOwner.ID) {
+		t.Errorf("co-owner should record match events")
+	}
+	if _, err := models.GetOwnersLobby(repos.Lobbies, coOwner.ID); err == nil {
+		t.Errorf("co-owner shouldn't become an owner")
+	}
+
+	if err := managed.RemoveCoOwner(repos.Lobbies, coOwner.ID); err != nil {
+		t.Fatalf("removing co-owner: %v", err)
+	}
+	if _, err := models.GetManagedLobby(repos.Lobbies, coOwner.ID, 0); httpCode(err) != 404 {
+		t.Errorf("removed co-owner shouldn't manage the lobby, got %v", err)
+	}
+}
+
+func TestPlayerManagingSeveralLobbiesChoosesOne(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	coOwner := newAccount(t, repos, "coowner")
+	first := newLobby(t, repos, newAccount(t, repos, "first").ID, 4)
+	second := newLobby(t, repos, newAccount(t, repos, "second").ID, 4)
+	other := newLobby(t, repos, newAccount(t, repos, "other").ID, 4)
+	for _, lobby := range []*models.Lobby{first, second} {
+		if err := lobby.AddCoOwner(repos.Lobbies, repos.Accounts, coOwner.ID); err != nil {
+			t.Fatalf("adding co-owner: %v", err)
+		}
+	}
+
+	if _, err := models.GetManagedLobby(repos.Lobbies, coOwner.ID, 0); err != models.ManagedLobbyAmbiguous {
+		t.Errorf("expected the lobby to be chosen explicitly, got %v", err)
+	}
+	if managed, err := models.GetManagedLobby(repos.Lobbies, coOwner.ID, second.ID); err != nil || managed.ID != second.ID {
+		t.Errorf("expected the chosen lobby, got %v, %v", managed, err)
+	}
+	if _, err := models.GetManagedLobby(repos.Lobbies, coOwner.ID, other.ID); httpCode(err) != 404 {
+		t.Errorf("expected lobby the player doesn't manage not to be found, got %v", err)
+	}
+}
+
+func TestOwnershipIsTransferredOnlyToMembers(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+	stranger := newAccount(t, repos, "stranger")
+	otherOwner := newAccount(t, repos, "otherowner")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	newLobby(t, repos, otherOwner.ID, 4)
+	join(t, repos, lobby.ID, player, models.Blue)
+	join(t, repos, lobby.ID, otherOwner, models.Red)
+
+	lobby = ownersLobby(t, repos, owner.ID)
+	if err := lobby.TransferOwnership(repos.Lobbies, stranger.ID, time.Now()); httpCode(err) != 400 {
+		t.Errorf("expected 400 when handing lobby over to a stranger, got %v", err)
+	}
+	if err := lobby.TransferOwnership(repos.Lobbies, otherOwner.ID, time.Now()); httpCode(err) != 409 {
+		t.Errorf("expected 409 when handing lobby over to an owner of another lobby, got %v", err)
+	}
+	if err := lobby.TransferOwnership(repos.Lobbies, player.ID, time.Now()); err != nil {
+		t.Fatalf("transferring ownership: %v", err)
+	}
+	if _, err := models.GetOwnersLobby(repos.Lobbies, owner.ID); err == nil {
+		t.Errorf("previous owner shouldn't own the lobby anymore")
+	}
+	if owned := ownersLobby(t, repos, player.ID); owned.ID != lobby.ID {
+		t.Errorf("player should own lobby %d, got %d", lobby.ID, owned.ID)
+	}
+}
+
+func TestLobbyIsHandedOverToPlayerPresentTheLongest(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	otherOwner := newAccount(t, repos, "otherowner")
+	first := newAccount(t, repos, "first")
+	second := newAccount(t, repos, "second")
+	lobby := newLobby(t, repos, owner.ID, 6)
+	newLobby(t, repos, otherOwner.ID, 4)
+	join(t, repos, lobby.ID, owner, models.Red)
+	join(t, repos, lobby.ID, otherOwner, models.Blue)
+	join(t, repos, lobby.ID, first, models.Red)
+	join(t, repos, lobby.ID, second, models.Blue)
+
+	// nobody is idle yet
+	handedOver, err := models.HandOverIdleLobbies(repos.Lobbies, 30*time.Minute, time.Now())
+	if err != nil || len(handedOver) != 0 {
+		t.Fatalf("expected no lobby handed over, got %d, %v", len(handedOver), err)
+	}
+	if err := models.SeeOwner(repos.Lobbies, owner.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("seeing owner: %v", err)
+	}
+	handedOver, err = models.HandOverIdleLobbies(repos.Lobbies, 30*time.Minute, time.Now().Add(time.Hour))
+	if err != nil || len(handedOver) != 0 {
+		t.Fatalf("owner seen recently shouldn't lose the lobby, got %d, %v", len(handedOver), err)
+	}
+
+	// owner of another lobby is skipped even though they have joined earlier
+	handedOver, err = models.HandOverIdleLobbies(repos.Lobbies, 30*time.Minute, time.Now().Add(2*time.Hour))
+	if err != nil || len(handedOver) != 1 {
+		t.Fatalf("expected idle owner's lobby handed over, got %d, %v", len(handedOver), err)
+	}
+	if handedOver[0].OwnerID != first.ID {
+		t.Errorf("expected lobby handed over to %d, got %d", first.ID, handedOver[0].OwnerID)
+	}
+
+	// the new owner leaving hands the lobby over again
+	lobby = ownersLobby(t, repos, first.ID)
+	newOwner, err := lobby.Leave(repos.Lobbies, repos.Accounts, first.ID, time.Now())
+	if err != nil {
+		t.Fatalf("leaving lobby: %v", err)
+	}
+	if newOwner == nil || newOwner.PlayerID != owner.ID {
+		t.Fatalf("expected lobby handed over to the previous owner still playing in it, got %+v", newOwner)
+	}
+	newOwner, err = ownersLobby(t, repos, owner.ID).Leave(repos.Lobbies, repos.Accounts, second.ID, time.Now())
+	if err != nil || newOwner != nil {
+		t.Errorf("lobby shouldn't change hands when a player leaves, got %+v, %v", newOwner, err)
+	}
+}
+
+func TestOnlyOwnerChangesRefereeAndConfirmation(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	coOwner := newAccount(t, repos, "coowner")
+	referee := newAccount(t, repos, "referee")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	if err := lobby.AddCoOwner(repos.Lobbies, repos.Accounts, coOwner.ID); err != nil {
+		t.Fatal(err)
+	}
+	confirm := true
+	if err := (&models.Lobby{OwnerID: owner.ID, RefereeID: &referee.ID, ConfirmResults: &confirm}).Update(repos.Lobbies, owner.ID); err != nil {
+		t.Fatalf("owner updating referee and confirmation: %v", err)
+	}
+
+	off := false
+	if err := (&models.Lobby{OwnerID: owner.ID, ConfirmResults: &off}).Update(repos.Lobbies, referee.ID); httpCode(err) != 403 {
+		t.Errorf("expected referee not to switch off confirmation, got %v", err)
+	}
+	if err := (&models.Lobby{OwnerID: owner.ID, RefereeID: &coOwner.ID}).Update(repos.Lobbies, coOwner.ID); httpCode(err) != 403 {
+		t.Errorf("expected co-owner not to change the referee, got %v", err)
+	}
+	// sending unchanged values back is fine
+	if err := (&models.Lobby{OwnerID: owner.ID, Name: "Renamed lobby", RefereeID: &referee.ID, ConfirmResults: &confirm}).Update(repos.Lobbies, coOwner.ID); err != nil {
+		t.Errorf("co-owner updating other fields: %v", err)
+	}
+	lobby = ownersLobby(t, repos, owner.ID)
+	if lobby.Name != "Renamed lobby" || *lobby.RefereeID != referee.ID || !*lobby.ConfirmResults {
+		t.Errorf("unexpected lobby after updates %+v", lobby)
+	}
+}
@@ -2,7 +2,6 @@ package models
 
 import (
 	"FlankiRest/errors"
-	"github.com/jinzhu/gorm"
 )
 
 type Player struct {
@@ -13,10 +12,8 @@ type Player struct {
 	Playing     bool   `json:"playing"`
 }
 
-func GetAllPlayersFunc(db *gorm.DB) ([]*Player, error) {
-	var account Account
-	var players []*Player
-	err := db.Model(&account).Select("id, nickname, sex, description, playing").Scan(&players).Error
+func GetAllPlayersFunc(accounts AccountRepository) ([]*Player, error) {
+	players, err := accounts.GetAllPlayers()
 
 	if err != nil {
 		return players, errors.New("Database error while fetching list of players" + err.Error(), 500)
@@ -24,17 +21,14 @@ func GetAllPlayersFunc(db *gorm.DB) ([]*Player, error) {
 	return players, nil
 }
 
-func GetPlayerByIdFunc(db *gorm.DB, id uint) (*Player, error) {
-	//var account Account
-	var players []*Player
-	err := db.Model(&Account{}).Select("id, nickname, sex, description, playing").Where("id = ?", id).Scan(&players).Error
+func GetPlayerByIdFunc(accounts AccountRepository, id uint) (*Player, error) {
+	player, err := accounts.GetPlayerById(id)
+	if err == errors.RecordNotFound {
+		return nil, errors.New("Player has not been found", 404)
+	}
 	if err != nil {
 		return nil, errors.New("Database error while fetching player by id" + err.Error(), 500)
 	}
-	if len(players) == 0 {
-		return nil, errors.New("Player has not been found", 404)
-	}
-	return players[0], nil
+	return player, nil
 }
 
-
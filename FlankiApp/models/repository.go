@@ -0,0 +1,234 @@
+package models
+
+import "time"
+
+// Repositories hide the way models are persisted so that business logic placed in models
+// doesn't have to know anything about gorm or postgres and can be tested against in-memory storage.
+// Every getter returns errors.RecordNotFound when nothing has been found, other errors are
+// raw storage errors which should be wrapped by the caller.
+
+type AccountRepository interface {
+	Create(account *Account) error
+	Save(account *Account) error
+
+	// soft deletes the account, getters don't find deleted accounts unless they say otherwise
+	Delete(account *Account) error
+	GetById(id uint) (*Account, error)
+	GetByEmail(email string) (*Account, error)
+	GetByCalendarToken(token string) (*Account, error)
+
+	// counts accounts having given value in given field, only 'nickname' and 'email' fields are supported
+	CountByField(fieldName string, value string) (int, error)
+	SetPlaying(id uint, playing bool) error
+	// sets playing status of every account to whether the player is in a team of any open lobby,
+	// returns numbers of accounts which have stopped and started playing
+	ReconcilePlaying() (stopped int64, started int64, err error)
+	GetAllPlayers() ([]*Player, error)
+	GetPlayerById(id uint) (*Player, error)
+
+	// the most recently deleted account with given email which hasn't been erased yet
+	GetDeletedByEmail(email string) (*Account, error)
+	Restore(account *Account) error
+
+	// accounts deleted before given time which haven't been erased yet
+	GetToErase(deletedBefore time.Time) ([]*Account, error)
+
+	// creates the tombstone, moves counted statistics of the account to it detached from their matches,
+	// anonymizes the account in match history and permanently deletes it together with everything else linked to it
+	Erase(account *Account, tombstone *Account) error
+}
+
+type LobbyRepository interface {
+	// creates lobby together with its teams
+	Create(lobby *Lobby) error
+
+	// saves only lobby's own fields, teams and their entries are left untouched
+	Save(lobby *Lobby) error
+
+	// permanently deletes lobby together with its teams and entries
+	Delete(lobby *Lobby) error
+
+	// all lobby getters return lobbies with teams and team entries loaded
+	GetById(id uint) (*Lobby, error)
+	GetOpenByOwner(ownerID uint) (*Lobby, error)
+	GetOpenByPlayer(playerID uint) (*Lobby, error)
+
+	// open lobbies co-owned or refereed by the player, the oldest first
+	GetOpenByManager(playerID uint) ([]*Lobby, error)
+
+	// lists lobbies ordered from the most recently updated
+	GetAll(closed bool, limit int) ([]*Lobby, error)
+
+	AddTeamEntry(team *Team, entry *TeamEntry) error
+	DeleteTeamEntry(entry *TeamEntry) error
+
+	// saves entry's own fields, e.g. ready flag
+	SaveTeamEntry(entry *TeamEntry) error
+
+	// moves entry to another team of the same lobby
+	MoveTeamEntry(entry *TeamEntry, team *Team) error
+
+	// appends record to lobby's result history
+	AddResultRecord(lobby *Lobby, record *ResultRecord) error
+
+	AddCoOwner(lobby *Lobby, coOwner *LobbyCoOwner) error
+	DeleteCoOwner(coOwner *LobbyCoOwner) error
+
+	// records activity of the owner of open lobby unless they have been seen after since
+	SeeOwner(ownerID uint, at time.Time, since time.Time) error
+
+	// open lobbies whose owners haven't been seen after given time
+	GetIdleOwned(seenBefore time.Time) ([]*Lobby, error)
+
+	// open lobbies which haven't been updated, whose owners haven't been seen and which aren't scheduled after given time
+	GetStale(activeBefore time.Time) ([]*Lobby, error)
+
+	// finished lobbies with results still pending at given time
+	GetPendingResults(deadline time.Time) ([]*Lobby, error)
+
+	// open lobbies starting after given time, from the soonest one
+	GetUpcoming(from time.Time, limit int) ([]*Lobby, error)
+
+	// open lobbies starting before given time whose players haven't been reminded yet
+	GetToRemind(until time.Time) ([]*Lobby, error)
+}
+
+type RSVPRepository interface {
+	// saves player's response replacing the previous one to the same lobby
+	Save(rsvp *RSVP) error
+
+	// responses to the lobby ordered as they were first given
+	GetByLobby(lobbyID uint) ([]RSVP, error)
+	GetByPlayer(playerID uint) ([]RSVP, error)
+}
+
+type SpectatorRepository interface {
+	// adds the spectator unless the player already watches the lobby, reports whether they have been added
+	Add(spectator *LobbySpectator) (bool, error)
+	// reports whether the player has been watching the lobby
+	Remove(lobbyID uint, playerID uint) (bool, error)
+
+	// spectators of the lobby in order they have come
+	GetByLobby(lobbyID uint) ([]LobbySpectator, error)
+}
+
+type InviteRepository interface {
+	Create(invite *LobbyInvite) error
+	Save(invite *LobbyInvite) error
+
+	// returns errors.RecordNotFound when there is no invite with the code
+	GetByCode(code string) (*LobbyInvite, error)
+	GetByLobby(lobbyID uint) ([]LobbyInvite, error)
+
+	// counts use of the invite unless it has been revoked or used up, reports whether it has been counted
+	Use(invite *LobbyInvite) (bool, error)
+	// gives back the use when joining with the invite failed
+	Release(invite *LobbyInvite) error
+}
+
+type FriendRepository interface {
+	// creates or updates friendship
+	SaveFriendship(friendship *Friendship) error
+	DeleteFriendship(friendship *Friendship) error
+
+	// friendship of two players whichever of them sent the request
+	GetFriendship(playerID uint, otherID uint) (*Friendship, error)
+
+	// all friendships and requests of the player in both directions, ordered as they were requested
+	GetFriendships(playerID uint) ([]Friendship, error)
+
+	AddFollow(follow *Follow) error
+	DeleteFollow(followerID uint, followeeID uint) error
+
+	// follows ordered as they were added
+	GetFollowing(followerID uint) ([]Follow, error)
+	GetFollowers(followeeID uint) ([]Follow, error)
+}
+
+type NotificationRepository interface {
+	Create(notification *Notification) error
+
+	// account's notifications from the newest one
+	GetByAccount(accountID uint, unreadOnly bool, limit int) ([]Notification, error)
+	CountUnread(accountID uint) (int, error)
+
+	// marks unread notifications of the account with given ids, or all of them when there are none,
+	// returns how many have been marked
+	MarkRead(accountID uint, ids []uint, at time.Time) (int, error)
+
+	// preferences the account has chosen, types it hasn't chosen are missing
+	GetPreferences(accountID uint) ([]NotificationPreference, error)
+
+	// creates or updates preference of its type
+	SavePreference(preference *NotificationPreference) error
+
+	// saves subscription replacing the one with the same endpoint
+	SavePushSubscription(subscription *PushSubscription) error
+	GetPushSubscriptions(accountID uint) ([]PushSubscription, error)
+	DeletePushSubscription(accountID uint, endpoint string) error
+
+	// replaces body of notifications of given type which is exactly the same as given one, returns how many have been replaced
+	ReplaceBody(notificationType NotificationType, body string, replacement string) (int, error)
+}
+
+type TournamentRepository interface {
+	Create(tournament *Tournament) error
+
+	// saves tournament's own fields, teams and matches are saved by their own methods
+	Save(tournament *Tournament) error
+
+	// all tournament getters return tournaments with teams, their members and matches ordered by number
+	GetById(id uint) (*Tournament, error)
+	GetByMatch(matchID uint) (*Tournament, error)
+
+	// lists tournaments from the most recently created
+	GetAll(limit int) ([]*Tournament, error)
+
+	// finished tournaments won by a team the player was member of, the oldest first
+	GetWonBy(playerID uint) ([]*Tournament, error)
+
+	AddTeam(tournament *Tournament, team *TournamentTeam) error
+	SaveTeam(team *TournamentTeam) error
+
+	// creates all matches of the tournament at once
+	CreateMatches(tournament *Tournament) error
+	SaveMatch(match *TournamentMatch) error
+}
+
+type MatchEventRepository interface {
+	Create(event *MatchEvent) error
+
+	// events of the lobbies ordered as they were recorded
+	GetByLobbies(lobbyIDs ...uint) ([]MatchEvent, error)
+
+	// events of the player from finished matches only
+	GetByPlayer(playerID uint) ([]MatchEvent, error)
+}
+
+type StatisticsRepository interface {
+	Create(entry *PlayerStatisticsEntry) error
+
+	// freezes or unfreezes all entries of the lobby's match
+	SetFrozen(lobbyID uint, frozen bool) error
+	DeleteByLobby(lobbyID uint) error
+
+	// summary without player's nickname, frozen entries aren't counted
+	GetSummary(playerID uint) (*PlayerSummary, error)
+
+	// players' summaries ordered by points
+	GetRanking() ([]PlayerSummary, error)
+
+	// entries of the player's counted matches, the oldest first
+	GetByPlayer(playerID uint) ([]PlayerStatisticsEntry, error)
+
+	// entries of all the player's matches including frozen ones, the oldest first
+	GetAllByPlayer(playerID uint) ([]PlayerStatisticsEntry, error)
+}
+
+type AchievementRepository interface {
+	// creates the achievement unless the player already has its badge, returns whether it has been created
+	Award(achievement *Achievement) (bool, error)
+
+	// achievements of the player in order they have been awarded
+	GetByPlayer(playerID uint) ([]Achievement, error)
+}
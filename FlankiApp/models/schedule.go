@@ -0,0 +1,241 @@
+package models
+
+import (
+	"FlankiRest/calendar"
+	"FlankiRest/errors"
+	"FlankiRest/validation"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type RSVPResponse string
+
+const (
+	Going    RSVPResponse = "going"
+	Maybe    RSVPResponse = "maybe"
+	Declined RSVPResponse = "declined"
+)
+
+// RSVP is player's response whether they are coming to the scheduled lobby, every player has one response per lobby
+type RSVP struct {
+	ID        uint         `json:"-" gorm:"primary_key"`
+	LobbyID   uint         `json:"lobby_id"`
+	PlayerID  uint         `json:"player_id"`
+	Nickname  string       `json:"nickname"`
+	Response  RSVPResponse `json:"response"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type RSVPRequest struct {
+	Response RSVPResponse `json:"response" validate:"oneof=going maybe declined"`
+}
+
+// Reminder of the scheduled lobby for players who are coming or might come, together with those already in its teams
+type Reminder struct {
+	Lobby     *Lobby
+	PlayerIDs []uint
+}
+
+// how many upcoming lobbies are listed at most
+const upcomingLimit = 100
+
+// checkSchedule accepts only known time zones and, when the start is being set, only starts in the future.
+// Lobby scheduled without time zone is in UTC
+func (lobby *Lobby) checkSchedule(starting bool, now time.Time) error {
+	if lobby.StartsAt == nil {
+		return nil
+	}
+	if lobby.Timezone == "" {
+		lobby.Timezone = "UTC"
+	}
+	location, err := time.LoadLocation(lobby.Timezone)
+	if err != nil {
+		return validation.Merge(validation.Field("timezone", validation.UnknownZone, nil))
+	}
+	if starting && !lobby.StartsAt.After(now) {
+		return errors.New("Lobby can be scheduled only in the future", 400)
+	}
+	startsAt := lobby.StartsAt.In(location)
+	lobby.StartsAt = &startsAt
+	return nil
+}
+
+// Location is the time zone of the lobby's start
+func (lobby *Lobby) Location() *time.Location {
+	if location, err := time.LoadLocation(lobby.Timezone); err == nil && lobby.Timezone != "" {
+		return location
+	}
+	return time.UTC
+}
+
+// Respond records whether the player is coming to the scheduled lobby, they can change their mind until the match starts
+func (lobby *Lobby) Respond(rsvps RSVPRepository, account *Account, request *RSVPRequest) (*RSVP, error) {
+	if err := validation.Check(request); err != nil {
+		return nil, err
+	}
+	if lobby.StartsAt == nil {
+		return nil, errors.New("Lobby has not been scheduled", 409)
+	}
+	if !lobby.RosterIsOpen() {
+		return nil, errors.RosterLocked
+	}
+	rsvp := &RSVP{LobbyID: lobby.ID, PlayerID: account.ID, Nickname: account.Nickname, Response: request.Response}
+	if err := rsvps.Save(rsvp); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return rsvp, nil
+}
+
+func GetRSVPs(rsvps RSVPRepository, lobbyID uint) ([]RSVP, error) {
+	responses, err := rsvps.GetByLobby(lobbyID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return responses, nil
+}
+
+// GetUpcomingLobbies lists lobbies visible to the player which haven't started yet from the soonest one
+func GetUpcomingLobbies(lobbies LobbyRepository, friends FriendRepository, playerID uint, now time.Time) ([]*LobbyListing, error) {
+	upcoming, err := lobbies.GetUpcoming(now, upcomingLimit)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if upcoming, err = VisibleLobbies(friends, upcoming, playerID); err != nil {
+		return nil, err
+	}
+	listings := make([]*LobbyListing, len(upcoming))
+	for i, lobby := range upcoming {
+		listings[i] = NewLobbyListing(lobby)
+	}
+	return listings, nil
+}
+
+// TakeDueReminders marks lobbies starting within given time as reminded and returns whom to remind about them.
+// Lobbies whose start has already passed, e.g. while the app wasn't running, are marked without reminding anybody
+func TakeDueReminders(lobbies LobbyRepository, rsvps RSVPRepository, now time.Time, before time.Duration) ([]Reminder, error) {
+	due, err := lobbies.GetToRemind(now.Add(before))
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	reminders := []Reminder{}
+	for _, lobby := range due {
+		lobby.RemindedAt = &now
+		if err := lobbies.Save(lobby); err != nil {
+			return reminders, errors.DatabaseError(err)
+		}
+		if !lobby.StartsAt.After(now) {
+			continue
+		}
+
+		responses, err := rsvps.GetByLobby(lobby.ID)
+		if err != nil {
+			return reminders, errors.DatabaseError(err)
+		}
+		reminded := map[uint]bool{}
+		reminder := Reminder{Lobby: lobby}
+		remind := func(playerID uint) {
+			if !reminded[playerID] {
+				reminded[playerID] = true
+				reminder.PlayerIDs = append(reminder.PlayerIDs, playerID)
+			}
+		}
+		remind(lobby.OwnerID)
+		for _, team := range lobby.Teams {
+			for _, entry := range team.TeamEntries {
+				remind(entry.PlayerID)
+			}
+		}
+		for _, response := range responses {
+			if response.Response != Declined {
+				remind(response.PlayerID)
+			}
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, nil
+}
+
+// CalendarEvent describes scheduled lobby, cancelled lobbies stay in feeds so that calendars drop them
+func (lobby *Lobby) CalendarEvent() calendar.Event {
+	return calendar.Event{
+		UID:         fmt.Sprintf("lobby-%d@flanki", lobby.ID),
+		Summary:     lobby.Name,
+		Description: fmt.Sprintf("Flanki match for up to %d players", lobby.PlayerLimit),
+		Start:       *lobby.StartsAt,
+		Updated:     lobby.UpdatedAt,
+		Latitude:    lobby.Latitude,
+		Longitude:   lobby.Longitude,
+		HasLocation: lobby.Latitude != 0 || lobby.Longitude != 0,
+		Cancelled:   lobby.State == Cancelled,
+	}
+}
+
+// GetLobbyCalendar is a feed with the single event of scheduled lobby. The feed is public, so lobbies which aren't
+// visible to everyone look like they don't exist, players find them in their own feeds
+func GetLobbyCalendar(lobbies LobbyRepository, lobbyID uint) (*calendar.Feed, error) {
+	lobby, err := GetLobbyByIdFunc(lobbies, lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	// lobbies created before visibility was introduced are visible to everyone
+	if lobby.Visibility != "" && lobby.Visibility != Everyone {
+		return nil, errors.New("Lobby has not been found", 404)
+	}
+	if lobby.StartsAt == nil {
+		return nil, errors.New("Lobby has not been scheduled", 404)
+	}
+	return &calendar.Feed{Name: lobby.Name, Timezone: lobby.Location().String(), Events: []calendar.Event{lobby.CalendarEvent()}}, nil
+}
+
+// CalendarFeedToken returns token of the account's calendar feed, a new one is generated when there is none yet
+// or when renew is set, which makes the old address stop working
+func (account *Account) CalendarFeedToken(accounts AccountRepository, renew bool) (string, error) {
+	if account.CalendarToken != "" && !renew {
+		return account.CalendarToken, nil
+	}
+	token, err := uuid.NewRandom()
+	if err != nil {
+		return "", errors.New("Error while generating uuid: "+err.Error(), 500)
+	}
+	account.CalendarToken = token.String()
+	if err := accounts.Save(account); err != nil {
+		return "", errors.DatabaseError(err)
+	}
+	return account.CalendarToken, nil
+}
+
+// GetPlayersCalendar is a feed of lobbies the player is coming or might come to, looked up by their calendar token
+func GetPlayersCalendar(accounts AccountRepository, lobbies LobbyRepository, rsvps RSVPRepository, token string) (*calendar.Feed, error) {
+	if token == "" {
+		return nil, errors.New("Calendar has not been found", 404)
+	}
+	account, err := accounts.GetByCalendarToken(token)
+	if err != nil {
+		if err == errors.RecordNotFound {
+			return nil, errors.New("Calendar has not been found", 404)
+		}
+		return nil, errors.DatabaseError(err)
+	}
+	responses, err := rsvps.GetByPlayer(account.ID)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	feed := &calendar.Feed{Name: "Flanki - " + account.Nickname, Events: []calendar.Event{}}
+	for _, response := range responses {
+		if response.Response == Declined {
+			continue
+		}
+		lobby, err := lobbies.GetById(response.LobbyID)
+		if err == errors.RecordNotFound {
+			continue // deleted lobby
+		}
+		if err != nil {
+			return nil, errors.DatabaseError(err)
+		}
+		if lobby.StartsAt != nil {
+			feed.Events = append(feed.Events, lobby.CalendarEvent())
+		}
+	}
+	return feed, nil
+}
@@ -0,0 +1,178 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+	"time"
+)
+
+func scheduledLobby(t *testing.T, repos *repositories.Repositories, ownerID uint, startsAt time.Time) *models.Lobby {
+	t.Helper()
+	lobby := &models.Lobby{OwnerID: ownerID, Name: "Scheduled lobby", PlayerLimit: 4, StartsAt: &startsAt, Timezone: "Europe/Warsaw"}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating scheduled lobby: %v", err)
+	}
+	fetched, err := models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if err != nil {
+		t.Fatalf("fetching lobby: %v", err)
+	}
+	return fetched
+}
+
+func respond(t *testing.T, repos *repositories.Repositories, lobby *models.Lobby, account *models.Account, response models.RSVPResponse) {
+	t.Helper()
+	if _, err := lobby.Respond(repos.RSVPs, account, &models.RSVPRequest{Response: response}); err != nil {
+		t.Fatalf("responding %s to lobby: %v", response, err)
+	}
+}
+
+func TestLobbyCanBeScheduledOnlyAhead(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Warsaw"); err != nil {
+		t.Skip("tz database is not available")
+	}
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+
+	past := time.Now().Add(-time.Hour)
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Late lobby", PlayerLimit: 4, StartsAt: &past}
+	if err := lobby.Create(repos.Lobbies); httpCode(err) != 400 {
+		t.Fatalf("expected lobby starting in the past to be rejected, got %v", err)
+	}
+	future := time.Now().Add(24 * time.Hour)
+	lobby = &models.Lobby{OwnerID: owner.ID, Name: "Lobby on Mars", PlayerLimit: 4, StartsAt: &future, Timezone: "Mars/Olympus"}
+	err := lobby.Create(repos.Lobbies)
+	if apiErr, ok := err.(*errors.ApiError); !ok || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "timezone" {
+		t.Fatalf("expected unknown time zone to be rejected, got %v", err)
+	}
+
+	lobby = scheduledLobby(t, repos, owner.ID, future)
+	if lobby.StartsAt.Location().String() != "Europe/Warsaw" || !lobby.StartsAt.Equal(future) {
+		t.Errorf("expected start in lobby's time zone, got %s", lobby.StartsAt)
+	}
+	// renaming doesn't touch the start
+	if err := (&models.Lobby{OwnerID: owner.ID, Name: "Renamed lobby"}).Update(repos.Lobbies, owner.ID); err != nil {
+		t.Fatalf("updating lobby: %v", err)
+	}
+	if updated := ownersLobby(t, repos, owner.ID); updated.StartsAt == nil || !updated.StartsAt.Equal(future) {
+		t.Errorf("expected start to survive update, got %v", updated.StartsAt)
+	}
+}
+
+func TestPlayersRespondUntilMatchStarts(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	player := newAccount(t, repos, "player")
+
+	unscheduled := newLobby(t, repos, owner.ID, 4)
+	if _, err := unscheduled.Respond(repos.RSVPs, player, &models.RSVPRequest{Response: models.Going}); httpCode(err) != 409 {
+		t.Fatalf("expected response to unscheduled lobby to be rejected, got %v", err)
+	}
+	if err := unscheduled.Delete(repos.Lobbies, repos.Accounts); err != nil {
+		t.Fatalf("deleting lobby: %v", err)
+	}
+
+	lobby := scheduledLobby(t, repos, owner.ID, time.Now().Add(24*time.Hour))
+	if _, err := lobby.Respond(repos.RSVPs, player, &models.RSVPRequest{Response: "perhaps"}); httpCode(err) != 400 {
+		t.Fatalf("expected unknown response to be rejected, got %v", err)
+	}
+	respond(t, repos, lobby, player, models.Going)
+	respond(t, repos, lobby, player, models.Maybe)
+
+	rsvps, err := models.GetRSVPs(repos.RSVPs, lobby.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rsvps) != 1 || rsvps[0].Response != models.Maybe || rsvps[0].Nickname != "player" {
+		t.Fatalf("expected single changed response, got %+v", rsvps)
+	}
+
+	upcoming, err := models.GetUpcomingLobbies(repos.Lobbies, repos.Friends, player.ID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) != 1 || upcoming[0].ID != lobby.ID {
+		t.Errorf("expected scheduled lobby to be upcoming, got %+v", upcoming)
+	}
+}
+
+func TestRemindersAreTakenOnce(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	blue := newAccount(t, repos, "blueplayer")
+	coming := newAccount(t, repos, "coming")
+	declined := newAccount(t, repos, "declined")
+
+	now := time.Now()
+	soon := scheduledLobby(t, repos, owner.ID, now.Add(30*time.Minute))
+	join(t, repos, soon.ID, blue, models.Blue)
+	respond(t, repos, soon, blue, models.Going)
+	respond(t, repos, soon, coming, models.Maybe)
+	respond(t, repos, soon, declined, models.Declined)
+	other := newAccount(t, repos, "other")
+	scheduledLobby(t, repos, other.ID, now.Add(48*time.Hour))
+
+	reminders, err := models.TakeDueReminders(repos.Lobbies, repos.RSVPs, now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 1 || reminders[0].Lobby.ID != soon.ID {
+		t.Fatalf("expected reminder of the lobby starting soon only, got %+v", reminders)
+	}
+	expected := []uint{owner.ID, blue.ID, coming.ID}
+	if ids := reminders[0].PlayerIDs; len(ids) != len(expected) || ids[0] != expected[0] || ids[1] != expected[1] || ids[2] != expected[2] {
+		t.Errorf("expected %v to be reminded, got %v", expected, ids)
+	}
+
+	reminders, err = models.TakeDueReminders(repos.Lobbies, repos.RSVPs, now.Add(time.Minute), time.Hour)
+	if err != nil || len(reminders) != 0 {
+		t.Fatalf("expected players to be reminded only once, got %+v, %v", reminders, err)
+	}
+}
+
+func TestPlayersCalendarListsLobbiesTheyAreComingTo(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	first := scheduledLobby(t, repos, newAccount(t, repos, "first").ID, time.Now().Add(24*time.Hour))
+	second := scheduledLobby(t, repos, newAccount(t, repos, "second").ID, time.Now().Add(48*time.Hour))
+	respond(t, repos, first, player, models.Going)
+	respond(t, repos, second, player, models.Declined)
+
+	token, err := player.CalendarFeedToken(repos.Accounts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := models.GetPlayersCalendar(repos.Accounts, repos.Lobbies, repos.RSVPs, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Events) != 1 || feed.Events[0].Summary != first.Name {
+		t.Fatalf("expected only the lobby player is coming to, got %+v", feed.Events)
+	}
+
+	renewed, err := player.CalendarFeedToken(repos.Accounts, true)
+	if err != nil || renewed == token {
+		t.Fatalf("expected new token, got %q, %v", renewed, err)
+	}
+	if _, err := models.GetPlayersCalendar(repos.Accounts, repos.Lobbies, repos.RSVPs, token); httpCode(err) != 404 {
+		t.Errorf("expected old token to stop working, got %v", err)
+	}
+}
+
+func TestLobbyCalendarIsPublicOnlyForLobbiesVisibleToEveryone(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	lobby := scheduledLobby(t, repos, owner.ID, time.Now().Add(24*time.Hour))
+	if feed, err := models.GetLobbyCalendar(repos.Lobbies, lobby.ID); err != nil || len(feed.Events) != 1 {
+		t.Fatalf("expected feed of the lobby visible to everyone, got %v", err)
+	}
+
+	lobby.Visibility = models.FriendsOfOwner
+	if err := repos.Lobbies.Save(lobby); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.GetLobbyCalendar(repos.Lobbies, lobby.ID); httpCode(err) != 404 {
+		t.Errorf("expected friends only lobby not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"time"
+)
+
+// LobbySpectator watches the lobby without playing in it, so watching doesn't set Playing
+// and players can watch other lobbies while they play
+type LobbySpectator struct {
+	ID        uint      `json:"-" gorm:"primary_key"`
+	CreatedAt time.Time `json:"since"`
+	LobbyID   uint      `json:"-"`
+	PlayerID  uint      `json:"player_id"`
+	Nickname  string    `json:"nickname"`
+}
+
+var spectatorNotFound = errors.New("Player is not watching the lobby", 404)
+
+// LoadSpectators fills spectators of the lobby, in order they have come
+func (lobby *Lobby) LoadSpectators(spectators SpectatorRepository) error {
+	list, err := spectators.GetByLobby(lobby.ID)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	lobby.Spectators = list
+	return nil
+}
+
+// IsMember tells whether the player owns, plays in or watches the lobby, spectators have to be loaded
+func (lobby *Lobby) IsMember(playerID uint) bool {
+	if lobby.OwnerID == playerID {
+		return true
+	}
+	for i := range lobby.Teams {
+		if lobby.Teams[i].ContainsPlayerWithId(playerID) {
+			return true
+		}
+	}
+	for _, spectator := range lobby.Spectators {
+		if spectator.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddSpectator lets the account's owner watch the lobby, private lobbies need the password just like joining a team.
+// Watching again doesn't change anything
+func (lobby *Lobby) AddSpectator(spectators SpectatorRepository, account *Account, request *LobbyRequest) error {
+	if *lobby.Closed {
+		return errors.New("Closed lobby can't be watched", 409)
+	}
+	if *lobby.Private && !request.CheckPassword(lobby) {
+		return errors.UnauthorizedLobbyJoinRequest
+	}
+	if err := lobby.LoadSpectators(spectators); err != nil {
+		return err
+	}
+	if lobby.IsMember(account.ID) {
+		for _, spectator := range lobby.Spectators {
+			if spectator.PlayerID == account.ID {
+				return nil
+			}
+		}
+		return errors.New("Players and the owner of the lobby can't watch it", 400)
+	}
+	if lobby.SpectatorLimit != nil && *lobby.SpectatorLimit > 0 && len(lobby.Spectators) >= int(*lobby.SpectatorLimit) {
+		return errors.New("Lobby has reached its limit of spectators", 403)
+	}
+
+	spectator := &LobbySpectator{LobbyID: lobby.ID, PlayerID: account.ID, Nickname: account.Nickname}
+	if _, err := spectators.Add(spectator); err != nil {
+		return errors.DatabaseError(err)
+	}
+	lobby.Spectators = append(lobby.Spectators, *spectator)
+	return nil
+}
+
+// RemoveSpectator stops the player watching the lobby, the player can come back unless the lobby is full of spectators
+func (lobby *Lobby) RemoveSpectator(spectators SpectatorRepository, playerID uint) error {
+	removed, err := spectators.Remove(lobby.ID, playerID)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	if !removed {
+		return spectatorNotFound
+	}
+	return nil
+}
+
+// StopWatching removes the player from spectators of the lobby if they have been watching it,
+// players joining a team of the lobby stop watching it
+func (lobby *Lobby) StopWatching(spectators SpectatorRepository, playerID uint) error {
+	if _, err := spectators.Remove(lobby.ID, playerID); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+)
+
+func watch(repos *repositories.Repositories, lobbyID uint, account *models.Account, password string) error {
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, lobbyID)
+	if err != nil {
+		return err
+	}
+	return lobby.AddSpectator(repos.Spectators, account, &models.LobbyRequest{TeamColor: models.Spectator, Password: password})
+}
+
+func TestSpectatorsWatchWithoutPlaying(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner, player := newAccount(t, repos, "owner"), newAccount(t, repos, "player")
+	watcher, late := newAccount(t, repos, "watcher"), newAccount(t, repos, "late_watcher")
+	limit := uint(1)
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Watched lobby", PlayerLimit: 4, SpectatorLimit: &limit}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating lobby: %v", err)
+	}
+	join(t, repos, lobby.ID, player, models.Blue)
+
+	if err := watch(repos, lobby.ID, player, ""); httpCode(err) != 400 {
+		t.Fatalf("expected player not to watch their own lobby, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := watch(repos, lobby.ID, watcher, ""); err != nil {
+			t.Fatalf("expected watching to be allowed, got %v", err)
+		}
+	}
+	if err := watch(repos, lobby.ID, late, ""); httpCode(err) != 403 {
+		t.Fatalf("expected spectator limit to be reached, got %v", err)
+	}
+	if account, _ := models.GetAccountById(repos.Accounts, watcher.ID); account.Playing {
+		t.Errorf("expected spectator not to be playing")
+	}
+	// spectators don't take places of players
+	join(t, repos, lobby.ID, late, models.Red)
+
+	lobby = ownersLobby(t, repos, owner.ID)
+	if err := lobby.LoadSpectators(repos.Spectators); err != nil {
+		t.Fatal(err)
+	}
+	if len(lobby.Spectators) != 1 || lobby.Spectators[0].Nickname != "watcher" || !lobby.IsMember(watcher.ID) {
+		t.Fatalf("expected single spectator listed apart from teams, got %+v", lobby.Spectators)
+	}
+	if err := lobby.RemoveSpectator(repos.Spectators, watcher.ID); err != nil {
+		t.Fatalf("removing spectator: %v", err)
+	}
+	if err := lobby.RemoveSpectator(repos.Spectators, watcher.ID); httpCode(err) != 404 {
+		t.Errorf("expected removed spectator not to be found, got %v", err)
+	}
+}
+
+func TestPrivateLobbyIsWatchedWithPassword(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner, watcher := newAccount(t, repos, "owner"), newAccount(t, repos, "watcher")
+	lobby := privateLobby(t, repos, owner)
+
+	if err := watch(repos, lobby.ID, watcher, "wrong"); httpCode(err) != 401 {
+		t.Fatalf("expected wrong password to be rejected, got %v", err)
+	}
+	if err := watch(repos, lobby.ID, watcher, "flanki"); err != nil {
+		t.Fatalf("expected password to let spectator in, got %v", err)
+	}
+	// controllers stop players watching the lobby once they join its team
+	if err := lobby.StopWatching(repos.Spectators, watcher.ID); err != nil {
+		t.Fatal(err)
+	}
+	if spectators, _ := repos.Spectators.GetByLobby(lobby.ID); len(spectators) != 0 {
+		t.Errorf("expected player to stop watching, got %+v", spectators)
+	}
+}
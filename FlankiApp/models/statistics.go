@@ -2,8 +2,6 @@ package models
 
 import (
 	"FlankiRest/errors"
-	"fmt"
-	"github.com/jinzhu/gorm"
 	"math"
 )
 
@@ -13,6 +11,8 @@ type PlayerStatisticsEntry struct {
 	LobbyId  uint   `json:"lobby_id"`
 	Win      bool   `json:"is_win"`
 	Points   int    `json:"points"`
+	// frozen entries don't count until result of the match is confirmed or settled
+	Frozen   bool   `json:"frozen,omitempty" gorm:"not null;default:false"`
 }
 
 type PlayerSummary struct {
@@ -35,38 +35,25 @@ type QuickSummary struct {
 
 
 
-func GetPlayersSummary(db *gorm.DB, id uint) (*PlayerSummary, error) {
-	var summary PlayerSummary
-	var account Account
-	err := db.Model(&Account{}).Select("id, nickname").Where("id = ?", id).First(&account).Error
+func GetPlayersSummary(accounts AccountRepository, statistics StatisticsRepository, id uint) (*PlayerSummary, error) {
+	account, err := accounts.GetById(id)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if err == errors.RecordNotFound {
 			return nil, errors.PlayerNotFound
 		}
 		return nil, errors.New("Error while checking players ranking", 500)
 	}
-	summary.Nickname = account.Nickname
 
-	querry := fmt.Sprintf(`Select %d as player_id,
-	(select count(id) from player_statistics_entries where player_id = %d and win = 'true') as wins,
-		(select count(id) from player_statistics_entries where player_id = %d and  win = 'false') as loses,
-		(select sum(points) from player_statistics_entries where player_id = %d ) as points;`, id, id, id, id)
-	err = db.Raw(querry).Scan(&summary).Error
+	summary, err := statistics.GetSummary(id)
 	if err != nil {
 		return nil, errors.New("Couldn't get players summary: " + err.Error(), 500)
 	}
-	return &summary, nil
+	summary.Nickname = account.Nickname
+	return summary, nil
 }
 
-func GetPlayersRanking(db *gorm.DB) ([]PlayerSummary, error) {
-	var  summaries []PlayerSummary
-	querry := `select player_id, (select nickname from accounts where id = player_id),
-  					  	sum(case when win = 'true' then 1 else 0 end) as wins, 
-						sum(case when win = 'false' then 1 else 0 end) as loses, 
-						sum(points) as points  from player_statistics_entries 
-						group by player_id
-						order by points desc`
-	err := db.Raw(querry).Scan(&summaries).Error
+func GetPlayersRanking(statistics StatisticsRepository) ([]PlayerSummary, error) {
+	summaries, err := statistics.GetRanking()
 	if err != nil {
 		return nil, errors.New("Couldn't get players ranking: " + err.Error(), 500)
 	}
@@ -76,23 +63,19 @@ func GetPlayersRanking(db *gorm.DB) ([]PlayerSummary, error) {
 
 // Points are computed based on number of players in your team, if  your team wins
 // you gain 10 points for each player in your team, but if you lose you lose 10 points for each teammate
+// Lobby has to be fetched together with its teams.
 
-func ComputePoints(db *gorm.DB, lobby *Lobby, currentTeam TeamColor) (int, error) {
+func ComputePoints(lobby *Lobby, currentTeam TeamColor) int {
 
 	const multiplier = 1.5
 	// penalty is zero for now
 	const penalty = 0
 	winner := lobby.Winner
-	var teams []Team
-	err := db.Model(&lobby).Related(&teams).Error
-	if err != nil {
-		return 0, errors.New("Encountered error while fetching teams from lobby", 500)
-	}
-	for _, team := range teams {
+	for _, team := range lobby.Teams {
 
 		// it means that we caught enemy team, we calculate points based on the number of enemies
 		if team.TeamColor != currentTeam {
-			players := team.TeamEntriesCount(db)
+			players := team.TeamEntriesCount()
 
 			// if the number of enemy players equals 0 we then apply the penalty score
 			// multiplier ^ 0 equals 1 so if there were no players then substracting more than
@@ -108,24 +91,16 @@ func ComputePoints(db *gorm.DB, lobby *Lobby, currentTeam TeamColor) (int, error
 			if lobby.Winner != currentTeam {
 				points *= -0.5
 			}
-			return int(math.Floor(points)), nil
+			return int(math.Floor(points))
 		}
 	}
-	return 0,nil
+	return 0
 }
 
-func SubmitMatch(db *gorm.DB, lobby *Lobby) error {
-	var teams []Team
-	err := db.Model(&lobby).Related(&teams).Error
-	if err != nil {
-		return errors.New("Encountered error while fetching teams from lobby", 500)
-	}
-	for _, team := range teams {
+func SubmitMatch(statistics StatisticsRepository, lobby *Lobby) error {
+	for _, team := range lobby.Teams {
 
-		points, err := ComputePoints(db, lobby, team.TeamColor)
-		if err != nil {
-			return err
-		}
+		points := ComputePoints(lobby, team.TeamColor)
 
 		var won bool
 		switch lobby.Winner {
@@ -135,18 +110,17 @@ func SubmitMatch(db *gorm.DB, lobby *Lobby) error {
 			won = false
 		}
 
-		var players []TeamEntry
-		err = db.Model(&team).Related(&players).Error
-		if err != nil {
-			return errors.New("Encountered error while fetching teams entries from team", 500)
-		}
-		for _, entry := range players {
-			res := &PlayerStatisticsEntry{PlayerID: entry.PlayerID, LobbyId: lobby.ID, Points: points, Win: won}
-			err = db.Create(res).Error
+		for _, entry := range team.TeamEntries {
+			// erased players still count in points of the others but get no statistics
+			if entry.PlayerID == 0 {
+				continue
+			}
+			res := &PlayerStatisticsEntry{PlayerID: entry.PlayerID, LobbyId: lobby.ID, Points: points, Win: won, Frozen: lobby.StatisticsFrozen()}
+			err := statistics.Create(res)
 			if err != nil {
 				return errors.New("Error while saving results"+err.Error(), 500)
 			}
 		}
 	}
 	return nil
-}
\ No newline at end of file
+}
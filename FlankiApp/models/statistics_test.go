@@ -0,0 +1,88 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+)
+
+func lobbyWithTeams(winner models.TeamColor, blue int, red int) *models.Lobby {
+	entries := func(count int, offset uint) []models.TeamEntry {
+		list := []models.TeamEntry{}
+		for i := 0; i < count; i++ {
+			list = append(list, models.TeamEntry{PlayerID: offset + uint(i)})
+		}
+		return list
+	}
+	return &models.Lobby{
+		Winner: winner,
+		Teams: []models.Team{
+			{TeamColor: models.Blue, TeamEntries: entries(blue, 1)},
+			{TeamColor: models.Red, TeamEntries: entries(red, 100)},
+		},
+	}
+}
+
+func TestComputePoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		lobby    *models.Lobby
+		team     models.TeamColor
+		expected int
+	}{
+		{"winner against one player", lobbyWithTeams(models.Blue, 1, 1), models.Blue, 5},
+		{"loser against one player", lobbyWithTeams(models.Blue, 1, 1), models.Red, -3},
+		{"winner against three players", lobbyWithTeams(models.Red, 3, 2), models.Red, 23},
+		{"loser against two players", lobbyWithTeams(models.Red, 3, 2), models.Blue, -7},
+		{"winner against empty team", lobbyWithTeams(models.Blue, 2, 0), models.Blue, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if points := models.ComputePoints(test.lobby, test.team); points != test.expected {
+				t.Fatalf("expected %d points, got %d", test.expected, points)
+			}
+		})
+	}
+}
+
+func TestSubmitMatchUpdatesSummaryAndRanking(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	winner := newAccount(t, repos, "winner")
+	loser := newAccount(t, repos, "loser")
+	lobby := newLobby(t, repos, owner.ID, 4)
+	join(t, repos, lobby.ID, winner, models.Blue)
+	join(t, repos, lobby.ID, loser, models.Red)
+
+	lobby, _ = models.GetOwnersLobby(repos.Lobbies, owner.ID)
+	lobby.Winner = models.Blue
+	if err := models.SubmitMatch(repos.Statistics, lobby); err != nil {
+		t.Fatalf("submitting match: %v", err)
+	}
+
+	summary, err := models.GetPlayersSummary(repos.Accounts, repos.Statistics, winner.ID)
+	if err != nil {
+		t.Fatalf("getting summary: %v", err)
+	}
+	if summary.Nickname != "winner" || summary.Wins != 1 || summary.Loses != 0 || summary.Points != 5 {
+		t.Fatalf("unexpected winner's summary %+v", summary)
+	}
+
+	ranking, err := models.GetPlayersRanking(repos.Statistics)
+	if err != nil {
+		t.Fatalf("getting ranking: %v", err)
+	}
+	if len(ranking) != 2 || ranking[0].PlayerID != winner.ID || ranking[1].PlayerID != loser.ID {
+		t.Fatalf("unexpected ranking %+v", ranking)
+	}
+	if ranking[1].Points != -3 || ranking[1].Loses != 1 {
+		t.Fatalf("unexpected loser's ranking entry %+v", ranking[1])
+	}
+}
+
+func TestPlayersSummaryOfUnknownPlayer(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	if _, err := models.GetPlayersSummary(repos.Accounts, repos.Statistics, 42); httpCode(err) != 404 {
+		t.Fatalf("expected player not found, got %v", err)
+	}
+}
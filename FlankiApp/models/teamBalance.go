@@ -0,0 +1,118 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"sort"
+)
+
+// Ratings of players by their ids, rating is the sum of points the player has got in all matches
+type Ratings map[uint]int
+
+func GetRatings(statistics StatisticsRepository, playerIDs []uint) (Ratings, error) {
+	ratings := Ratings{}
+	for _, id := range playerIDs {
+		summary, err := statistics.GetSummary(id)
+		if err != nil {
+			return nil, errors.New("Couldn't get players ratings: " + err.Error(), 500)
+		}
+		ratings[id] = summary.Points
+	}
+	return ratings, nil
+}
+
+func (ratings Ratings) TeamRating(team *Team) int {
+	rating := 0
+	for _, entry := range team.TeamEntries {
+		rating += ratings[entry.PlayerID]
+	}
+	return rating
+}
+
+// TeamIsFull tells whether team has reached lobby's cap of players in each team, lobbies without the cap
+// are limited only by PlayerLimit
+func (lobby *Lobby) TeamIsFull(team *Team) bool {
+	return lobby.TeamLimit != nil && *lobby.TeamLimit > 0 && team.TeamEntriesCount() >= int(*lobby.TeamLimit)
+}
+
+// AutoTeam chooses team for the player joining with 'auto' color, it is the smaller team
+// or the weaker one by rating when both have the same number of players.
+// Lobby has to be fetched together with its teams
+func (lobby *Lobby) AutoTeam(statistics StatisticsRepository) (TeamColor, error) {
+	blue, red := lobby.GetTeam(Blue), lobby.GetTeam(Red)
+	if blue == nil || red == nil {
+		return NoneTeam, errors.New("Lobby should have blue and red teams", 500)
+	}
+	switch {
+	case lobby.TeamIsFull(blue) && lobby.TeamIsFull(red):
+		return NoneTeam, errors.TeamIsFull
+	case lobby.TeamIsFull(blue):
+		return Red, nil
+	case lobby.TeamIsFull(red):
+		return Blue, nil
+	case blue.TeamEntriesCount() < red.TeamEntriesCount():
+		return Blue, nil
+	case red.TeamEntriesCount() < blue.TeamEntriesCount():
+		return Red, nil
+	}
+
+	ids, err := lobby.GetLobbyPlayersIds()
+	if err != nil {
+		return NoneTeam, err
+	}
+	ratings, err := GetRatings(statistics, ids)
+	if err != nil {
+		return NoneTeam, err
+	}
+	if ratings.TeamRating(red) < ratings.TeamRating(blue) {
+		return Red, nil
+	}
+	return Blue, nil
+}
+
+// Shuffle rebalances teams by rating, players are dealt from the strongest one to the team with lower rating
+// until one of the teams has half of the players. Only players who change their team are moved.
+// Lobby has to be fetched together with its teams
+func (lobby *Lobby) Shuffle(lobbies LobbyRepository, statistics StatisticsRepository) error {
+	if !lobby.RosterIsOpen() {
+		return errors.RosterLocked
+	}
+	blue, red := lobby.GetTeam(Blue), lobby.GetTeam(Red)
+	if blue == nil || red == nil {
+		return errors.New("Lobby should have blue and red teams", 500)
+	}
+	ids, err := lobby.GetLobbyPlayersIds()
+	if err != nil {
+		return err
+	}
+	ratings, err := GetRatings(statistics, ids)
+	if err != nil {
+		return err
+	}
+
+	entries := append(append([]TeamEntry{}, blue.TeamEntries...), red.TeamEntries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if ratings[entries[i].PlayerID] == ratings[entries[j].PlayerID] {
+			return entries[i].PlayerID < entries[j].PlayerID
+		}
+		return ratings[entries[i].PlayerID] > ratings[entries[j].PlayerID]
+	})
+
+	half := (len(entries) + 1) / 2
+	shuffled := map[TeamColor]*Team{Blue: {TeamEntries: []TeamEntry{}}, Red: {TeamEntries: []TeamEntry{}}}
+	for _, entry := range entries {
+		newBlue, newRed := shuffled[Blue], shuffled[Red]
+		target := blue
+		if len(newBlue.TeamEntries) >= half ||
+			(len(newRed.TeamEntries) < half && ratings.TeamRating(newRed) < ratings.TeamRating(newBlue)) {
+			target = red
+		}
+		if entry.TeamID != target.ID {
+			if err := lobbies.MoveTeamEntry(&entry, target); err != nil {
+				return errors.New("Database error while shuffling teams: " + err.Error(), 500)
+			}
+		}
+		shuffled[target.TeamColor].TeamEntries = append(shuffled[target.TeamColor].TeamEntries, entry)
+	}
+	blue.TeamEntries, red.TeamEntries = shuffled[Blue].TeamEntries, shuffled[Red].TeamEntries
+	return nil
+}
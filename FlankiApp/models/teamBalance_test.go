@@ -0,0 +1,116 @@
+package models_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"testing"
+)
+
+// gives account a rating by recording a match with given points
+func rate(t *testing.T, repos *repositories.Repositories, account *models.Account, points int) {
+	t.Helper()
+	entry := &models.PlayerStatisticsEntry{PlayerID: account.ID, Points: points, Win: points > 0}
+	if err := repos.Statistics.Create(entry); err != nil {
+		t.Fatalf("rating %s: %v", account.Nickname, err)
+	}
+}
+
+func teamOf(t *testing.T, repos *repositories.Repositories, lobbyID uint, playerID uint) models.TeamColor {
+	t.Helper()
+	lobby, _ := models.GetLobbyByIdFunc(repos.Lobbies, lobbyID)
+	for _, team := range lobby.Teams {
+		if team.ContainsPlayerWithId(playerID) {
+			return team.TeamColor
+		}
+	}
+	t.Fatalf("player %d is not in any team", playerID)
+	return models.NoneTeam
+}
+
+func TestAutoJoinPicksSmallerThenWeakerTeam(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	lobby := newLobby(t, repos, owner.ID, 6)
+
+	strong := newAccount(t, repos, "strong")
+	rate(t, repos, strong, 50)
+	join(t, repos, lobby.ID, strong, models.Blue)
+
+	second := newAccount(t, repos, "second")
+	join(t, repos, lobby.ID, second, models.Auto)
+	if color := teamOf(t, repos, lobby.ID, second.ID); color != models.Red {
+		t.Fatalf("expected player to join the smaller red team, got %s", color)
+	}
+
+	// teams are even, red is weaker
+	third := newAccount(t, repos, "third")
+	join(t, repos, lobby.ID, third, models.Auto)
+	if color := teamOf(t, repos, lobby.ID, third.ID); color != models.Red {
+		t.Fatalf("expected player to join the weaker red team, got %s", color)
+	}
+}
+
+func TestTeamLimit(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	limit := uint(1)
+	lobby := &models.Lobby{OwnerID: owner.ID, Name: "Capped lobby", PlayerLimit: 4, TeamLimit: &limit}
+	if err := lobby.Create(repos.Lobbies); err != nil {
+		t.Fatalf("creating lobby: %v", err)
+	}
+
+	join(t, repos, lobby.ID, newAccount(t, repos, "blueplayer"), models.Blue)
+	player := newAccount(t, repos, "player")
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	err := lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, player, &models.LobbyRequest{TeamColor: models.Blue})
+	if err != errors.TeamIsFull {
+		t.Fatalf("expected full team error, got %v", err)
+	}
+	join(t, repos, lobby.ID, player, models.Auto)
+	if color := teamOf(t, repos, lobby.ID, player.ID); color != models.Red {
+		t.Fatalf("expected player to be put into red team, got %s", color)
+	}
+
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	err = lobby.AddPlayer(repos.Lobbies, repos.Accounts, repos.Statistics, newAccount(t, repos, "late"), &models.LobbyRequest{TeamColor: models.Auto})
+	if err != errors.TeamIsFull {
+		t.Fatalf("expected both teams to be full, got %v", err)
+	}
+}
+
+func TestShuffleBalancesTeamsByRating(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "owner")
+	lobby := newLobby(t, repos, owner.ID, 6)
+
+	// all strong players start in blue
+	points := map[string]int{"first": 40, "second": 30, "third": 20, "fourth": 10, "fifth": 0}
+	players := map[string]*models.Account{}
+	for _, nickname := range []string{"first", "second", "third", "fourth", "fifth"} {
+		players[nickname] = newAccount(t, repos, nickname)
+		rate(t, repos, players[nickname], points[nickname])
+	}
+	for _, nickname := range []string{"first", "second", "third"} {
+		join(t, repos, lobby.ID, players[nickname], models.Blue)
+	}
+	for _, nickname := range []string{"fourth", "fifth"} {
+		join(t, repos, lobby.ID, players[nickname], models.Red)
+	}
+
+	lobby, _ = models.GetOwnersLobby(repos.Lobbies, owner.ID)
+	if err := lobby.Shuffle(repos.Lobbies, repos.Statistics); err != nil {
+		t.Fatalf("shuffling teams: %v", err)
+	}
+
+	// 40 + 10 + 0 against 30 + 20
+	expected := map[string]models.TeamColor{"first": models.Blue, "fourth": models.Blue, "fifth": models.Blue, "second": models.Red, "third": models.Red}
+	for nickname, color := range expected {
+		if actual := teamOf(t, repos, lobby.ID, players[nickname].ID); actual != color {
+			t.Errorf("expected %s in %s team, got %s", nickname, color, actual)
+		}
+		if !lobby.GetTeam(color).ContainsPlayerWithId(players[nickname].ID) {
+			t.Errorf("shuffled lobby doesn't have %s in %s team", nickname, color)
+		}
+	}
+}
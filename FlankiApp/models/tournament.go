@@ -0,0 +1,571 @@
+package models
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/validation"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type TournamentFormat string
+
+const (
+	SingleElimination TournamentFormat = "single_elimination"
+	DoubleElimination TournamentFormat = "double_elimination"
+	RoundRobinFormat  TournamentFormat = "round_robin"
+)
+
+type TournamentState string
+
+const (
+	// teams register until the owner starts the tournament
+	Registration TournamentState = "registration"
+	// bracket is being played, every pairing gets its own lobby
+	TournamentInProgress TournamentState = "in_progress"
+	TournamentFinished   TournamentState = "finished"
+)
+
+type Tournament struct {
+	ID        uint             `json:"id" gorm:"primary_key"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"-"`
+	OwnerID   uint             `json:"owner_id"`
+	Name      string           `json:"name" validate:"min=4,max=40"`
+	Format    TournamentFormat `json:"format" validate:"oneof=single_elimination double_elimination round_robin"`
+	TeamSize  uint             `json:"team_size" validate:"min=1,max=10"` // most players of each team
+	MaxTeams  uint             `json:"max_teams" validate:"min=2,max=64"`
+	State     TournamentState  `json:"state"`
+	WinnerID  *uint            `json:"winner_id,omitempty"` // team which has won the tournament
+
+	Teams   []TournamentTeam  `json:"teams"`
+	Matches []TournamentMatch `json:"matches"`
+}
+
+// TournamentTeam is registered by its captain, teams are seeded by ratings of their players when the tournament starts
+type TournamentTeam struct {
+	ID           uint               `json:"id" gorm:"primary_key"`
+	TournamentID uint               `json:"-"`
+	Name         string             `json:"name"`
+	CaptainID    uint               `json:"captain_id"`
+	Seed         uint               `json:"seed,omitempty"`
+	Members      []TournamentMember `json:"members"`
+}
+
+type TournamentMember struct {
+	ID               uint `json:"-" gorm:"primary_key"`
+	TournamentTeamID uint `json:"-"`
+	PlayerID         uint `json:"player_id"`
+}
+
+// TournamentMatch is a single pairing of the bracket, it gets a lobby as soon as both of its teams are known.
+// Winner and loser go to the matches with given numbers, 0 means they don't go anywhere
+type TournamentMatch struct {
+	ID           uint        `json:"id" gorm:"primary_key"`
+	TournamentID uint        `json:"-"`
+	Number       uint        `json:"number"`
+	Bracket      BracketType `json:"bracket"`
+	Round        uint        `json:"round"`
+	BlueTeamID   *uint       `json:"blue_team_id,omitempty"`
+	RedTeamID    *uint       `json:"red_team_id,omitempty"`
+	WinnerTo     uint        `json:"winner_to,omitempty"`
+	WinnerSlot   TeamColor   `json:"-"`
+	LoserTo      uint        `json:"loser_to,omitempty"`
+	LoserSlot    TeamColor   `json:"-"`
+	LobbyID      *uint       `json:"lobby_id,omitempty"`
+	WinnerID     *uint       `json:"winner_id,omitempty"` // none for walkovers without any team
+	Done         bool        `json:"done"`
+	// why lobby couldn't be created, owner of the tournament can try again
+	SpawnError string `json:"spawn_error,omitempty"`
+}
+
+type TeamRegistration struct {
+	Name    string `json:"name" validate:"min=2,max=30"`
+	Players []uint `json:"players"` // captain has to be one of them
+}
+
+// Standing of a team, teams are ordered by wins and then by losses. In elimination formats teams
+// with too many losses are eliminated, in round-robin nobody is
+type Standing struct {
+	TeamID     uint   `json:"team_id"`
+	Name       string `json:"name"`
+	Seed       uint   `json:"seed"`
+	Played     int    `json:"played"`
+	Wins       int    `json:"wins"`
+	Losses     int    `json:"losses"`
+	Eliminated bool   `json:"eliminated"`
+}
+
+func (tournament *Tournament) Create(tournaments TournamentRepository) error {
+	if err := validation.Check(tournament); err != nil {
+		return err
+	}
+	tournament.State = Registration
+	tournament.WinnerID = nil
+	tournament.Teams = []TournamentTeam{}
+	tournament.Matches = []TournamentMatch{}
+	if err := tournaments.Create(tournament); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+func GetTournament(tournaments TournamentRepository, id uint) (*Tournament, error) {
+	tournament, err := tournaments.GetById(id)
+	if err != nil {
+		if err == errors.RecordNotFound {
+			return nil, errors.TournamentNotFound
+		}
+		return nil, errors.DatabaseError(err)
+	}
+	return tournament, nil
+}
+
+func GetAllTournaments(tournaments TournamentRepository) ([]*Tournament, error) {
+	list, err := tournaments.GetAll(100)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return list, nil
+}
+
+func (tournament *Tournament) team(id uint) *TournamentTeam {
+	for i := range tournament.Teams {
+		if tournament.Teams[i].ID == id {
+			return &tournament.Teams[i]
+		}
+	}
+	return nil
+}
+
+func (tournament *Tournament) match(number uint) *TournamentMatch {
+	for i := range tournament.Matches {
+		if tournament.Matches[i].Number == number {
+			return &tournament.Matches[i]
+		}
+	}
+	return nil
+}
+
+func (tournament *Tournament) teamOfPlayer(playerID uint) *TournamentTeam {
+	for i := range tournament.Teams {
+		for _, member := range tournament.Teams[i].Members {
+			if member.PlayerID == playerID {
+				return &tournament.Teams[i]
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterTeam signs up captain's team, every player can play in one team of the tournament only
+func (tournament *Tournament) RegisterTeam(tournaments TournamentRepository, accounts AccountRepository, captainID uint, request *TeamRegistration) (*TournamentTeam, error) {
+	if err := validation.Check(request); err != nil {
+		return nil, err
+	}
+	if tournament.State != Registration {
+		return nil, errors.New("Registration of the tournament has been closed", 409)
+	}
+	if len(tournament.Teams) >= int(tournament.MaxTeams) {
+		return nil, errors.New("Tournament is already full", 403)
+	}
+	if len(request.Players) == 0 || len(request.Players) > int(tournament.TeamSize) {
+		return nil, errors.New(fmt.Sprintf("Team should have from 1 up to %d players", tournament.TeamSize), 400)
+	}
+
+	team := &TournamentTeam{Name: request.Name, CaptainID: captainID}
+	hasCaptain := false
+	for _, playerID := range request.Players {
+		if playerID == captainID {
+			hasCaptain = true
+		}
+		if tournament.teamOfPlayer(playerID) != nil || team.hasMember(playerID) {
+			return nil, errors.New(fmt.Sprintf("Player %d is already registered", playerID), 409)
+		}
+		if _, err := accounts.GetById(playerID); err != nil {
+			if err == errors.RecordNotFound {
+				return nil, errors.PlayerNotFound
+			}
+			return nil, errors.DatabaseError(err)
+		}
+		team.Members = append(team.Members, TournamentMember{PlayerID: playerID})
+	}
+	if !hasCaptain {
+		return nil, errors.New("Captain has to play in his team", 400)
+	}
+
+	if err := tournaments.AddTeam(tournament, team); err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	return team, nil
+}
+
+func (team *TournamentTeam) hasMember(playerID uint) bool {
+	for _, member := range team.Members {
+		if member.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (team *TournamentTeam) playerIDs() []uint {
+	ids := make([]uint, len(team.Members))
+	for i, member := range team.Members {
+		ids[i] = member.PlayerID
+	}
+	return ids
+}
+
+// Start closes registration, seeds teams by ratings of their players and generates the whole bracket.
+// Lobbies of pairings which can be played right away are created
+func (tournament *Tournament) Start(tournaments TournamentRepository, lobbies LobbyRepository, accounts AccountRepository, statistics StatisticsRepository, ownerID uint) error {
+	if tournament.OwnerID != ownerID {
+		return errors.NotTournamentOwner
+	}
+	if tournament.State != Registration {
+		return errors.New("Tournament has already been started", 409)
+	}
+	if len(tournament.Teams) < 2 {
+		return errors.New("Tournament needs at least two teams to start", 409)
+	}
+
+	seeded, err := tournament.seed(statistics)
+	if err != nil {
+		return err
+	}
+	for _, team := range seeded {
+		if err := tournaments.SaveTeam(team); err != nil {
+			return errors.DatabaseError(err)
+		}
+	}
+
+	switch tournament.Format {
+	case SingleElimination:
+		tournament.Matches = singleElimination(seeded)
+	case DoubleElimination:
+		tournament.Matches = doubleElimination(seeded)
+	default:
+		tournament.Matches = roundRobin(seeded)
+	}
+	tournament.State = TournamentInProgress
+	tournament.resolve()
+	tournament.finishIfDone()
+	if err := tournaments.Save(tournament); err != nil {
+		return errors.DatabaseError(err)
+	}
+	if err := tournaments.CreateMatches(tournament); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return tournament.SpawnLobbies(tournaments, lobbies, accounts)
+}
+
+// seed orders teams from the strongest one by sum of their players' ratings, ties are kept in order of registration
+func (tournament *Tournament) seed(statistics StatisticsRepository) ([]*TournamentTeam, error) {
+	teams := make([]*TournamentTeam, len(tournament.Teams))
+	ratings := map[uint]int{}
+	for i := range tournament.Teams {
+		team := &tournament.Teams[i]
+		teams[i] = team
+		playerRatings, err := GetRatings(statistics, team.playerIDs())
+		if err != nil {
+			return nil, err
+		}
+		for _, rating := range playerRatings {
+			ratings[team.ID] += rating
+		}
+	}
+	sort.SliceStable(teams, func(i, j int) bool { return ratings[teams[i].ID] > ratings[teams[j].ID] })
+	for i, team := range teams {
+		team.Seed = uint(i + 1)
+	}
+	return teams, nil
+}
+
+// feeds tells whether a match which hasn't been decided yet sends its winner or loser to the given slot
+func (tournament *Tournament) feeds(number uint, slot TeamColor) bool {
+	for _, match := range tournament.Matches {
+		if match.Done {
+			continue
+		}
+		if (match.WinnerTo == number && match.WinnerSlot == slot) || (match.LoserTo == number && match.LoserSlot == slot) {
+			return true
+		}
+	}
+	return false
+}
+
+func (match *TournamentMatch) slot(color TeamColor) **uint {
+	if color == Blue {
+		return &match.BlueTeamID
+	}
+	return &match.RedTeamID
+}
+
+// decide records winner of the match and sends both teams further, returns changed matches
+func (tournament *Tournament) decide(match *TournamentMatch, winnerID *uint, loserID *uint) []*TournamentMatch {
+	match.Done = true
+	match.WinnerID = winnerID
+	changed := []*TournamentMatch{match}
+	if next := tournament.match(match.WinnerTo); next != nil && winnerID != nil {
+		id := *winnerID
+		*next.slot(match.WinnerSlot) = &id
+		changed = append(changed, next)
+	}
+	if next := tournament.match(match.LoserTo); next != nil && loserID != nil {
+		id := *loserID
+		*next.slot(match.LoserSlot) = &id
+		changed = append(changed, next)
+	}
+	return changed
+}
+
+// resolve decides matches which can't be played because one or both of their slots will never be filled,
+// a team without an opponent wins by walkover. Returns changed matches
+func (tournament *Tournament) resolve() []*TournamentMatch {
+	var changed []*TournamentMatch
+	for progressed := true; progressed; {
+		progressed = false
+		for i := range tournament.Matches {
+			match := &tournament.Matches[i]
+			if match.Done || (match.BlueTeamID != nil && match.RedTeamID != nil) {
+				continue
+			}
+			if (match.BlueTeamID == nil && tournament.feeds(match.Number, Blue)) || (match.RedTeamID == nil && tournament.feeds(match.Number, Red)) {
+				continue
+			}
+			winnerID := match.BlueTeamID
+			if winnerID == nil {
+				winnerID = match.RedTeamID
+			}
+			changed = append(changed, tournament.decide(match, winnerID, nil)...)
+			progressed = true
+		}
+	}
+	return changed
+}
+
+// finishIfDone finishes the tournament once all its matches are decided, winner of elimination formats
+// is the winner of the final match, in round-robin it's the first team of standings
+func (tournament *Tournament) finishIfDone() {
+	for _, match := range tournament.Matches {
+		if !match.Done {
+			return
+		}
+	}
+	tournament.State = TournamentFinished
+	if tournament.Format == RoundRobinFormat {
+		if standings := tournament.Standings(); len(standings) > 0 {
+			winnerID := standings[0].TeamID
+			tournament.WinnerID = &winnerID
+		}
+		return
+	}
+	for _, match := range tournament.Matches {
+		if match.WinnerTo == 0 {
+			tournament.WinnerID = match.WinnerID
+		}
+	}
+}
+
+// SpawnLobbies creates lobbies of matches whose teams are known, including those whose lobby has been cancelled.
+// Lobby is owned by blue team's captain, or by red team's one when the first already owns an open lobby,
+// players of both teams are put into their teams. Matches whose lobby can't be created keep the reason
+// and are tried again the next time
+func (tournament *Tournament) SpawnLobbies(tournaments TournamentRepository, lobbies LobbyRepository, accounts AccountRepository) error {
+	for i := range tournament.Matches {
+		match := &tournament.Matches[i]
+		if match.Done || match.BlueTeamID == nil || match.RedTeamID == nil {
+			continue
+		}
+		if match.LobbyID != nil {
+			lobby, err := lobbies.GetById(*match.LobbyID)
+			if err != nil && err != errors.RecordNotFound {
+				return errors.DatabaseError(err)
+			}
+			if err == nil && lobby.State != Cancelled {
+				continue
+			}
+		}
+
+		match.LobbyID, match.SpawnError = nil, ""
+		lobby, err := tournament.spawnLobby(lobbies, accounts, match)
+		if err != nil {
+			if apiErr, ok := err.(*errors.ApiError); ok && apiErr.HttpCode == 500 {
+				return err
+			}
+			match.SpawnError = err.Error()
+		} else {
+			match.LobbyID = &lobby.ID
+		}
+		if err := tournaments.SaveMatch(match); err != nil {
+			return errors.DatabaseError(err)
+		}
+	}
+	return nil
+}
+
+// RetrySpawns is used by the owner to create lobbies which couldn't be created before, e.g. because players were still playing
+func (tournament *Tournament) RetrySpawns(tournaments TournamentRepository, lobbies LobbyRepository, accounts AccountRepository, ownerID uint) error {
+	if tournament.OwnerID != ownerID {
+		return errors.NotTournamentOwner
+	}
+	if tournament.State != TournamentInProgress {
+		return errors.New("Tournament is not in progress", 409)
+	}
+	return tournament.SpawnLobbies(tournaments, lobbies, accounts)
+}
+
+func (tournament *Tournament) spawnLobby(lobbies LobbyRepository, accounts AccountRepository, match *TournamentMatch) (*Lobby, error) {
+	blue, red := tournament.team(*match.BlueTeamID), tournament.team(*match.RedTeamID)
+	if blue == nil || red == nil {
+		return nil, errors.New("Team of the match is not registered in the tournament", 500)
+	}
+
+	players := map[TeamColor][]*Account{}
+	for color, team := range map[TeamColor]*TournamentTeam{Blue: blue, Red: red} {
+		for _, id := range team.playerIDs() {
+			account, err := accounts.GetById(id)
+			if err != nil {
+				return nil, errors.DatabaseError(err)
+			}
+			if account.Playing {
+				return nil, errors.New(fmt.Sprintf("%s is still playing in another lobby", account.Nickname), 409)
+			}
+			players[color] = append(players[color], account)
+		}
+	}
+
+	name := fmt.Sprintf("%s #%d", tournament.Name, match.Number)
+	limit := 2 * tournament.TeamSize
+	if limit < 4 {
+		limit = 4
+	}
+	private, confirm := false, false
+	matchID := match.ID
+	var lobby *Lobby
+	for _, captainID := range []uint{blue.CaptainID, red.CaptainID} {
+		lobby = &Lobby{OwnerID: captainID, Name: name, PlayerLimit: limit, Private: &private, ConfirmResults: &confirm, TournamentMatchID: &matchID}
+		err := lobby.Create(lobbies)
+		if err == nil {
+			break
+		}
+		if apiErr, ok := err.(*errors.ApiError); !ok || apiErr.HttpCode != 400 || captainID == red.CaptainID {
+			return nil, err
+		}
+	}
+
+	for _, color := range []TeamColor{Blue, Red} {
+		team := lobby.GetTeam(color)
+		for _, account := range players[color] {
+			if err := team.AddNewEntry(lobbies, &TeamEntry{PlayerID: account.ID, Nickname: account.Nickname}); err != nil {
+				return nil, errors.DatabaseError(err)
+			}
+			if err := accounts.SetPlaying(account.ID, true); err != nil {
+				return nil, errors.DatabaseError(err)
+			}
+		}
+	}
+	return lobby, nil
+}
+
+// advanceTournament moves teams of the finished lobby's match further and creates lobbies of matches
+// which can be played now
+func (lobby *Lobby) advanceTournament(tournaments TournamentRepository, lobbies LobbyRepository, accounts AccountRepository) error {
+	tournament, err := tournaments.GetByMatch(*lobby.TournamentMatchID)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+	var match *TournamentMatch
+	for i := range tournament.Matches {
+		if tournament.Matches[i].ID == *lobby.TournamentMatchID {
+			match = &tournament.Matches[i]
+		}
+	}
+	if match == nil || match.Done {
+		return nil
+	}
+
+	winnerID, loserID := match.BlueTeamID, match.RedTeamID
+	if lobby.Winner == Red {
+		winnerID, loserID = loserID, winnerID
+	}
+	changed := tournament.decide(match, winnerID, loserID)
+	changed = append(changed, tournament.resolve()...)
+	for _, match := range changed {
+		if err := tournaments.SaveMatch(match); err != nil {
+			return errors.DatabaseError(err)
+		}
+	}
+	tournament.finishIfDone()
+	if err := tournaments.Save(tournament); err != nil {
+		return errors.DatabaseError(err)
+	}
+	return tournament.SpawnLobbies(tournaments, lobbies, accounts)
+}
+
+// Standings of all teams based on decided matches, walkovers aren't counted. Winner of finished tournament goes first
+func (tournament *Tournament) Standings() []Standing {
+	byTeam := map[uint]*Standing{}
+	standings := make([]*Standing, 0, len(tournament.Teams))
+	for _, team := range tournament.Teams {
+		standing := &Standing{TeamID: team.ID, Name: team.Name, Seed: team.Seed}
+		byTeam[team.ID] = standing
+		standings = append(standings, standing)
+	}
+	for _, match := range tournament.Matches {
+		if !match.Done || match.WinnerID == nil || match.BlueTeamID == nil || match.RedTeamID == nil {
+			continue
+		}
+		loserID := *match.BlueTeamID
+		if loserID == *match.WinnerID {
+			loserID = *match.RedTeamID
+		}
+		if winner, found := byTeam[*match.WinnerID]; found {
+			winner.Played++
+			winner.Wins++
+		}
+		if loser, found := byTeam[loserID]; found {
+			loser.Played++
+			loser.Losses++
+		}
+	}
+
+	allowedLosses := 0
+	switch tournament.Format {
+	case SingleElimination:
+		allowedLosses = 1
+	case DoubleElimination:
+		allowedLosses = 2
+	}
+	for _, standing := range standings {
+		if allowedLosses > 0 {
+			standing.Eliminated = standing.Losses >= allowedLosses ||
+				(tournament.State == TournamentFinished && (tournament.WinnerID == nil || *tournament.WinnerID != standing.TeamID))
+		}
+	}
+
+	isWinner := func(standing *Standing) bool {
+		return tournament.WinnerID != nil && *tournament.WinnerID == standing.TeamID
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		switch {
+		case isWinner(a) != isWinner(b):
+			return isWinner(a)
+		case a.Eliminated != b.Eliminated:
+			return !a.Eliminated
+		case a.Wins != b.Wins:
+			return a.Wins > b.Wins
+		case a.Losses != b.Losses:
+			return a.Losses < b.Losses
+		}
+		return a.Seed < b.Seed
+	})
+
+	result := make([]Standing, len(standings))
+	for i, standing := range standings {
+		result[i] = *standing
+	}
+	return result
+}
@@ -0,0 +1,281 @@
+package models_test
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// creates tournament of one-player teams, captains are registered in the given order
+func newTournament(t *testing.T, repos *repositories.Repositories, format models.TournamentFormat, captains ...*models.Account) *models.Tournament {
+	t.Helper()
+	owner := newAccount(t, repos, "organizer")
+	tournament := &models.Tournament{OwnerID: owner.ID, Name: "Test cup", Format: format, TeamSize: 1, MaxTeams: 8}
+	if err := tournament.Create(repos.Tournaments); err != nil {
+		t.Fatalf("creating tournament: %v", err)
+	}
+	for _, captain := range captains {
+		request := &models.TeamRegistration{Name: "Team " + captain.Nickname, Players: []uint{captain.ID}}
+		if _, err := tournament.RegisterTeam(repos.Tournaments, repos.Accounts, captain.ID, request); err != nil {
+			t.Fatalf("registering team of %s: %v", captain.Nickname, err)
+		}
+	}
+	if err := tournament.Start(repos.Tournaments, repos.Lobbies, repos.Accounts, repos.Statistics, owner.ID); err != nil {
+		t.Fatalf("starting tournament: %v", err)
+	}
+	return fetchTournament(t, repos, tournament.ID)
+}
+
+func fetchTournament(t *testing.T, repos *repositories.Repositories, id uint) *models.Tournament {
+	t.Helper()
+	tournament, err := models.GetTournament(repos.Tournaments, id)
+	if err != nil {
+		t.Fatalf("fetching tournament: %v", err)
+	}
+	return tournament
+}
+
+func tournamentMatch(t *testing.T, tournament *models.Tournament, number uint) models.TournamentMatch {
+	t.Helper()
+	for _, match := range tournament.Matches {
+		if match.Number == number {
+			return match
+		}
+	}
+	t.Fatalf("tournament has no match %d", number)
+	return models.TournamentMatch{}
+}
+
+func teamName(tournament *models.Tournament, id *uint) string {
+	if id == nil {
+		return "nobody"
+	}
+	for _, team := range tournament.Teams {
+		if team.ID == *id {
+			return team.Name
+		}
+	}
+	return fmt.Sprintf("unknown team %d", *id)
+}
+
+// plays lobby of the tournament's match, the given color wins
+func playTournamentMatch(t *testing.T, repos *repositories.Repositories, tournament *models.Tournament, number uint, winner models.TeamColor) *models.Tournament {
+	t.Helper()
+	match := tournamentMatch(t, tournament, number)
+	if match.LobbyID == nil {
+		t.Fatalf("match %d has no lobby: %s", number, match.SpawnError)
+	}
+	lobby, err := models.GetLobbyByIdFunc(repos.Lobbies, *match.LobbyID)
+	if err != nil {
+		t.Fatalf("fetching lobby of match %d: %v", number, err)
+	}
+	if err := lobby.StartReadyCheck(repos.Lobbies); err != nil {
+		t.Fatalf("starting ready check of match %d: %v", number, err)
+	}
+	players, _ := lobby.GetLobbyPlayersIds()
+	for _, id := range players {
+		lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+		if err := lobby.SetReady(repos.Lobbies, id, true); err != nil {
+			t.Fatalf("setting player %d ready: %v", id, err)
+		}
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if err := lobby.Start(repos.Lobbies); err != nil {
+		t.Fatalf("starting match %d: %v", number, err)
+	}
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, lobby.ID)
+	if err := lobby.SubmitResults(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, lobby.OwnerID, winner, time.Hour); err != nil {
+		t.Fatalf("submitting results of match %d: %v", number, err)
+	}
+	return fetchTournament(t, repos, tournament.ID)
+}
+
+func TestSingleEliminationGivesByeToTopSeed(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	first, second, third := newAccount(t, repos, "first"), newAccount(t, repos, "second"), newAccount(t, repos, "third")
+	rate(t, repos, first, 300)
+	rate(t, repos, second, 200)
+	rate(t, repos, third, 100)
+	// registered from the weakest, seeds are given by ratings
+	tournament := newTournament(t, repos, models.SingleElimination, third, second, first)
+
+	// bracket of four: 1 plays the missing 4th seed, 2 plays 3, winners meet in the final
+	if len(tournament.Matches) != 3 {
+		t.Fatalf("expected 3 matches, got %d", len(tournament.Matches))
+	}
+	bye := tournamentMatch(t, tournament, 1)
+	if !bye.Done || teamName(tournament, bye.WinnerID) != "Team first" || bye.LobbyID != nil {
+		t.Fatalf("expected top seed to advance by walkover, got %+v", bye)
+	}
+	semi := tournamentMatch(t, tournament, 2)
+	if teamName(tournament, semi.BlueTeamID) != "Team second" || teamName(tournament, semi.RedTeamID) != "Team third" || semi.LobbyID == nil {
+		t.Fatalf("expected second and third seed to play in a lobby, got %+v", semi)
+	}
+	if final := tournamentMatch(t, tournament, 3); final.LobbyID != nil || final.BlueTeamID == nil || final.RedTeamID != nil {
+		t.Fatalf("final should wait for the semi-final, got %+v", final)
+	}
+
+	tournament = playTournamentMatch(t, repos, tournament, 2, models.Red)
+	final := tournamentMatch(t, tournament, 3)
+	if teamName(tournament, final.RedTeamID) != "Team third" || final.LobbyID == nil {
+		t.Fatalf("expected winner of the semi-final to get into final's lobby, got %+v", final)
+	}
+
+	tournament = playTournamentMatch(t, repos, tournament, 3, models.Red)
+	if tournament.State != models.TournamentFinished || teamName(tournament, tournament.WinnerID) != "Team third" {
+		t.Fatalf("expected third seed to win the tournament, got %s won by %s", tournament.State, teamName(tournament, tournament.WinnerID))
+	}
+	standings := tournament.Standings()
+	if standings[0].Name != "Team third" || standings[0].Wins != 2 || standings[0].Eliminated {
+		t.Fatalf("expected the winner first in standings, got %+v", standings[0])
+	}
+	for _, standing := range standings[1:] {
+		if !standing.Eliminated {
+			t.Fatalf("expected other teams to be eliminated, got %+v", standing)
+		}
+	}
+}
+
+func TestPendingResultAdvancesBracketOnceSettled(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	first, second, third := newAccount(t, repos, "first"), newAccount(t, repos, "second"), newAccount(t, repos, "third")
+	rate(t, repos, first, 300)
+	rate(t, repos, second, 200)
+	rate(t, repos, third, 100)
+	tournament := newTournament(t, repos, models.SingleElimination, first, second, third)
+
+	semi := tournamentMatch(t, tournament, 2)
+	lobby, _ := models.GetLobbyByIdFunc(repos.Lobbies, *semi.LobbyID)
+	confirm := true
+	lobby.ConfirmResults = &confirm
+	if err := repos.Lobbies.Save(lobby); err != nil {
+		t.Fatalf("turning on confirmation of results: %v", err)
+	}
+	tournament = playTournamentMatch(t, repos, tournament, 2, models.Red)
+	if semi = tournamentMatch(t, tournament, 2); semi.Done {
+		t.Fatalf("expected pending result not to advance the bracket, got %+v", semi)
+	}
+
+	lobby, _ = models.GetLobbyByIdFunc(repos.Lobbies, *semi.LobbyID)
+	if err := lobby.DisputeResult(repos.Lobbies, repos.Statistics, second.ID, &models.DisputeRequest{Reason: "Red spilled the beer"}); err != nil {
+		t.Fatalf("disputing result: %v", err)
+	}
+	moderator := newAccount(t, repos, "moderator")
+	request := &models.SettleRequest{Winner: models.Blue, Reason: "Foul confirmed"}
+	if err := lobby.SettleResult(repos.Lobbies, repos.Accounts, repos.Statistics, repos.Tournaments, moderator.ID, request); err != nil {
+		t.Fatalf("settling dispute of the pending result: %v", err)
+	}
+	tournament = fetchTournament(t, repos, tournament.ID)
+	if semi = tournamentMatch(t, tournament, 2); !semi.Done || teamName(tournament, semi.WinnerID) != "Team second" {
+		t.Fatalf("expected settled winner to advance, got %+v", semi)
+	}
+	if final := tournamentMatch(t, tournament, 3); teamName(tournament, final.RedTeamID) != "Team second" {
+		t.Fatalf("expected settled winner in the final, got %+v", final)
+	}
+}
+
+func TestDoubleEliminationGivesLosersSecondChance(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	players := []*models.Account{newAccount(t, repos, "first"), newAccount(t, repos, "second"), newAccount(t, repos, "third"), newAccount(t, repos, "fourth")}
+	for i, player := range players {
+		rate(t, repos, player, 400-100*i)
+	}
+	tournament := newTournament(t, repos, models.DoubleElimination, players...)
+
+	// winners bracket: 1 (1v4), 2 (2v3), 3 (final), losers bracket: 4 (losers of 1 and 2), 5 (4 vs loser of 3), grand final 6
+	if len(tournament.Matches) != 6 {
+		t.Fatalf("expected 6 matches, got %d", len(tournament.Matches))
+	}
+	tournament = playTournamentMatch(t, repos, tournament, 1, models.Blue) // first beats fourth
+	tournament = playTournamentMatch(t, repos, tournament, 2, models.Red)  // third beats second
+	losers := tournamentMatch(t, tournament, 4)
+	if losers.Bracket != models.LosersBracket || teamName(tournament, losers.BlueTeamID) != "Team fourth" || teamName(tournament, losers.RedTeamID) != "Team second" {
+		t.Fatalf("expected losers of the first round to meet in the losers bracket, got %+v", losers)
+	}
+
+	tournament = playTournamentMatch(t, repos, tournament, 3, models.Blue) // first beats third
+	tournament = playTournamentMatch(t, repos, tournament, 4, models.Red)  // second knocks fourth out
+	tournament = playTournamentMatch(t, repos, tournament, 5, models.Red)  // third beats second
+	final := tournamentMatch(t, tournament, 6)
+	if final.Bracket != models.GrandFinal || teamName(tournament, final.BlueTeamID) != "Team first" || teamName(tournament, final.RedTeamID) != "Team third" {
+		t.Fatalf("expected winners of both brackets in the grand final, got %+v", final)
+	}
+
+	tournament = playTournamentMatch(t, repos, tournament, 6, models.Red)
+	if tournament.State != models.TournamentFinished || teamName(tournament, tournament.WinnerID) != "Team third" {
+		t.Fatalf("expected team from the losers bracket to win, got %s", teamName(tournament, tournament.WinnerID))
+	}
+	standings := tournament.Standings()
+	if standings[0].Name != "Team third" || standings[0].Losses != 1 || standings[1].Name != "Team first" {
+		t.Fatalf("unexpected standings %+v", standings)
+	}
+}
+
+func TestRoundRobinPlaysEveryPairing(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	players := []*models.Account{newAccount(t, repos, "first"), newAccount(t, repos, "second"), newAccount(t, repos, "third")}
+	tournament := newTournament(t, repos, models.RoundRobinFormat, players...)
+
+	if len(tournament.Matches) != 3 {
+		t.Fatalf("expected every pair of teams to meet once, got %d matches", len(tournament.Matches))
+	}
+	// first team wins every match it plays, blue wins the other one
+	for number := uint(1); number <= 3; number++ {
+		tournament = fetchTournament(t, repos, tournament.ID)
+		match := tournamentMatch(t, tournament, number)
+		if match.LobbyID == nil {
+			// teams still playing another match get their lobby once they have finished
+			if err := tournament.RetrySpawns(repos.Tournaments, repos.Lobbies, repos.Accounts, tournament.OwnerID); err != nil {
+				t.Fatalf("spawning lobbies: %v", err)
+			}
+			tournament = fetchTournament(t, repos, tournament.ID)
+		}
+		winner := models.Blue
+		if teamName(tournament, match.RedTeamID) == "Team first" {
+			winner = models.Red
+		}
+		tournament = playTournamentMatch(t, repos, tournament, number, winner)
+	}
+
+	if tournament.State != models.TournamentFinished || teamName(tournament, tournament.WinnerID) != "Team first" {
+		t.Fatalf("expected team with most wins to win, got %s", teamName(tournament, tournament.WinnerID))
+	}
+	standings := tournament.Standings()
+	if standings[0].Wins != 2 || standings[2].Wins != 0 {
+		t.Fatalf("unexpected standings %+v", standings)
+	}
+	for _, standing := range standings {
+		if standing.Played != 2 || standing.Eliminated {
+			t.Fatalf("expected every team to play twice without elimination, got %+v", standing)
+		}
+	}
+}
+
+func TestTournamentRegistration(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	owner := newAccount(t, repos, "organizer")
+	captain := newAccount(t, repos, "captain")
+	mate := newAccount(t, repos, "mate")
+	tournament := &models.Tournament{OwnerID: owner.ID, Name: "Test cup", Format: models.SingleElimination, TeamSize: 2, MaxTeams: 2}
+	if err := tournament.Create(repos.Tournaments); err != nil {
+		t.Fatalf("creating tournament: %v", err)
+	}
+
+	if _, err := tournament.RegisterTeam(repos.Tournaments, repos.Accounts, captain.ID, &models.TeamRegistration{Name: "Without captain", Players: []uint{mate.ID}}); httpCode(err) != 400 {
+		t.Fatalf("expected team without its captain to be rejected, got %v", err)
+	}
+	if _, err := tournament.RegisterTeam(repos.Tournaments, repos.Accounts, captain.ID, &models.TeamRegistration{Name: "Kapsle", Players: []uint{captain.ID, mate.ID}}); err != nil {
+		t.Fatalf("registering team: %v", err)
+	}
+	if _, err := tournament.RegisterTeam(repos.Tournaments, repos.Accounts, mate.ID, &models.TeamRegistration{Name: "Again", Players: []uint{mate.ID}}); httpCode(err) != 409 {
+		t.Fatalf("expected player to be registered in one team only, got %v", err)
+	}
+	if err := tournament.Start(repos.Tournaments, repos.Lobbies, repos.Accounts, repos.Statistics, owner.ID); httpCode(err) != 409 {
+		t.Fatalf("expected tournament with a single team not to start, got %v", err)
+	}
+	if err := tournament.Start(repos.Tournaments, repos.Lobbies, repos.Accounts, repos.Statistics, captain.ID); httpCode(err) != 403 {
+		t.Fatalf("expected only the owner to start the tournament, got %v", err)
+	}
+}
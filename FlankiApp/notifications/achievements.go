@@ -0,0 +1,26 @@
+package notifications
+
+import (
+	"FlankiRest/logger"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+)
+
+// AwardAchievements evaluates achievement rules for the players after their match has been counted and notifies
+// them of new badges, failures are only logged. Returns number of awarded achievements
+func AwardAchievements(repos *repositories.Repositories, playerIDs []uint) int {
+	logEntry := logger.GetGlobalLogger().WithField("prefix", "[ACHIEVEMENTS]")
+	awarded := 0
+	for _, playerID := range playerIDs {
+		achievements, err := models.EvaluateAchievements(repos.Achievements, repos.Statistics, repos.Tournaments, playerID)
+		if err != nil {
+			logEntry.Errorf("Achievements of player %d can't be evaluated: %s", playerID, err.Error())
+			continue
+		}
+		for i := range achievements {
+			Notify(repos, playerID, models.AchievementNotice(&achievements[i]))
+		}
+		awarded += len(achievements)
+	}
+	return awarded
+}
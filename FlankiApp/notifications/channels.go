@@ -0,0 +1,29 @@
+package notifications
+
+import (
+	"FlankiRest/models"
+	"FlankiRest/services"
+)
+
+// InApp keeps notifications in the inbox of the account, it should go first so that other channels
+// send notifications together with their ids
+type InApp struct{}
+
+func (InApp) Name() models.NotificationChannel {
+	return models.InAppChannel
+}
+
+func (InApp) Deliver(notifications models.NotificationRepository, account *models.Account, notification *models.Notification) error {
+	return notifications.Create(notification)
+}
+
+// Email sends notifications with the app's mailer
+type Email struct{}
+
+func (Email) Name() models.NotificationChannel {
+	return models.EmailChannel
+}
+
+func (Email) Deliver(notifications models.NotificationRepository, account *models.Account, notification *models.Notification) error {
+	return services.SendNotificationEmail(account, notification)
+}
@@ -0,0 +1,107 @@
+package notifications
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/logger"
+	"FlankiRest/models"
+	"FlankiRest/repositories"
+	"sync"
+)
+
+// Channel delivers notifications to the account, in-app channel keeps them in the inbox
+// while the others send them away e.g. by email or to subscribed browsers
+type Channel interface {
+	Name() models.NotificationChannel
+	Deliver(notifications models.NotificationRepository, account *models.Account, notification *models.Notification) error
+}
+
+// Dispatcher delivers notifications through channels the account allows for their type
+type Dispatcher struct {
+	channels []Channel
+}
+
+func NewDispatcher(channels ...Channel) *Dispatcher {
+	return &Dispatcher{channels: channels}
+}
+
+var dispatcher *Dispatcher
+var dispatcherMutex sync.RWMutex
+
+// GetDispatcher returns dispatcher set with SetDispatcher, by default it delivers notifications in-app,
+// by email and with Web Push when VAPID keys are configured. The default one is built once, when it is first needed
+func GetDispatcher() *Dispatcher {
+	dispatcherMutex.RLock()
+	current := dispatcher
+	dispatcherMutex.RUnlock()
+	if current != nil {
+		return current
+	}
+
+	dispatcherMutex.Lock()
+	defer dispatcherMutex.Unlock()
+	if dispatcher == nil {
+		channels := []Channel{InApp{}, Email{}}
+		if cfg := config.GetPushConfig(); cfg.Enabled() {
+			channels = append(channels, NewPush(cfg))
+		}
+		dispatcher = NewDispatcher(channels...)
+	}
+	return dispatcher
+}
+
+// replaces the way notifications are delivered, e.g. with fake channels in tests, nil brings back the default one
+func SetDispatcher(d *Dispatcher) {
+	dispatcherMutex.Lock()
+	defer dispatcherMutex.Unlock()
+	dispatcher = d
+}
+
+// Notify delivers the notification to the account, failures of channels are only logged so that they don't break
+// actions which caused the notification. Returns through how many channels it has been delivered
+func (d *Dispatcher) Notify(repos *repositories.Repositories, accountID uint, notification models.Notification) int {
+	logEntry := logger.GetGlobalLogger().WithField("prefix", "[NOTIFICATIONS]")
+	account, err := repos.Accounts.GetById(accountID)
+	if err != nil {
+		logEntry.Errorf("Account %d can't be notified: %s", accountID, err.Error())
+		return 0
+	}
+	preference, err := models.GetNotificationPreference(repos.Notifications, accountID, notification.Type)
+	if err != nil {
+		logEntry.Errorf("Preferences of account %d can't be read: %s", accountID, err.Error())
+		return 0
+	}
+
+	notification.AccountID = accountID
+	delivered := 0
+	for _, channel := range d.channels {
+		if !preference.Allows(channel.Name()) {
+			continue
+		}
+		if err := channel.Deliver(repos.Notifications, account, &notification); err != nil {
+			logEntry.Errorf("Delivering %s notification to account %d through %s failed: %s",
+				notification.Type, accountID, channel.Name(), err.Error())
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// NotifyAll delivers separate copy of the notification to every account
+func (d *Dispatcher) NotifyAll(repos *repositories.Repositories, accountIDs []uint, notification models.Notification) int {
+	delivered := 0
+	for _, accountID := range accountIDs {
+		delivered += d.Notify(repos, accountID, notification)
+	}
+	return delivered
+}
+
+// Notify delivers the notification with the current dispatcher
+func Notify(repos *repositories.Repositories, accountID uint, notification models.Notification) int {
+	return GetDispatcher().Notify(repos, accountID, notification)
+}
+
+// NotifyAll delivers the notification to every account with the current dispatcher
+func NotifyAll(repos *repositories.Repositories, accountIDs []uint, notification models.Notification) int {
+	return GetDispatcher().NotifyAll(repos, accountIDs, notification)
+}
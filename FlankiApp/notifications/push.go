@@ -0,0 +1,268 @@
+package notifications
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/models"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// how long push services keep notifications of browsers which are offline
+	pushTTL = 24 * time.Hour
+	// size of the single record notifications are encrypted into
+	pushRecordSize = 4096
+	// how long VAPID tokens are valid, push services don't accept longer than a day
+	vapidExpiration = 12 * time.Hour
+)
+
+// Push sends notifications to browsers subscribed with the Push API (RFC 8030), their contents are encrypted
+// for the browser (RFC 8291) and the app identifies itself with VAPID keys (RFC 8292)
+type Push struct {
+	cfg    *config.PushConfig
+	Client *http.Client
+}
+
+// ErrPrivatePushAddress is returned when endpoint of a subscription resolves to an address of a private network
+var ErrPrivatePushAddress = errors.New("push endpoint resolves to a private address")
+
+func NewPush(cfg *config.PushConfig) *Push {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: publicAddressesOnly}
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second}
+	return &Push{cfg: cfg, Client: &http.Client{Timeout: 5 * time.Second, Transport: transport}}
+}
+
+// publicAddressesOnly refuses connections to loopback, private and link-local addresses, endpoints are given
+// by browsers and their names could resolve to hosts of the app's own network
+func publicAddressesOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return ErrPrivatePushAddress
+	}
+	return nil
+}
+
+func (*Push) Name() models.NotificationChannel {
+	return models.PushChannel
+}
+
+// Deliver sends the notification to every browser of the account, subscriptions push services don't know anymore are dropped
+func (push *Push) Deliver(notifications models.NotificationRepository, account *models.Account, notification *models.Notification) error {
+	subscriptions, err := notifications.GetPushSubscriptions(account.ID)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, subscription := range subscriptions {
+		status, err := push.send(&subscription, payload)
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		if status == http.StatusNotFound || status == http.StatusGone {
+			if err := notifications.DeletePushSubscription(account.ID, subscription.Endpoint); err != nil {
+				failed = append(failed, err.Error())
+			}
+		} else if status >= 300 {
+			failed = append(failed, fmt.Sprintf("push service responded with %d", status))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d subscriptions failed: %s", len(failed), len(subscriptions), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// send posts encrypted payload to subscription's endpoint and returns status of push service's response
+func (push *Push) send(subscription *models.PushSubscription, payload []byte) (int, error) {
+	body, err := encrypt(subscription, payload)
+	if err != nil {
+		return 0, err
+	}
+	authorization, err := push.authorization(subscription.Endpoint, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Authorization", authorization)
+	request.Header.Set("Content-Encoding", "aes128gcm")
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("TTL", fmt.Sprint(int(pushTTL.Seconds())))
+
+	response, err := push.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	return response.StatusCode, nil
+}
+
+// authorization header with VAPID token signed for origin of the endpoint
+func (push *Push) authorization(endpoint string, now time.Time) (string, error) {
+	origin, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": origin.Scheme + "://" + origin.Host,
+		"exp": now.Add(vapidExpiration).Unix(),
+		"sub": push.cfg.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	key, err := vapidKey(push.cfg.VAPIDPrivateKey)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, push.cfg.VAPIDPublicKey), nil
+}
+
+// signing key from the raw private key of the config
+func vapidKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	raw, err := decodeKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	point := key.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(point[1:33]), Y: new(big.Int).SetBytes(point[33:])},
+		D:         new(big.Int).SetBytes(raw),
+	}, nil
+}
+
+// encrypt returns payload encrypted for the subscribed browser as a single aes128gcm record
+func encrypt(subscription *models.PushSubscription, payload []byte) ([]byte, error) {
+	rawBrowserKey, err := decodeKey(subscription.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %s", err)
+	}
+	authSecret, err := decodeKey(subscription.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %s", err)
+	}
+	browserKey, err := ecdh.P256().NewPublicKey(rawBrowserKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %s", err)
+	}
+	// push services accept bodies of at most 4096 bytes, 86 of them take the header
+	if 86+len(payload)+1+aes.BlockSize > pushRecordSize {
+		return nil, fmt.Errorf("payload of %d bytes doesn't fit into a single record", len(payload))
+	}
+
+	// every message is encrypted with a new key pair of the app
+	localKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := localKey.ECDH(browserKey)
+	if err != nil {
+		return nil, err
+	}
+	localPublic := localKey.PublicKey().Bytes()
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), rawBrowserKey...), localPublic...)
+	ikm, err := derive(sharedSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	contentKey, err := derive(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := derive(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 delimits the last record
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(localPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, pushRecordSize)
+	header = append(header, byte(len(localPublic)))
+	header = append(header, localPublic...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+func derive(secret []byte, salt []byte, info []byte, size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// keys are sent by browsers with base64url encoding, some of them pad it
+func decodeKey(key string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+}
+
+// GenerateVAPIDKeys returns new key pair for push.vapid_public_key and push.vapid_private_key
+func GenerateVAPIDKeys() (publicKey string, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
@@ -0,0 +1,262 @@
+package notifications_test
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/models"
+	"FlankiRest/notifications"
+	"FlankiRest/repositories"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pushService stands in for push service of a browser, it checks VAPID token of the app and decrypts
+// notifications with keys of the subscribed browser
+type pushService struct {
+	t         *testing.T
+	server    *httptest.Server
+	vapidKey  string
+	browser   *ecdh.PrivateKey
+	auth      []byte
+	status    int
+	delivered []models.Notification
+}
+
+func newPushService(t *testing.T, vapidKey string, status int) *pushService {
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &pushService{t: t, vapidKey: vapidKey, browser: browser, auth: make([]byte, 16), status: status}
+	rand.Read(service.auth)
+	service.server = httptest.NewTLSServer(http.HandlerFunc(service.receive))
+	t.Cleanup(service.server.Close)
+	return service
+}
+
+func (service *pushService) subscription() *models.PushSubscriptionRequest {
+	return &models.PushSubscriptionRequest{Endpoint: service.server.URL + "/push/browser", Keys: models.PushKeys{
+		P256dh: base64.RawURLEncoding.EncodeToString(service.browser.PublicKey().Bytes()),
+		Auth:   base64.RawURLEncoding.EncodeToString(service.auth),
+	}}
+}
+
+// subscribe saves the subscription without validating it, the service listens on a loopback address
+func (service *pushService) subscribe(repos *repositories.Repositories, accountID uint) {
+	request := service.subscription()
+	subscription := &models.PushSubscription{AccountID: accountID, Endpoint: request.Endpoint, P256dh: request.Keys.P256dh, Auth: request.Keys.Auth}
+	if err := repos.Notifications.SavePushSubscription(subscription); err != nil {
+		service.t.Fatal(err)
+	}
+}
+
+func (service *pushService) receive(w http.ResponseWriter, r *http.Request) {
+	t := service.t
+	if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+		t.Errorf("unexpected headers %v", r.Header)
+	}
+	service.checkToken(r.Header.Get("Authorization"))
+	body, _ := ioutil.ReadAll(r.Body)
+	notification := models.Notification{}
+	if err := json.Unmarshal(service.decrypt(body), &notification); err != nil {
+		t.Errorf("decoding notification: %v", err)
+	}
+	service.delivered = append(service.delivered, notification)
+	w.WriteHeader(service.status)
+}
+
+func (service *pushService) checkToken(authorization string) {
+	t := service.t
+	if !strings.HasPrefix(authorization, "vapid t=") || !strings.HasSuffix(authorization, ", k="+service.vapidKey) {
+		t.Fatalf("unexpected authorization %q", authorization)
+	}
+	token := strings.TrimSuffix(strings.TrimPrefix(authorization, "vapid t="), ", k="+service.vapidKey)
+	parts := strings.Split(token, ".")
+	claims := map[string]interface{}{}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(payload, &claims); err != nil || claims["aud"] != service.server.URL || claims["sub"] != "mailto:admin@flanki.pl" {
+		t.Fatalf("unexpected claims %v, %v", claims, err)
+	}
+	point, _ := base64.RawURLEncoding.DecodeString(service.vapidKey)
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(point[1:33]), Y: new(big.Int).SetBytes(point[33:])}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(signature) != 64 || !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Fatalf("VAPID token isn't signed with the app's key")
+	}
+}
+
+func (service *pushService) decrypt(body []byte) []byte {
+	t := service.t
+	salt, recordSize, idLength := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if recordSize != 4096 || idLength != 65 {
+		t.Fatalf("unexpected header: record size %d, key id length %d", recordSize, idLength)
+	}
+	appKey, err := ecdh.P256().NewPublicKey(body[21 : 21+idLength])
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := service.browser.ECDH(appKey)
+	info := append(append([]byte("WebPush: info\x00"), service.browser.PublicKey().Bytes()...), appKey.Bytes()...)
+	ikm := derive(t, secret, service.auth, info, 32)
+	block, _ := aes.NewCipher(derive(t, ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, derive(t, ikm, salt, []byte("Content-Encoding: nonce\x00"), 12), body[21+idLength:], nil)
+	if err != nil || plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatalf("decrypting notification: %v", err)
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+func derive(t *testing.T, secret []byte, salt []byte, info []byte, size int) []byte {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func pushChannel(t *testing.T, service *pushService, privateKey string) *notifications.Push {
+	push := notifications.NewPush(&config.PushConfig{VAPIDPublicKey: service.vapidKey, VAPIDPrivateKey: privateKey, Subject: "mailto:admin@flanki.pl"})
+	push.Client = service.server.Client()
+	return push
+}
+
+func newAccount(t *testing.T, repos *repositories.Repositories, nickname string) *models.Account {
+	t.Helper()
+	account := &models.Account{Nickname: nickname, Email: nickname + "@flanki.pl", Password: "secret123", Sex: "male"}
+	if err := account.Create(repos.Accounts); err != nil {
+		t.Fatalf("creating account %s: %v", nickname, err)
+	}
+	return account
+}
+
+func TestPushIsEncryptedForSubscribedBrowser(t *testing.T) {
+	publicKey, privateKey, err := notifications.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := newPushService(t, publicKey, http.StatusCreated)
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	service.subscribe(repos, player.ID)
+
+	dispatcher := notifications.NewDispatcher(notifications.InApp{}, pushChannel(t, service, privateKey))
+	lobby := &models.Lobby{LobbyModel: models.LobbyModel{ID: 7}, Name: "Evening match"}
+	if delivered := dispatcher.Notify(repos, player.ID, models.KickedNotice(lobby)); delivered != 2 {
+		t.Fatalf("expected notification to be delivered in-app and pushed, got %d", delivered)
+	}
+	if len(service.delivered) != 1 {
+		t.Fatalf("expected single push, got %d", len(service.delivered))
+	}
+	pushed := service.delivered[0]
+	if pushed.Type != models.KickedNotification || pushed.ID == 0 || pushed.LobbyID == nil || *pushed.LobbyID != lobby.ID {
+		t.Errorf("unexpected pushed notification %+v", pushed)
+	}
+}
+
+func TestGoneSubscriptionsAreDropped(t *testing.T) {
+	publicKey, privateKey, _ := notifications.GenerateVAPIDKeys()
+	service := newPushService(t, publicKey, http.StatusGone)
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	service.subscribe(repos, player.ID)
+
+	push := pushChannel(t, service, privateKey)
+	account, _ := repos.Accounts.GetById(player.ID)
+	if err := push.Deliver(repos.Notifications, account, &models.Notification{Type: models.ResultsNotification}); err != nil {
+		t.Fatalf("expected expired subscription not to fail delivery, got %v", err)
+	}
+	if subscriptions, _ := repos.Notifications.GetPushSubscriptions(player.ID); len(subscriptions) != 0 {
+		t.Errorf("expected subscription to be dropped, got %+v", subscriptions)
+	}
+}
+
+func TestPushIsNotSentToPrivateAddresses(t *testing.T) {
+	publicKey, privateKey, _ := notifications.GenerateVAPIDKeys()
+	service := newPushService(t, publicKey, http.StatusCreated)
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	service.subscribe(repos, player.ID)
+
+	// the app's own client, not the one trusting the test service
+	push := notifications.NewPush(&config.PushConfig{VAPIDPublicKey: publicKey, VAPIDPrivateKey: privateKey, Subject: "mailto:admin@flanki.pl"})
+	account, _ := repos.Accounts.GetById(player.ID)
+	err := push.Deliver(repos.Notifications, account, &models.Notification{Type: models.ResultsNotification})
+	if err == nil || !strings.Contains(err.Error(), notifications.ErrPrivatePushAddress.Error()) {
+		t.Errorf("expected push to loopback address to be refused, got %v", err)
+	}
+	if len(service.delivered) != 0 {
+		t.Errorf("expected nothing to be pushed, got %d", len(service.delivered))
+	}
+}
+
+// channel remembering what it has delivered
+type fakeChannel struct {
+	name      models.NotificationChannel
+	delivered []models.NotificationType
+}
+
+func (channel *fakeChannel) Name() models.NotificationChannel {
+	return channel.name
+}
+
+func (channel *fakeChannel) Deliver(notifications models.NotificationRepository, account *models.Account, notification *models.Notification) error {
+	channel.delivered = append(channel.delivered, notification.Type)
+	return nil
+}
+
+func TestDispatcherFollowsPreferences(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	player := newAccount(t, repos, "player")
+	email := &fakeChannel{name: models.EmailChannel}
+	push := &fakeChannel{name: models.PushChannel}
+	dispatcher := notifications.NewDispatcher(notifications.InApp{}, email, push)
+
+	off := false
+	if _, err := models.UpdateNotificationPreferences(repos.Notifications, player.ID,
+		[]models.NotificationPreference{{Type: models.KickedNotification, Push: &off}}); err != nil {
+		t.Fatal(err)
+	}
+	lobby := &models.Lobby{LobbyModel: models.LobbyModel{ID: 1}, Name: "Lobby"}
+	dispatcher.Notify(repos, player.ID, models.KickedNotice(lobby))
+	dispatcher.Notify(repos, player.ID, models.ResultsNotice(lobby))
+
+	if len(push.delivered) != 1 || push.delivered[0] != models.ResultsNotification {
+		t.Errorf("expected only results to be pushed, got %v", push.delivered)
+	}
+	if len(email.delivered) != 0 {
+		t.Errorf("expected no emails by default, got %v", email.delivered)
+	}
+	if inbox, _ := models.GetInbox(repos.Notifications, player.ID, false); inbox.Unread != 2 {
+		t.Errorf("expected both notifications in the inbox, got %+v", inbox)
+	}
+}
+
+func TestDefaultDispatcherIsBuiltOnce(t *testing.T) {
+	notifications.SetDispatcher(nil)
+	t.Cleanup(func() { notifications.SetDispatcher(nil) })
+	if first, second := notifications.GetDispatcher(), notifications.GetDispatcher(); first != second {
+		t.Errorf("expected default dispatcher to be reused")
+	}
+	fake := notifications.NewDispatcher(&fakeChannel{name: models.InAppChannel})
+	notifications.SetDispatcher(fake)
+	if notifications.GetDispatcher() != fake {
+		t.Errorf("expected dispatcher set by SetDispatcher to be used")
+	}
+}
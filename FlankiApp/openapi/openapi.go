@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Only the part of OpenAPI 3 specification which is needed to describe Flanki's services
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	// types of component schemas by their names
+	componentTypes map[string]reflect.Type
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string                    `json:"url"`
+	Description string                    `json:"description,omitempty"`
+	Variables   map[string]ServerVariable `json:"variables,omitempty"`
+}
+
+type ServerVariable struct {
+	Default     string `json:"default"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower case http methods to operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Servers     []Server              `json:"servers,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+const BearerAuth = "bearerAuth"
+
+// Endpoint describes a single route, request and response types are given as values of model structs
+// and their schemas are generated from struct's fields and json tags
+type Endpoint struct {
+	Tag         string
+	Summary     string
+	Description string
+
+	// doesn't require access token
+	Public bool
+
+	// nil when request has no body
+	Request            interface{}
+	RequestContentType string // application/json by default
+
+	// nil when successful response has no body
+	Response            interface{}
+	ResponseContentType string // application/json by default
+	ResponseCode        string // 200 by default
+
+	// overrides document's servers, e.g. for endpoints of other services
+	Servers []Server
+}
+
+func NewDocument(title string, version string, description string) *Document {
+	doc := &Document{
+		OpenAPI: "3.0.2",
+		Info:    Info{title, version, description},
+		Paths:   map[string]PathItem{},
+	}
+	doc.Components.Schemas = map[string]*Schema{}
+	doc.Components.SecuritySchemes = map[string]SecurityScheme{BearerAuth: {Type: "http", Scheme: "bearer"}}
+	return doc
+}
+
+// matches variables of gorilla's path templates e.g. {id:[0-9]+}
+var pathVariable = regexp.MustCompile(`{([^}:]+)(:[^}]+)?}`)
+
+// AddEndpoint documents route registered under given gorilla's path template
+func (doc *Document) AddEndpoint(pathTemplate string, method string, endpoint Endpoint, errorSchema interface{}) {
+	op := &Operation{
+		Summary:     endpoint.Summary,
+		Description: endpoint.Description,
+		OperationID: operationID(method, pathTemplate),
+		Responses:   map[string]Response{},
+		Servers:     endpoint.Servers,
+	}
+	if endpoint.Tag != "" {
+		op.Tags = []string{endpoint.Tag}
+	}
+	if !endpoint.Public {
+		op.Security = []map[string][]string{{BearerAuth: {}}}
+	}
+
+	for _, match := range pathVariable.FindAllStringSubmatch(pathTemplate, -1) {
+		schema := &Schema{Type: "string"}
+		if match[2] == ":[0-9]+" {
+			schema = &Schema{Type: "integer", Minimum: new(float64)}
+		}
+		op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+
+	if endpoint.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{orDefault(endpoint.RequestContentType, "application/json"): {doc.SchemaOf(endpoint.Request)}},
+		}
+	}
+
+	response := Response{Description: "Successful response"}
+	if endpoint.Response != nil {
+		response.Content = map[string]MediaType{orDefault(endpoint.ResponseContentType, "application/json"): {doc.SchemaOf(endpoint.Response)}}
+	}
+	op.Responses[orDefault(endpoint.ResponseCode, "200")] = response
+	if errorSchema != nil {
+		op.Responses["default"] = Response{
+			Description: "Error with its message",
+			Content:     map[string]MediaType{"application/json": {doc.SchemaOf(errorSchema)}},
+		}
+	}
+
+	path := pathVariable.ReplaceAllString(pathTemplate, "{$1}")
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = PathItem{}
+	}
+	doc.Paths[path][strings.ToLower(method)] = op
+}
+
+// HasOperation checks whether route registered under gorilla's path template is documented
+func (doc *Document) HasOperation(pathTemplate string, method string) bool {
+	item, ok := doc.Paths[pathVariable.ReplaceAllString(pathTemplate, "{$1}")]
+	if !ok {
+		return false
+	}
+	_, ok = item[strings.ToLower(method)]
+	return ok
+}
+
+// e.g. POST /lobbies/{id:[0-9]+}/join -> postLobbiesIdJoin
+func operationID(method string, pathTemplate string) string {
+	id := strings.ToLower(method)
+	path := pathVariable.ReplaceAllString(pathTemplate, "$1")
+	for _, part := range regexp.MustCompile(`[^a-zA-Z0-9]+`).Split(path, -1) {
+		if part != "" {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/errors"
+	"FlankiRest/logger"
+	"FlankiRest/metrics"
+	u "FlankiRest/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bodies bigger than this aren't read when looking for the account, the handler still gets the whole body
+const maxPeekedBody = 64 << 10
+
+// AccountKey returns the account request is about, empty when there is none
+type AccountKey func(r *http.Request) string
+
+// Limiter throttles routes with token buckets of client's address and of the account,
+// limits of routes are read on every request so changing the config takes effect immediately
+type Limiter struct {
+	store Store
+	cfg   *config.RateLimitConfig
+	now   func() time.Time
+}
+
+func NewLimiter(store Store, cfg *config.RateLimitConfig) *Limiter {
+	return &Limiter{store: store, cfg: cfg, now: time.Now}
+}
+
+// Limit wraps handler of named route, throttled requests are responded with 429 and Retry-After header.
+// Requests are let through when the store fails, broken store shouldn't lock everyone out
+func (limiter *Limiter) Limit(route string, account AccountKey, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limits := limiter.cfg.Routes[route]
+		now := limiter.now()
+		checks := []struct {
+			kind  string
+			limit config.Limit
+			key   func() string
+		}{
+			{"ip", limits.IP, func() string { return limiter.clientIP(r) }},
+			{"account", limits.Account, func() string { return account(r) }},
+		}
+		for _, check := range checks {
+			if !check.limit.Enabled() {
+				continue
+			}
+			key := check.key()
+			if key == "" {
+				continue
+			}
+			allowed, retryAfter, err := limiter.store.Take(r.Context(), route+":"+check.kind+":"+key, check.limit, now)
+			if err != nil {
+				logger.GetGlobalLogger().WithField("prefix", "[RATE LIMIT]").Warn("Couldn't check rate limit of ", route, ": ", err)
+				continue
+			}
+			if !allowed {
+				metrics.RateLimited.WithLabelValues(route, check.kind).Inc()
+				w.Header().Set("Retry-After", RetryAfterSeconds(retryAfter))
+				u.ApiErrorResponse(w, errors.TooManyRequests)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// RetryAfterSeconds formats duration as value of Retry-After header, rounded up to whole seconds
+func RetryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
+
+func (limiter *Limiter) clientIP(r *http.Request) string {
+	if limiter.cfg.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// UserAccount is the logged in user, for routes behind authentication
+func UserAccount(r *http.Request) string {
+	id, err := u.GetUserIdFromContext(r.Context())
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+// EmailAccount reads email from json body of the request, the body is restored for the handler
+func EmailAccount(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPeekedBody+1))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxPeekedBody {
+		return ""
+	}
+	data := struct {
+		Email string `json:"email"`
+	}{}
+	if json.Unmarshal(body, &data) != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(data.Email))
+}
+
+// body of the request with peeked part put back in front of the rest of it
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
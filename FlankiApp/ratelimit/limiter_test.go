@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"FlankiRest/config"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBucketRefillsAtLimitsRate(t *testing.T) {
+	store := NewMemoryStore()
+	limit := config.Limit{Requests: 2, Per: time.Minute}
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := store.Take(context.Background(), "key", limit, start); !allowed {
+			t.Fatalf("request %d within burst was throttled", i)
+		}
+	}
+	allowed, retryAfter, _ := store.Take(context.Background(), "key", limit, start)
+	if allowed || retryAfter != 30*time.Second {
+		t.Errorf("expected throttling for 30s, got %v %s", allowed, retryAfter)
+	}
+	if allowed, _, _ := store.Take(context.Background(), "key", limit, start.Add(30*time.Second)); !allowed {
+		t.Errorf("token wasn't refilled")
+	}
+	if allowed, _, _ := store.Take(context.Background(), "other", limit, start); !allowed {
+		t.Errorf("buckets of different keys aren't independent")
+	}
+}
+
+func newTestLimiter(limits config.RouteLimits) (*Limiter, *time.Time) {
+	now := time.Now()
+	limiter := NewLimiter(NewMemoryStore(), &config.RateLimitConfig{Routes: map[string]config.RouteLimits{"login": limits}})
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func login(handler http.HandlerFunc, address string, email string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("POST", "/user/login", strings.NewReader(`{"email": "`+email+`", "password": "secret"}`))
+	request.RemoteAddr = address + ":51000"
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder
+}
+
+func TestLimitThrottlesByAccount(t *testing.T) {
+	limiter, now := newTestLimiter(config.RouteLimits{Account: config.Limit{Requests: 1, Per: 10 * time.Second}})
+	var body string
+	handler := limiter.Limit("login", EmailAccount, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	})
+
+	if code := login(handler, "10.0.0.1", "player@example.com").Code; code != http.StatusOK {
+		t.Fatalf("first request was throttled with %d", code)
+	}
+	if !strings.Contains(body, "secret") {
+		t.Errorf("handler didn't get the whole body: '%s'", body)
+	}
+	// another address doesn't help, account's bucket is empty
+	recorder := login(handler, "10.0.0.2", "PLAYER@example.com")
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "10" {
+		t.Errorf("expected 429 with Retry-After 10, got %d '%s'", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+	if code := login(handler, "10.0.0.2", "other@example.com").Code; code != http.StatusOK {
+		t.Errorf("other account was throttled with %d", code)
+	}
+	*now = now.Add(10 * time.Second)
+	if code := login(handler, "10.0.0.1", "player@example.com").Code; code != http.StatusOK {
+		t.Errorf("account was throttled after its bucket was refilled, got %d", code)
+	}
+}
+
+func TestLimitThrottlesByAddress(t *testing.T) {
+	limiter, _ := newTestLimiter(config.RouteLimits{IP: config.Limit{Requests: 2, Per: time.Minute}})
+	handler := limiter.Limit("login", EmailAccount, func(w http.ResponseWriter, r *http.Request) {})
+	login(handler, "10.0.0.1", "first@example.com")
+	login(handler, "10.0.0.1", "second@example.com")
+	if code := login(handler, "10.0.0.1", "third@example.com").Code; code != http.StatusTooManyRequests {
+		t.Errorf("address wasn't throttled, got %d", code)
+	}
+	if code := login(handler, "10.0.0.2", "third@example.com").Code; code != http.StatusOK {
+		t.Errorf("other address was throttled with %d", code)
+	}
+
+	// address set by proxy is used only when proxy is trusted
+	request := httptest.NewRequest("POST", "/user/login", nil)
+	request.RemoteAddr = "10.0.0.1:51000"
+	request.Header.Set("X-Forwarded-For", "192.168.0.7, 10.0.0.5")
+	if ip := limiter.clientIP(request); ip != "10.0.0.1" {
+		t.Errorf("untrusted X-Forwarded-For was used: %s", ip)
+	}
+	limiter.cfg.TrustProxy = true
+	if ip := limiter.clientIP(request); ip != "192.168.0.7" {
+		t.Errorf("expected client's address from X-Forwarded-For, got %s", ip)
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/database"
+	"FlankiRest/tracing"
+	"context"
+	"sync"
+	"time"
+)
+
+// PostgresStore keeps buckets in the app's database so that all instances of the app share them,
+// it always uses current connection kept by ApiDatabase
+type PostgresStore struct {
+	db *database.ApiDatabase
+
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *database.ApiDatabase) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Take locks the row of the bucket for the time of the transaction, concurrent requests for the same key wait for each other
+func (store *PostgresStore) Take(ctx context.Context, key string, limit config.Limit, now time.Time) (allowed bool, retryAfter time.Duration, err error) {
+	db := tracing.WithContext(store.db.DB(), ctx)
+	if db == nil {
+		return false, 0, database.ErrNotConnected
+	}
+	store.sweep(ctx, now)
+
+	tx := db.Begin()
+	if err = tx.Error; err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	bucket := newBucket(key, limit, now)
+	err = tx.Exec("INSERT INTO rate_limit_buckets (key, tokens, refilled) VALUES (?, ?, ?) ON CONFLICT (key) DO NOTHING",
+		bucket.Key, bucket.Tokens, bucket.Refilled).Error
+	if err != nil {
+		return
+	}
+	if err = tx.Set("gorm:query_option", "FOR UPDATE").Where("key = ?", key).First(bucket).Error; err != nil {
+		return
+	}
+	allowed, retryAfter = bucket.take(limit, now)
+	if err = tx.Save(bucket).Error; err != nil {
+		return
+	}
+	err = tx.Commit().Error
+	return
+}
+
+// sweep deletes idle buckets at most once an hour, failing to do so doesn't matter for the request
+func (store *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	store.mutex.Lock()
+	if now.Sub(store.lastSweep) < time.Hour {
+		store.mutex.Unlock()
+		return
+	}
+	store.lastSweep = now
+	store.mutex.Unlock()
+	if db := tracing.WithContext(store.db.DB(), ctx); db != nil {
+		db.Where("refilled < ?", now.Add(-idleBucketTTL)).Delete(&Bucket{})
+	}
+}
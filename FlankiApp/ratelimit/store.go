@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"FlankiRest/config"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// buckets unused for this long are dropped from stores, they are refilled by then for any sensible limit
+const idleBucketTTL = 24 * time.Hour
+
+// Bucket of tokens, every request takes one and they are refilled at limit's rate up to its capacity
+type Bucket struct {
+	Key      string `gorm:"primary_key"`
+	Tokens   float64
+	Refilled time.Time
+}
+
+func (Bucket) TableName() string {
+	return "rate_limit_buckets"
+}
+
+func newBucket(key string, limit config.Limit, now time.Time) *Bucket {
+	return &Bucket{Key: key, Tokens: float64(limit.Capacity()), Refilled: now}
+}
+
+// take refills the bucket for the time since its last refill and takes a token out of it,
+// when it is empty it returns how long it takes until there is one
+func (bucket *Bucket) take(limit config.Limit, now time.Time) (bool, time.Duration) {
+	rate := float64(limit.Requests) / limit.Per.Seconds()
+	if elapsed := now.Sub(bucket.Refilled).Seconds(); elapsed > 0 {
+		bucket.Tokens = math.Min(float64(limit.Capacity()), bucket.Tokens+elapsed*rate)
+		bucket.Refilled = now
+	}
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.Tokens) / rate * float64(time.Second))
+}
+
+// Store keeps buckets of all keys, taking a token has to be atomic so that concurrent requests can't exceed the limit
+type Store interface {
+	// Take takes a token from the bucket of key, which is created full when it doesn't exist yet
+	Take(ctx context.Context, key string, limit config.Limit, now time.Time) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// MemoryStore keeps buckets of a single instance of the app
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*Bucket{}}
+}
+
+func (store *MemoryStore) Take(ctx context.Context, key string, limit config.Limit, now time.Time) (bool, time.Duration, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.sweep(now)
+	bucket, ok := store.buckets[key]
+	if !ok {
+		bucket = newBucket(key, limit, now)
+		store.buckets[key] = bucket
+	}
+	allowed, retryAfter := bucket.take(limit, now)
+	return allowed, retryAfter, nil
+}
+
+// sweep drops idle buckets, at most once an hour, so that every address ever seen isn't kept forever
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < time.Hour {
+		return
+	}
+	store.lastSweep = now
+	for key, bucket := range store.buckets {
+		if now.Sub(bucket.Refilled) > idleBucketTTL {
+			delete(store.buckets, key)
+		}
+	}
+}
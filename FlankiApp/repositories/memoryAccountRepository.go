@@ -0,0 +1,371 @@
+package repositories
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type MemoryAccountRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryAccountRepository(store *MemoryStore) *MemoryAccountRepository {
+	return &MemoryAccountRepository{store}
+}
+
+func (repo *MemoryAccountRepository) Create(account *models.Account) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	account.ID = repo.store.nextID()
+	account.CreatedAt = time.Now()
+	account.UpdatedAt = account.CreatedAt
+	repo.store.accounts[account.ID] = copyAccount(account)
+	return nil
+}
+
+func (repo *MemoryAccountRepository) Save(account *models.Account) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	if account.ID == 0 {
+		account.ID = repo.store.nextID()
+		account.CreatedAt = time.Now()
+	}
+	account.UpdatedAt = time.Now()
+	repo.store.accounts[account.ID] = copyAccount(account)
+	return nil
+}
+
+func (repo *MemoryAccountRepository) Delete(account *models.Account) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	if stored, found := repo.store.accounts[account.ID]; found && stored.DeletedAt == nil {
+		now := time.Now()
+		stored.DeletedAt = &now
+		account.DeletedAt = &now
+	}
+	return nil
+}
+
+func (repo *MemoryAccountRepository) GetById(id uint) (*models.Account, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	account, found := repo.store.accounts[id]
+	if !found || account.DeletedAt != nil {
+		return nil, errors.RecordNotFound
+	}
+	return copyAccount(account), nil
+}
+
+func (repo *MemoryAccountRepository) GetByEmail(email string) (*models.Account, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	for _, account := range repo.store.accounts {
+		if account.Email == email && account.DeletedAt == nil {
+			return copyAccount(account), nil
+		}
+	}
+	return nil, errors.RecordNotFound
+}
+
+func (repo *MemoryAccountRepository) GetByCalendarToken(token string) (*models.Account, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	for _, account := range repo.store.accounts {
+		if account.CalendarToken == token && account.DeletedAt == nil {
+			return copyAccount(account), nil
+		}
+	}
+	return nil, errors.RecordNotFound
+}
+
+func (repo *MemoryAccountRepository) CountByField(fieldName string, value string) (int, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	count := 0
+	for _, account := range repo.store.accounts {
+		if account.DeletedAt != nil {
+			continue
+		}
+		switch fieldName {
+		case "nickname":
+			if account.Nickname == value {
+				count++
+			}
+		case "email":
+			if account.Email == value {
+				count++
+			}
+		default:
+			return 0, fmt.Errorf("counting accounts by field '%s' is not supported", fieldName)
+		}
+	}
+	return count, nil
+}
+
+func (repo *MemoryAccountRepository) SetPlaying(id uint, playing bool) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	if account, found := repo.store.accounts[id]; found && account.DeletedAt == nil {
+		account.Playing = playing
+	}
+	return nil
+}
+
+func (repo *MemoryAccountRepository) ReconcilePlaying() (int64, int64, error) {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	active := map[uint]bool{}
+	for _, lobby := range repo.store.lobbies {
+		if lobby.Closed != nil && *lobby.Closed == false {
+			for _, team := range lobby.Teams {
+				for _, entry := range team.TeamEntries {
+					active[entry.PlayerID] = true
+				}
+			}
+		}
+	}
+	var stopped, started int64
+	for _, account := range repo.store.accounts {
+		if account.Playing && !active[account.ID] {
+			account.Playing = false
+			stopped++
+		} else if !account.Playing && active[account.ID] && account.DeletedAt == nil {
+			account.Playing = true
+			started++
+		}
+	}
+	return stopped, started, nil
+}
+
+func (repo *MemoryAccountRepository) GetAllPlayers() ([]*models.Player, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	players := make([]*models.Player, 0, len(repo.store.accounts))
+	for _, account := range repo.store.accounts {
+		if account.DeletedAt == nil {
+			players = append(players, playerFromAccount(account))
+		}
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	return players, nil
+}
+
+func (repo *MemoryAccountRepository) GetPlayerById(id uint) (*models.Player, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	account, found := repo.store.accounts[id]
+	if !found || account.DeletedAt != nil {
+		return nil, errors.RecordNotFound
+	}
+	return playerFromAccount(account), nil
+}
+
+func (repo *MemoryAccountRepository) GetDeletedByEmail(email string) (*models.Account, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	var deleted *models.Account
+	for _, account := range repo.store.accounts {
+		if account.Email == email && account.DeletedAt != nil && account.ErasedAt == nil &&
+			(deleted == nil || account.DeletedAt.After(*deleted.DeletedAt)) {
+			deleted = account
+		}
+	}
+	if deleted == nil {
+		return nil, errors.RecordNotFound
+	}
+	return copyAccount(deleted), nil
+}
+
+func (repo *MemoryAccountRepository) Restore(account *models.Account) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	if stored, found := repo.store.accounts[account.ID]; found {
+		stored.DeletedAt = nil
+		stored.UpdatedAt = time.Now()
+	}
+	account.DeletedAt = nil
+	return nil
+}
+
+func (repo *MemoryAccountRepository) GetToErase(deletedBefore time.Time) ([]*models.Account, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	accounts := []*models.Account{}
+	for _, account := range repo.store.accounts {
+		if account.DeletedAt != nil && account.ErasedAt == nil && account.DeletedAt.Before(deletedBefore) {
+			accounts = append(accounts, copyAccount(account))
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
+func (repo *MemoryAccountRepository) Erase(account *models.Account, tombstone *models.Account) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	store := repo.store
+	tombstone.ID = store.nextID()
+	tombstone.CreatedAt = time.Now()
+	tombstone.UpdatedAt = tombstone.CreatedAt
+	store.accounts[tombstone.ID] = copyAccount(tombstone)
+
+	// only aggregate statistics are kept under the tombstone, they are detached from matches. Frozen entries don't count
+	// so they are dropped together with the account's own events, other rows of its matches stop pointing at it
+	moved := func(id *uint) {
+		if *id == account.ID {
+			*id = tombstone.ID
+		}
+	}
+	detached := func(id *uint) {
+		if *id == account.ID {
+			*id = 0
+		}
+	}
+	statistics := store.statistics[:0]
+	for _, entry := range store.statistics {
+		if entry.PlayerID == account.ID {
+			if entry.Frozen {
+				continue
+			}
+			entry.PlayerID, entry.LobbyId = tombstone.ID, 0
+		}
+		statistics = append(statistics, entry)
+	}
+	store.statistics = statistics
+	events := store.events[:0]
+	for _, event := range store.events {
+		if event.PlayerID != account.ID {
+			detached(&event.RecordedBy)
+			events = append(events, event)
+		}
+	}
+	store.events = events
+	for _, lobby := range store.lobbies {
+		moved(&lobby.OwnerID)
+		if lobby.RefereeID != nil {
+			moved(lobby.RefereeID)
+		}
+		for i := range lobby.Teams {
+			for j := range lobby.Teams[i].TeamEntries {
+				if entry := &lobby.Teams[i].TeamEntries[j]; entry.PlayerID == account.ID {
+					entry.PlayerID, entry.Nickname = 0, tombstone.Nickname
+				}
+			}
+		}
+		for i := range lobby.ResultHistory {
+			detached(&lobby.ResultHistory[i].PlayerID)
+		}
+		coOwners := lobby.CoOwners[:0]
+		for _, coOwner := range lobby.CoOwners {
+			if coOwner.PlayerID != account.ID {
+				coOwners = append(coOwners, coOwner)
+			}
+		}
+		lobby.CoOwners = coOwners
+	}
+	for _, tournament := range store.tournaments {
+		moved(&tournament.OwnerID)
+		for i := range tournament.Teams {
+			moved(&tournament.Teams[i].CaptainID)
+			for j := range tournament.Teams[i].Members {
+				moved(&tournament.Teams[i].Members[j].PlayerID)
+			}
+		}
+	}
+
+	// everything else linked to the account is deleted just like postgres cascades deleting it
+	rsvps := store.rsvps[:0]
+	for _, rsvp := range store.rsvps {
+		if rsvp.PlayerID != account.ID {
+			rsvps = append(rsvps, rsvp)
+		}
+	}
+	store.rsvps = rsvps
+	spectators := store.spectators[:0]
+	for _, spectator := range store.spectators {
+		if spectator.PlayerID != account.ID {
+			spectators = append(spectators, spectator)
+		}
+	}
+	store.spectators = spectators
+	invites := store.invites[:0]
+	for _, invite := range store.invites {
+		if invite.PlayerID == nil || *invite.PlayerID != account.ID {
+			invites = append(invites, invite)
+		}
+	}
+	store.invites = invites
+	friendships := store.friendships[:0]
+	for _, friendship := range store.friendships {
+		if friendship.RequesterID != account.ID && friendship.AddresseeID != account.ID {
+			friendships = append(friendships, friendship)
+		}
+	}
+	store.friendships = friendships
+	follows := store.follows[:0]
+	for _, follow := range store.follows {
+		if follow.FollowerID != account.ID && follow.FolloweeID != account.ID {
+			follows = append(follows, follow)
+		}
+	}
+	store.follows = follows
+	notifications := store.notifications[:0]
+	for _, notification := range store.notifications {
+		if notification.AccountID != account.ID {
+			notifications = append(notifications, notification)
+		}
+	}
+	store.notifications = notifications
+	preferences := store.preferences[:0]
+	for _, preference := range store.preferences {
+		if preference.AccountID != account.ID {
+			preferences = append(preferences, preference)
+		}
+	}
+	store.preferences = preferences
+	subscriptions := store.pushSubscriptions[:0]
+	for _, subscription := range store.pushSubscriptions {
+		if subscription.AccountID != account.ID {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	store.pushSubscriptions = subscriptions
+	achievements := store.achievements[:0]
+	for _, achievement := range store.achievements {
+		if achievement.PlayerID != account.ID {
+			achievements = append(achievements, achievement)
+		}
+	}
+	store.achievements = achievements
+
+	delete(store.accounts, account.ID)
+	return nil
+}
+
+func playerFromAccount(account *models.Account) *models.Player {
+	return &models.Player{
+		ID:          account.ID,
+		Nickname:    account.Nickname,
+		Sex:         account.Sex,
+		Description: account.Description,
+		Playing:     account.Playing,
+	}
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"FlankiRest/models"
+	"time"
+)
+
+type MemoryAchievementRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryAchievementRepository(store *MemoryStore) *MemoryAchievementRepository {
+	return &MemoryAchievementRepository{store}
+}
+
+func (repo *MemoryAchievementRepository) Award(achievement *models.Achievement) (bool, error) {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for _, stored := range repo.store.achievements {
+		if stored.PlayerID == achievement.PlayerID && stored.Badge == achievement.Badge {
+			return false, nil
+		}
+	}
+	achievement.ID = repo.store.nextID()
+	achievement.CreatedAt = time.Now()
+	repo.store.achievements = append(repo.store.achievements, *achievement)
+	return true, nil
+}
+
+func (repo *MemoryAchievementRepository) GetByPlayer(playerID uint) ([]models.Achievement, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	achievements := []models.Achievement{}
+	for _, achievement := range repo.store.achievements {
+		if achievement.PlayerID == playerID {
+			achievements = append(achievements, achievement)
+		}
+	}
+	return achievements, nil
+}
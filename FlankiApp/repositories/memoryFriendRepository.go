@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"time"
+)
+
+type MemoryFriendRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryFriendRepository(store *MemoryStore) *MemoryFriendRepository {
+	return &MemoryFriendRepository{store}
+}
+
+func (repo *MemoryFriendRepository) SaveFriendship(friendship *models.Friendship) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	friendship.UpdatedAt = time.Now()
+	for i := range repo.store.friendships {
+		if repo.store.friendships[i].ID == friendship.ID {
+			repo.store.friendships[i] = *friendship
+			return nil
+		}
+	}
+	friendship.ID = repo.store.nextID()
+	friendship.CreatedAt = friendship.UpdatedAt
+	repo.store.friendships = append(repo.store.friendships, *friendship)
+	return nil
+}
+
+func (repo *MemoryFriendRepository) DeleteFriendship(friendship *models.Friendship) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for i := range repo.store.friendships {
+		if repo.store.friendships[i].ID == friendship.ID {
+			repo.store.friendships = append(repo.store.friendships[:i], repo.store.friendships[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryFriendRepository) GetFriendship(playerID uint, otherID uint) (*models.Friendship, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	for _, friendship := range repo.store.friendships {
+		if (friendship.RequesterID == playerID && friendship.AddresseeID == otherID) ||
+			(friendship.RequesterID == otherID && friendship.AddresseeID == playerID) {
+			return &friendship, nil
+		}
+	}
+	return nil, errors.RecordNotFound
+}
+
+func (repo *MemoryFriendRepository) GetFriendships(playerID uint) ([]models.Friendship, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	friendships := []models.Friendship{}
+	for _, friendship := range repo.store.friendships {
+		if friendship.RequesterID == playerID || friendship.AddresseeID == playerID {
+			friendships = append(friendships, friendship)
+		}
+	}
+	return friendships, nil
+}
+
+func (repo *MemoryFriendRepository) AddFollow(follow *models.Follow) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	follow.ID = repo.store.nextID()
+	follow.CreatedAt = time.Now()
+	repo.store.follows = append(repo.store.follows, *follow)
+	return nil
+}
+
+func (repo *MemoryFriendRepository) DeleteFollow(followerID uint, followeeID uint) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for i, follow := range repo.store.follows {
+		if follow.FollowerID == followerID && follow.FolloweeID == followeeID {
+			repo.store.follows = append(repo.store.follows[:i], repo.store.follows[i+1:]...)
+			return nil
+		}
+	}
+	return errors.RecordNotFound
+}
+
+func (repo *MemoryFriendRepository) GetFollowing(followerID uint) ([]models.Follow, error) {
+	return repo.findFollows(func(follow *models.Follow) bool { return follow.FollowerID == followerID })
+}
+
+func (repo *MemoryFriendRepository) GetFollowers(followeeID uint) ([]models.Follow, error) {
+	return repo.findFollows(func(follow *models.Follow) bool { return follow.FolloweeID == followeeID })
+}
+
+func (repo *MemoryFriendRepository) findFollows(predicate func(follow *models.Follow) bool) ([]models.Follow, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	follows := []models.Follow{}
+	for i := range repo.store.follows {
+		if predicate(&repo.store.follows[i]) {
+			follows = append(follows, repo.store.follows[i])
+		}
+	}
+	return follows, nil
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"time"
+)
+
+type MemoryInviteRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryInviteRepository(store *MemoryStore) *MemoryInviteRepository {
+	return &MemoryInviteRepository{store}
+}
+
+func copyInvite(invite *models.LobbyInvite) *models.LobbyInvite {
+	inviteCopy := *invite
+	if invite.PlayerID != nil {
+		playerID := *invite.PlayerID
+		inviteCopy.PlayerID = &playerID
+	}
+	if invite.MaxUses != nil {
+		maxUses := *invite.MaxUses
+		inviteCopy.MaxUses = &maxUses
+	}
+	for _, at := range []**time.Time{&inviteCopy.ExpiresAt, &inviteCopy.RevokedAt} {
+		if *at != nil {
+			value := **at
+			*at = &value
+		}
+	}
+	return &inviteCopy
+}
+
+func (repo *MemoryInviteRepository) Create(invite *models.LobbyInvite) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	invite.ID = repo.store.nextID()
+	invite.CreatedAt = time.Now()
+	repo.store.invites = append(repo.store.invites, *copyInvite(invite))
+	return nil
+}
+
+func (repo *MemoryInviteRepository) Save(invite *models.LobbyInvite) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored := repo.find(invite.ID)
+	if stored == nil {
+		return errors.RecordNotFound
+	}
+	*stored = *copyInvite(invite)
+	return nil
+}
+
+func (repo *MemoryInviteRepository) GetByCode(code string) (*models.LobbyInvite, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	for i := range repo.store.invites {
+		if repo.store.invites[i].Code == code {
+			return copyInvite(&repo.store.invites[i]), nil
+		}
+	}
+	return nil, errors.RecordNotFound
+}
+
+func (repo *MemoryInviteRepository) GetByLobby(lobbyID uint) ([]models.LobbyInvite, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	invites := []models.LobbyInvite{}
+	for i := range repo.store.invites {
+		if repo.store.invites[i].LobbyID == lobbyID {
+			invites = append(invites, *copyInvite(&repo.store.invites[i]))
+		}
+	}
+	return invites, nil
+}
+
+func (repo *MemoryInviteRepository) Use(invite *models.LobbyInvite) (bool, error) {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored := repo.find(invite.ID)
+	if stored == nil {
+		return false, errors.RecordNotFound
+	}
+	if stored.RevokedAt != nil || (stored.MaxUses != nil && stored.Uses >= *stored.MaxUses) {
+		return false, nil
+	}
+	stored.Uses++
+	invite.Uses = stored.Uses
+	return true, nil
+}
+
+func (repo *MemoryInviteRepository) Release(invite *models.LobbyInvite) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored := repo.find(invite.ID)
+	if stored == nil {
+		return errors.RecordNotFound
+	}
+	if stored.Uses > 0 {
+		stored.Uses--
+	}
+	invite.Uses = stored.Uses
+	return nil
+}
+
+// store has to be locked by the caller
+func (repo *MemoryInviteRepository) find(id uint) *models.LobbyInvite {
+	for i := range repo.store.invites {
+		if repo.store.invites[i].ID == id {
+			return &repo.store.invites[i]
+		}
+	}
+	return nil
+}
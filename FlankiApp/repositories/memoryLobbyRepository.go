@@ -0,0 +1,357 @@
+package repositories
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"sort"
+	"time"
+)
+
+type MemoryLobbyRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryLobbyRepository(store *MemoryStore) *MemoryLobbyRepository {
+	return &MemoryLobbyRepository{store}
+}
+
+func (repo *MemoryLobbyRepository) Create(lobby *models.Lobby) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	lobby.ID = repo.store.nextID()
+	lobby.CreatedAt = time.Now()
+	lobby.UpdatedAt = lobby.CreatedAt
+	for i := range lobby.Teams {
+		team := &lobby.Teams[i]
+		team.ID = repo.store.nextID()
+		team.LobbyID = lobby.ID
+		for j := range team.TeamEntries {
+			team.TeamEntries[j].ID = repo.store.nextID()
+			team.TeamEntries[j].TeamID = team.ID
+		}
+	}
+	repo.store.lobbies[lobby.ID] = copyLobby(lobby)
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) Save(lobby *models.Lobby) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.lobbies[lobby.ID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	lobby.UpdatedAt = time.Now()
+	saved := copyLobby(lobby)
+	saved.Teams = stored.Teams
+	saved.ResultHistory = stored.ResultHistory
+	saved.CoOwners = stored.CoOwners
+	repo.store.lobbies[lobby.ID] = saved
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) Delete(lobby *models.Lobby) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	delete(repo.store.lobbies, lobby.ID)
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) GetById(id uint) (*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	lobby, found := repo.store.lobbies[id]
+	if !found {
+		return nil, errors.RecordNotFound
+	}
+	return copyLobby(lobby), nil
+}
+
+func (repo *MemoryLobbyRepository) GetOpenByOwner(ownerID uint) (*models.Lobby, error) {
+	return repo.findOpen(func(lobby *models.Lobby) bool {
+		return lobby.OwnerID == ownerID
+	})
+}
+
+func (repo *MemoryLobbyRepository) GetOpenByPlayer(playerID uint) (*models.Lobby, error) {
+	return repo.findOpen(func(lobby *models.Lobby) bool {
+		for _, team := range lobby.Teams {
+			if team.ContainsPlayerWithId(playerID) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (repo *MemoryLobbyRepository) GetOpenByManager(playerID uint) ([]*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	managed := []*models.Lobby{}
+	for _, lobby := range repo.sortedLobbies() {
+		if lobby.Closed != nil && *lobby.Closed == false && lobby.OwnerID != playerID && lobby.IsManager(playerID) {
+			managed = append(managed, copyLobby(lobby))
+		}
+	}
+	return managed, nil
+}
+
+func (repo *MemoryLobbyRepository) findOpen(predicate func(lobby *models.Lobby) bool) (*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	for _, lobby := range repo.sortedLobbies() {
+		if lobby.Closed != nil && *lobby.Closed == false && predicate(lobby) {
+			return copyLobby(lobby), nil
+		}
+	}
+	return nil, errors.RecordNotFound
+}
+
+func (repo *MemoryLobbyRepository) GetAll(closed bool, limit int) ([]*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	sorted := repo.sortedLobbies()
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].UpdatedAt.After(sorted[j].UpdatedAt) })
+
+	lobbies := []*models.Lobby{}
+	for _, lobby := range sorted {
+		if len(lobbies) >= limit {
+			break
+		}
+		if lobby.Closed != nil && *lobby.Closed == closed {
+			lobbies = append(lobbies, copyLobby(lobby))
+		}
+	}
+	return lobbies, nil
+}
+
+func (repo *MemoryLobbyRepository) AddTeamEntry(team *models.Team, entry *models.TeamEntry) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored := repo.findTeam(team.ID)
+	if stored == nil {
+		return errors.RecordNotFound
+	}
+	entry.ID = repo.store.nextID()
+	entry.TeamID = team.ID
+	stored.TeamEntries = append(stored.TeamEntries, *entry)
+	team.TeamEntries = append(team.TeamEntries, *entry)
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) DeleteTeamEntry(entry *models.TeamEntry) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored := repo.findTeam(entry.TeamID)
+	if stored == nil {
+		return nil
+	}
+	for i, e := range stored.TeamEntries {
+		if e.ID == entry.ID {
+			stored.TeamEntries = append(stored.TeamEntries[:i], stored.TeamEntries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) SaveTeamEntry(entry *models.TeamEntry) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	team := repo.findTeam(entry.TeamID)
+	if team == nil {
+		return errors.RecordNotFound
+	}
+	for i := range team.TeamEntries {
+		if team.TeamEntries[i].ID == entry.ID {
+			team.TeamEntries[i] = *entry
+			return nil
+		}
+	}
+	return errors.RecordNotFound
+}
+
+func (repo *MemoryLobbyRepository) MoveTeamEntry(entry *models.TeamEntry, team *models.Team) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	from, to := repo.findTeam(entry.TeamID), repo.findTeam(team.ID)
+	if from == nil || to == nil {
+		return errors.RecordNotFound
+	}
+	for i, e := range from.TeamEntries {
+		if e.ID == entry.ID {
+			from.TeamEntries = append(from.TeamEntries[:i], from.TeamEntries[i+1:]...)
+			entry.TeamID = team.ID
+			to.TeamEntries = append(to.TeamEntries, *entry)
+			return nil
+		}
+	}
+	return errors.RecordNotFound
+}
+
+func (repo *MemoryLobbyRepository) AddResultRecord(lobby *models.Lobby, record *models.ResultRecord) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.lobbies[lobby.ID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	record.ID = repo.store.nextID()
+	record.LobbyID = lobby.ID
+	record.CreatedAt = time.Now()
+	stored.ResultHistory = append(stored.ResultHistory, *record)
+	lobby.ResultHistory = append(lobby.ResultHistory, *record)
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) AddCoOwner(lobby *models.Lobby, coOwner *models.LobbyCoOwner) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.lobbies[lobby.ID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	coOwner.ID = repo.store.nextID()
+	coOwner.LobbyID = lobby.ID
+	coOwner.CreatedAt = time.Now()
+	stored.CoOwners = append(stored.CoOwners, *coOwner)
+	lobby.CoOwners = append(lobby.CoOwners, *coOwner)
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) DeleteCoOwner(coOwner *models.LobbyCoOwner) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.lobbies[coOwner.LobbyID]
+	if !found {
+		return nil
+	}
+	for i, c := range stored.CoOwners {
+		if c.ID == coOwner.ID {
+			stored.CoOwners = append(stored.CoOwners[:i], stored.CoOwners[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) SeeOwner(ownerID uint, at time.Time, since time.Time) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for _, lobby := range repo.store.lobbies {
+		if lobby.OwnerID == ownerID && lobby.Closed != nil && *lobby.Closed == false &&
+			(lobby.OwnerSeenAt == nil || lobby.OwnerSeenAt.Before(since)) {
+			seenAt := at
+			lobby.OwnerSeenAt = &seenAt
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryLobbyRepository) GetIdleOwned(seenBefore time.Time) ([]*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	lobbies := []*models.Lobby{}
+	for _, lobby := range repo.sortedLobbies() {
+		if lobby.Closed != nil && *lobby.Closed == false && lobby.OwnerSeenAt != nil && lobby.OwnerSeenAt.Before(seenBefore) {
+			lobbies = append(lobbies, copyLobby(lobby))
+		}
+	}
+	return lobbies, nil
+}
+
+func (repo *MemoryLobbyRepository) GetStale(activeBefore time.Time) ([]*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	lobbies := []*models.Lobby{}
+	for _, lobby := range repo.sortedLobbies() {
+		if lobby.Closed != nil && *lobby.Closed == false && lobby.UpdatedAt.Before(activeBefore) &&
+			(lobby.OwnerSeenAt == nil || lobby.OwnerSeenAt.Before(activeBefore)) && (lobby.StartsAt == nil || lobby.StartsAt.Before(activeBefore)) {
+			lobbies = append(lobbies, copyLobby(lobby))
+		}
+	}
+	return lobbies, nil
+}
+
+func (repo *MemoryLobbyRepository) GetPendingResults(deadline time.Time) ([]*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	lobbies := []*models.Lobby{}
+	for _, lobby := range repo.sortedLobbies() {
+		if lobby.State == models.Finished && lobby.ResultStatus == models.ResultPending &&
+			lobby.ResultDeadline != nil && lobby.ResultDeadline.Before(deadline) {
+			lobbies = append(lobbies, copyLobby(lobby))
+		}
+	}
+	return lobbies, nil
+}
+
+func (repo *MemoryLobbyRepository) GetUpcoming(from time.Time, limit int) ([]*models.Lobby, error) {
+	return repo.scheduled(limit, func(lobby *models.Lobby) bool {
+		return lobby.StartsAt.After(from)
+	})
+}
+
+func (repo *MemoryLobbyRepository) GetToRemind(until time.Time) ([]*models.Lobby, error) {
+	return repo.scheduled(0, func(lobby *models.Lobby) bool {
+		return lobby.StartsAt.Before(until) && lobby.RemindedAt == nil
+	})
+}
+
+// open scheduled lobbies matching predicate, ordered by their start, limit 0 means all of them
+func (repo *MemoryLobbyRepository) scheduled(limit int, predicate func(lobby *models.Lobby) bool) ([]*models.Lobby, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	lobbies := []*models.Lobby{}
+	for _, lobby := range repo.sortedLobbies() {
+		if lobby.Closed != nil && *lobby.Closed == false && lobby.StartsAt != nil && predicate(lobby) {
+			lobbies = append(lobbies, copyLobby(lobby))
+		}
+	}
+	sort.SliceStable(lobbies, func(i, j int) bool { return lobbies[i].StartsAt.Before(*lobbies[j].StartsAt) })
+	if limit > 0 && len(lobbies) > limit {
+		lobbies = lobbies[:limit]
+	}
+	return lobbies, nil
+}
+
+// store's mutex has to be held by the caller
+func (repo *MemoryLobbyRepository) findTeam(id uint) *models.Team {
+	for _, lobby := range repo.store.lobbies {
+		for i := range lobby.Teams {
+			if lobby.Teams[i].ID == id {
+				return &lobby.Teams[i]
+			}
+		}
+	}
+	return nil
+}
+
+// store's mutex has to be held by the caller
+func (repo *MemoryLobbyRepository) sortedLobbies() []*models.Lobby {
+	lobbies := make([]*models.Lobby, 0, len(repo.store.lobbies))
+	for _, lobby := range repo.store.lobbies {
+		lobbies = append(lobbies, lobby)
+	}
+	sort.Slice(lobbies, func(i, j int) bool { return lobbies[i].ID < lobbies[j].ID })
+	return lobbies
+}
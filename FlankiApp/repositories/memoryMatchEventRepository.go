@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"FlankiRest/models"
+	"time"
+)
+
+type MemoryMatchEventRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryMatchEventRepository(store *MemoryStore) *MemoryMatchEventRepository {
+	return &MemoryMatchEventRepository{store}
+}
+
+func (repo *MemoryMatchEventRepository) Create(event *models.MatchEvent) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	event.ID = repo.store.nextID()
+	event.CreatedAt = time.Now()
+	repo.store.events = append(repo.store.events, *event)
+	return nil
+}
+
+func (repo *MemoryMatchEventRepository) GetByLobbies(lobbyIDs ...uint) ([]models.MatchEvent, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	wanted := map[uint]bool{}
+	for _, id := range lobbyIDs {
+		wanted[id] = true
+	}
+	events := []models.MatchEvent{}
+	for _, event := range repo.store.events {
+		if wanted[event.LobbyID] {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (repo *MemoryMatchEventRepository) GetByPlayer(playerID uint) ([]models.MatchEvent, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	events := []models.MatchEvent{}
+	for _, event := range repo.store.events {
+		lobby, found := repo.store.lobbies[event.LobbyID]
+		if event.PlayerID == playerID && found && lobby.State == models.Finished {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
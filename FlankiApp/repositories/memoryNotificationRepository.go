@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"time"
+)
+
+type MemoryNotificationRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryNotificationRepository(store *MemoryStore) *MemoryNotificationRepository {
+	return &MemoryNotificationRepository{store}
+}
+
+func (repo *MemoryNotificationRepository) Create(notification *models.Notification) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	notification.ID = repo.store.nextID()
+	notification.CreatedAt = time.Now()
+	repo.store.notifications = append(repo.store.notifications, *notification)
+	return nil
+}
+
+func (repo *MemoryNotificationRepository) GetByAccount(accountID uint, unreadOnly bool, limit int) ([]models.Notification, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	notifications := []models.Notification{}
+	for i := len(repo.store.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		notification := repo.store.notifications[i]
+		if notification.AccountID == accountID && (!unreadOnly || notification.ReadAt == nil) {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (repo *MemoryNotificationRepository) CountUnread(accountID uint) (int, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	unread := 0
+	for _, notification := range repo.store.notifications {
+		if notification.AccountID == accountID && notification.ReadAt == nil {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (repo *MemoryNotificationRepository) MarkRead(accountID uint, ids []uint, at time.Time) (int, error) {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	selected := map[uint]bool{}
+	for _, id := range ids {
+		selected[id] = true
+	}
+	marked := 0
+	for i := range repo.store.notifications {
+		notification := &repo.store.notifications[i]
+		if notification.AccountID == accountID && notification.ReadAt == nil && (len(ids) == 0 || selected[notification.ID]) {
+			readAt := at
+			notification.ReadAt = &readAt
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (repo *MemoryNotificationRepository) GetPreferences(accountID uint) ([]models.NotificationPreference, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	preferences := []models.NotificationPreference{}
+	for _, preference := range repo.store.preferences {
+		if preference.AccountID == accountID {
+			preferences = append(preferences, preference)
+		}
+	}
+	return preferences, nil
+}
+
+func (repo *MemoryNotificationRepository) SavePreference(preference *models.NotificationPreference) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for i := range repo.store.preferences {
+		stored := &repo.store.preferences[i]
+		if stored.AccountID == preference.AccountID && stored.Type == preference.Type {
+			preference.ID = stored.ID
+			*stored = *preference
+			return nil
+		}
+	}
+	preference.ID = repo.store.nextID()
+	repo.store.preferences = append(repo.store.preferences, *preference)
+	return nil
+}
+
+func (repo *MemoryNotificationRepository) SavePushSubscription(subscription *models.PushSubscription) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for i := range repo.store.pushSubscriptions {
+		stored := &repo.store.pushSubscriptions[i]
+		if stored.Endpoint == subscription.Endpoint {
+			subscription.ID, subscription.CreatedAt = stored.ID, stored.CreatedAt
+			*stored = *subscription
+			return nil
+		}
+	}
+	subscription.ID = repo.store.nextID()
+	subscription.CreatedAt = time.Now()
+	repo.store.pushSubscriptions = append(repo.store.pushSubscriptions, *subscription)
+	return nil
+}
+
+func (repo *MemoryNotificationRepository) GetPushSubscriptions(accountID uint) ([]models.PushSubscription, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	subscriptions := []models.PushSubscription{}
+	for _, subscription := range repo.store.pushSubscriptions {
+		if subscription.AccountID == accountID {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (repo *MemoryNotificationRepository) DeletePushSubscription(accountID uint, endpoint string) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for i, subscription := range repo.store.pushSubscriptions {
+		if subscription.AccountID == accountID && subscription.Endpoint == endpoint {
+			repo.store.pushSubscriptions = append(repo.store.pushSubscriptions[:i], repo.store.pushSubscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return errors.RecordNotFound
+}
+
+func (repo *MemoryNotificationRepository) ReplaceBody(notificationType models.NotificationType, body string, replacement string) (int, error) {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	replaced := 0
+	for i := range repo.store.notifications {
+		if notification := &repo.store.notifications[i]; notification.Type == notificationType && notification.Body == body {
+			notification.Body = replacement
+			replaced++
+		}
+	}
+	return replaced, nil
+}
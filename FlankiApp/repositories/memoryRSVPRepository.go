@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"FlankiRest/models"
+	"time"
+)
+
+type MemoryRSVPRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryRSVPRepository(store *MemoryStore) *MemoryRSVPRepository {
+	return &MemoryRSVPRepository{store}
+}
+
+func (repo *MemoryRSVPRepository) Save(rsvp *models.RSVP) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	rsvp.UpdatedAt = time.Now()
+	for i := range repo.store.rsvps {
+		stored := &repo.store.rsvps[i]
+		if stored.LobbyID == rsvp.LobbyID && stored.PlayerID == rsvp.PlayerID {
+			rsvp.ID = stored.ID
+			*stored = *rsvp
+			return nil
+		}
+	}
+	rsvp.ID = repo.store.nextID()
+	repo.store.rsvps = append(repo.store.rsvps, *rsvp)
+	return nil
+}
+
+func (repo *MemoryRSVPRepository) GetByLobby(lobbyID uint) ([]models.RSVP, error) {
+	return repo.find(func(rsvp *models.RSVP) bool { return rsvp.LobbyID == lobbyID })
+}
+
+func (repo *MemoryRSVPRepository) GetByPlayer(playerID uint) ([]models.RSVP, error) {
+	return repo.find(func(rsvp *models.RSVP) bool { return rsvp.PlayerID == playerID })
+}
+
+func (repo *MemoryRSVPRepository) find(predicate func(rsvp *models.RSVP) bool) ([]models.RSVP, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	rsvps := []models.RSVP{}
+	for i := range repo.store.rsvps {
+		if predicate(&repo.store.rsvps[i]) {
+			rsvps = append(rsvps, repo.store.rsvps[i])
+		}
+	}
+	return rsvps, nil
+}
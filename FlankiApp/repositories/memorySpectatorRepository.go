@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"FlankiRest/models"
+	"time"
+)
+
+type MemorySpectatorRepository struct {
+	store *MemoryStore
+}
+
+func NewMemorySpectatorRepository(store *MemoryStore) *MemorySpectatorRepository {
+	return &MemorySpectatorRepository{store}
+}
+
+func (repo *MemorySpectatorRepository) Add(spectator *models.LobbySpectator) (bool, error) {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for _, stored := range repo.store.spectators {
+		if stored.LobbyID == spectator.LobbyID && stored.PlayerID == spectator.PlayerID {
+			*spectator = stored
+			return false, nil
+		}
+	}
+	spectator.ID = repo.store.nextID()
+	spectator.CreatedAt = time.Now()
+	repo.store.spectators = append(repo.store.spectators, *spectator)
+	return true, nil
+}
+
+func (repo *MemorySpectatorRepository) Remove(lobbyID uint, playerID uint) (bool, error) {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for i, stored := range repo.store.spectators {
+		if stored.LobbyID == lobbyID && stored.PlayerID == playerID {
+			repo.store.spectators = append(repo.store.spectators[:i], repo.store.spectators[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (repo *MemorySpectatorRepository) GetByLobby(lobbyID uint) ([]models.LobbySpectator, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	spectators := []models.LobbySpectator{}
+	for _, spectator := range repo.store.spectators {
+		if spectator.LobbyID == lobbyID {
+			spectators = append(spectators, spectator)
+		}
+	}
+	return spectators, nil
+}
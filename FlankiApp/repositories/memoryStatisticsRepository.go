@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"FlankiRest/models"
+	"sort"
+)
+
+type MemoryStatisticsRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryStatisticsRepository(store *MemoryStore) *MemoryStatisticsRepository {
+	return &MemoryStatisticsRepository{store}
+}
+
+func (repo *MemoryStatisticsRepository) Create(entry *models.PlayerStatisticsEntry) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	entry.ID = repo.store.nextID()
+	repo.store.statistics = append(repo.store.statistics, *entry)
+	return nil
+}
+
+func (repo *MemoryStatisticsRepository) SetFrozen(lobbyID uint, frozen bool) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	for i := range repo.store.statistics {
+		if repo.store.statistics[i].LobbyId == lobbyID {
+			repo.store.statistics[i].Frozen = frozen
+		}
+	}
+	return nil
+}
+
+func (repo *MemoryStatisticsRepository) DeleteByLobby(lobbyID uint) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	kept := repo.store.statistics[:0]
+	for _, entry := range repo.store.statistics {
+		if entry.LobbyId != lobbyID {
+			kept = append(kept, entry)
+		}
+	}
+	repo.store.statistics = kept
+	return nil
+}
+
+func (repo *MemoryStatisticsRepository) GetSummary(playerID uint) (*models.PlayerSummary, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	summary := &models.PlayerSummary{PlayerID: playerID}
+	for _, entry := range repo.store.statistics {
+		if entry.PlayerID == playerID && !entry.Frozen {
+			addToSummary(summary, entry)
+		}
+	}
+	return summary, nil
+}
+
+func (repo *MemoryStatisticsRepository) GetRanking() ([]models.PlayerSummary, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	summaries := map[uint]*models.PlayerSummary{}
+	for _, entry := range repo.store.statistics {
+		if entry.Frozen {
+			continue
+		}
+		summary, found := summaries[entry.PlayerID]
+		if !found {
+			summary = &models.PlayerSummary{PlayerID: entry.PlayerID}
+			if account, ok := repo.store.accounts[entry.PlayerID]; ok {
+				summary.Nickname = account.Nickname
+			}
+			summaries[entry.PlayerID] = summary
+		}
+		addToSummary(summary, entry)
+	}
+
+	ranking := make([]models.PlayerSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		ranking = append(ranking, *summary)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Points == ranking[j].Points {
+			return ranking[i].PlayerID < ranking[j].PlayerID
+		}
+		return ranking[i].Points > ranking[j].Points
+	})
+	return ranking, nil
+}
+
+func addToSummary(summary *models.PlayerSummary, entry models.PlayerStatisticsEntry) {
+	if entry.Win {
+		summary.Wins++
+	} else {
+		summary.Loses++
+	}
+	summary.Points += entry.Points
+}
+
+func (repo *MemoryStatisticsRepository) GetByPlayer(playerID uint) ([]models.PlayerStatisticsEntry, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	entries := []models.PlayerStatisticsEntry{}
+	for _, entry := range repo.store.statistics {
+		if entry.PlayerID == playerID && !entry.Frozen {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (repo *MemoryStatisticsRepository) GetAllByPlayer(playerID uint) ([]models.PlayerStatisticsEntry, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	entries := []models.PlayerStatisticsEntry{}
+	for _, entry := range repo.store.statistics {
+		if entry.PlayerID == playerID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
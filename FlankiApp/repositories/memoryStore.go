@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"FlankiRest/models"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps all the data of in-memory repositories, repositories created
+// with the same store see each other's changes just like postgres ones do.
+// Models are always copied when entering or leaving the store so that callers can't
+// modify stored data without going through repository.
+type MemoryStore struct {
+	mutex       sync.RWMutex
+	lastID      uint
+	accounts    map[uint]*models.Account
+	lobbies     map[uint]*models.Lobby
+	statistics  []models.PlayerStatisticsEntry
+	events      []models.MatchEvent
+	rsvps       []models.RSVP
+	friendships []models.Friendship
+	follows     []models.Follow
+	tournaments map[uint]*models.Tournament
+	invites     []models.LobbyInvite
+	spectators  []models.LobbySpectator
+
+	notifications     []models.Notification
+	preferences       []models.NotificationPreference
+	pushSubscriptions []models.PushSubscription
+	achievements      []models.Achievement
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		accounts:    map[uint]*models.Account{},
+		lobbies:     map[uint]*models.Lobby{},
+		tournaments: map[uint]*models.Tournament{},
+	}
+}
+
+// ids are unique across all the models, which is fine as long as they are increasing
+func (store *MemoryStore) nextID() uint {
+	store.lastID++
+	return store.lastID
+}
+
+func copyAccount(account *models.Account) *models.Account {
+	accountCopy := *account
+	for _, at := range []**time.Time{&accountCopy.DeletedAt, &accountCopy.ErasedAt} {
+		if *at != nil {
+			value := **at
+			*at = &value
+		}
+	}
+	return &accountCopy
+}
+
+func copyLobby(lobby *models.Lobby) *models.Lobby {
+	lobbyCopy := *lobby
+	if lobby.Private != nil {
+		private := *lobby.Private
+		lobbyCopy.Private = &private
+	}
+	if lobby.Closed != nil {
+		closed := *lobby.Closed
+		lobbyCopy.Closed = &closed
+	}
+	if lobby.TeamLimit != nil {
+		limit := *lobby.TeamLimit
+		lobbyCopy.TeamLimit = &limit
+	}
+	if lobby.SpectatorLimit != nil {
+		limit := *lobby.SpectatorLimit
+		lobbyCopy.SpectatorLimit = &limit
+	}
+	// spectators are kept apart just like postgres doesn't save them together with the lobby
+	lobbyCopy.Spectators = nil
+	if lobby.TournamentMatchID != nil {
+		matchID := *lobby.TournamentMatchID
+		lobbyCopy.TournamentMatchID = &matchID
+	}
+	if lobby.RefereeID != nil {
+		referee := *lobby.RefereeID
+		lobbyCopy.RefereeID = &referee
+	}
+	for _, at := range []**time.Time{&lobbyCopy.StartsAt, &lobbyCopy.RemindedAt, &lobbyCopy.OwnerSeenAt} {
+		if *at != nil {
+			value := **at
+			*at = &value
+		}
+	}
+	if lobby.ConfirmResults != nil {
+		confirm := *lobby.ConfirmResults
+		lobbyCopy.ConfirmResults = &confirm
+	}
+	lobbyCopy.Teams = copyTeams(lobby.Teams)
+	if lobby.CoOwners != nil {
+		lobbyCopy.CoOwners = append([]models.LobbyCoOwner{}, lobby.CoOwners...)
+	}
+	if lobby.ResultHistory != nil {
+		lobbyCopy.ResultHistory = append([]models.ResultRecord{}, lobby.ResultHistory...)
+	}
+	return &lobbyCopy
+}
+
+func copyTeams(teams []models.Team) []models.Team {
+	if teams == nil {
+		return nil
+	}
+	teamsCopy := make([]models.Team, len(teams))
+	for i, team := range teams {
+		teamsCopy[i] = team
+		teamsCopy[i].TeamEntries = append([]models.TeamEntry{}, team.TeamEntries...)
+	}
+	return teamsCopy
+}
+
+func copyTournament(tournament *models.Tournament) *models.Tournament {
+	tournamentCopy := *tournament
+	if tournament.WinnerID != nil {
+		winnerID := *tournament.WinnerID
+		tournamentCopy.WinnerID = &winnerID
+	}
+	tournamentCopy.Teams = make([]models.TournamentTeam, len(tournament.Teams))
+	for i, team := range tournament.Teams {
+		tournamentCopy.Teams[i] = team
+		tournamentCopy.Teams[i].Members = append([]models.TournamentMember{}, team.Members...)
+	}
+	tournamentCopy.Matches = make([]models.TournamentMatch, len(tournament.Matches))
+	for i, match := range tournament.Matches {
+		tournamentCopy.Matches[i] = copyTournamentMatch(&match)
+	}
+	return &tournamentCopy
+}
+
+func copyTournamentMatch(match *models.TournamentMatch) models.TournamentMatch {
+	matchCopy := *match
+	for _, id := range []**uint{&matchCopy.BlueTeamID, &matchCopy.RedTeamID, &matchCopy.LobbyID, &matchCopy.WinnerID} {
+		if *id != nil {
+			value := **id
+			*id = &value
+		}
+	}
+	return matchCopy
+}
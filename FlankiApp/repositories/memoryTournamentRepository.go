@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"sort"
+	"time"
+)
+
+type MemoryTournamentRepository struct {
+	store *MemoryStore
+}
+
+func NewMemoryTournamentRepository(store *MemoryStore) *MemoryTournamentRepository {
+	return &MemoryTournamentRepository{store}
+}
+
+func (repo *MemoryTournamentRepository) Create(tournament *models.Tournament) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	tournament.ID = repo.store.nextID()
+	tournament.CreatedAt = time.Now()
+	tournament.UpdatedAt = tournament.CreatedAt
+	repo.store.tournaments[tournament.ID] = copyTournament(tournament)
+	return nil
+}
+
+func (repo *MemoryTournamentRepository) Save(tournament *models.Tournament) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.tournaments[tournament.ID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	tournament.UpdatedAt = time.Now()
+	saved := copyTournament(tournament)
+	saved.Teams, saved.Matches = stored.Teams, stored.Matches
+	repo.store.tournaments[tournament.ID] = saved
+	return nil
+}
+
+func (repo *MemoryTournamentRepository) GetById(id uint) (*models.Tournament, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	tournament, found := repo.store.tournaments[id]
+	if !found {
+		return nil, errors.RecordNotFound
+	}
+	return copyTournament(tournament), nil
+}
+
+func (repo *MemoryTournamentRepository) GetByMatch(matchID uint) (*models.Tournament, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	for _, tournament := range repo.store.tournaments {
+		for _, match := range tournament.Matches {
+			if match.ID == matchID {
+				return copyTournament(tournament), nil
+			}
+		}
+	}
+	return nil, errors.RecordNotFound
+}
+
+func (repo *MemoryTournamentRepository) GetAll(limit int) ([]*models.Tournament, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	tournaments := make([]*models.Tournament, 0, len(repo.store.tournaments))
+	for _, tournament := range repo.store.tournaments {
+		tournaments = append(tournaments, copyTournament(tournament))
+	}
+	sort.Slice(tournaments, func(i, j int) bool { return tournaments[i].ID > tournaments[j].ID })
+	if len(tournaments) > limit {
+		tournaments = tournaments[:limit]
+	}
+	return tournaments, nil
+}
+
+func (repo *MemoryTournamentRepository) GetWonBy(playerID uint) ([]*models.Tournament, error) {
+	repo.store.mutex.RLock()
+	defer repo.store.mutex.RUnlock()
+
+	tournaments := []*models.Tournament{}
+	for _, tournament := range repo.store.tournaments {
+		if tournament.State != models.TournamentFinished || tournament.WinnerID == nil {
+			continue
+		}
+		for _, team := range tournament.Teams {
+			if team.ID != *tournament.WinnerID {
+				continue
+			}
+			for _, member := range team.Members {
+				if member.PlayerID == playerID {
+					tournaments = append(tournaments, copyTournament(tournament))
+				}
+			}
+		}
+	}
+	sort.Slice(tournaments, func(i, j int) bool { return tournaments[i].ID < tournaments[j].ID })
+	return tournaments, nil
+}
+
+func (repo *MemoryTournamentRepository) AddTeam(tournament *models.Tournament, team *models.TournamentTeam) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.tournaments[tournament.ID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	team.ID = repo.store.nextID()
+	team.TournamentID = tournament.ID
+	for i := range team.Members {
+		team.Members[i].ID = repo.store.nextID()
+		team.Members[i].TournamentTeamID = team.ID
+	}
+	teamCopy := *team
+	teamCopy.Members = append([]models.TournamentMember{}, team.Members...)
+	stored.Teams = append(stored.Teams, teamCopy)
+	tournament.Teams = append(tournament.Teams, *team)
+	return nil
+}
+
+func (repo *MemoryTournamentRepository) SaveTeam(team *models.TournamentTeam) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.tournaments[team.TournamentID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	for i := range stored.Teams {
+		if stored.Teams[i].ID == team.ID {
+			members := stored.Teams[i].Members
+			stored.Teams[i] = *team
+			stored.Teams[i].Members = members
+			return nil
+		}
+	}
+	return errors.RecordNotFound
+}
+
+func (repo *MemoryTournamentRepository) CreateMatches(tournament *models.Tournament) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.tournaments[tournament.ID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	for i := range tournament.Matches {
+		tournament.Matches[i].ID = repo.store.nextID()
+		tournament.Matches[i].TournamentID = tournament.ID
+		stored.Matches = append(stored.Matches, copyTournamentMatch(&tournament.Matches[i]))
+	}
+	return nil
+}
+
+func (repo *MemoryTournamentRepository) SaveMatch(match *models.TournamentMatch) error {
+	repo.store.mutex.Lock()
+	defer repo.store.mutex.Unlock()
+
+	stored, found := repo.store.tournaments[match.TournamentID]
+	if !found {
+		return errors.RecordNotFound
+	}
+	for i := range stored.Matches {
+		if stored.Matches[i].ID == match.ID {
+			stored.Matches[i] = copyTournamentMatch(match)
+			return nil
+		}
+	}
+	return errors.RecordNotFound
+}
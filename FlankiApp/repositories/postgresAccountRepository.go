@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"context"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type PostgresAccountRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresAccountRepository(db *database.ApiDatabase) *PostgresAccountRepository {
+	return &PostgresAccountRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresAccountRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+func (repo *PostgresAccountRepository) Create(account *models.Account) error {
+	return repo.conn().Create(account).Error
+}
+
+func (repo *PostgresAccountRepository) Save(account *models.Account) error {
+	return repo.conn().Save(account).Error
+}
+
+func (repo *PostgresAccountRepository) Delete(account *models.Account) error {
+	return repo.conn().Delete(account).Error
+}
+
+func (repo *PostgresAccountRepository) GetById(id uint) (*models.Account, error) {
+	account := &models.Account{}
+	err := repo.conn().Where("id = ?", id).First(account).Error
+	return account, notFound(err)
+}
+
+func (repo *PostgresAccountRepository) GetByEmail(email string) (*models.Account, error) {
+	account := &models.Account{}
+	err := repo.conn().Where("email = ?", email).First(account).Error
+	return account, notFound(err)
+}
+
+func (repo *PostgresAccountRepository) GetByCalendarToken(token string) (*models.Account, error) {
+	account := &models.Account{}
+	err := repo.conn().Where("calendar_token = ?", token).First(account).Error
+	return account, notFound(err)
+}
+
+func (repo *PostgresAccountRepository) CountByField(fieldName string, value string) (int, error) {
+	if fieldName != "nickname" && fieldName != "email" {
+		return 0, fmt.Errorf("counting accounts by field '%s' is not supported", fieldName)
+	}
+	var count int
+	err := repo.conn().Model(&models.Account{}).Where(fmt.Sprintf("%s = ?", fieldName), value).Count(&count).Error
+	return count, err
+}
+
+func (repo *PostgresAccountRepository) SetPlaying(id uint, playing bool) error {
+	return repo.conn().Model(&models.Account{}).Where("id = ?", id).Update("playing", playing).Error
+}
+
+// players in teams of open lobbies
+const activePlayers = `SELECT team_entries.player_id FROM team_entries
+	JOIN teams ON teams.id = team_entries.team_id JOIN lobbies ON lobbies.id = teams.lobby_id
+	WHERE lobbies.closed = false AND lobbies.deleted_at IS NULL`
+
+func (repo *PostgresAccountRepository) ReconcilePlaying() (int64, int64, error) {
+	stopped := repo.conn().Exec("UPDATE accounts SET playing = false WHERE playing = true AND id NOT IN (" + activePlayers + ")")
+	if stopped.Error != nil {
+		return 0, 0, stopped.Error
+	}
+	started := repo.conn().Exec("UPDATE accounts SET playing = true WHERE playing = false AND deleted_at IS NULL AND id IN (" + activePlayers + ")")
+	return stopped.RowsAffected, started.RowsAffected, started.Error
+}
+
+func (repo *PostgresAccountRepository) GetAllPlayers() ([]*models.Player, error) {
+	var players []*models.Player
+	err := repo.conn().Model(&models.Account{}).Select("id, nickname, sex, description, playing").Scan(&players).Error
+	return players, err
+}
+
+func (repo *PostgresAccountRepository) GetPlayerById(id uint) (*models.Player, error) {
+	var players []*models.Player
+	err := repo.conn().Model(&models.Account{}).Select("id, nickname, sex, description, playing").Where("id = ?", id).Scan(&players).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(players) == 0 {
+		return nil, errors.RecordNotFound
+	}
+	return players[0], nil
+}
+
+func (repo *PostgresAccountRepository) GetDeletedByEmail(email string) (*models.Account, error) {
+	account := &models.Account{}
+	err := repo.conn().Unscoped().Where("email = ? AND deleted_at IS NOT NULL AND erased_at IS NULL", email).
+		Order("deleted_at desc").First(account).Error
+	return account, notFound(err)
+}
+
+func (repo *PostgresAccountRepository) Restore(account *models.Account) error {
+	err := repo.conn().Unscoped().Model(account).Update("deleted_at", nil).Error
+	if err == nil {
+		account.DeletedAt = nil
+	}
+	return err
+}
+
+func (repo *PostgresAccountRepository) GetToErase(deletedBefore time.Time) ([]*models.Account, error) {
+	accounts := []*models.Account{}
+	err := repo.conn().Unscoped().Where("deleted_at < ? AND erased_at IS NULL", deletedBefore).Order("id").Find(&accounts).Error
+	return accounts, err
+}
+
+// counted statistics are moved to the tombstone detached from their matches, frozen ones and the account's own events
+// are deleted and other rows of its matches stop pointing at it. Deleting the account cascades to friendships,
+// follows, notifications, achievements and memberships of lobbies, rsvps aren't linked to accounts so they are deleted by hand
+func (repo *PostgresAccountRepository) Erase(account *models.Account, tombstone *models.Account) (err error) {
+	tx := repo.conn().Begin()
+	if err = tx.Error; err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = tx.Create(tombstone).Error; err != nil {
+		return
+	}
+	statements := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{"DELETE FROM player_statistics_entries WHERE player_id = ? AND frozen", []interface{}{account.ID}},
+		{"UPDATE player_statistics_entries SET player_id = ?, lobby_id = 0 WHERE player_id = ?", []interface{}{tombstone.ID, account.ID}},
+		{"UPDATE team_entries SET player_id = 0, nickname = ? WHERE player_id = ?", []interface{}{tombstone.Nickname, account.ID}},
+		{"UPDATE result_records SET player_id = 0 WHERE player_id = ?", []interface{}{account.ID}},
+		{"DELETE FROM match_events WHERE player_id = ?", []interface{}{account.ID}},
+		{"UPDATE match_events SET recorded_by = 0 WHERE recorded_by = ?", []interface{}{account.ID}},
+		{"UPDATE lobbies SET owner_id = ? WHERE owner_id = ?", []interface{}{tombstone.ID, account.ID}},
+		{"UPDATE lobbies SET referee_id = ? WHERE referee_id = ?", []interface{}{tombstone.ID, account.ID}},
+		{"UPDATE tournaments SET owner_id = ? WHERE owner_id = ?", []interface{}{tombstone.ID, account.ID}},
+		{"UPDATE tournament_teams SET captain_id = ? WHERE captain_id = ?", []interface{}{tombstone.ID, account.ID}},
+		{"UPDATE tournament_members SET player_id = ? WHERE player_id = ?", []interface{}{tombstone.ID, account.ID}},
+		{"DELETE FROM rsvps WHERE player_id = ?", []interface{}{account.ID}},
+		{"DELETE FROM accounts WHERE id = ?", []interface{}{account.ID}},
+	}
+	for _, statement := range statements {
+		if err = tx.Exec(statement.sql, statement.args...).Error; err != nil {
+			return
+		}
+	}
+	return tx.Commit().Error
+}
+
+// translates gorm's not found error to the one used by repositories
+func notFound(err error) error {
+	if err == gorm.ErrRecordNotFound {
+		return errors.RecordNotFound
+	}
+	return err
+}
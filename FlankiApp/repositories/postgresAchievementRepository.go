@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"database/sql"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresAchievementRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresAchievementRepository(db *database.ApiDatabase) *PostgresAchievementRepository {
+	return &PostgresAchievementRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresAchievementRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+// unique index of player and badge leaves the achievement out when the player already has it,
+// no id is returned then
+func (repo *PostgresAchievementRepository) Award(achievement *models.Achievement) (bool, error) {
+	err := repo.conn().Set("gorm:insert_option", "ON CONFLICT (player_id, badge) DO NOTHING").Create(achievement).Error
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (repo *PostgresAchievementRepository) GetByPlayer(playerID uint) ([]models.Achievement, error) {
+	achievements := []models.Achievement{}
+	err := repo.conn().Where("player_id = ?", playerID).Order("id").Find(&achievements).Error
+	return achievements, err
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresFriendRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresFriendRepository(db *database.ApiDatabase) *PostgresFriendRepository {
+	return &PostgresFriendRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresFriendRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+func (repo *PostgresFriendRepository) SaveFriendship(friendship *models.Friendship) error {
+	return repo.conn().Save(friendship).Error
+}
+
+func (repo *PostgresFriendRepository) DeleteFriendship(friendship *models.Friendship) error {
+	return repo.conn().Delete(friendship).Error
+}
+
+func (repo *PostgresFriendRepository) GetFriendship(playerID uint, otherID uint) (*models.Friendship, error) {
+	friendship := &models.Friendship{}
+	err := repo.conn().Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)",
+		playerID, otherID, otherID, playerID).First(friendship).Error
+	return friendship, notFound(err)
+}
+
+func (repo *PostgresFriendRepository) GetFriendships(playerID uint) ([]models.Friendship, error) {
+	friendships := []models.Friendship{}
+	err := repo.conn().Where("requester_id = ? OR addressee_id = ?", playerID, playerID).Order("id").Find(&friendships).Error
+	return friendships, err
+}
+
+func (repo *PostgresFriendRepository) AddFollow(follow *models.Follow) error {
+	return repo.conn().Create(follow).Error
+}
+
+func (repo *PostgresFriendRepository) DeleteFollow(followerID uint, followeeID uint) error {
+	result := repo.conn().Where("follower_id = ? AND followee_id = ?", followerID, followeeID).Delete(&models.Follow{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.RecordNotFound
+	}
+	return nil
+}
+
+func (repo *PostgresFriendRepository) GetFollowing(followerID uint) ([]models.Follow, error) {
+	follows := []models.Follow{}
+	err := repo.conn().Where("follower_id = ?", followerID).Order("id").Find(&follows).Error
+	return follows, err
+}
+
+func (repo *PostgresFriendRepository) GetFollowers(followeeID uint) ([]models.Follow, error) {
+	follows := []models.Follow{}
+	err := repo.conn().Where("followee_id = ?", followeeID).Order("id").Find(&follows).Error
+	return follows, err
+}
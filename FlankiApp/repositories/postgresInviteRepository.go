@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresInviteRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresInviteRepository(db *database.ApiDatabase) *PostgresInviteRepository {
+	return &PostgresInviteRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresInviteRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+func (repo *PostgresInviteRepository) Create(invite *models.LobbyInvite) error {
+	return repo.conn().Create(invite).Error
+}
+
+func (repo *PostgresInviteRepository) Save(invite *models.LobbyInvite) error {
+	return repo.conn().Save(invite).Error
+}
+
+func (repo *PostgresInviteRepository) GetByCode(code string) (*models.LobbyInvite, error) {
+	invite := &models.LobbyInvite{}
+	err := repo.conn().Where("code = ?", code).First(invite).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return invite, nil
+}
+
+func (repo *PostgresInviteRepository) GetByLobby(lobbyID uint) ([]models.LobbyInvite, error) {
+	invites := []models.LobbyInvite{}
+	err := repo.conn().Where("lobby_id = ?", lobbyID).Order("id").Find(&invites).Error
+	return invites, err
+}
+
+// limit is checked by the same statement which counts the use, so concurrent joins can't exceed it
+func (repo *PostgresInviteRepository) Use(invite *models.LobbyInvite) (bool, error) {
+	result := repo.conn().Model(&models.LobbyInvite{}).
+		Where("id = ? AND revoked_at IS NULL AND (max_uses IS NULL OR uses < max_uses)", invite.ID).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	invite.Uses++
+	return true, nil
+}
+
+func (repo *PostgresInviteRepository) Release(invite *models.LobbyInvite) error {
+	err := repo.conn().Model(&models.LobbyInvite{}).Where("id = ? AND uses > 0", invite.ID).
+		UpdateColumn("uses", gorm.Expr("uses - 1")).Error
+	if err == nil && invite.Uses > 0 {
+		invite.Uses--
+	}
+	return err
+}
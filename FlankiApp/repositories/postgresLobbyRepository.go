@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type PostgresLobbyRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresLobbyRepository(db *database.ApiDatabase) *PostgresLobbyRepository {
+	return &PostgresLobbyRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresLobbyRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+// lobbies are always fetched with their teams, entries, co-owners and result history
+func (repo *PostgresLobbyRepository) preloaded() *gorm.DB {
+	return repo.conn().Preload("Teams.TeamEntries").Preload("CoOwners", func(db *gorm.DB) *gorm.DB {
+		return db.Order("lobby_co_owners.id")
+	}).Preload("ResultHistory", func(db *gorm.DB) *gorm.DB {
+		return db.Order("result_records.id")
+	})
+}
+
+func (repo *PostgresLobbyRepository) Create(lobby *models.Lobby) error {
+	return repo.conn().Create(lobby).Error
+}
+
+// gorm saves associations by default which would bring back entries deleted in the meantime
+func (repo *PostgresLobbyRepository) Save(lobby *models.Lobby) error {
+	return repo.conn().Set("gorm:save_associations", false).Save(lobby).Error
+}
+
+func (repo *PostgresLobbyRepository) Delete(lobby *models.Lobby) error {
+	return repo.conn().Unscoped().Delete(lobby).Error // player entries and teams are set to delete on CASCADE
+}
+
+func (repo *PostgresLobbyRepository) GetById(id uint) (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	err := repo.preloaded().First(lobby, id).Error
+	return lobby, notFound(err)
+}
+
+func (repo *PostgresLobbyRepository) GetOpenByOwner(ownerID uint) (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	err := repo.preloaded().Where("owner_id = ? AND closed = ?", ownerID, false).First(lobby).Error
+	return lobby, notFound(err)
+}
+
+func (repo *PostgresLobbyRepository) GetOpenByPlayer(playerID uint) (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	err := repo.preloaded().Joins("JOIN teams on lobbies.id = teams.lobby_id").
+		Joins("JOIN team_entries on teams.id = team_entries.team_id").
+		Where("team_entries.player_id = ? and lobbies.closed = false", playerID).
+		First(lobby).Error
+	return lobby, notFound(err)
+}
+
+func (repo *PostgresLobbyRepository) GetOpenByManager(playerID uint) ([]*models.Lobby, error) {
+	lobbies := []*models.Lobby{}
+	err := repo.preloaded().Where("closed = ? AND owner_id <> ? AND (referee_id = ? OR id IN (SELECT lobby_id FROM lobby_co_owners WHERE player_id = ?))",
+		false, playerID, playerID, playerID).Order("id").Find(&lobbies).Error
+	return lobbies, err
+}
+
+func (repo *PostgresLobbyRepository) GetAll(closed bool, limit int) ([]*models.Lobby, error) {
+	var lobbies []*models.Lobby
+	err := repo.preloaded().Where("closed = ?", closed).Order("updated_at desc").Limit(limit).Find(&lobbies).Error
+	return lobbies, err
+}
+
+func (repo *PostgresLobbyRepository) AddTeamEntry(team *models.Team, entry *models.TeamEntry) error {
+	entry.TeamID = team.ID
+	err := repo.conn().Create(entry).Error
+	if err != nil {
+		return err
+	}
+	team.TeamEntries = append(team.TeamEntries, *entry)
+	return nil
+}
+
+func (repo *PostgresLobbyRepository) DeleteTeamEntry(entry *models.TeamEntry) error {
+	return repo.conn().Delete(entry).Error
+}
+
+func (repo *PostgresLobbyRepository) SaveTeamEntry(entry *models.TeamEntry) error {
+	return repo.conn().Save(entry).Error
+}
+
+func (repo *PostgresLobbyRepository) MoveTeamEntry(entry *models.TeamEntry, team *models.Team) error {
+	err := repo.conn().Model(entry).Update("team_id", team.ID).Error
+	if err != nil {
+		return err
+	}
+	entry.TeamID = team.ID
+	return nil
+}
+
+func (repo *PostgresLobbyRepository) AddResultRecord(lobby *models.Lobby, record *models.ResultRecord) error {
+	record.LobbyID = lobby.ID
+	err := repo.conn().Create(record).Error
+	if err != nil {
+		return err
+	}
+	lobby.ResultHistory = append(lobby.ResultHistory, *record)
+	return nil
+}
+
+func (repo *PostgresLobbyRepository) AddCoOwner(lobby *models.Lobby, coOwner *models.LobbyCoOwner) error {
+	coOwner.LobbyID = lobby.ID
+	err := repo.conn().Create(coOwner).Error
+	if err != nil {
+		return err
+	}
+	lobby.CoOwners = append(lobby.CoOwners, *coOwner)
+	return nil
+}
+
+func (repo *PostgresLobbyRepository) DeleteCoOwner(coOwner *models.LobbyCoOwner) error {
+	return repo.conn().Delete(coOwner).Error
+}
+
+// updated_at is left untouched, lobbies aren't listed as recently updated whenever their owners show up
+func (repo *PostgresLobbyRepository) SeeOwner(ownerID uint, at time.Time, since time.Time) error {
+	return repo.conn().Model(&models.Lobby{}).
+		Where("owner_id = ? AND closed = ? AND (owner_seen_at IS NULL OR owner_seen_at < ?)", ownerID, false, since).
+		UpdateColumn("owner_seen_at", at).Error
+}
+
+func (repo *PostgresLobbyRepository) GetIdleOwned(seenBefore time.Time) ([]*models.Lobby, error) {
+	var lobbies []*models.Lobby
+	err := repo.preloaded().Where("closed = ? AND owner_seen_at < ?", false, seenBefore).Order("id").Find(&lobbies).Error
+	return lobbies, err
+}
+
+func (repo *PostgresLobbyRepository) GetStale(activeBefore time.Time) ([]*models.Lobby, error) {
+	var lobbies []*models.Lobby
+	err := repo.preloaded().Where("closed = ? AND updated_at < ? AND (owner_seen_at IS NULL OR owner_seen_at < ?) AND (starts_at IS NULL OR starts_at < ?)",
+		false, activeBefore, activeBefore, activeBefore).Order("id").Find(&lobbies).Error
+	return lobbies, err
+}
+
+func (repo *PostgresLobbyRepository) GetPendingResults(deadline time.Time) ([]*models.Lobby, error) {
+	var lobbies []*models.Lobby
+	err := repo.preloaded().Where("state = ? AND result_status = ? AND result_deadline < ?", models.Finished, models.ResultPending, deadline).
+		Order("id").Find(&lobbies).Error
+	return lobbies, err
+}
+
+func (repo *PostgresLobbyRepository) GetUpcoming(from time.Time, limit int) ([]*models.Lobby, error) {
+	var lobbies []*models.Lobby
+	err := repo.preloaded().Where("closed = ? AND starts_at > ?", false, from).Order("starts_at, id").Limit(limit).Find(&lobbies).Error
+	return lobbies, err
+}
+
+func (repo *PostgresLobbyRepository) GetToRemind(until time.Time) ([]*models.Lobby, error) {
+	var lobbies []*models.Lobby
+	err := repo.preloaded().Where("closed = ? AND starts_at < ? AND reminded_at IS NULL", false, until).Order("starts_at, id").Find(&lobbies).Error
+	return lobbies, err
+}
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresMatchEventRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresMatchEventRepository(db *database.ApiDatabase) *PostgresMatchEventRepository {
+	return &PostgresMatchEventRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresMatchEventRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+func (repo *PostgresMatchEventRepository) Create(event *models.MatchEvent) error {
+	return repo.conn().Create(event).Error
+}
+
+func (repo *PostgresMatchEventRepository) GetByLobbies(lobbyIDs ...uint) ([]models.MatchEvent, error) {
+	events := []models.MatchEvent{}
+	if len(lobbyIDs) == 0 {
+		return events, nil
+	}
+	err := repo.conn().Where("lobby_id IN (?)", lobbyIDs).Order("id").Find(&events).Error
+	return events, err
+}
+
+func (repo *PostgresMatchEventRepository) GetByPlayer(playerID uint) ([]models.MatchEvent, error) {
+	events := []models.MatchEvent{}
+	err := repo.conn().Joins("JOIN lobbies on lobbies.id = match_events.lobby_id").
+		Where("match_events.player_id = ? AND lobbies.state = ?", playerID, models.Finished).
+		Order("match_events.id").Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type PostgresNotificationRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresNotificationRepository(db *database.ApiDatabase) *PostgresNotificationRepository {
+	return &PostgresNotificationRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresNotificationRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+func (repo *PostgresNotificationRepository) Create(notification *models.Notification) error {
+	return repo.conn().Create(notification).Error
+}
+
+func (repo *PostgresNotificationRepository) GetByAccount(accountID uint, unreadOnly bool, limit int) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	query := repo.conn().Where("account_id = ?", accountID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	err := query.Order("id desc").Limit(limit).Find(&notifications).Error
+	return notifications, err
+}
+
+func (repo *PostgresNotificationRepository) CountUnread(accountID uint) (int, error) {
+	unread := 0
+	err := repo.conn().Model(&models.Notification{}).Where("account_id = ? AND read_at IS NULL", accountID).Count(&unread).Error
+	return unread, err
+}
+
+func (repo *PostgresNotificationRepository) MarkRead(accountID uint, ids []uint, at time.Time) (int, error) {
+	query := repo.conn().Model(&models.Notification{}).Where("account_id = ? AND read_at IS NULL", accountID)
+	if len(ids) > 0 {
+		query = query.Where("id IN (?)", ids)
+	}
+	result := query.Update("read_at", at)
+	return int(result.RowsAffected), result.Error
+}
+
+func (repo *PostgresNotificationRepository) GetPreferences(accountID uint) ([]models.NotificationPreference, error) {
+	preferences := []models.NotificationPreference{}
+	err := repo.conn().Where("account_id = ?", accountID).Order("id").Find(&preferences).Error
+	return preferences, err
+}
+
+// preferences are unique by account and type, the previous one is updated in place
+func (repo *PostgresNotificationRepository) SavePreference(preference *models.NotificationPreference) error {
+	return repo.conn().Where(models.NotificationPreference{AccountID: preference.AccountID, Type: preference.Type}).
+		Assign(models.NotificationPreference{InApp: preference.InApp, Email: preference.Email, Push: preference.Push}).
+		FirstOrCreate(preference).Error
+}
+
+// endpoints are unique, browser subscribing again for another account moves its subscription there
+func (repo *PostgresNotificationRepository) SavePushSubscription(subscription *models.PushSubscription) error {
+	return repo.conn().Where(models.PushSubscription{Endpoint: subscription.Endpoint}).
+		Assign(models.PushSubscription{AccountID: subscription.AccountID, P256dh: subscription.P256dh, Auth: subscription.Auth}).
+		FirstOrCreate(subscription).Error
+}
+
+func (repo *PostgresNotificationRepository) GetPushSubscriptions(accountID uint) ([]models.PushSubscription, error) {
+	subscriptions := []models.PushSubscription{}
+	err := repo.conn().Where("account_id = ?", accountID).Order("id").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (repo *PostgresNotificationRepository) DeletePushSubscription(accountID uint, endpoint string) error {
+	result := repo.conn().Where("account_id = ? AND endpoint = ?", accountID, endpoint).Delete(&models.PushSubscription{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.RecordNotFound
+	}
+	return nil
+}
+
+func (repo *PostgresNotificationRepository) ReplaceBody(notificationType models.NotificationType, body string, replacement string) (int, error) {
+	result := repo.conn().Model(&models.Notification{}).Where("type = ? AND body = ?", notificationType, body).Update("body", replacement)
+	return int(result.RowsAffected), result.Error
+}
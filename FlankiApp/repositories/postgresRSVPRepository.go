@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresRSVPRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresRSVPRepository(db *database.ApiDatabase) *PostgresRSVPRepository {
+	return &PostgresRSVPRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresRSVPRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+// responses are unique by lobby and player, the previous response is updated in place
+func (repo *PostgresRSVPRepository) Save(rsvp *models.RSVP) error {
+	return repo.conn().Where(models.RSVP{LobbyID: rsvp.LobbyID, PlayerID: rsvp.PlayerID}).
+		Assign(models.RSVP{Nickname: rsvp.Nickname, Response: rsvp.Response}).
+		FirstOrCreate(rsvp).Error
+}
+
+func (repo *PostgresRSVPRepository) GetByLobby(lobbyID uint) ([]models.RSVP, error) {
+	rsvps := []models.RSVP{}
+	err := repo.conn().Where("lobby_id = ?", lobbyID).Order("id").Find(&rsvps).Error
+	return rsvps, err
+}
+
+func (repo *PostgresRSVPRepository) GetByPlayer(playerID uint) ([]models.RSVP, error) {
+	rsvps := []models.RSVP{}
+	err := repo.conn().Where("player_id = ?", playerID).Order("id").Find(&rsvps).Error
+	return rsvps, err
+}
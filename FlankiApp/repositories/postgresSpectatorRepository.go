@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"database/sql"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresSpectatorRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresSpectatorRepository(db *database.ApiDatabase) *PostgresSpectatorRepository {
+	return &PostgresSpectatorRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresSpectatorRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+// unique index of lobby and player leaves the spectator out when the player already watches the lobby,
+// no id is returned then
+func (repo *PostgresSpectatorRepository) Add(spectator *models.LobbySpectator) (bool, error) {
+	err := repo.conn().Set("gorm:insert_option", "ON CONFLICT (lobby_id, player_id) DO NOTHING").Create(spectator).Error
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (repo *PostgresSpectatorRepository) Remove(lobbyID uint, playerID uint) (bool, error) {
+	result := repo.conn().Where("lobby_id = ? AND player_id = ?", lobbyID, playerID).Delete(&models.LobbySpectator{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repo *PostgresSpectatorRepository) GetByLobby(lobbyID uint) ([]models.LobbySpectator, error) {
+	spectators := []models.LobbySpectator{}
+	err := repo.conn().Where("lobby_id = ?", lobbyID).Order("id").Find(&spectators).Error
+	return spectators, err
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresStatisticsRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresStatisticsRepository(db *database.ApiDatabase) *PostgresStatisticsRepository {
+	return &PostgresStatisticsRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresStatisticsRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+func (repo *PostgresStatisticsRepository) Create(entry *models.PlayerStatisticsEntry) error {
+	return repo.conn().Create(entry).Error
+}
+
+func (repo *PostgresStatisticsRepository) SetFrozen(lobbyID uint, frozen bool) error {
+	return repo.conn().Model(&models.PlayerStatisticsEntry{}).Where("lobby_id = ?", lobbyID).Update("frozen", frozen).Error
+}
+
+func (repo *PostgresStatisticsRepository) DeleteByLobby(lobbyID uint) error {
+	return repo.conn().Where("lobby_id = ?", lobbyID).Delete(&models.PlayerStatisticsEntry{}).Error
+}
+
+func (repo *PostgresStatisticsRepository) GetSummary(playerID uint) (*models.PlayerSummary, error) {
+	summary := &models.PlayerSummary{}
+	query := `select ?::bigint as player_id,
+						count(case when win = true then 1 end) as wins,
+						count(case when win = false then 1 end) as loses,
+						coalesce(sum(points), 0) as points from player_statistics_entries
+						where player_id = ? and frozen = false`
+	err := repo.conn().Raw(query, playerID, playerID).Scan(summary).Error
+	return summary, err
+}
+
+func (repo *PostgresStatisticsRepository) GetRanking() ([]models.PlayerSummary, error) {
+	var summaries []models.PlayerSummary
+	query := `select player_id, (select nickname from accounts where id = player_id),
+						sum(case when win = true then 1 else 0 end) as wins,
+						sum(case when win = false then 1 else 0 end) as loses,
+						sum(points) as points from player_statistics_entries
+						where frozen = false
+						group by player_id
+						order by points desc`
+	err := repo.conn().Raw(query).Scan(&summaries).Error
+	return summaries, err
+}
+
+func (repo *PostgresStatisticsRepository) GetByPlayer(playerID uint) ([]models.PlayerStatisticsEntry, error) {
+	entries := []models.PlayerStatisticsEntry{}
+	err := repo.conn().Where("player_id = ? AND frozen = false", playerID).Order("id").Find(&entries).Error
+	return entries, err
+}
+
+func (repo *PostgresStatisticsRepository) GetAllByPlayer(playerID uint) ([]models.PlayerStatisticsEntry, error) {
+	entries := []models.PlayerStatisticsEntry{}
+	err := repo.conn().Where("player_id = ?", playerID).Order("id").Find(&entries).Error
+	return entries, err
+}
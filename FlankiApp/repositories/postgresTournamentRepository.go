@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+type PostgresTournamentRepository struct {
+	db  *database.ApiDatabase
+	ctx context.Context
+}
+
+func NewPostgresTournamentRepository(db *database.ApiDatabase) *PostgresTournamentRepository {
+	return &PostgresTournamentRepository{db: db}
+}
+
+// current connection, bound to the context of served request if there is any
+func (repo *PostgresTournamentRepository) conn() *gorm.DB {
+	return connection(repo.db, repo.ctx)
+}
+
+// tournaments are always fetched with their teams, members and matches
+func (repo *PostgresTournamentRepository) preloaded() *gorm.DB {
+	return repo.conn().Preload("Teams", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tournament_teams.id")
+	}).Preload("Teams.Members").Preload("Matches", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tournament_matches.number")
+	})
+}
+
+func (repo *PostgresTournamentRepository) Create(tournament *models.Tournament) error {
+	return repo.conn().Create(tournament).Error
+}
+
+// gorm saves associations by default which would overwrite teams and matches changed in the meantime
+func (repo *PostgresTournamentRepository) Save(tournament *models.Tournament) error {
+	return repo.conn().Set("gorm:save_associations", false).Save(tournament).Error
+}
+
+func (repo *PostgresTournamentRepository) GetById(id uint) (*models.Tournament, error) {
+	tournament := &models.Tournament{}
+	err := repo.preloaded().First(tournament, id).Error
+	return tournament, notFound(err)
+}
+
+func (repo *PostgresTournamentRepository) GetByMatch(matchID uint) (*models.Tournament, error) {
+	tournament := &models.Tournament{}
+	err := repo.preloaded().Joins("JOIN tournament_matches on tournaments.id = tournament_matches.tournament_id").
+		Where("tournament_matches.id = ?", matchID).
+		First(tournament).Error
+	return tournament, notFound(err)
+}
+
+func (repo *PostgresTournamentRepository) GetAll(limit int) ([]*models.Tournament, error) {
+	var tournaments []*models.Tournament
+	err := repo.preloaded().Order("created_at desc").Limit(limit).Find(&tournaments).Error
+	return tournaments, err
+}
+
+func (repo *PostgresTournamentRepository) GetWonBy(playerID uint) ([]*models.Tournament, error) {
+	var tournaments []*models.Tournament
+	err := repo.preloaded().Joins("JOIN tournament_members on tournament_members.tournament_team_id = tournaments.winner_id").
+		Where("tournaments.state = ? AND tournament_members.player_id = ?", models.TournamentFinished, playerID).
+		Order("tournaments.id").Find(&tournaments).Error
+	return tournaments, err
+}
+
+func (repo *PostgresTournamentRepository) AddTeam(tournament *models.Tournament, team *models.TournamentTeam) error {
+	team.TournamentID = tournament.ID
+	err := repo.conn().Create(team).Error // members are created with the team
+	if err != nil {
+		return err
+	}
+	tournament.Teams = append(tournament.Teams, *team)
+	return nil
+}
+
+func (repo *PostgresTournamentRepository) SaveTeam(team *models.TournamentTeam) error {
+	return repo.conn().Set("gorm:save_associations", false).Save(team).Error
+}
+
+func (repo *PostgresTournamentRepository) CreateMatches(tournament *models.Tournament) (err error) {
+	tx := repo.conn().Begin()
+	if err = tx.Error; err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	for i := range tournament.Matches {
+		tournament.Matches[i].TournamentID = tournament.ID
+		if err = tx.Create(&tournament.Matches[i]).Error; err != nil {
+			return
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (repo *PostgresTournamentRepository) SaveMatch(match *models.TournamentMatch) error {
+	return repo.conn().Save(match).Error
+}
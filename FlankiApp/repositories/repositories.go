@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"FlankiRest/database"
+	"FlankiRest/models"
+	"FlankiRest/tracing"
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+// Repositories groups every repository used by controllers and services
+type Repositories struct {
+	Accounts      models.AccountRepository
+	Lobbies       models.LobbyRepository
+	Statistics    models.StatisticsRepository
+	Events        models.MatchEventRepository
+	Tournaments   models.TournamentRepository
+	RSVPs         models.RSVPRepository
+	Friends       models.FriendRepository
+	Notifications models.NotificationRepository
+	Achievements  models.AchievementRepository
+	Invites       models.InviteRepository
+	Spectators    models.SpectatorRepository
+
+	// binds repositories to request's context, nil when they don't make use of it
+	bind func(ctx context.Context) *Repositories
+}
+
+// WithContext returns repositories bound to the context of served request so that their queries are traced as its part
+func (repos *Repositories) WithContext(ctx context.Context) *Repositories {
+	if repos.bind == nil {
+		return repos
+	}
+	return repos.bind(ctx)
+}
+
+// repositories backed by postgres, they always use current connection kept by ApiDatabase
+// so reconnecting with database doesn't require creating them again
+func NewPostgresRepositories(db *database.ApiDatabase) *Repositories {
+	repos := &Repositories{
+		Accounts:      NewPostgresAccountRepository(db),
+		Lobbies:       NewPostgresLobbyRepository(db),
+		Statistics:    NewPostgresStatisticsRepository(db),
+		Events:        NewPostgresMatchEventRepository(db),
+		Tournaments:   NewPostgresTournamentRepository(db),
+		RSVPs:         NewPostgresRSVPRepository(db),
+		Friends:       NewPostgresFriendRepository(db),
+		Notifications: NewPostgresNotificationRepository(db),
+		Achievements:  NewPostgresAchievementRepository(db),
+		Invites:       NewPostgresInviteRepository(db),
+		Spectators:    NewPostgresSpectatorRepository(db),
+	}
+	repos.bind = func(ctx context.Context) *Repositories {
+		return &Repositories{
+			Accounts:      &PostgresAccountRepository{db: db, ctx: ctx},
+			Lobbies:       &PostgresLobbyRepository{db: db, ctx: ctx},
+			Statistics:    &PostgresStatisticsRepository{db: db, ctx: ctx},
+			Events:        &PostgresMatchEventRepository{db: db, ctx: ctx},
+			Tournaments:   &PostgresTournamentRepository{db: db, ctx: ctx},
+			RSVPs:         &PostgresRSVPRepository{db: db, ctx: ctx},
+			Friends:       &PostgresFriendRepository{db: db, ctx: ctx},
+			Notifications: &PostgresNotificationRepository{db: db, ctx: ctx},
+			Achievements:  &PostgresAchievementRepository{db: db, ctx: ctx},
+			Invites:       &PostgresInviteRepository{db: db, ctx: ctx},
+			Spectators:    &PostgresSpectatorRepository{db: db, ctx: ctx},
+			bind:          repos.bind,
+		}
+	}
+	return repos
+}
+
+func connection(db *database.ApiDatabase, ctx context.Context) *gorm.DB {
+	return tracing.WithContext(db.DB(), ctx)
+}
+
+// repositories keeping everything in memory, meant for tests
+func NewMemoryRepositories() *Repositories {
+	store := NewMemoryStore()
+	return &Repositories{
+		Accounts:      NewMemoryAccountRepository(store),
+		Lobbies:       NewMemoryLobbyRepository(store),
+		Statistics:    NewMemoryStatisticsRepository(store),
+		Events:        NewMemoryMatchEventRepository(store),
+		Tournaments:   NewMemoryTournamentRepository(store),
+		RSVPs:         NewMemoryRSVPRepository(store),
+		Friends:       NewMemoryFriendRepository(store),
+		Notifications: NewMemoryNotificationRepository(store),
+		Achievements:  NewMemoryAchievementRepository(store),
+		Invites:       NewMemoryInviteRepository(store),
+		Spectators:    NewMemorySpectatorRepository(store),
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"archive/zip"
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// table in which authorization server keeps issued tokens, it shares the database with the app
+const tokensTable = "oauth2_token"
+
+// RevokeTokens deletes all tokens issued to the account so that it is logged out everywhere
+func RevokeTokens(db *gorm.DB, accountID uint) error {
+	if !db.HasTable(tokensTable) {
+		return nil
+	}
+	if err := db.Exec("DELETE FROM "+tokensTable+" WHERE user_id = ?", strconv.Itoa(int(accountID))).Error; err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+// EraseAuthData deletes everything the app and authorization server keep to authenticate the account,
+// failed logins are counted by lowercased email just like authorization server does
+func EraseAuthData(db *gorm.DB, account *models.Account) error {
+	if err := RevokeTokens(db, account.ID); err != nil {
+		return err
+	}
+	if db.HasTable("login_failures") {
+		if err := db.Exec("DELETE FROM login_failures WHERE email = ?", strings.ToLower(strings.TrimSpace(account.Email))).Error; err != nil {
+			return errors.DatabaseError(err)
+		}
+	}
+	if err := db.Where("account_id = ?", account.ID).Delete(&PasswordReset{}).Error; err != nil {
+		return errors.DatabaseError(err)
+	}
+	return nil
+}
+
+const exportReadme = `This archive contains all the data Flanki keeps about your account:
+
+profile.json             your account without the password
+statistics.json          your summary and statistics of every match, frozen ones wait for their results to be confirmed
+matches.json             lobbies of these matches together with their teams and results
+events.json              match events recorded for you
+achievements.json        badges you have been awarded
+friendships.json         your friends and friend requests sent by you or to you
+follows.json             players you follow and players following you
+rsvps.json               your responses to scheduled lobbies
+notifications.json       all your notifications
+push_subscriptions.json  browsers subscribed to your push notifications
+chat_messages.json       messages you have sent in the chat together with their rooms
+avatar.*                 your avatar, when you have uploaded one
+`
+
+// WriteAccountExport writes ZIP archive of the export, avatar is left out when it is empty
+func WriteAccountExport(w io.Writer, export *models.AccountExport, avatar []byte, avatarType string) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", export.Account},
+		{"statistics.json", map[string]interface{}{"summary": export.Summary, "matches": export.Statistics}},
+		{"matches.json", export.Matches},
+		{"events.json", export.Events},
+		{"achievements.json", export.Achievements},
+		{"friendships.json", export.Friendships},
+		{"follows.json", map[string]interface{}{"following": export.Following, "followers": export.Followers}},
+		{"rsvps.json", export.RSVPs},
+		{"notifications.json", export.Notifications},
+		{"push_subscriptions.json", export.PushSubscriptions},
+		{"chat_messages.json", export.ChatMessages},
+	}
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return err
+		}
+	}
+	if len(avatar) > 0 {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: "avatar" + avatarExtension(avatarType), Method: zip.Store, Modified: export.ExportedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(avatar); err != nil {
+			return err
+		}
+	}
+	f, err := archive.CreateHeader(&zip.FileHeader{Name: "README.txt", Method: zip.Deflate, Modified: export.ExportedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, exportReadme); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// image server accepts only png and jpeg avatars
+func avatarExtension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/errors"
+	"FlankiRest/logger"
+	"FlankiRest/models"
+	"FlankiRest/tracing"
+	"FlankiRest/utils"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// ChatService reaches the chat on behalf of the user, requests carry the user's own access token
+type ChatService struct {
+	Cfg *config.ChatServerConfig
+}
+
+// GetUserMessages returns all messages the user has sent in the chat
+func (service *ChatService) GetUserMessages(ctx context.Context, token string) ([]models.ChatMessage, error) {
+	resp, err := service.do(ctx, http.MethodGet, token)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	messages := []models.ChatMessage{}
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, errors.New("ChatService error: "+err.Error(), 500)
+	}
+	return messages, nil
+}
+
+// DeleteUserMessages removes all messages the user has sent in the chat
+func (service *ChatService) DeleteUserMessages(ctx context.Context, token string) error {
+	resp, err := service.do(ctx, http.MethodDelete, token)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends request to the user's messages, response of other status than 200 is turned into an error
+func (service *ChatService) do(ctx context.Context, method string, token string) (*http.Response, error) {
+	url := service.Cfg.Domain + ":" + service.Cfg.Port + "/chat/messages"
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, errors.New("ChatService error: "+err.Error(), 500)
+	}
+	request.Header.Set("Authorization", token)
+	resp, err := tracing.HTTPClient.Do(request)
+	if err != nil {
+		return nil, errors.New("ChatService error: "+err.Error(), 500)
+	}
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, errors.New("Chat error: "+utils.DecodeErrMessage(body).Message, http.StatusBadGateway)
+	}
+	return resp, nil
+}
+
+var chatServiceInstance *ChatService
+var chatServiceOnce sync.Once
+
+func GetChatService() *ChatService {
+	chatServiceOnce.Do(func() {
+		cfg := config.GetChatServerConfig()
+		if cfg.Domain == "" || cfg.Port == "" {
+			logger.GetGlobalLogger().Warn("Lacking Chat Server configuration environmental variables")
+		}
+		chatServiceInstance = &ChatService{cfg}
+	})
+	return chatServiceInstance
+}
@@ -1,18 +1,19 @@
 package services
 
 import (
-	"FlankiRest/logger"
+	"FlankiRest/config"
 	"crypto/tls"
-	"github.com/joeshaw/envdecode"
 	"gopkg.in/gomail.v2"
 	"io"
 	"io/ioutil"
 )
 
-const SMTPServer  = "smtp.gmail.com"
-const SMTPPort    = 587
+// Mailer delivers emails, by default it is app's MailAuth sending them through SMTP server
+type Mailer interface {
+	SendEmail(sender EmailSender, useDefaultEmail bool) error
+}
 
-var appEmailInstance *MailAuth
+var mailer Mailer
 
 type EmailSender struct {
 	From string
@@ -22,20 +23,28 @@ type EmailSender struct {
 }
 
 type MailAuth struct {
-	Username string `env:"APP_EMAIL,required"`
-	Password string `env:"APP_EMAIL_PASSWORD,required"`
+	Username   string
+	Password   string
+	SMTPServer string
+	SMTPPort   int
 }
 
-func init() {
-	appEmailInstance = &MailAuth{}
-	err := envdecode.Decode(appEmailInstance)
-	if err != nil {
-		logger.GetGlobalLogger().WithField("prefix", "[EMAIL SERVICE]").Fatal(err.Error())
+// GetAppMailAuth returns account of the app from its email config
+func GetAppMailAuth() *MailAuth {
+	cfg := config.GetEmailConfig()
+	return &MailAuth{Username: cfg.Address, Password: cfg.Password, SMTPServer: cfg.SMTPServer, SMTPPort: cfg.SMTPPort}
+}
+
+func GetMailer() Mailer {
+	if mailer == nil {
+		return GetAppMailAuth()
 	}
+	return mailer
 }
 
-func GetAppMailAuth() *MailAuth {
-	return appEmailInstance
+// replaces the way emails are delivered, e.g. with a fake mailer in tests
+func SetMailer(m Mailer) {
+	mailer = m
 }
 
 func (auth MailAuth) SendEmail(sender EmailSender, useDefaultEmail bool) error {
@@ -60,7 +69,7 @@ func (auth MailAuth) SendEmail(sender EmailSender, useDefaultEmail bool) error {
 	}
 	m.SetBody("text/html", string(b))
 
-	d := gomail.NewDialer(SMTPServer, SMTPPort, auth.Username, auth.Password)
+	d := gomail.NewDialer(auth.SMTPServer, auth.SMTPPort, auth.Username, auth.Password)
 	d.TLSConfig = &tls.Config{InsecureSkipVerify: true} // temporary because it doesnt work on linux
 	return d.DialAndSend(m)
 }
@@ -4,7 +4,9 @@ import (
 	"FlankiRest/config"
 	"FlankiRest/errors"
 	"FlankiRest/logger"
+	"FlankiRest/tracing"
 	"FlankiRest/utils"
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -16,10 +18,15 @@ type ImageService struct {
 	Cfg *config.ImageServerConfig
 }
 
-func (service *ImageService) GetUserImageById(id int) (img []byte, contentType string, err error) {
+func (service *ImageService) GetUserImageById(ctx context.Context, id int) (img []byte, contentType string, err error) {
 
 	url := service.Cfg.Domain + ":" + service.Cfg.Port + "/images/" + strconv.Itoa(id)
-	resp, err := http.Get(url)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		err = errors.New("ImageService error: " + err.Error(), 500)
+		return
+	}
+	resp, err := tracing.HTTPClient.Do(request)
 	if err != nil {
 		err = errors.New("ImageService error: " + err.Error(), 500)
 		return
@@ -45,9 +52,14 @@ func (service *ImageService) GetUserImageById(id int) (img []byte, contentType s
 	return
 }
 
-func (service *ImageService) UploadImageById(id int, contentType string, img io.Reader) error {
+func (service *ImageService) UploadImageById(ctx context.Context, id int, contentType string, img io.Reader) error {
 	url := service.Cfg.Domain + ":" + service.Cfg.Port + "/upload/" + strconv.Itoa(id)
-	resp, err := http.Post(url,contentType, img)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, img)
+	if err != nil {
+		return errors.New("Error while uploading image: " + err.Error(), 500)
+	}
+	request.Header.Set("Content-Type", contentType)
+	resp, err := tracing.HTTPClient.Do(request)
 
 	if err != nil {
 		return errors.New("Error while uploading image: " + err.Error(), 500)
@@ -61,6 +73,28 @@ func (service *ImageService) UploadImageById(id int, contentType string, img io.
 	return nil
 }
 
+// DeleteUserImageById deletes the avatar, errors with 404 code when there was none
+func (service *ImageService) DeleteUserImageById(ctx context.Context, id int) error {
+	url := service.Cfg.Domain + ":" + service.Cfg.Port + "/images/" + strconv.Itoa(id)
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return errors.New("ImageService error: " + err.Error(), 500)
+	}
+	resp, err := tracing.HTTPClient.Do(request)
+	if err != nil {
+		return errors.New("ImageService error: " + err.Error(), 500)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return errors.New("Image not found", 404)
+	}
+	if resp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.New(utils.DecodeErrMessage(body).Message, resp.StatusCode)
+	}
+	return nil
+}
+
 var imgServiceInstance *ImageService
 var imgServiceOnce 		sync.Once
 
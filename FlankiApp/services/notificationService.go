@@ -0,0 +1,38 @@
+package services
+
+import (
+	"FlankiRest/metrics"
+	"FlankiRest/models"
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+const notificationTemplate = "notification.txt"
+
+type NotificationTemplate struct {
+	Nickname string
+	Title    string
+	Body     string
+}
+
+// SendNotificationEmail emails the notification to the account, failures are counted and returned to the caller
+func SendNotificationEmail(account *models.Account, notification *models.Notification) error {
+	tmpl, err := template.ParseFiles(TemplatesDirectory + "/" + notificationTemplate)
+	if err != nil {
+		return fmt.Errorf("Error while parsing template: %s", err)
+	}
+	var buffer bytes.Buffer
+	data := NotificationTemplate{Nickname: account.Nickname, Title: notification.Title, Body: notification.Body}
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return fmt.Errorf("Error while filling template: %s", err)
+	}
+
+	sender := EmailSender{To: []string{account.Email}, Subject: notification.Title, Body: &buffer}
+	if err := GetMailer().SendEmail(sender, true); err != nil {
+		metrics.EmailsSent.WithLabelValues("notification", "error").Inc()
+		return err
+	}
+	metrics.EmailsSent.WithLabelValues("notification", "ok").Inc()
+	return nil
+}
@@ -1,18 +1,33 @@
 package services
 
 import (
+	"FlankiRest/config"
 	"FlankiRest/errors"
 	"FlankiRest/logger"
+	"FlankiRest/metrics"
 	"FlankiRest/models"
+	"FlankiRest/validation"
 	"bytes"
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 	"html/template"
-	"os"
 	"time"
 )
 
+// directory containing email templates
+var TemplatesDirectory = "./templates"
+
+const passwordResetTemplate = "passwordReset.txt"
+
+// codes sent in password reset emails can be used for this long
+const PasswordResetValidity = 15 * time.Minute
+
+// TemplateFiles lists paths of all templates the app needs to send its emails
+func TemplateFiles() []string {
+	return []string{TemplatesDirectory + "/" + passwordResetTemplate, TemplatesDirectory + "/" + notificationTemplate}
+}
+
 type ResetModel struct {
 	ID        uint `gorm:"primary_key"`
 	CreatedAt time.Time
@@ -30,16 +45,15 @@ type PasswordResetTemplate struct {
 }
 
 type ResetRequest struct {
-	Code        string `json:"code"`
-	NewPassword string `json:"new_password"`
+	Code        string `json:"code" validate:"required"`
+	NewPassword string `json:"new_password" validate:"min=6,max=32"`
 }
 
-func EmailPasswordResetRequest(db *gorm.DB, email string) error {
+func EmailPasswordResetRequest(db *gorm.DB, accounts models.AccountRepository, email string) error {
 
-	account := &models.Account{}
-	err := db.Model(account).Where("email = ?", email).Select("id, nickname, email").First(account).Error
+	account, err := accounts.GetByEmail(email)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if err == errors.RecordNotFound {
 			return errors.New("Email '"+email+"' has not been found", 400)
 		}
 		return errors.DatabaseError(err)
@@ -58,13 +72,13 @@ func EmailPasswordResetRequest(db *gorm.DB, email string) error {
 	if err != nil {
 		return errors.DatabaseError(err)
 	}
-	domain, found := os.LookupEnv("RESET_PASSWORD_DOMAIN")
-	if !found {
+	domain := config.GetEmailConfig().ResetPasswordDomain
+	if domain == "" {
 		return errors.New("There is no front end domain to which redirect the user", 500)
 	}
 	data := PasswordResetTemplate{account.Nickname, domain + "/" + resetEntry.Code}
 
-	tmpl, err := template.ParseFiles("./templates/passwordReset.txt")
+	tmpl, err := template.ParseFiles(TemplatesDirectory + "/" + passwordResetTemplate)
 	if err != nil {
 		return errors.New("Error while parsing template: "+err.Error(), 500)
 	}
@@ -75,21 +89,28 @@ func EmailPasswordResetRequest(db *gorm.DB, email string) error {
 		return errors.New("Error while filling template: "+err.Error(), 500)
 	}
 
-	mailAuth := GetAppMailAuth()
+	mailer := GetMailer()
 	sender := EmailSender{To: []string{email}, Subject: "Password reset", Body: &buffer}
 
 	go func() {
-		err = mailAuth.SendEmail(sender, true)
+		err := mailer.SendEmail(sender, true)
 		if err != nil {
+			metrics.EmailsSent.WithLabelValues("password_reset", "error").Inc()
 			logger.GetGlobalLogger().WithField("prefix", "[EMAIL SERVICE]").Error(err.Error())
+			return
 		}
+		metrics.EmailsSent.WithLabelValues("password_reset", "ok").Inc()
 	}()
 	return nil
 }
 
-func ResetPassword(db *gorm.DB, request ResetRequest) error {
+func ResetPassword(db *gorm.DB, accounts models.AccountRepository, request ResetRequest) error {
+	if err := validation.Check(request); err != nil {
+		return err
+	}
+
 	resetEntry := &PasswordReset{}
-	allowed_time := time.Now().Add(time.Minute * time.Duration(-15))
+	allowed_time := time.Now().Add(-PasswordResetValidity)
 	err := db.Model(resetEntry).Where("code = ? and created_at > ?", request.Code, allowed_time).First(resetEntry).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -98,22 +119,17 @@ func ResetPassword(db *gorm.DB, request ResetRequest) error {
 		return errors.DatabaseError(err)
 	}
 
-	account := &models.Account{}
-	err = db.Model(account).Where("id = ?", resetEntry.AccountID).First(account).Error
+	account, err := accounts.GetById(resetEntry.AccountID)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if err == errors.RecordNotFound {
 			return errors.New("account has not been found", 404)
 		}
 		return errors.DatabaseError(err)
 	}
 
-	if size := len(request.NewPassword); size < 6 || size > 32 {
-		return errors.New("Invalid password size, range 6 to 32", 400)
-	}
-
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
 	account.Password = string(hashedPassword)
-	err = db.Save(account).Error
+	err = accounts.Save(account)
 	if err != nil {
 		return errors.DatabaseError(err)
 	}
@@ -123,3 +139,11 @@ func ResetPassword(db *gorm.DB, request ResetRequest) error {
 
 }
 
+// PurgeExpiredPasswordResets deletes codes which can't be used anymore, returns how many of them were deleted
+func PurgeExpiredPasswordResets(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Where("created_at <= ?", now.Add(-PasswordResetValidity)).Delete(&PasswordReset{})
+	if result.Error != nil {
+		return 0, errors.DatabaseError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	contextSetting = "tracing:context"
+	spanSetting    = "tracing:span"
+)
+
+// WithContext binds queries made with returned db to the context of served request,
+// queries of db which is not bound to any context are not traced
+func WithContext(db *gorm.DB, ctx context.Context) *gorm.DB {
+	if db == nil || ctx == nil {
+		return db
+	}
+	return db.Set(contextSetting, ctx)
+}
+
+// InstrumentGorm wraps gorm's queries in spans, callbacks are kept per connection
+// so it has to be called again after reconnecting
+func InstrumentGorm(db *gorm.DB) {
+	callback := db.Callback()
+	callback.Create().Before("gorm:begin_transaction").Register("tracing:before_create", startSpan("create"))
+	callback.Create().After("gorm:commit_or_rollback_transaction").Register("tracing:after_create", endSpan)
+	callback.Query().Before("gorm:query").Register("tracing:before_query", startSpan("query"))
+	callback.Query().After("gorm:after_query").Register("tracing:after_query", endSpan)
+	callback.RowQuery().Before("gorm:row_query").Register("tracing:before_row_query", startSpan("row_query"))
+	callback.RowQuery().After("gorm:row_query").Register("tracing:after_row_query", endSpan)
+	callback.Update().Before("gorm:assign_updating_attributes").Register("tracing:before_update", startSpan("update"))
+	callback.Update().After("gorm:commit_or_rollback_transaction").Register("tracing:after_update", endSpan)
+	callback.Delete().Before("gorm:begin_transaction").Register("tracing:before_delete", startSpan("delete"))
+	callback.Delete().After("gorm:commit_or_rollback_transaction").Register("tracing:after_delete", endSpan)
+}
+
+func startSpan(operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		value, ok := scope.Get(contextSetting)
+		if !ok {
+			return
+		}
+		ctx, ok := value.(context.Context)
+		if !ok {
+			return
+		}
+		table := scope.TableName()
+		_, span := tracer().Start(ctx, "gorm "+operation+" "+table,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBCollectionName(table)))
+		scope.InstanceSet(spanSetting, span)
+	}
+}
+
+func endSpan(scope *gorm.Scope) {
+	value, ok := scope.InstanceGet(spanSetting)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	span.SetAttributes(semconv.DBQueryText(scope.SQL))
+	if err := scope.DB().Error; err != nil && err != gorm.ErrRecordNotFound {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
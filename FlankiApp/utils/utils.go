@@ -4,11 +4,13 @@ import (
 	"FlankiRest/database"
 	"FlankiRest/errors"
 	"FlankiRest/logger"
+	"FlankiRest/validation"
 	"bytes"
 	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -49,13 +51,28 @@ func ApiErrorResponse(w http.ResponseWriter, e error) {
 	logger.GetGlobalLogger().WithField("prefix", "[RESPONSE]").Error(apiErr.Error())
 	w.WriteHeader(apiErr.HttpCode)
 
-	data := map[string] string {"message": apiErr.Message}
+	data := map[string] interface{} {"message": apiErr.Message}
+	if len(apiErr.Fields) > 0 {
+		language := validation.DefaultLanguage
+		if lw, ok := w.(*LocalizedResponseWriter); ok {
+			language = lw.Language
+		}
+		translated := validation.Translate(apiErr, language)
+		data["message"] = translated.Message
+		data["errors"] = translated.Fields
+	}
 	err := json.NewEncoder(w).Encode(data)
 	if err != nil {
 		log.Println("Got error while responding: " + err.Error())
 	}
 }
 
+// LocalizedResponseWriter carries language preferred by the client so that errors can be responded in it
+type LocalizedResponseWriter struct {
+	http.ResponseWriter
+	Language string
+}
+
 func SimpleRespond(w http.ResponseWriter, data interface{}) {
 	w.Header().Add("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(data)
@@ -68,13 +85,28 @@ type DatabaseReconnectTicker struct {
 	db *database.ApiDatabase
 	uri string
 	Ticker *time.Ticker
+	done chan struct{}
+	stopOnce sync.Once
 }
 
 func NewDatabaseReconnectTicker(conn *database.ApiDatabase,uri string,  interval time.Duration) *DatabaseReconnectTicker {
-	drt := &DatabaseReconnectTicker{conn,uri, time.NewTicker(interval)}
+	drt := &DatabaseReconnectTicker{db: conn, uri: uri, Ticker: time.NewTicker(interval), done: make(chan struct{})}
 	return drt
 }
 
+// Stop stops ticking and closes Done, goroutine reconnecting on ticks should return then
+func (drt *DatabaseReconnectTicker) Stop() {
+	drt.stopOnce.Do(func() {
+		drt.Ticker.Stop()
+		close(drt.done)
+	})
+}
+
+// Done is closed when ticker has been stopped
+func (drt *DatabaseReconnectTicker) Done() <-chan struct{} {
+	return drt.done
+}
+
 func (drt *DatabaseReconnectTicker) TryReconnect() (bool, error) {
 	if err := drt.db.DB().DB().Ping(); err != nil {
 		_ = drt.db.Close()
@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"FlankiRest/errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultLanguage = "en"
+
+// messages of failed rules by language and code, {param} is replaced with rule's parameter
+// and the empty code holds message of whole validation error
+var catalogs = map[string]map[string]string{
+	"en": {
+		"":           errors.ValidationFailedMessage,
+		Required:     "Field is required",
+		TooShort:     "Too short, at least {min} characters",
+		TooLong:      "Too long, up to {max} characters",
+		TooSmall:     "Too small, should be at least {min}",
+		TooLarge:     "Too large, should be at most {max}",
+		NotAllowed:   "Should be one of: {values}",
+		InvalidEmail: "Incorrect email format",
+		NotUnique:    "Value is already taken",
+		UnknownZone:  "Unknown time zone, use names like Europe/Warsaw",
+	},
+	"pl": {
+		"":           "Zapytanie zawiera niepoprawne pola",
+		Required:     "Pole jest wymagane",
+		TooShort:     "Za krótkie, minimalna długość to {min}",
+		TooLong:      "Za długie, maksymalna długość to {max}",
+		TooSmall:     "Za mała wartość, co najmniej {min}",
+		TooLarge:     "Za duża wartość, najwyżej {max}",
+		NotAllowed:   "Dozwolone wartości: {values}",
+		InvalidEmail: "Niepoprawny format adresu email",
+		NotUnique:    "Wartość jest już zajęta",
+		UnknownZone:  "Nieznana strefa czasowa, użyj nazwy w stylu Europe/Warsaw",
+	},
+}
+
+// Translate returns copy of validation error with messages in given language
+func Translate(apiErr *errors.ApiError, language string) *errors.ApiError {
+	catalog, ok := catalogs[language]
+	if !ok {
+		catalog = catalogs[DefaultLanguage]
+	}
+	translated := *apiErr
+	translated.Message = catalog[""]
+	translated.Fields = make([]errors.FieldError, len(apiErr.Fields))
+	for i, field := range apiErr.Fields {
+		field.Message = format(catalog[field.Code], field.Params)
+		if field.Message == "" {
+			field.Message = field.Code
+		}
+		translated.Fields[i] = field
+	}
+	return &translated
+}
+
+func format(message string, params map[string]interface{}) string {
+	for name, value := range params {
+		text := fmt.Sprint(value)
+		if values, ok := value.([]string); ok {
+			text = strings.Join(values, ", ")
+		}
+		message = strings.Replace(message, "{"+name+"}", text, -1)
+	}
+	return message
+}
+
+// PreferredLanguage picks the best supported language from Accept-Language header
+// e.g. "pl-PL,pl;q=0.9,en;q=0.8", default language is returned when none is supported
+func PreferredLanguage(acceptLanguage string) string {
+	type option struct {
+		language string
+		quality  float64
+	}
+	var options []option
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		language := strings.ToLower(strings.SplitN(params[0], "-", 2)[0])
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if _, ok := catalogs[language]; ok && quality > 0 {
+			options = append(options, option{language, quality})
+		}
+	}
+	if len(options) == 0 {
+		return DefaultLanguage
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].quality > options[j].quality })
+	return options[0].language
+}
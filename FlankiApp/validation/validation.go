@@ -0,0 +1,183 @@
+package validation
+
+import (
+	"FlankiRest/errors"
+	"github.com/badoux/checkmail"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+	Rules are declared in `validate` tag of request's struct fields and separated by commas, e.g.
+
+		Nickname string `json:"nickname" validate:"min=4,max=20"`
+
+	omitempty       skips the other rules when field has zero value
+	when=Field      skips the other rules unless bool (or *bool) Field of the same struct is true
+	required        field can't have zero value, the other rules are skipped when it fails
+	min=N, max=N    length of strings and slices or value of numbers
+	oneof=a b c     one of space separated values
+	email           valid email address
+
+	Fields are reported under their json names.
+*/
+
+// machine readable codes of failed rules
+const (
+	Required     = "required"
+	TooShort     = "too_short"
+	TooLong      = "too_long"
+	TooSmall     = "too_small"
+	TooLarge     = "too_large"
+	NotAllowed   = "not_allowed"
+	InvalidEmail = "invalid_email"
+	NotUnique    = "not_unique"
+	UnknownZone  = "unknown_timezone"
+)
+
+// Check validates struct (or pointer to it) against rules of its fields, returned error lists all failures
+func Check(value interface{}) error {
+	return Merge(Fields(value)...)
+}
+
+// Fields returns failures of all rules declared on struct's fields
+func Fields(value interface{}) []errors.FieldError {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	var fields []errors.FieldError
+	checkStruct(v, &fields)
+	return fields
+}
+
+// Field creates failure of single field, used for rules which can't be declared in tags e.g. uniqueness
+func Field(name string, code string, params map[string]interface{}) errors.FieldError {
+	return errors.FieldError{Field: name, Code: code, Params: params}
+}
+
+// Merge returns validation error listing given fields or nil when there are none
+func Merge(fields ...errors.FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return errors.Invalid(fields)
+}
+
+// Append adds fields of validation error to the list, any other error is returned
+// because it means validation couldn't be finished
+func Append(fields []errors.FieldError, err error) ([]errors.FieldError, error) {
+	if err == nil {
+		return fields, nil
+	}
+	if apiErr, ok := err.(*errors.ApiError); ok && len(apiErr.Fields) > 0 {
+		return append(fields, apiErr.Fields...), nil
+	}
+	return fields, err
+}
+
+func checkStruct(v reflect.Value, fields *[]errors.FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			checkStruct(v.Field(i), fields)
+			continue
+		}
+		rules := field.Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		checkField(v, v.Field(i), name, strings.Split(rules, ","), fields)
+	}
+}
+
+func checkField(parent reflect.Value, value reflect.Value, name string, rules []string, fields *[]errors.FieldError) {
+	for _, rule := range rules {
+		key, param := rule, ""
+		if index := strings.Index(rule, "="); index >= 0 {
+			key, param = rule[:index], rule[index+1:]
+		}
+
+		switch key {
+		case "omitempty":
+			if isZero(value) {
+				return
+			}
+		case "when":
+			condition := reflect.Indirect(parent.FieldByName(param))
+			if !condition.IsValid() || !condition.Bool() {
+				return
+			}
+		case "required":
+			if isZero(value) {
+				*fields = append(*fields, Field(name, Required, nil))
+				return
+			}
+		case "min", "max":
+			limit, _ := strconv.ParseFloat(param, 64)
+			if failed := checkLimit(value, key, limit); failed != "" {
+				*fields = append(*fields, Field(name, failed, map[string]interface{}{key: limit}))
+			}
+		case "oneof":
+			allowed := strings.Fields(param)
+			if !contains(allowed, toString(value)) {
+				*fields = append(*fields, Field(name, NotAllowed, map[string]interface{}{"values": allowed}))
+			}
+		case "email":
+			if err := checkmail.ValidateFormat(toString(value)); err != nil {
+				*fields = append(*fields, Field(name, InvalidEmail, nil))
+			}
+		}
+	}
+}
+
+// returns code of failure or empty string
+func checkLimit(value reflect.Value, key string, limit float64) string {
+	value = reflect.Indirect(value)
+	var size float64
+	short, long := TooSmall, TooLarge
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		size = float64(value.Len())
+		short, long = TooShort, TooLong
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = value.Float()
+	default:
+		return ""
+	}
+	if key == "min" && size < limit {
+		return short
+	}
+	if key == "max" && size > limit {
+		return long
+	}
+	return ""
+}
+
+func isZero(value reflect.Value) bool {
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
+
+func toString(value reflect.Value) string {
+	value = reflect.Indirect(value)
+	if value.Kind() == reflect.String {
+		return value.String()
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
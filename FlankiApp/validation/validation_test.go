@@ -0,0 +1,93 @@
+package validation_test
+
+import (
+	"FlankiRest/errors"
+	"FlankiRest/validation"
+	"reflect"
+	"testing"
+)
+
+type request struct {
+	Nickname string  `json:"nickname" validate:"min=4,max=20"`
+	Email    string  `json:"email,omitempty" validate:"omitempty,email"`
+	Sex      string  `json:"sex" validate:"oneof=male female"`
+	Limit    uint    `json:"limit" validate:"min=4,max=20"`
+	Private  *bool   `json:"private"`
+	Password string  `json:"password" validate:"when=Private,required,min=4"`
+	Latitude float64 `json:"latitude" validate:"min=-90,max=90"`
+}
+
+func codes(err error) map[string]string {
+	result := map[string]string{}
+	if apiErr, ok := err.(*errors.ApiError); ok {
+		for _, field := range apiErr.Fields {
+			result[field.Field] = field.Code
+		}
+	}
+	return result
+}
+
+func TestCheckReportsEveryInvalidField(t *testing.T) {
+	private := true
+	err := validation.Check(&request{Nickname: "abc", Email: "not an email", Sex: "other", Limit: 21, Private: &private, Latitude: -91})
+
+	expected := map[string]string{
+		"nickname": validation.TooShort,
+		"email":    validation.InvalidEmail,
+		"sex":      validation.NotAllowed,
+		"limit":    validation.TooLarge,
+		"password": validation.Required,
+		"latitude": validation.TooSmall,
+	}
+	if got := codes(err); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if err.(*errors.ApiError).HttpCode != 400 {
+		t.Fatalf("expected status 400, got %d", err.(*errors.ApiError).HttpCode)
+	}
+}
+
+func TestCheckSkipsOptionalAndConditionalRules(t *testing.T) {
+	private := false
+	valid := []request{
+		{Nickname: "nick", Sex: "male", Limit: 4},
+		{Nickname: "nick", Sex: "female", Limit: 20, Private: &private, Password: "x"},
+	}
+	for _, r := range valid {
+		if err := validation.Check(r); err != nil {
+			t.Fatalf("expected %+v to be valid, got %v", r, err)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                        "en",
+		"pl":                      "pl",
+		"pl-PL,pl;q=0.9,en;q=0.8": "pl",
+		"de-DE,en;q=0.5,pl;q=0.7": "pl",
+		"de, fr":                  "en",
+		"en-US,en;q=0.9,pl;q=0":   "en",
+	}
+	for header, expected := range tests {
+		if got := validation.PreferredLanguage(header); got != expected {
+			t.Errorf("%q: expected %s, got %s", header, expected, got)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	err := validation.Check(request{Nickname: "abc", Sex: "male", Limit: 4}).(*errors.ApiError)
+
+	english := validation.Translate(err, "en")
+	if english.Message != errors.ValidationFailedMessage || english.Fields[0].Message != "Too short, at least 4 characters" {
+		t.Fatalf("unexpected english translation: %+v", english)
+	}
+	polish := validation.Translate(err, "pl")
+	if polish.Fields[0].Message != "Za krótkie, minimalna długość to 4" {
+		t.Fatalf("unexpected polish translation: %+v", polish)
+	}
+	if err.Fields[0].Message != "" {
+		t.Fatal("translation should not modify original error")
+	}
+}
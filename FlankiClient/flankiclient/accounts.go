@@ -0,0 +1,80 @@
+package flankiclient
+
+import (
+	"FlankiRest/models"
+	"path"
+)
+
+// AccountInfo is the response of /user/me, summary is nil until user plays his first match
+type AccountInfo struct {
+	Account models.Account       `json:"account"`
+	Summary *models.QuickSummary `json:"summary"`
+}
+
+func (client *Client) Register(account models.Account) error {
+	return client.do("POST", "/user/create", account, nil, false)
+}
+
+func (client *Client) Me() (*AccountInfo, error) {
+	info := &AccountInfo{}
+	if err := client.do("GET", "/user/me", nil, info, true); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// UpdateMe changes only fields set in update, if password or email changes
+// client has to log in again with new credentials
+func (client *Client) UpdateMe(update models.UpdateAccount) error {
+	return client.do("PATCH", "/user/me", update, nil, true)
+}
+
+// DeleteMe deletes user's account and logs the client out, the account can be restored
+// with RestoreAccount until it is erased after the grace period
+func (client *Client) DeleteMe() error {
+	if err := client.do("DELETE", "/user/me", nil, nil, true); err != nil {
+		return err
+	}
+	return client.Logout()
+}
+
+// RestoreAccount brings back deleted account, the client has to log in afterwards
+func (client *Client) RestoreAccount(email string, password string) error {
+	return client.do("POST", "/user/restore", map[string]string{"email": email, "password": password}, nil, false)
+}
+
+// Export returns ZIP archive of everything the app keeps about the user
+func (client *Client) Export() ([]byte, error) {
+	b, _, err := client.image("/user/me/export", true)
+	return b, err
+}
+
+// RememberPassword requests an email with password reset link
+func (client *Client) RememberPassword(email string) error {
+	return client.do("POST", "/remember_password", map[string]string{"email": email}, nil, false)
+}
+
+// ResetPassword sets new password using code from password reset email
+func (client *Client) ResetPassword(code string, newPassword string) error {
+	request := map[string]string{"code": code, "new_password": newPassword}
+	return client.do("POST", "/reset_password", request, nil, false)
+}
+
+// CalendarPath returns path of the user's calendar feed, renewing it makes the old path stop working
+func (client *Client) CalendarPath(renew bool) (string, error) {
+	method := "GET"
+	if renew {
+		method = "POST"
+	}
+	response := map[string]string{}
+	if err := client.do(method, "/user/me/calendar", nil, &response, true); err != nil {
+		return "", err
+	}
+	return response["path"], nil
+}
+
+// Calendar returns iCalendar feed found at the path given by CalendarPath, it doesn't need the client to be logged in
+func (client *Client) Calendar(feedPath string) ([]byte, error) {
+	b, _, err := client.image("/calendar/"+path.Base(feedPath), false)
+	return b, err
+}
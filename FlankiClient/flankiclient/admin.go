@@ -0,0 +1,12 @@
+package flankiclient
+
+import (
+	"FlankiRest/jobs"
+)
+
+// Jobs returns background jobs of the app with their recent runs, only admins can see them
+func (client *Client) Jobs() ([]jobs.Status, error) {
+	var statuses []jobs.Status
+	err := client.do("GET", "/admin/jobs", nil, &statuses, true)
+	return statuses, err
+}
@@ -0,0 +1,138 @@
+package flankiclient
+
+import (
+	"Chat/websocketchat"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Rooms lists names of all opened chat rooms
+func (client *Client) Rooms() ([]string, error) {
+	b, err := client.send("GET", client.ChatURL+"/chat/rooms", "", nil, false)
+	if err != nil {
+		return nil, err
+	}
+	var rooms []string
+	return rooms, json.Unmarshal(b, &rooms)
+}
+
+// CreateRoom opens new chat room owned by the user
+func (client *Client) CreateRoom(name string) error {
+	_, err := client.send("POST", client.ChatURL+"/chat/create/"+name, "", nil, true)
+	return err
+}
+
+// CloseRoom closes room owned by the user
+func (client *Client) CloseRoom(name string) error {
+	_, err := client.send("POST", client.ChatURL+"/chat/close/"+name, "", nil, true)
+	return err
+}
+
+// ChatMessages lists messages the user has sent in the chat, the oldest first
+func (client *Client) ChatMessages() ([]websocketchat.StoredMessage, error) {
+	b, err := client.send("GET", client.ChatURL+"/chat/messages", "", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	var messages []websocketchat.StoredMessage
+	return messages, json.Unmarshal(b, &messages)
+}
+
+// DeleteChatMessages removes all messages the user has sent in the chat
+func (client *Client) DeleteChatMessages() error {
+	_, err := client.send("DELETE", client.ChatURL+"/chat/messages", "", nil, true)
+	return err
+}
+
+// ChatConnection is user's websocket connection with a single chat room
+type ChatConnection struct {
+	socket     *websocket.Conn
+	writeMutex sync.Mutex
+}
+
+// JoinRoom connects to the chat room and introduces the user with his access token
+func (client *Client) JoinRoom(name string) (*ChatConnection, error) {
+	token, err := client.accessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	url := "ws" + strings.TrimPrefix(client.ChatURL, "http") + "/chat/join/" + name
+	socket, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			return nil, responseError(resp.StatusCode, b)
+		}
+		return nil, err
+	}
+
+	conn := &ChatConnection{socket: socket}
+	if err := socket.WriteJSON(websocketchat.User{Token: "Bearer " + token}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Send sends text message to everyone in the room
+func (conn *ChatConnection) Send(text string) error {
+	return conn.write(&websocketchat.Message{Action: "message", Text: text})
+}
+
+// RequestUsers asks for nicknames of users present in the room,
+// the answer comes as a message with "users" action and nicknames in its Data
+func (conn *ChatConnection) RequestUsers() error {
+	return conn.write(&websocketchat.Message{Action: "users"})
+}
+
+// Kick removes user with given nickname from the room, only room's owner is allowed to do so
+func (conn *ChatConnection) Kick(nickname string) error {
+	return conn.write(&websocketchat.Message{Action: "kick", Text: nickname})
+}
+
+// Receive waits for the next message, zero timeout means waiting forever
+func (conn *ChatConnection) Receive(timeout time.Duration) (*websocketchat.Message, error) {
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := conn.socket.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	msg := &websocketchat.Message{}
+	if err := conn.socket.ReadJSON(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Users extracts nicknames from the answer to RequestUsers
+func Users(msg *websocketchat.Message) []string {
+	users := []string{}
+	if list, ok := msg.Data.([]interface{}); ok {
+		for _, user := range list {
+			if nickname, ok := user.(string); ok {
+				users = append(users, nickname)
+			}
+		}
+	}
+	return users
+}
+
+func (conn *ChatConnection) Close() error {
+	return conn.socket.Close()
+}
+
+// websocket connection supports only one concurrent writer
+func (conn *ChatConnection) write(msg *websocketchat.Message) error {
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+	return conn.socket.WriteJSON(msg)
+}
@@ -0,0 +1,201 @@
+package flankiclient
+
+import (
+	"FlankiRest/errors"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Client of Flanki REST api and chat, a single client acts as a single user.
+//
+// The api doesn't issue refresh tokens, so when client has been logged in with Login it remembers user's credentials
+// and logs in again whenever the token expires or gets rejected.
+type Client struct {
+	ApiURL  string
+	ChatURL string
+
+	HTTPClient *http.Client
+	Tokens     TokenStore
+
+	// sent as Accept-Language so that messages of validation errors are translated, e.g. "pl"
+	Language string
+
+	credentialsMutex sync.Mutex
+	credentials      *credentials
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// NewClient creates client keeping its token in memory, urls should not end with slash e.g. https://flaneczki.pl:8443
+func NewClient(apiURL string, chatURL string) *Client {
+	return &Client{
+		ApiURL:     apiURL,
+		ChatURL:    chatURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Tokens:     &MemoryTokenStore{},
+	}
+}
+
+// Login obtains new access token and keeps credentials for logging in again when the token expires
+func (client *Client) Login(email string, password string) (*Token, error) {
+	creds := &credentials{email, password}
+	token, err := client.login(creds)
+	if err != nil {
+		return nil, err
+	}
+	client.credentialsMutex.Lock()
+	client.credentials = creds
+	client.credentialsMutex.Unlock()
+	return token, nil
+}
+
+// Logout forgets both token and credentials
+func (client *Client) Logout() error {
+	client.credentialsMutex.Lock()
+	client.credentials = nil
+	client.credentialsMutex.Unlock()
+	return client.Tokens.SetToken(nil)
+}
+
+func (client *Client) login(creds *credentials) (*Token, error) {
+	response := struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := client.do("POST", "/user/login", creds, &response, false); err != nil {
+		return nil, err
+	}
+	token := &Token{
+		AccessToken: response.AccessToken,
+		TokenType:   response.TokenType,
+		Expiry:      time.Now().Add(time.Duration(response.ExpiresIn) * time.Second),
+	}
+	return token, client.Tokens.SetToken(token)
+}
+
+// returns valid access token, logging in again if the stored one has expired
+func (client *Client) accessToken() (string, error) {
+	token, err := client.Tokens.Token()
+	if err != nil {
+		return "", err
+	}
+	if token.Valid() {
+		return token.AccessToken, nil
+	}
+	token, err = client.refresh()
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+func (client *Client) refresh() (*Token, error) {
+	creds := client.savedCredentials()
+	if creds == nil {
+		// without credentials there is no way to get a new token, let the api decide about the old one
+		token, err := client.Tokens.Token()
+		if err != nil {
+			return nil, err
+		}
+		if token == nil || token.AccessToken == "" {
+			return nil, ErrNotLoggedIn
+		}
+		return token, nil
+	}
+	return client.login(creds)
+}
+
+// sends body encoded as json and decodes json response into result when it is given
+func (client *Client) do(method string, path string, body interface{}, result interface{}, authorized bool) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	resp, err := client.send(method, client.ApiURL+path, "application/json", b, authorized)
+	if err != nil {
+		return err
+	}
+	if result == nil || len(resp) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp, result)
+}
+
+// sends raw body and returns raw response body, unsuccessful responses are turned into errors
+func (client *Client) send(method string, url string, contentType string, body []byte, authorized bool) ([]byte, error) {
+	resp, err := client.sendRequest(method, url, contentType, body, authorized)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// token might have been revoked before its expiry, try once again with a fresh one
+	creds := client.savedCredentials()
+	if authorized && creds != nil && responseError(resp.StatusCode, b) == errors.InvalidToken {
+		if _, err := client.login(creds); err != nil {
+			return nil, err
+		}
+		if resp, err = client.sendRequest(method, url, contentType, body, authorized); err != nil {
+			return nil, err
+		}
+		if b, err = readBody(resp); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(resp.StatusCode, b)
+	}
+	return b, nil
+}
+
+func (client *Client) sendRequest(method string, url string, contentType string, body []byte, authorized bool) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if client.Language != "" {
+		request.Header.Set("Accept-Language", client.Language)
+	}
+	if authorized {
+		token, err := client.accessToken()
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client.HTTPClient.Do(request)
+}
+
+func (client *Client) savedCredentials() *credentials {
+	client.credentialsMutex.Lock()
+	defer client.credentialsMutex.Unlock()
+	return client.credentials
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
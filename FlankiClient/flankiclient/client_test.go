@@ -0,0 +1,111 @@
+package flankiclient_test
+
+import (
+	"FlankiClient/flankiclient"
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeApi issues tokens numbered by logins and accepts only the latest one
+type fakeApi struct {
+	logins int
+}
+
+func (api *fakeApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	respond := func(code int, data interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(data)
+	}
+	switch r.URL.Path {
+	case "/user/login":
+		api.logins++
+		respond(200, map[string]interface{}{"access_token": api.token(), "token_type": "Bearer", "expires_in": 3600})
+	case "/user/me":
+		if r.Header.Get("Authorization") != "Bearer "+api.token() {
+			respond(401, map[string]string{"message": errors.InvalidToken.Message})
+			return
+		}
+		respond(200, map[string]interface{}{"account": models.Account{Nickname: "tester"}})
+	case "/lobbies/1/join":
+		respond(403, map[string]string{"message": errors.LobbyIsFull.Message})
+	default:
+		respond(404, map[string]string{"message": "not found"})
+	}
+}
+
+func (api *fakeApi) token() string {
+	return fmt.Sprintf("token_%d", api.logins)
+}
+
+func TestClientLogsInAgainWhenTokenIsRejected(t *testing.T) {
+	api := &fakeApi{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := flankiclient.NewClient(server.URL, server.URL)
+	if _, err := client.Login("tester@example.com", "password"); err != nil {
+		t.Fatalf("logging in: %s", err)
+	}
+	_ = client.Tokens.SetToken(&flankiclient.Token{AccessToken: "revoked", Expiry: time.Now().Add(time.Hour)})
+
+	info, err := client.Me()
+	if err != nil {
+		t.Fatalf("expected client to log in again, got: %v", err)
+	}
+	if info.Account.Nickname != "tester" || api.logins != 2 {
+		t.Fatalf("unexpected result, account: %+v, logins: %d", info.Account, api.logins)
+	}
+}
+
+func TestClientRefreshesExpiredToken(t *testing.T) {
+	api := &fakeApi{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := flankiclient.NewClient(server.URL, server.URL)
+	if _, err := client.Login("tester@example.com", "password"); err != nil {
+		t.Fatalf("logging in: %s", err)
+	}
+	token, _ := client.Tokens.Token()
+	token.Expiry = time.Now()
+
+	if _, err := client.Me(); err != nil || api.logins != 2 {
+		t.Fatalf("expected expired token to be refreshed before request, err: %v, logins: %d", err, api.logins)
+	}
+}
+
+func TestClientWithoutTokenIsNotLoggedIn(t *testing.T) {
+	server := httptest.NewServer(&fakeApi{})
+	defer server.Close()
+
+	if _, err := flankiclient.NewClient(server.URL, server.URL).Me(); err != flankiclient.ErrNotLoggedIn {
+		t.Fatalf("expected ErrNotLoggedIn, got: %v", err)
+	}
+}
+
+func TestErrorsAreMappedToApiErrors(t *testing.T) {
+	api := &fakeApi{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := flankiclient.NewClient(server.URL, server.URL)
+	if _, err := client.Login("tester@example.com", "password"); err != nil {
+		t.Fatalf("logging in: %s", err)
+	}
+
+	if err := client.JoinLobby(1, models.Red, ""); err != errors.LobbyIsFull {
+		t.Fatalf("expected errors.LobbyIsFull, got: %v", err)
+	}
+
+	_, err := client.Lobby(2)
+	if flankiclient.StatusCode(err) != 404 || err.Error() != "not found" {
+		t.Fatalf("expected generic api error with status 404, got: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package flankiclient
+
+import (
+	"FlankiRest/errors"
+	"encoding/json"
+	"strings"
+)
+
+// errors the api responds with by their messages, known errors are returned as the very same values
+// that the server uses so they can be compared directly, e.g. err == errors.LobbyIsFull
+var knownErrors = map[string]*errors.ApiError{}
+
+func init() {
+	for _, e := range []*errors.ApiError{
+		errors.BadJsonRequestFormat,
+		errors.UnauthorizedAccount,
+		errors.UnauthorizedLobbyJoinRequest,
+		errors.LobbyIsFull,
+		errors.TeamIsFull,
+		errors.FriendsOnlyLobby,
+		errors.RosterLocked,
+		errors.PlayersNotReady,
+		errors.TeamsNotComplete,
+		errors.ResultNotPending,
+		errors.NotModerator,
+		errors.NotAdmin,
+		errors.NotScorekeeper,
+		errors.TournamentNotFound,
+		errors.NotTournamentOwner,
+		errors.InviteNotFound,
+		errors.InviteRevoked,
+		errors.InviteExpired,
+		errors.InviteUsedUp,
+		errors.PlayerNotFoundInAnyTeam,
+		errors.PlayerNotActive,
+		errors.CryptoError,
+		errors.InvalidToken,
+		errors.PlayerNotFound,
+		errors.RecordNotFound,
+		errors.TooManyRequests,
+		errors.AccountLocked,
+		errors.AccountErased,
+	} {
+		knownErrors[e.Message] = e
+	}
+}
+
+// ErrNotLoggedIn is returned when endpoint requires authorization and client has no token
+var ErrNotLoggedIn = errors.New("Client is not logged in", 401)
+
+// parses {"message": ..., "errors": [...]} body of unsuccessful response,
+// returned error is always *errors.ApiError with status code of the response and invalid fields if there were any
+func responseError(code int, body []byte) error {
+	msg := struct {
+		Message string              `json:"message"`
+		Errors  []errors.FieldError `json:"errors"`
+	}{}
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(body))
+	}
+	if known, ok := knownErrors[msg.Message]; ok && known.HttpCode == code {
+		return known
+	}
+	return &errors.ApiError{Message: msg.Message, HttpCode: code, Fields: msg.Errors}
+}
+
+// InvalidFields returns fields which failed validation on the server or nil for other errors
+func InvalidFields(err error) []errors.FieldError {
+	if apiErr, ok := err.(*errors.ApiError); ok {
+		return apiErr.Fields
+	}
+	return nil
+}
+
+// StatusCode returns http status of error returned by the client or 0 when it was not api's error (e.g. connection error)
+func StatusCode(err error) int {
+	if apiErr, ok := err.(*errors.ApiError); ok {
+		return apiErr.HttpCode
+	}
+	return 0
+}
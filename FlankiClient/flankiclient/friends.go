@@ -0,0 +1,64 @@
+package flankiclient
+
+import (
+	"FlankiRest/models"
+	"fmt"
+)
+
+// Friends lists user's friends together with lobbies they are in
+func (client *Client) Friends() ([]models.Friend, error) {
+	var friends []models.Friend
+	err := client.do("GET", "/players/me/friends", nil, &friends, true)
+	return friends, err
+}
+
+func (client *Client) FriendRequests() (*models.FriendRequests, error) {
+	requests := &models.FriendRequests{}
+	if err := client.do("GET", "/players/me/friends/requests", nil, requests, true); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// AddFriend asks the player for friendship, or accepts request the player has already sent
+func (client *Client) AddFriend(playerID uint) (*models.Friendship, error) {
+	return client.friendship("POST", fmt.Sprintf("/players/%d/friend", playerID))
+}
+
+func (client *Client) AcceptFriend(playerID uint) (*models.Friendship, error) {
+	return client.friendship("POST", fmt.Sprintf("/players/%d/friend/accept", playerID))
+}
+
+func (client *Client) DeclineFriend(playerID uint) error {
+	return client.do("POST", fmt.Sprintf("/players/%d/friend/decline", playerID), nil, nil, true)
+}
+
+// RemoveFriend ends the friendship or withdraws request sent to the player
+func (client *Client) RemoveFriend(playerID uint) error {
+	return client.do("DELETE", fmt.Sprintf("/players/%d/friend", playerID), nil, nil, true)
+}
+
+func (client *Client) friendship(method string, path string) (*models.Friendship, error) {
+	friendship := &models.Friendship{}
+	if err := client.do(method, path, nil, friendship, true); err != nil {
+		return nil, err
+	}
+	return friendship, nil
+}
+
+// Follows lists players the user follows and those following the user
+func (client *Client) Follows() (*models.Follows, error) {
+	follows := &models.Follows{}
+	if err := client.do("GET", "/players/me/follows", nil, follows, true); err != nil {
+		return nil, err
+	}
+	return follows, nil
+}
+
+func (client *Client) Follow(playerID uint) error {
+	return client.do("POST", fmt.Sprintf("/players/%d/follow", playerID), nil, nil, true)
+}
+
+func (client *Client) Unfollow(playerID uint) error {
+	return client.do("DELETE", fmt.Sprintf("/players/%d/follow", playerID), nil, nil, true)
+}
@@ -0,0 +1,43 @@
+package flankiclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Image returns player's avatar together with its content type
+func (client *Client) Image(playerID uint) ([]byte, string, error) {
+	return client.image(fmt.Sprintf("/images/%d", playerID), false)
+}
+
+func (client *Client) MyImage() ([]byte, string, error) {
+	return client.image("/images/my", true)
+}
+
+// UploadImage sets user's avatar, only image/jpeg and image/png content types are accepted
+func (client *Client) UploadImage(contentType string, image io.Reader) error {
+	b, err := ioutil.ReadAll(image)
+	if err != nil {
+		return err
+	}
+	_, err = client.send("POST", client.ApiURL+"/images/my", contentType, b, true)
+	return err
+}
+
+// images, calendars and account exports are the only responses which are not json so content type has to be read from headers
+func (client *Client) image(path string, authorized bool) ([]byte, string, error) {
+	resp, err := client.sendRequest("GET", client.ApiURL+path, "", nil, authorized)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := readBody(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", responseError(resp.StatusCode, b)
+	}
+	return b, resp.Header.Get("Content-Type"), nil
+}
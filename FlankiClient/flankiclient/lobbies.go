@@ -0,0 +1,245 @@
+package flankiclient
+
+import (
+	"FlankiRest/models"
+	"fmt"
+)
+
+// CreateLobby creates lobby owned by the user, owner is not added to any team
+func (client *Client) CreateLobby(lobby models.Lobby) (*models.Lobby, error) {
+	created := &models.Lobby{}
+	if err := client.do("POST", "/lobbies/owner/create", lobby, created, true); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// OwnerLobby returns opened lobby owned by the user
+func (client *Client) OwnerLobby() (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	if err := client.do("GET", "/lobbies/owner", nil, lobby, true); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+func (client *Client) UpdateLobby(update models.UpdateLobby) error {
+	return client.do("PATCH", "/lobbies/owner", update, nil, true)
+}
+
+func (client *Client) DeleteLobby() error {
+	return client.do("DELETE", "/lobbies/owner", nil, nil, true)
+}
+
+// CloseLobby closes owner's lobby without submitting results
+func (client *Client) CloseLobby() error {
+	return client.do("POST", "/lobbies/owner/close", nil, nil, true)
+}
+
+// SubmitResults finishes the started match in owner's lobby
+func (client *Client) SubmitResults(winner models.TeamColor) error {
+	return client.do("POST", "/lobbies/owner/submit", map[string]models.TeamColor{"winner": winner}, nil, true)
+}
+
+// ConfirmResult confirms pending results of the lobby on behalf of the other team
+func (client *Client) ConfirmResult(lobbyID uint) error {
+	return client.do("POST", fmt.Sprintf("/lobbies/%d/results/confirm", lobbyID), nil, nil, true)
+}
+
+// DisputeResult disputes results of the lobby, statistics of the match don't count until a moderator settles it
+func (client *Client) DisputeResult(lobbyID uint, reason string) error {
+	return client.do("POST", fmt.Sprintf("/lobbies/%d/results/dispute", lobbyID), &models.DisputeRequest{Reason: reason}, nil, true)
+}
+
+// SettleResult settles disputed results of the lobby, only moderators can do that
+func (client *Client) SettleResult(lobbyID uint, winner models.TeamColor, reason string) error {
+	return client.do("POST", fmt.Sprintf("/lobbies/%d/results/settle", lobbyID), &models.SettleRequest{Winner: winner, Reason: reason}, nil, true)
+}
+
+// RecordEvent records event of the started match, the user has to be lobby's owner or referee
+func (client *Client) RecordEvent(lobbyID uint, event models.MatchEventRequest) (*models.MatchEvent, error) {
+	recorded := &models.MatchEvent{}
+	if err := client.do("POST", fmt.Sprintf("/lobbies/%d/events", lobbyID), event, recorded, true); err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+// MatchLog returns event log of the lobby's match with stats of its players
+func (client *Client) MatchLog(lobbyID uint) (*models.MatchLog, error) {
+	log := &models.MatchLog{}
+	if err := client.do("GET", fmt.Sprintf("/lobbies/%d/events", lobbyID), nil, log, true); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+func (client *Client) KickPlayer(playerID uint) error {
+	return client.do("POST", "/lobbies/owner/kick_player", map[string]uint{"player_id": playerID}, nil, true)
+}
+
+// KickSpectator removes spectator from owner's lobby
+func (client *Client) KickSpectator(playerID uint) error {
+	return client.do("POST", "/lobbies/owner/kick_spectator", map[string]uint{"player_id": playerID}, nil, true)
+}
+
+// TransferOwnership hands owner's lobby over to one of its players or co-owners and returns the lobby
+func (client *Client) TransferOwnership(playerID uint) (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	if err := client.do("POST", "/lobbies/owner/transfer", map[string]uint{"player_id": playerID}, lobby, true); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// AddCoOwner lets the player manage owner's lobby, returns all co-owners of the lobby
+func (client *Client) AddCoOwner(playerID uint) ([]models.LobbyCoOwner, error) {
+	var coOwners []models.LobbyCoOwner
+	if err := client.do("POST", "/lobbies/owner/co_owners", map[string]uint{"player_id": playerID}, &coOwners, true); err != nil {
+		return nil, err
+	}
+	return coOwners, nil
+}
+
+// RemoveCoOwner takes management of owner's lobby away from the co-owner
+func (client *Client) RemoveCoOwner(playerID uint) error {
+	return client.do("DELETE", fmt.Sprintf("/lobbies/owner/co_owners/%d", playerID), nil, nil, true)
+}
+
+// StartReadyCheck asks players of owner's lobby to confirm they are ready
+func (client *Client) StartReadyCheck() error {
+	return client.do("POST", "/lobbies/owner/ready_check", nil, nil, true)
+}
+
+// StartMatch starts the match in owner's lobby once all players are ready, teams can't be changed since then
+func (client *Client) StartMatch() error {
+	return client.do("POST", "/lobbies/owner/start", nil, nil, true)
+}
+
+// ShuffleTeams rebalances teams of owner's lobby by players' ratings
+func (client *Client) ShuffleTeams() (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	if err := client.do("POST", "/lobbies/owner/shuffle", nil, lobby, true); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// CreateInvite creates invite to owner's lobby, invite with PlayerID can be used once and only by that player
+func (client *Client) CreateInvite(request models.InviteRequest) (*models.LobbyInvite, error) {
+	invite := &models.LobbyInvite{}
+	if err := client.do("POST", "/lobbies/owner/invites", request, invite, true); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// Invites lists invites to owner's lobby including revoked ones
+func (client *Client) Invites() ([]models.LobbyInvite, error) {
+	var invites []models.LobbyInvite
+	err := client.do("GET", "/lobbies/owner/invites", nil, &invites, true)
+	return invites, err
+}
+
+// RevokeInvite stops the invite from letting anybody else join owner's lobby
+func (client *Client) RevokeInvite(code string) error {
+	return client.do("DELETE", "/lobbies/owner/invites/"+code, nil, nil, true)
+}
+
+// CurrentLobby returns lobby in which the user is playing
+func (client *Client) CurrentLobby() (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	if err := client.do("GET", "/lobbies/my", nil, lobby, true); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// Lobbies lists opened lobbies
+func (client *Client) Lobbies() ([]models.LobbyListing, error) {
+	var lobbies []models.LobbyListing
+	err := client.do("GET", "/lobbies", nil, &lobbies, true)
+	return lobbies, err
+}
+
+func (client *Client) Lobby(id uint) (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	if err := client.do("GET", fmt.Sprintf("/lobbies/%d", id), nil, lobby, true); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// Results lists finished matches
+func (client *Client) Results() ([]models.MatchResult, error) {
+	var results []models.MatchResult
+	err := client.do("GET", "/lobbies/results", nil, &results, true)
+	return results, err
+}
+
+// JoinLobby adds the user to given team or to the one chosen by the lobby with models.Auto, password is needed only for private lobbies
+func (client *Client) JoinLobby(id uint, color models.TeamColor, password string) error {
+	request := models.LobbyRequest{TeamColor: color, Password: password}
+	return client.do("POST", fmt.Sprintf("/lobbies/%d/join", id), request, nil, true)
+}
+
+// WatchLobby makes the user a spectator of the lobby, password is needed only for private lobbies
+func (client *Client) WatchLobby(id uint, password string) (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	request := models.LobbyRequest{TeamColor: models.Spectator, Password: password}
+	if err := client.do("POST", fmt.Sprintf("/lobbies/%d/join", id), request, lobby, true); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// StopWatching stops the user watching the lobby
+func (client *Client) StopWatching(id uint) error {
+	return client.do("DELETE", fmt.Sprintf("/lobbies/%d/spectators", id), nil, nil, true)
+}
+
+// JoinWithInvite adds the user to the lobby of the invite without its password, models.Auto lets the lobby choose the team
+func (client *Client) JoinWithInvite(code string, color models.TeamColor) (*models.Lobby, error) {
+	lobby := &models.Lobby{}
+	if err := client.do("POST", "/lobbies/join/"+code, models.InviteJoinRequest{TeamColor: color}, lobby, true); err != nil {
+		return nil, err
+	}
+	return lobby, nil
+}
+
+// SetReady tells the owner of user's lobby whether the user is ready to play
+func (client *Client) SetReady(ready bool) error {
+	return client.do("POST", "/lobbies/my/ready", models.ReadyRequest{Ready: &ready}, nil, true)
+}
+
+func (client *Client) LeaveLobby() error {
+	return client.do("POST", "/lobbies/my/leave", nil, nil, true)
+}
+
+// UpcomingLobbies lists scheduled lobbies which haven't started yet from the soonest one
+func (client *Client) UpcomingLobbies() ([]models.LobbyListing, error) {
+	var lobbies []models.LobbyListing
+	err := client.do("GET", "/lobbies/upcoming", nil, &lobbies, true)
+	return lobbies, err
+}
+
+// RespondToLobby tells whether the user is coming to the scheduled lobby, response can be changed until the match starts
+func (client *Client) RespondToLobby(lobbyID uint, response models.RSVPResponse) (*models.RSVP, error) {
+	rsvp := &models.RSVP{}
+	if err := client.do("POST", fmt.Sprintf("/lobbies/%d/rsvp", lobbyID), models.RSVPRequest{Response: response}, rsvp, true); err != nil {
+		return nil, err
+	}
+	return rsvp, nil
+}
+
+func (client *Client) LobbyRSVPs(lobbyID uint) ([]models.RSVP, error) {
+	var rsvps []models.RSVP
+	err := client.do("GET", fmt.Sprintf("/lobbies/%d/rsvp", lobbyID), nil, &rsvps, true)
+	return rsvps, err
+}
+
+// LobbyCalendar returns iCalendar feed with the scheduled lobby
+func (client *Client) LobbyCalendar(lobbyID uint) ([]byte, error) {
+	b, _, err := client.image(fmt.Sprintf("/lobbies/%d/calendar.ics", lobbyID), false)
+	return b, err
+}
@@ -0,0 +1,58 @@
+package flankiclient
+
+import (
+	"FlankiRest/models"
+)
+
+// Notifications returns the latest notifications of the user, only unread ones when unreadOnly is set
+func (client *Client) Notifications(unreadOnly bool) (*models.Inbox, error) {
+	path := "/notifications"
+	if unreadOnly {
+		path += "?unread=true"
+	}
+	inbox := &models.Inbox{}
+	if err := client.do("GET", path, nil, inbox, true); err != nil {
+		return nil, err
+	}
+	return inbox, nil
+}
+
+// MarkNotificationsRead marks given notifications as read, or all of them when no ids are given.
+// Returns how many notifications have been marked
+func (client *Client) MarkNotificationsRead(ids ...uint) (int, error) {
+	var response struct {
+		Marked int `json:"marked"`
+	}
+	err := client.do("POST", "/notifications/read", models.ReadRequest{IDs: ids}, &response, true)
+	return response.Marked, err
+}
+
+func (client *Client) NotificationPreferences() ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	err := client.do("GET", "/notifications/preferences", nil, &preferences, true)
+	return preferences, err
+}
+
+// UpdateNotificationPreferences changes channels set in given preferences and returns preferences of every type
+func (client *Client) UpdateNotificationPreferences(updates ...models.NotificationPreference) ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	err := client.do("PATCH", "/notifications/preferences", updates, &preferences, true)
+	return preferences, err
+}
+
+// PushKey returns public VAPID key to subscribe browsers with
+func (client *Client) PushKey() (string, error) {
+	var response struct {
+		PublicKey string `json:"public_key"`
+	}
+	err := client.do("GET", "/notifications/push/key", nil, &response, true)
+	return response.PublicKey, err
+}
+
+func (client *Client) SubscribeToPush(subscription models.PushSubscriptionRequest) error {
+	return client.do("POST", "/notifications/push/subscriptions", subscription, nil, true)
+}
+
+func (client *Client) UnsubscribeFromPush(endpoint string) error {
+	return client.do("DELETE", "/notifications/push/subscriptions", models.PushSubscriptionRequest{Endpoint: endpoint}, nil, true)
+}
@@ -0,0 +1,43 @@
+package flankiclient
+
+import (
+	"FlankiRest/models"
+	"fmt"
+)
+
+// PlayerInfo is the response of /players/{id}, summary is nil until player plays his first match
+type PlayerInfo struct {
+	Player       models.Player               `json:"player"`
+	Summary      *models.QuickSummary        `json:"summary"`
+	Scorekeeping *models.ScorekeepingSummary `json:"scorekeeping"`
+	Achievements []models.Achievement        `json:"achievements"`
+}
+
+func (client *Client) Players() ([]models.Player, error) {
+	var players []models.Player
+	err := client.do("GET", "/players", nil, &players, true)
+	return players, err
+}
+
+func (client *Client) Player(id uint) (*PlayerInfo, error) {
+	info := &PlayerInfo{}
+	if err := client.do("GET", fmt.Sprintf("/players/%d", id), nil, info, false); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (client *Client) PlayerSummary(id uint) (*models.PlayerSummary, error) {
+	summary := &models.PlayerSummary{}
+	if err := client.do("GET", fmt.Sprintf("/players/%d/summary", id), nil, summary, false); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Ranking returns players' summaries ordered by points
+func (client *Client) Ranking() ([]models.PlayerSummary, error) {
+	var ranking []models.PlayerSummary
+	err := client.do("GET", "/players/ranking", nil, &ranking, false)
+	return ranking, err
+}
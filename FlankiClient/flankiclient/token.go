@@ -0,0 +1,78 @@
+package flankiclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// Valid reports whether token is present and will not expire within a minute
+func (token *Token) Valid() bool {
+	return token != nil && token.AccessToken != "" && time.Now().Add(time.Minute).Before(token.Expiry)
+}
+
+// TokenStore keeps user's access token between requests (and between runs when it's persistent)
+type TokenStore interface {
+	// returns nil token when there is none
+	Token() (*Token, error)
+	SetToken(token *Token) error
+}
+
+type MemoryTokenStore struct {
+	mutex sync.RWMutex
+	token *Token
+}
+
+func (store *MemoryTokenStore) Token() (*Token, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.token, nil
+}
+
+func (store *MemoryTokenStore) SetToken(token *Token) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.token = token
+	return nil
+}
+
+// FileTokenStore keeps token as json file, setting nil token removes the file
+type FileTokenStore struct {
+	Path string
+}
+
+func (store *FileTokenStore) Token() (*Token, error) {
+	b, err := ioutil.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := &Token{}
+	return token, json.Unmarshal(b, token)
+}
+
+func (store *FileTokenStore) SetToken(token *Token) error {
+	if token == nil {
+		err := os.Remove(store.Path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	// token gives full access to the account so nobody else should read it
+	return ioutil.WriteFile(store.Path, b, 0600)
+}
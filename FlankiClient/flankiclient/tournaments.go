@@ -0,0 +1,72 @@
+package flankiclient
+
+import (
+	"FlankiRest/models"
+	"fmt"
+)
+
+// Standings is the response of /tournaments/{id}/standings
+type Standings struct {
+	State     models.TournamentState   `json:"state"`
+	WinnerID  *uint                    `json:"winner_id"`
+	Matches   []models.TournamentMatch `json:"matches"`
+	Standings []models.Standing        `json:"standings"`
+}
+
+// CreateTournament creates tournament owned by the user, teams can register until it's started
+func (client *Client) CreateTournament(tournament models.Tournament) (*models.Tournament, error) {
+	created := &models.Tournament{}
+	if err := client.do("POST", "/tournaments", tournament, created, true); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (client *Client) Tournaments() ([]models.Tournament, error) {
+	var tournaments []models.Tournament
+	err := client.do("GET", "/tournaments", nil, &tournaments, true)
+	return tournaments, err
+}
+
+func (client *Client) Tournament(id uint) (*models.Tournament, error) {
+	tournament := &models.Tournament{}
+	if err := client.do("GET", fmt.Sprintf("/tournaments/%d", id), nil, tournament, true); err != nil {
+		return nil, err
+	}
+	return tournament, nil
+}
+
+// RegisterTeam registers team captained by the user, who has to be one of its players
+func (client *Client) RegisterTeam(tournamentID uint, team models.TeamRegistration) (*models.TournamentTeam, error) {
+	registered := &models.TournamentTeam{}
+	if err := client.do("POST", fmt.Sprintf("/tournaments/%d/teams", tournamentID), team, registered, true); err != nil {
+		return nil, err
+	}
+	return registered, nil
+}
+
+// StartTournament generates the bracket of the user's tournament and creates lobbies of the first matches
+func (client *Client) StartTournament(id uint) (*models.Tournament, error) {
+	tournament := &models.Tournament{}
+	if err := client.do("POST", fmt.Sprintf("/tournaments/%d/start", id), nil, tournament, true); err != nil {
+		return nil, err
+	}
+	return tournament, nil
+}
+
+// SpawnLobbies retries creating lobbies of matches of the user's tournament which couldn't get them before
+func (client *Client) SpawnLobbies(id uint) (*models.Tournament, error) {
+	tournament := &models.Tournament{}
+	if err := client.do("POST", fmt.Sprintf("/tournaments/%d/spawn", id), nil, tournament, true); err != nil {
+		return nil, err
+	}
+	return tournament, nil
+}
+
+func (client *Client) Standings(id uint) (*Standings, error) {
+	standings := &Standings{}
+	if err := client.do("GET", fmt.Sprintf("/tournaments/%d/standings", id), nil, standings, true); err != nil {
+		return nil, err
+	}
+	return standings, nil
+}
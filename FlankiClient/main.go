@@ -0,0 +1,350 @@
+package main
+
+import (
+	"FlankiClient/flankiclient"
+	"FlankiRest/errors"
+	"FlankiRest/models"
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const usage = `usage: flanki [flags] <command> [arguments]
+
+commands:
+  register <nickname> <email> <password> <male|female>
+  login <email> <password>
+  logout
+  me
+  players
+  player <id>
+  ranking
+  lobbies
+  lobby <id>
+  my-lobby
+  create-lobby <name> <player limit> [password]
+  join <lobby id> <red|blue> [password]
+  leave
+  kick <player id>
+  submit <red|blue>
+  close-lobby
+  delete-lobby
+  results
+  confirm-result <lobby id>
+  dispute-result <lobby id> <reason>
+  invites
+  create-invite [player id]
+  revoke-invite <code>
+  join-invite <code> [red|blue]
+  friends
+  friend-requests
+  add-friend <player id>
+  accept-friend <player id>
+  decline-friend <player id>
+  remove-friend <player id>
+  follow <player id>
+  unfollow <player id>
+  notifications [unread]
+  read-notifications [id...]   marks all notifications as read when no ids are given
+  tournaments
+  tournament <id>
+  create-tournament <name> <single_elimination|double_elimination|round_robin> <team size> <max teams>
+  register-team <tournament id> <name> <player id...>
+  start-tournament <id>
+  standings <id>
+  export             writes ZIP archive of your data to stdout
+  delete-me
+  remember-password <email>
+  reset-password <code> <new password>
+  upload-image <file>
+  rooms
+  chat <room>        reads messages from stdin and prints everything said in the room
+  chat-messages
+  delete-chat-messages
+  jobs               admins only
+
+flags:
+`
+
+func main() {
+	home := os.Getenv("HOME")
+	apiURL := flag.String("api", envOrDefault("FLANKI_API_URL", "http://localhost:8080"), "url of Flanki api")
+	chatURL := flag.String("chat", envOrDefault("FLANKI_CHAT_URL", "http://localhost:8000"), "url of Flanki chat")
+	tokenFile := flag.String("token", filepath.Join(home, ".flanki_token"), "file keeping access token between runs")
+	language := flag.String("lang", envOrDefault("FLANKI_LANG", ""), "language of error messages e.g. pl")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := flankiclient.NewClient(*apiURL, *chatURL)
+	client.Tokens = &flankiclient.FileTokenStore{Path: *tokenFile}
+	client.Language = *language
+
+	if err := run(client, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fields := flankiclient.InvalidFields(err)
+		if len(fields) == 0 {
+			fmt.Fprintln(os.Stderr, "error: "+err.Error())
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "error: "+err.(*errors.ApiError).Message)
+		for _, field := range fields {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", field.Field, field.Message)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(client *flankiclient.Client, command string, args []string) error {
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	id := func(i int) uint {
+		value, err := strconv.ParseUint(arg(i), 10, 32)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid id: '%s'\n", arg(i))
+			os.Exit(2)
+		}
+		return uint(value)
+	}
+	required := func(n int) {
+		if len(args) < n {
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+
+	switch command {
+	case "register":
+		required(4)
+		return client.Register(models.Account{Nickname: arg(0), Email: arg(1), Password: arg(2), Sex: arg(3)})
+	case "login":
+		required(2)
+		return printResult(client.Login(arg(0), arg(1)))
+	case "logout":
+		return client.Logout()
+	case "me":
+		return printResult(client.Me())
+	case "players":
+		return printResult(client.Players())
+	case "player":
+		required(1)
+		return printResult(client.Player(id(0)))
+	case "ranking":
+		return printResult(client.Ranking())
+	case "lobbies":
+		return printResult(client.Lobbies())
+	case "lobby":
+		required(1)
+		return printResult(client.Lobby(id(0)))
+	case "my-lobby":
+		return printResult(client.CurrentLobby())
+	case "create-lobby":
+		required(2)
+		limit, err := strconv.ParseUint(arg(1), 10, 32)
+		if err != nil {
+			return err
+		}
+		private := arg(2) != ""
+		return printResult(client.CreateLobby(models.Lobby{Name: arg(0), PlayerLimit: uint(limit), Private: &private, Password: arg(2)}))
+	case "join":
+		required(2)
+		return client.JoinLobby(id(0), models.TeamColor(arg(1)), arg(2))
+	case "leave":
+		return client.LeaveLobby()
+	case "kick":
+		required(1)
+		return client.KickPlayer(id(0))
+	case "submit":
+		required(1)
+		return client.SubmitResults(models.TeamColor(arg(0)))
+	case "close-lobby":
+		return client.CloseLobby()
+	case "delete-lobby":
+		return client.DeleteLobby()
+	case "results":
+		return printResult(client.Results())
+	case "confirm-result":
+		required(1)
+		return client.ConfirmResult(id(0))
+	case "dispute-result":
+		required(2)
+		return client.DisputeResult(id(0), arg(1))
+	case "invites":
+		return printResult(client.Invites())
+	case "create-invite":
+		request := models.InviteRequest{}
+		if arg(0) != "" {
+			playerID := id(0)
+			request.PlayerID = &playerID
+		}
+		return printResult(client.CreateInvite(request))
+	case "revoke-invite":
+		required(1)
+		return client.RevokeInvite(arg(0))
+	case "join-invite":
+		required(1)
+		return printResult(client.JoinWithInvite(arg(0), models.TeamColor(arg(1))))
+	case "friends":
+		return printResult(client.Friends())
+	case "friend-requests":
+		return printResult(client.FriendRequests())
+	case "add-friend":
+		required(1)
+		return printResult(client.AddFriend(id(0)))
+	case "accept-friend":
+		required(1)
+		return printResult(client.AcceptFriend(id(0)))
+	case "decline-friend":
+		required(1)
+		return client.DeclineFriend(id(0))
+	case "remove-friend":
+		required(1)
+		return client.RemoveFriend(id(0))
+	case "follow":
+		required(1)
+		return client.Follow(id(0))
+	case "unfollow":
+		required(1)
+		return client.Unfollow(id(0))
+	case "notifications":
+		return printResult(client.Notifications(arg(0) == "unread"))
+	case "read-notifications":
+		ids := make([]uint, len(args))
+		for i := range args {
+			ids[i] = id(i)
+		}
+		return printResult(client.MarkNotificationsRead(ids...))
+	case "tournaments":
+		return printResult(client.Tournaments())
+	case "tournament":
+		required(1)
+		return printResult(client.Tournament(id(0)))
+	case "create-tournament":
+		required(4)
+		return printResult(client.CreateTournament(models.Tournament{
+			Name: arg(0), Format: models.TournamentFormat(arg(1)), TeamSize: id(2), MaxTeams: id(3),
+		}))
+	case "register-team":
+		required(3)
+		players := make([]uint, len(args)-2)
+		for i := range players {
+			players[i] = id(i + 2)
+		}
+		return printResult(client.RegisterTeam(id(0), models.TeamRegistration{Name: arg(1), Players: players}))
+	case "start-tournament":
+		required(1)
+		return printResult(client.StartTournament(id(0)))
+	case "standings":
+		required(1)
+		return printResult(client.Standings(id(0)))
+	case "export":
+		archive, err := client.Export()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(archive)
+		return err
+	case "delete-me":
+		return client.DeleteMe()
+	case "remember-password":
+		required(1)
+		return client.RememberPassword(arg(0))
+	case "reset-password":
+		required(2)
+		return client.ResetPassword(arg(0), arg(1))
+	case "upload-image":
+		required(1)
+		return uploadImage(client, arg(0))
+	case "rooms":
+		return printResult(client.Rooms())
+	case "chat":
+		required(1)
+		return chat(client, arg(0))
+	case "chat-messages":
+		return printResult(client.ChatMessages())
+	case "delete-chat-messages":
+		return client.DeleteChatMessages()
+	case "jobs":
+		return printResult(client.Jobs())
+	}
+	flag.Usage()
+	os.Exit(2)
+	return nil
+}
+
+func uploadImage(client *flankiclient.Client, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// content type is detected from the first 512 bytes
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	return client.UploadImage(http.DetectContentType(head[:n]), f)
+}
+
+func chat(client *flankiclient.Client, room string) error {
+	conn, err := client.JoinRoom(room)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if err := conn.Send(scanner.Text()); err != nil {
+				fmt.Fprintln(os.Stderr, "error: "+err.Error())
+				return
+			}
+		}
+		conn.Close()
+	}()
+
+	for {
+		msg, err := conn.Receive(0)
+		if err != nil {
+			return nil
+		}
+		fmt.Printf("[%s] %s: %s\n", msg.Time.Format(time.Kitchen), msg.Nickname, msg.Text)
+	}
+}
+
+func printResult(result interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func envOrDefault(key string, defaultValue string) string {
+	if value, found := os.LookupEnv(key); found {
+		return value
+	}
+	return defaultValue
+}
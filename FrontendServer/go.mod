@@ -1,3 +1,5 @@
 module FlankiRest/FrontendServer
 
-require github.com/gorilla/mux v1.7.0 // indirect
+go 1.27.1
+
+require github.com/gorilla/mux v1.7.0
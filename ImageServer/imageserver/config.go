@@ -0,0 +1,117 @@
+package imageserver
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joeshaw/envdecode"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config of the image server is read from sources in this order, every one overriding the previous ones:
+// defaults, YAML file given by -config flag or CONFIG_FILE variable, environment variables, command line flags
+type Config struct {
+	Port      string `yaml:"port" env:"IMAGE_SERVER_PORT"`
+	Directory string `yaml:"directory" env:"IMAGES_DIRECTORY"`
+
+	// file the config was read from, empty when there was none
+	File string `yaml:"-"`
+	// set by -print-config, the config should be printed instead of starting the server
+	PrintConfig bool `yaml:"-"`
+}
+
+// DefaultConfig returns config with values used when no source sets them
+func DefaultConfig() *Config {
+	return &Config{Port: "5555", Directory: "./images/"}
+}
+
+// LoadConfig reads config from all sources, args are command line arguments without program name.
+// Invalid config is returned together with the error so that it can still be printed
+func LoadConfig(args []string) (*Config, error) {
+	// flags are parsed twice, at first only to find the file, then to override values read from other sources
+	scratch := DefaultConfig()
+	if err := newFlagSet(scratch, os.Stderr).Parse(args); err != nil {
+		return nil, err
+	}
+	path := scratch.File
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := envdecode.Decode(cfg); err != nil && err != envdecode.ErrNoTargetFieldsAreSet {
+		return nil, fmt.Errorf("reading environment variables: %s", err)
+	}
+	if err := newFlagSet(cfg, ioutil.Discard).Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.File = path
+	return cfg, cfg.Validate()
+}
+
+func newFlagSet(cfg *Config, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("image_server", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.File, "config", cfg.File, "YAML file with config, CONFIG_FILE variable is used when not given")
+	flags.BoolVar(&cfg.PrintConfig, "print-config", cfg.PrintConfig, "print effective config and exit")
+
+	flags.StringVar(&cfg.Port, "port", cfg.Port, "port the server listens on")
+	flags.StringVar(&cfg.Directory, "directory", cfg.Directory, "directory images are stored in")
+	return flags
+}
+
+func (cfg *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %s", err)
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	// misspelled keys would be silently ignored otherwise
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("reading config file %s: %s", path, err)
+	}
+	return nil
+}
+
+// Validate reports all missing and malformed values at once
+func (cfg *Config) Validate() error {
+	var problems []string
+	if number, err := strconv.Atoi(cfg.Port); err != nil || number <= 0 || number > 65535 {
+		problems = append(problems, fmt.Sprintf("port '%s' is not a valid port", cfg.Port))
+	}
+	if cfg.Directory == "" {
+		problems = append(problems, "directory is required")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Print writes effective config as YAML, image server's config has no secrets
+func (cfg *Config) Print(w io.Writer) error {
+	if cfg.File != "" {
+		if _, err := fmt.Fprintf(w, "# read from %s\n", cfg.File); err != nil {
+			return err
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,84 @@
+package imageserver
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	metricsNamespace = "flanki"
+	metricsSubsystem = "images"
+)
+
+var (
+	requestsMetric = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name: "http_requests_total",
+		Help: "Number of served requests by route template, method and status",
+	}, []string{"route", "method", "status"})
+
+	requestDurationMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name:    "http_request_duration_seconds",
+		Help:    "Time of serving requests by route template, method and status",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	uploadSizeMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace, Subsystem: metricsSubsystem,
+		Name:    "upload_size_bytes",
+		Help:    "Size of uploaded images by content type",
+		Buckets: prometheus.ExponentialBuckets(16*1024, 2, 9), // 16KB up to 4MB, above MAX_SIZE
+	}, []string{"content_type"})
+)
+
+// registry separate from prometheus' default one, services running in one process (e.g. in tests) don't mix their metrics
+var metricsRegistry = newMetricsRegistry()
+
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		requestsMetric, requestDurationMetric, uploadSizeMetric,
+	)
+	return registry
+}
+
+// MetricsHandler serves image server's metrics in prometheus' text format
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// counts served requests and measures their time labeled with route's template
+func requestMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		status := strconv.Itoa(recorder.status)
+		requestsMetric.WithLabelValues(route, r.Method, status).Inc()
+		requestDurationMetric.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(code int) {
+	recorder.status = code
+	recorder.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,99 @@
+package imageserver
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const MAX_SIZE = 3 * (1 << (10 * 2))
+
+type ImageServer struct {
+	ImagesDirectory string
+	Router          *mux.Router
+	logEntry        *logrus.Entry
+}
+
+// NewImageServer creates server storing and serving images from given directory
+func NewImageServer(imagesDirectory string, logEntry *logrus.Entry) *ImageServer {
+	server := &ImageServer{
+		ImagesDirectory: imagesDirectory,
+		Router:          mux.NewRouter(),
+		logEntry:        logEntry,
+	}
+	server.Router.HandleFunc("/images/{id:[0-9]+}", server.deleteFile).Methods("DELETE")
+	server.Router.PathPrefix("/images/").Handler(
+		http.StripPrefix("/images/", http.FileServer(http.Dir(imagesDirectory)))).Methods("GET")
+	server.Router.HandleFunc("/upload/{id:[0-9]+}", server.uploadFile).Methods("POST")
+	server.Router.Handle("/metrics", MetricsHandler()).Methods("GET")
+	health := newHealthChecker("flanki-images", map[string]healthCheck{"images_directory": server.checkImagesDirectory})
+	server.Router.HandleFunc("/healthz", health.liveness).Methods("GET")
+	server.Router.HandleFunc("/readyz", health.readiness).Methods("GET")
+	server.Router.HandleFunc("/status", health.statusPage).Methods("GET")
+	server.Router.Use(tracingMiddleware, requestMetricsMiddleware)
+	return server
+}
+
+func (server *ImageServer) RespondWithStatus(w http.ResponseWriter, code int, data map[string]string) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		server.logEntry.Error("Got error while responding: " + err.Error())
+	}
+}
+
+func Message(msg string) map[string]string {
+	return map[string]string{"message": msg}
+}
+
+func (server *ImageServer) uploadFile(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_SIZE)
+	defer r.Body.Close()
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "image/jpeg" && contentType != "image/png" {
+		server.logEntry.Error("Invalid content type: ", contentType)
+		server.RespondWithStatus(w, 400, Message("Invalid content type: "+contentType))
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		server.logEntry.Error("Error while reading request: " + err.Error())
+		server.RespondWithStatus(w, 400, Message(err.Error()))
+		return
+	}
+	vars := mux.Vars(r)
+	f, err := os.Create(filepath.Join(server.ImagesDirectory, vars["id"]))
+	if err != nil {
+		server.logEntry.Error("Error while creating file: " + err.Error())
+		server.RespondWithStatus(w, 500, Message("Failed to save the file"))
+		return
+	}
+	defer f.Close()
+	f.Write(b)
+	uploadSizeMetric.WithLabelValues(r.Header.Get("Content-Type")).Observe(float64(len(b)))
+	server.RespondWithStatus(w, 200, Message("File has been uploaded!"))
+	server.logEntry.Debug("Uploaded file with id: ", vars["id"])
+	return
+}
+
+// deleteFile removes avatar of erased account, 404 tells the app there was none
+func (server *ImageServer) deleteFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := os.Remove(filepath.Join(server.ImagesDirectory, vars["id"]))
+	if os.IsNotExist(err) {
+		server.RespondWithStatus(w, 404, Message("File has not been found"))
+		return
+	}
+	if err != nil {
+		server.logEntry.Error("Error while deleting file: " + err.Error())
+		server.RespondWithStatus(w, 500, Message("Failed to delete the file"))
+		return
+	}
+	server.RespondWithStatus(w, 200, Message("File has been deleted"))
+	server.logEntry.Debug("Deleted file with id: ", vars["id"])
+}
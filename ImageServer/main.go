@@ -1,15 +1,21 @@
 package main
 
 import (
-	"encoding/json"
-	"github.com/gorilla/mux"
+	"ImageService/imageserver"
+	"context"
+	"flag"
 	"github.com/sirupsen/logrus"
 	"github.com/x-cray/logrus-prefixed-formatter"
-	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// time given to requests being served to finish when shutting down
+const shutdownTimeout = 15 * time.Second
+
 var (
 	HomeFolder  = "."
 	logger *logrus.Logger
@@ -29,50 +35,48 @@ func init() {
 
 }
 
-func RespondWithStatus(w http.ResponseWriter, code int, data map[string] string) {
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(code)
-	err := json.NewEncoder(w).Encode(data)
+func main() {
+
+	cfg, err := imageserver.LoadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if cfg != nil && cfg.PrintConfig {
+		if printErr := cfg.Print(os.Stdout); printErr != nil {
+			logEntry.Fatal(printErr)
+		}
+		if err == nil {
+			return
+		}
+	}
 	if err != nil {
-		logEntry.Error("Got error while responding: " + err.Error())
+		logEntry.Fatal(err)
 	}
-}
-
-func Message(msg string) map[string] string {
-	return map[string] string {"message": msg}
-}
 
-func uploadFile(w http.ResponseWriter, r *http.Request) {
-	const MAX_SIZE = 3 * (1 << (10 * 2))
-	r.Body = http.MaxBytesReader(w,r.Body, MAX_SIZE)
-	defer r.Body.Close()
+	server := imageserver.NewImageServer(cfg.Directory, logEntry)
+	logger.Info("Image server running on port: " + cfg.Port)
+	shutdownTracing, err := imageserver.SetupTracing("flanki-images")
+	if err != nil {
+		logEntry.Fatal("Couldn't set up tracing: ", err)
+	}
+	httpServer := &http.Server{Addr: ":" + cfg.Port, Handler: server.Router}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpServer.ListenAndServe() }()
 
-	if contentType := r.Header.Get("Content-Type"); contentType != "image/jpeg" && contentType != "image/png" {
-		logEntry.Error("Invalid content type: ", contentType)
-		RespondWithStatus(w, 400, Message("Invalid content type: " + contentType))
-		return
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err = <-serveErr:
+	case sig := <-signals:
+		// uploads being received are finished before exiting
+		logger.Info("Received ", sig, ", shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		err = httpServer.Shutdown(ctx)
+		cancel()
 	}
-	b, err := ioutil.ReadAll(r.Body)
+	shutdownTracing(context.Background())
 	if err != nil {
-		logEntry.Error("Error while reading request: " + err.Error())
-		RespondWithStatus(w, 400, Message(err.Error()))
-		return
+		logEntry.Fatal(err)
 	}
-	vars := mux.Vars(r)
-	f, err := os.Create("./images/" + vars["id"])
-	defer f.Close()
-	f.Write(b)
-	RespondWithStatus(w,200, Message("File has been uploaded!"))
-	logEntry.Debug("Uploaded file with id: ", vars["id"])
-	return
+	logger.Info("Image server has been shut down")
 }
-
-func main() {
-
-	router := mux.NewRouter()
-	router.PathPrefix("/images/").Handler(
-		http.StripPrefix("/images/", http.FileServer(http.Dir("./images/")))).Methods("GET")
-	router.HandleFunc("/upload/{id:[0-9]+}", uploadFile).Methods("POST")
-	logger.Info("Image server running on port: 5555")
-	logEntry.Fatal(http.ListenAndServe(":5555", router))
-}
\ No newline at end of file
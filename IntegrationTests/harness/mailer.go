@@ -0,0 +1,62 @@
+package harness
+
+import (
+	"FlankiRest/services"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Email struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// FakeMailer replaces SMTP delivery, every email is kept in memory so that scenarios can read it
+type FakeMailer struct {
+	mutex  sync.Mutex
+	emails []Email
+}
+
+func NewFakeMailer() *FakeMailer {
+	return &FakeMailer{}
+}
+
+func (mailer *FakeMailer) SendEmail(sender services.EmailSender, useDefaultEmail bool) error {
+	body, err := ioutil.ReadAll(sender.Body)
+	if err != nil {
+		return err
+	}
+	mailer.mutex.Lock()
+	mailer.emails = append(mailer.emails, Email{To: sender.To, Subject: sender.Subject, Body: string(body)})
+	mailer.mutex.Unlock()
+	return nil
+}
+
+// Emails returns copy of all emails sent so far
+func (mailer *FakeMailer) Emails() []Email {
+	mailer.mutex.Lock()
+	defer mailer.mutex.Unlock()
+	return append([]Email(nil), mailer.emails...)
+}
+
+// WaitForEmail waits until an email to given address is sent, emails are sent asynchronously by the app
+func (mailer *FakeMailer) WaitForEmail(address string, timeout time.Duration) (Email, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		emails := mailer.Emails()
+		for i := len(emails) - 1; i >= 0; i-- {
+			for _, to := range emails[i].To {
+				if strings.EqualFold(to, address) {
+					return emails[i], true
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			return Email{}, false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,173 @@
+package harness
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// ErrNoDatabase is returned when there is neither external database server configured
+// nor postgres binaries available to start an ephemeral one
+var ErrNoDatabase = errors.New("no postgres server available, set FLANKI_TEST_DB_HOST or put initdb and postgres in PATH")
+
+// Postgres is a database created only for a single test run, it is dropped (or its whole cluster removed) on Close
+type Postgres struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+
+	dataDir string
+	server  *exec.Cmd
+	admin   *sql.DB
+}
+
+// URI returns connection string in the same format services use
+func (pg *Postgres) URI() string {
+	return fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", pg.Host, pg.Port, pg.User, pg.Name, pg.Password)
+}
+
+// StartPostgres creates fresh database for tests.
+// When FLANKI_TEST_DB_HOST is set, randomly named database is created on that server
+// (FLANKI_TEST_DB_PORT, FLANKI_TEST_DB_USER and FLANKI_TEST_DB_PASS are used as well),
+// otherwise new cluster is initialized in temporary directory and started on a free local port.
+func StartPostgres() (*Postgres, error) {
+	if host, found := os.LookupEnv("FLANKI_TEST_DB_HOST"); found {
+		pg := &Postgres{
+			Host:     host,
+			Port:     envOrDefault("FLANKI_TEST_DB_PORT", "5432"),
+			User:     envOrDefault("FLANKI_TEST_DB_USER", "postgres"),
+			Password: os.Getenv("FLANKI_TEST_DB_PASS"),
+			Name:     fmt.Sprintf("flanki_test_%d", rand.New(rand.NewSource(time.Now().UnixNano())).Int31()),
+		}
+		return pg, pg.createDatabase()
+	}
+	return startEphemeralCluster()
+}
+
+func startEphemeralCluster() (*Postgres, error) {
+	initdb, err := lookPostgresBinary("initdb")
+	if err != nil {
+		return nil, ErrNoDatabase
+	}
+	postgres, err := lookPostgresBinary("postgres")
+	if err != nil {
+		return nil, ErrNoDatabase
+	}
+
+	dataDir, err := ioutil.TempDir("", "flanki_postgres")
+	if err != nil {
+		return nil, err
+	}
+	pg := &Postgres{Host: "127.0.0.1", User: "postgres", Password: "toor", Name: "flanki_test", dataDir: dataDir}
+
+	out, err := exec.Command(initdb, "-D", dataDir, "-U", pg.User, "--auth=trust", "--encoding=UTF8").CombinedOutput()
+	if err != nil {
+		pg.Close()
+		return nil, fmt.Errorf("initdb failed: %s: %s", err.Error(), out)
+	}
+
+	pg.Port, err = freePort()
+	if err != nil {
+		pg.Close()
+		return nil, err
+	}
+
+	pg.server = exec.Command(postgres, "-D", dataDir, "-p", pg.Port, "-k", dataDir, "-c", "listen_addresses="+pg.Host, "-c", "fsync=off")
+	logFile, err := os.Create(filepath.Join(dataDir, "postgres.log"))
+	if err == nil {
+		pg.server.Stdout = logFile
+		pg.server.Stderr = logFile
+	}
+	if err := pg.server.Start(); err != nil {
+		pg.Close()
+		return nil, fmt.Errorf("failed to start postgres: %s", err.Error())
+	}
+
+	if err := pg.createDatabase(); err != nil {
+		pg.Close()
+		return nil, err
+	}
+	return pg, nil
+}
+
+// connects to maintenance database, waiting for server to come up, and creates test database
+func (pg *Postgres) createDatabase() error {
+	uri := fmt.Sprintf("host=%s port=%s user=%s dbname=postgres sslmode=disable password=%s", pg.Host, pg.Port, pg.User, pg.Password)
+	admin, err := sql.Open("postgres", uri)
+	if err != nil {
+		return err
+	}
+	pg.admin = admin
+
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		err = admin.Ping()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("postgres is not responding: %s", err.Error())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	_, err = admin.Exec("CREATE DATABASE " + pg.Name)
+	return err
+}
+
+// Close drops test database and stops ephemeral cluster if it has been started
+func (pg *Postgres) Close() {
+	if pg.admin != nil {
+		if pg.server == nil {
+			_, _ = pg.admin.Exec("DROP DATABASE IF EXISTS " + pg.Name)
+		}
+		_ = pg.admin.Close()
+	}
+	if pg.server != nil && pg.server.Process != nil {
+		_ = pg.server.Process.Signal(os.Interrupt)
+		_ = pg.server.Wait()
+	}
+	if pg.dataDir != "" {
+		_ = os.RemoveAll(pg.dataDir)
+	}
+}
+
+// looks for binary in PATH and in default debian's installation directories
+func lookPostgresBinary(name string) (string, error) {
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	matches, _ := filepath.Glob("/usr/lib/postgresql/*/bin/" + name)
+	if len(matches) == 0 {
+		return "", exec.ErrNotFound
+	}
+	return matches[len(matches)-1], nil
+}
+
+func freePort() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	return port, err
+}
+
+func envOrDefault(key string, defaultValue string) string {
+	if value, found := os.LookupEnv(key); found {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,221 @@
+package harness
+
+import (
+	auth "AuthorizationServer/authorization"
+	authdb "AuthorizationServer/database"
+	authutils "AuthorizationServer/utils"
+	"Chat/websocketchat"
+	"FlankiClient/flankiclient"
+	"FlankiRest/app"
+	"FlankiRest/config"
+	"FlankiRest/jobs"
+	"FlankiRest/logger"
+	"FlankiRest/services"
+	"ImageService/imageserver"
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"golang.org/x/oauth2"
+)
+
+// Stack is the whole system (FlankiApp, AuthorizationServer, ImageServer and Chat) running in the test process,
+// every service listens on its own random local port and all of them share one ephemeral database.
+//
+// Services keep their configuration in package level variables so only one stack can be started per test binary.
+type Stack struct {
+	Postgres *Postgres
+	Mailer   *FakeMailer
+
+	App    *httptest.Server
+	Auth   *httptest.Server
+	Images *httptest.Server
+	Chat   *httptest.Server
+
+	ImagesDirectory string
+	// chat keeps sent messages there
+	MessagesDirectory string
+
+	application *app.App
+	authServer  *auth.AuthorizationServer
+	authDB      *authdb.AuthDatabase
+}
+
+// Configure loads configs of the app and the authorization server the way their mains do,
+// the values come from .env file of the tests so it has to be called before any service is used
+func Configure() error {
+	if _, err := config.Load(nil); err != nil {
+		return err
+	}
+	_, err := auth.LoadConfig(nil)
+	return err
+}
+
+// Start boots all services, returns ErrNoDatabase when there is no way to get postgres server
+func Start() (*Stack, error) {
+	pg, err := StartPostgres()
+	if err != nil {
+		return nil, err
+	}
+	stack := &Stack{Postgres: pg, Mailer: NewFakeMailer()}
+
+	if err := stack.startAuthorizationServer(); err != nil {
+		stack.Close()
+		return nil, err
+	}
+	if err := stack.startImageServer(); err != nil {
+		stack.Close()
+		return nil, err
+	}
+	stack.startApp()
+	if err := stack.startChat(); err != nil {
+		stack.Close()
+		return nil, err
+	}
+	return stack, nil
+}
+
+func (stack *Stack) startAuthorizationServer() error {
+	cfg := auth.GetAuthServerConfig()
+	cfg.DBHost, cfg.DBPort, cfg.DBUsername, cfg.DBPassword, cfg.DBName = stack.Postgres.Host, stack.Postgres.Port, stack.Postgres.User, stack.Postgres.Password, stack.Postgres.Name
+
+	stack.authDB = &authdb.AuthDatabase{}
+	if err := stack.authDB.NewConnection(stack.Postgres.URI()); err != nil {
+		return err
+	}
+	if err := auth.MigrateLockouts(stack.authDB.DB()); err != nil {
+		return err
+	}
+	stack.authServer = auth.NewAuthorizationServer(stack.authDB, authutils.AuthLogger())
+	stack.authServer.Initialize(cfg)
+	stack.Auth = httptest.NewServer(stack.authServer.Router)
+
+	// FlankiApp reaches authorization server through its own config
+	appAuthCfg := config.GetAuthServerConfig()
+	appAuthCfg.Domain, appAuthCfg.Port = splitURL(stack.Auth.URL)
+	cfg.Domain, cfg.Port = appAuthCfg.Domain, appAuthCfg.Port
+	return nil
+}
+
+func (stack *Stack) startImageServer() error {
+	dir, err := ioutil.TempDir("", "flanki_images")
+	if err != nil {
+		return err
+	}
+	stack.ImagesDirectory = dir
+	server := imageserver.NewImageServer(dir, logger.GetGlobalLogger().WithField("prefix", "[IMAGE SERVER]"))
+	stack.Images = httptest.NewServer(server.Router)
+
+	imgCfg := config.GetImageServerConfig()
+	imgCfg.Domain, imgCfg.Port = splitURL(stack.Images.URL)
+	return nil
+}
+
+func (stack *Stack) startApp() {
+	services.SetMailer(stack.Mailer)
+	services.TemplatesDirectory = filepath.Join(repositoryRoot(), "FlankiApp", "templates")
+
+	client := auth.GetAuthServerConfig().Clients[0]
+	oauthCfg := &oauth2.Config{
+		ClientID:     client.ID,
+		ClientSecret: client.Secret,
+		Scopes:       []string{"all"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  stack.Auth.URL + "/authorize",
+			TokenURL: stack.Auth.URL + "/token",
+		},
+	}
+
+	// all players of the tests log in and join lobbies from the same address
+	rateLimits := config.GetRateLimitConfig()
+	for route, limits := range rateLimits.Routes {
+		limits.IP = config.Limit{}
+		rateLimits.Routes[route] = limits
+	}
+
+	appCfg := config.GetAppConfig()
+	appCfg.DBHost, appCfg.DBPort, appCfg.DBUsername, appCfg.DBPassword, appCfg.DBName = stack.Postgres.Host, stack.Postgres.Port, stack.Postgres.User, stack.Postgres.Password, stack.Postgres.Name
+
+	stack.application = app.NewApp(oauthCfg)
+	stack.application.SetLogger(logger.GetGlobalLogger())
+	stack.application.Initialize(appCfg)
+	stack.App = httptest.NewServer(stack.application.Router)
+}
+
+func (stack *Stack) startChat() error {
+	dir, err := ioutil.TempDir("", "flanki_messages")
+	if err != nil {
+		return err
+	}
+	stack.MessagesDirectory = dir
+	if err := websocketchat.History.SetDirectory(dir); err != nil {
+		return err
+	}
+	websocketchat.GetFlankiChecker.SetEndpoints(stack.App.URL, stack.Auth.URL)
+	stack.Chat = httptest.NewServer(websocketchat.NewChatServer().Router())
+
+	// the app exports and deletes messages of its users through the chat
+	chatCfg := config.GetChatServerConfig()
+	chatCfg.Domain, chatCfg.Port = splitURL(stack.Chat.URL)
+	return nil
+}
+
+// Client returns new client of running stack without any user logged in
+func (stack *Stack) Client() *flankiclient.Client {
+	return flankiclient.NewClient(stack.App.URL, stack.Chat.URL)
+}
+
+// Scheduler returns background jobs of the running app, tests run them right away instead of waiting for their intervals
+func (stack *Stack) Scheduler() *jobs.Scheduler {
+	return stack.application.Scheduler
+}
+
+// NewInstanceScheduler returns scheduler of another instance of the app sharing its database, with the same jobs
+func (stack *Stack) NewInstanceScheduler(instance string) *jobs.Scheduler {
+	scheduler := jobs.NewScheduler(jobs.NewPostgresStore(stack.application.ApiDB), instance)
+	for _, job := range stack.Scheduler().Jobs() {
+		scheduler.Add(job)
+	}
+	return scheduler
+}
+
+// Close stops all services and removes database together with uploaded images and sent messages
+func (stack *Stack) Close() {
+	for _, server := range []*httptest.Server{stack.Chat, stack.App, stack.Images, stack.Auth} {
+		if server != nil {
+			server.Close()
+		}
+	}
+	// services are shut down the way they are on SIGTERM, stopping their tickers and closing the app's database
+	if stack.application != nil {
+		_ = stack.application.Shutdown(context.Background())
+	}
+	if stack.authServer != nil {
+		_ = stack.authServer.Shutdown(context.Background())
+	}
+	if stack.authDB != nil && stack.authDB.DB() != nil {
+		_ = stack.authDB.Close()
+	}
+	for _, dir := range []string{stack.ImagesDirectory, stack.MessagesDirectory} {
+		if dir != "" {
+			_ = os.RemoveAll(dir)
+		}
+	}
+	stack.Postgres.Close()
+}
+
+// services build urls by joining domain and port
+func splitURL(rawURL string) (domain string, port string) {
+	u, _ := url.Parse(rawURL)
+	return u.Scheme + "://" + u.Hostname(), u.Port()
+}
+
+func repositoryRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
@@ -0,0 +1,145 @@
+package integration_test
+
+import (
+	auth "AuthorizationServer/authorization"
+	authdb "AuthorizationServer/database"
+	authutils "AuthorizationServer/utils"
+	"Chat/websocketchat"
+	"FlankiRest/config"
+	"FlankiRest/logger"
+	"ImageService/imageserver"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type probe struct {
+	Status  string   `json:"status"`
+	Failing []string `json:"failing"`
+}
+
+func getProbe(t *testing.T, url string, expectedCode int) probe {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("requesting %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectedCode {
+		t.Fatalf("expected %d from %s, got %d", expectedCode, url, resp.StatusCode)
+	}
+	result := probe{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decoding response of %s: %s", url, err)
+	}
+	return result
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// services without database are alive but not ready, and routes which need database tell clients when to retry
+func TestServicesWithoutDatabase(t *testing.T) {
+	appServer := httptest.NewServer(newRoutedApp().Router)
+	defer appServer.Close()
+
+	getProbe(t, appServer.URL+config.API_PREFIX+"/healthz", 200)
+	if readiness := getProbe(t, appServer.URL+config.API_PREFIX+"/readyz", 503); !contains(readiness.Failing, "database") {
+		t.Errorf("database is not listed among failing checks: %v", readiness.Failing)
+	}
+	resp, err := http.Get(appServer.URL + config.API_PREFIX + "/lobbies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 503 || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After for route needing database, got %d '%s': %s", resp.StatusCode, resp.Header.Get("Retry-After"), body)
+	}
+	resp, err = http.Get(appServer.URL + config.API_PREFIX + "/metrics")
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("routes without database should be still served: %v %v", err, resp)
+	}
+	resp.Body.Close()
+
+	authServer := auth.NewAuthorizationServer(&authdb.AuthDatabase{}, authutils.AuthLogger())
+	authServer.Initialize(auth.GetAuthServerConfig())
+	authHttp := httptest.NewServer(authServer.Router)
+	defer authHttp.Close()
+	getProbe(t, authHttp.URL+"/healthz", 200)
+	getProbe(t, authHttp.URL+"/readyz", 503)
+	resp, err = http.Post(authHttp.URL+"/token", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After for token request, got %d", resp.StatusCode)
+	}
+}
+
+func TestImageServerReadiness(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flanki_images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := httptest.NewServer(imageserver.NewImageServer(dir, logger.GetGlobalLogger().WithField("prefix", "[IMAGE SERVER]")).Router)
+	defer images.Close()
+
+	getProbe(t, images.URL+"/readyz", 200)
+	os.RemoveAll(dir)
+	if readiness := getProbe(t, images.URL+"/readyz", 503); !contains(readiness.Failing, "images_directory") {
+		t.Errorf("images directory is not listed among failing checks: %v", readiness.Failing)
+	}
+}
+
+func TestRunningStackIsReady(t *testing.T) {
+	if stack == nil {
+		t.Skip("services are not running")
+	}
+	for _, url := range []string{stack.App.URL + config.API_PREFIX, stack.Auth.URL, stack.Images.URL, stack.Chat.URL} {
+		if readiness := getProbe(t, url+"/readyz", 200); readiness.Status != "ok" {
+			t.Errorf("%s is not ready: %v", url, readiness.Failing)
+		}
+	}
+
+	resp, err := http.Get(stack.App.URL + config.API_PREFIX + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	status := struct {
+		Service string                     `json:"service"`
+		Checks  map[string]json.RawMessage `json:"checks"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range []string{"database", "auth_server", "image_server", "templates"} {
+		if _, ok := status.Checks[check]; !ok {
+			t.Errorf("status page is missing check %s", check)
+		}
+	}
+}
+
+// chat is ready only when it can validate tokens with authorization server and fetch nicknames from the app
+func TestChatReadinessDependsOnAuthorization(t *testing.T) {
+	if stack != nil {
+		t.Skip("chat's endpoints are configured for the running stack")
+	}
+	chatServer := httptest.NewServer(websocketchat.NewChatServer().Router())
+	defer chatServer.Close()
+	getProbe(t, chatServer.URL+"/healthz", 200)
+	if readiness := getProbe(t, chatServer.URL+"/readyz", 503); !contains(readiness.Failing, "auth_server") {
+		t.Errorf("authorization server is not listed among failing checks: %v", readiness.Failing)
+	}
+}
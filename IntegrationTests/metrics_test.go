@@ -0,0 +1,89 @@
+package integration_test
+
+import (
+	auth "AuthorizationServer/authorization"
+	authdb "AuthorizationServer/database"
+	authutils "AuthorizationServer/utils"
+	"Chat/websocketchat"
+	"FlankiRest/logger"
+	"ImageService/imageserver"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url + "/metrics")
+	if err != nil {
+		t.Fatalf("scraping metrics: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200 from %s/metrics, got %d: %s", url, resp.StatusCode, body)
+	}
+	return string(body)
+}
+
+func expectMetrics(t *testing.T, metrics string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if !strings.Contains(metrics, name) {
+			t.Errorf("metric %s is missing", name)
+		}
+	}
+}
+
+// none of the services needs database to serve its metrics
+func TestMetricsAreServedByEveryService(t *testing.T) {
+	appServer := httptest.NewServer(newRoutedApp().Router)
+	defer appServer.Close()
+	if _, err := http.Get(appServer.URL + "/openapi.json"); err != nil {
+		t.Fatalf("requesting specification: %s", err)
+	}
+	expectMetrics(t, scrape(t, appServer.URL),
+		`flanki_app_http_requests_total{method="GET",route="/openapi.json",status="200"} 1`,
+		`flanki_app_http_request_duration_seconds_bucket{method="GET",route="/openapi.json",status="200"`,
+		"flanki_app_db_open_connections",
+		"flanki_app_lobbies_created_total",
+	)
+
+	authServer := auth.NewAuthorizationServer(&authdb.AuthDatabase{}, authutils.AuthLogger())
+	authServer.Initialize(auth.GetAuthServerConfig())
+	authHttp := httptest.NewServer(authServer.Router)
+	defer authHttp.Close()
+	expectMetrics(t, scrape(t, authHttp.URL), "flanki_auth_db_in_use_connections", "go_goroutines")
+
+	chatServer := httptest.NewServer(websocketchat.NewChatServer().Router())
+	defer chatServer.Close()
+	if _, err := http.Get(chatServer.URL + "/chat/rooms"); err != nil {
+		t.Fatalf("listing rooms: %s", err)
+	}
+	expectMetrics(t, scrape(t, chatServer.URL),
+		`flanki_chat_http_requests_total{method="GET",route="/chat/rooms",status="200"}`,
+		"flanki_chat_rooms",
+		"flanki_chat_clients",
+		"flanki_chat_messages_dropped_total",
+	)
+
+	dir, err := ioutil.TempDir("", "flanki_images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	images := httptest.NewServer(imageserver.NewImageServer(dir, logger.GetGlobalLogger().WithField("prefix", "[IMAGE SERVER]")).Router)
+	defer images.Close()
+	resp, err := http.Post(images.URL+"/upload/1", "image/png", bytes.NewReader(make([]byte, 1000)))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("uploading image: %v %v", err, resp)
+	}
+	expectMetrics(t, scrape(t, images.URL),
+		`flanki_images_upload_size_bytes_sum{content_type="image/png"} 1000`,
+		`flanki_images_http_requests_total{method="POST",route="/upload/{id:[0-9]+}",status="200"} 1`,
+	)
+}
@@ -0,0 +1,100 @@
+package integration_test
+
+import (
+	"Chat/websocketchat"
+	"FlankiRest/app"
+	"FlankiRest/config"
+	"FlankiRest/logger"
+	"FlankiRest/openapi"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/oauth2"
+)
+
+// app with routing set but without any connections, enough to inspect its routes
+func newRoutedApp() *app.App {
+	application := app.NewApp(&oauth2.Config{})
+	application.SetLogger(logger.GetGlobalLogger())
+	application.SetRouting(config.API_PREFIX)
+	return application
+}
+
+func forEachRoute(t *testing.T, router *mux.Router, f func(path string, method string)) {
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			f(path, method)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking router: %s", err)
+	}
+}
+
+func TestOpenAPICoversAllAppRoutes(t *testing.T) {
+	application := newRoutedApp()
+	doc := application.OpenAPI()
+	forEachRoute(t, application.Router, func(path string, method string) {
+		if !doc.HasOperation(path, method) {
+			t.Errorf("route %s %s is missing in OpenAPI specification", method, path)
+		}
+	})
+}
+
+func TestOpenAPICoversAllChatRoutes(t *testing.T) {
+	doc := newRoutedApp().OpenAPI()
+	router := websocketchat.NewChatServer().Router().(*mux.Router)
+
+	registered := map[string]bool{}
+	forEachRoute(t, router, func(path string, method string) {
+		registered[method+" "+path] = true
+		if !doc.HasOperation(path, method) {
+			t.Errorf("chat route %s %s is missing in OpenAPI specification", method, path)
+		}
+	})
+	for route := range app.ChatEndpoints {
+		if !registered[route] {
+			t.Errorf("documented chat route %s is not registered by the chat", route)
+		}
+	}
+}
+
+func TestOpenAPIIsServed(t *testing.T) {
+	server := httptest.NewServer(newRoutedApp().Router)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("fetching specification: %s", err)
+	}
+	defer resp.Body.Close()
+
+	doc := &openapi.Document{}
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		t.Fatalf("decoding specification: %s", err)
+	}
+	if resp.StatusCode != 200 || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Fatalf("unexpected response %d: %+v", resp.StatusCode, doc.Info)
+	}
+
+	join := doc.Paths["/lobbies/{id}/join"]["post"]
+	if join == nil || join.RequestBody == nil || len(join.Security) == 0 {
+		t.Fatalf("join lobby operation is not properly documented: %+v", join)
+	}
+	lobby := doc.Components.Schemas["Lobby"]
+	if lobby == nil || lobby.Properties["teams"] == nil || lobby.Properties["id"] == nil {
+		t.Fatalf("lobby schema hasn't been generated from the model: %+v", lobby)
+	}
+}
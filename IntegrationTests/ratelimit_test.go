@@ -0,0 +1,69 @@
+package integration_test
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/errors"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func postLogin(t *testing.T, url string, email string, password string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	resp, err := http.Post(url+config.API_PREFIX+"/user/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("logging in %s: %s", email, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func expectRetryAfter(t *testing.T, resp *http.Response) {
+	t.Helper()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || seconds <= 0 {
+		t.Errorf("expected Retry-After in seconds, got '%s'", resp.Header.Get("Retry-After"))
+	}
+}
+
+// logins are throttled per account before they reach authorization server
+func TestLoginIsThrottledPerAccount(t *testing.T) {
+	rateLimits := config.GetRateLimitConfig()
+	previous := rateLimits.Routes[config.LoginRoute]
+	rateLimits.Routes[config.LoginRoute] = config.RouteLimits{Account: config.Limit{Requests: 2, Per: time.Minute}}
+	defer func() { rateLimits.Routes[config.LoginRoute] = previous }()
+
+	appServer := httptest.NewServer(newRoutedApp().Router)
+	defer appServer.Close()
+
+	for i := 0; i < 2; i++ {
+		if resp := postLogin(t, appServer.URL, "throttled@example.com", "password"); resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatalf("request %d within the limit was throttled", i)
+		}
+	}
+	expectRetryAfter(t, postLogin(t, appServer.URL, "Throttled@example.com", "password"))
+	if resp := postLogin(t, appServer.URL, "other@example.com", "password"); resp.StatusCode == http.StatusTooManyRequests {
+		t.Errorf("other account was throttled")
+	}
+}
+
+// authorization server locks account after failed logins, even the right password is rejected until the lock expires
+func TestAccountIsLockedAfterFailedLogins(t *testing.T) {
+	_, account := newPlayer(t)
+	for i := 0; i < 5; i++ {
+		if _, err := stack.Client().Login(account.Email, "wrong_password"); err != errors.UnauthorizedAccount {
+			t.Fatalf("expected invalid credentials error on attempt %d, got %v", i, err)
+		}
+	}
+	if _, err := stack.Client().Login(account.Email, playersPassword); err != errors.AccountLocked {
+		t.Fatalf("expected locked account error, got %v", err)
+	}
+	expectRetryAfter(t, postLogin(t, stack.App.URL, account.Email, playersPassword))
+}
@@ -0,0 +1,803 @@
+package integration_test
+
+import (
+	"FlankiClient/flankiclient"
+	"FlankiRest/config"
+	"FlankiRest/errors"
+	"FlankiRest/jobs"
+	"FlankiRest/models"
+	"FlankiRest/validation"
+	"IntegrationTests/harness"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var stack *harness.Stack
+
+func TestMain(m *testing.M) {
+	if err := harness.Configure(); err != nil {
+		fmt.Println("failed to load config: " + err.Error())
+		os.Exit(1)
+	}
+	var err error
+	stack, err = harness.Start()
+	if err == harness.ErrNoDatabase {
+		// tests which don't need running services are still run
+		fmt.Println("skipping scenarios: " + err.Error())
+	} else if err != nil {
+		fmt.Println("failed to start services: " + err.Error())
+		os.Exit(1)
+	}
+	code := m.Run()
+	if stack != nil {
+		stack.Close()
+	}
+	os.Exit(code)
+}
+
+var playersCounter int32
+
+const playersPassword = "secret_password"
+
+// registers and logs in new player, every player gets unique nickname and email
+func newPlayer(t *testing.T) (*flankiclient.Client, *models.Account) {
+	t.Helper()
+	if stack == nil {
+		t.Skip("services are not running")
+	}
+	n := atomic.AddInt32(&playersCounter, 1)
+	account := models.Account{
+		Nickname: fmt.Sprintf("player_%d", n),
+		Email:    fmt.Sprintf("player_%d@example.com", n),
+		Password: playersPassword,
+		Sex:      "male",
+	}
+
+	client := stack.Client()
+	if err := client.Register(account); err != nil {
+		t.Fatalf("registering %s: %s", account.Nickname, err)
+	}
+	if _, err := client.Login(account.Email, account.Password); err != nil {
+		t.Fatalf("logging in %s: %s", account.Nickname, err)
+	}
+	me, err := client.Me()
+	if err != nil {
+		t.Fatalf("fetching account of %s: %s", account.Nickname, err)
+	}
+	return client, &me.Account
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	client, account := newPlayer(t)
+	if account.ID == 0 || account.Password != "" {
+		t.Fatalf("unexpected account: %+v", account)
+	}
+
+	if _, err := stack.Client().Login(account.Email, "wrong_password"); err != errors.UnauthorizedAccount {
+		t.Fatalf("expected invalid credentials error, got %v", err)
+	}
+
+	invalid := &flankiclient.Token{AccessToken: "invalid", Expiry: time.Now().Add(time.Hour)}
+	anonymous := stack.Client()
+	_ = anonymous.Tokens.SetToken(invalid)
+	if _, err := anonymous.Me(); err != errors.InvalidToken {
+		t.Fatalf("expected invalid token error, got %v", err)
+	}
+
+	// client remembers credentials and logs in again when its token gets rejected
+	_ = client.Tokens.SetToken(invalid)
+	if _, err := client.Me(); err != nil {
+		t.Fatalf("expected client to log in again, got %v", err)
+	}
+}
+
+func TestPlayMatch(t *testing.T) {
+	owner, ownerAccount := newPlayer(t)
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "integration match", PlayerLimit: 4})
+	if err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+	if err := owner.JoinLobby(lobby.ID, models.Blue, ""); err != nil {
+		t.Fatalf("owner joining lobby: %s", err)
+	}
+
+	teammate, teammateAccount := newPlayer(t)
+	if err := teammate.JoinLobby(lobby.ID, models.Blue, ""); err != nil {
+		t.Fatalf("joining blue team: %s", err)
+	}
+	players := []*flankiclient.Client{owner, teammate}
+	var opponents []*models.Account
+	for i := 0; i < 2; i++ {
+		opponent, account := newPlayer(t)
+		if err := opponent.JoinLobby(lobby.ID, models.Red, ""); err != nil {
+			t.Fatalf("joining red team: %s", err)
+		}
+		players = append(players, opponent)
+		opponents = append(opponents, account)
+	}
+
+	late, _ := newPlayer(t)
+	if err := late.JoinLobby(lobby.ID, models.Red, ""); err != errors.LobbyIsFull {
+		t.Fatalf("expected joining full lobby to fail, got %v", err)
+	}
+
+	if err := owner.SubmitResults(models.Blue); flankiclient.StatusCode(err) != 409 {
+		t.Fatalf("expected results of not started match to be rejected, got %v", err)
+	}
+	if err := owner.StartReadyCheck(); err != nil {
+		t.Fatalf("starting ready check: %s", err)
+	}
+	if err := owner.StartMatch(); err != errors.PlayersNotReady {
+		t.Fatalf("expected match not to start before players are ready, got %v", err)
+	}
+	for _, player := range players {
+		if err := player.SetReady(true); err != nil {
+			t.Fatalf("setting ready flag: %s", err)
+		}
+	}
+	if err := owner.StartMatch(); err != nil {
+		t.Fatalf("starting match: %s", err)
+	}
+	if err := teammate.LeaveLobby(); err != errors.RosterLocked {
+		t.Fatalf("expected teams to be locked during the match, got %v", err)
+	}
+
+	hit := true
+	if _, err := teammate.RecordEvent(lobby.ID, models.MatchEventRequest{Round: 1, Type: models.Throw, PlayerID: teammateAccount.ID, Hit: &hit}); err != errors.NotScorekeeper {
+		t.Fatalf("expected players not to record events, got %v", err)
+	}
+	if _, err := owner.RecordEvent(lobby.ID, models.MatchEventRequest{Round: 1, Type: models.Throw, PlayerID: teammateAccount.ID, Hit: &hit}); err != nil {
+		t.Fatalf("recording throw: %s", err)
+	}
+	if _, err := owner.RecordEvent(lobby.ID, models.MatchEventRequest{Round: 1, Type: models.CanFinished, PlayerID: teammateAccount.ID}); err != nil {
+		t.Fatalf("recording finish: %s", err)
+	}
+
+	if err := owner.SubmitResults(models.Blue); err != nil {
+		t.Fatalf("submitting results: %s", err)
+	}
+
+	info, err := owner.Player(teammateAccount.ID)
+	if err != nil {
+		t.Fatalf("fetching player: %s", err)
+	}
+	if info.Scorekeeping == nil || info.Scorekeeping.Hits != 1 || info.Scorekeeping.Finishes != 1 {
+		t.Fatalf("expected recorded events on player's profile, got %+v", info.Scorekeeping)
+	}
+
+	results, err := owner.Results()
+	if err != nil {
+		t.Fatalf("fetching results: %s", err)
+	}
+	found := false
+	for _, result := range results {
+		if result.ID == lobby.ID {
+			found = result.Winner == models.Blue && result.Status == models.ResultConfirmed && len(result.Players) == 1 &&
+				result.Started != nil && !result.Submitted.Before(*result.Started)
+		}
+	}
+	if !found {
+		t.Fatalf("lobby %d is missing in results or has wrong winner: %+v", lobby.ID, results)
+	}
+
+	for _, account := range []*models.Account{ownerAccount, teammateAccount} {
+		summary, err := owner.PlayerSummary(account.ID)
+		if err != nil {
+			t.Fatalf("fetching summary: %s", err)
+		}
+		if summary.Wins != 1 || summary.Loses != 0 || summary.Points <= 0 {
+			t.Errorf("unexpected winner's summary: %+v", summary)
+		}
+	}
+	for _, account := range opponents {
+		summary, err := owner.PlayerSummary(account.ID)
+		if err != nil {
+			t.Fatalf("fetching summary: %s", err)
+		}
+		if summary.Wins != 0 || summary.Loses != 1 {
+			t.Errorf("unexpected loser's summary: %+v", summary)
+		}
+	}
+
+	ranking, err := stack.Client().Ranking()
+	if err != nil {
+		t.Fatalf("fetching ranking: %s", err)
+	}
+	ranked := map[uint]bool{}
+	for _, summary := range ranking {
+		ranked[summary.PlayerID] = true
+	}
+	if !ranked[ownerAccount.ID] || !ranked[teammateAccount.ID] {
+		t.Fatalf("winners are missing in ranking: %+v", ranking)
+	}
+
+	// lobby has been closed so players are free to join another one
+	if _, err := teammate.CreateLobby(models.Lobby{Name: "rematch", PlayerLimit: 4}); err != nil {
+		t.Fatalf("creating lobby after the match: %s", err)
+	}
+}
+
+func TestPrivateLobbyRequiresPassword(t *testing.T) {
+	owner, _ := newPlayer(t)
+	private := true
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "private lobby", PlayerLimit: 4, Private: &private, Password: "pass1234"})
+	if err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+
+	player, _ := newPlayer(t)
+	if err := player.JoinLobby(lobby.ID, models.Red, "wrong"); err != errors.UnauthorizedLobbyJoinRequest {
+		t.Fatalf("expected joining with wrong password to fail, got %v", err)
+	}
+	if err := player.JoinLobby(lobby.ID, models.Red, "pass1234"); err != nil {
+		t.Fatalf("joining with password: %s", err)
+	}
+}
+
+func TestInviteJoinsPrivateLobby(t *testing.T) {
+	owner, _ := newPlayer(t)
+	private := true
+	if _, err := owner.CreateLobby(models.Lobby{Name: "invite lobby", PlayerLimit: 4, Private: &private, Password: "pass1234"}); err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+	once := uint(1)
+	invite, err := owner.CreateInvite(models.InviteRequest{MaxUses: &once})
+	if err != nil {
+		t.Fatalf("creating invite: %s", err)
+	}
+
+	player, _ := newPlayer(t)
+	lobby, err := player.JoinWithInvite(invite.Code, models.Auto)
+	if err != nil || lobby.ID != invite.LobbyID || lobby.PlayersCount() != 1 {
+		t.Fatalf("expected invite to join the lobby without password, got %+v, %v", lobby, err)
+	}
+	late, _ := newPlayer(t)
+	if _, err := late.JoinWithInvite(invite.Code, models.Auto); err != errors.InviteUsedUp {
+		t.Fatalf("expected used up invite to be rejected, got %v", err)
+	}
+	if err := owner.RevokeInvite(invite.Code); err != nil {
+		t.Fatalf("revoking invite: %s", err)
+	}
+	if invites, err := owner.Invites(); err != nil || len(invites) != 1 || invites[0].RevokedAt == nil {
+		t.Errorf("expected revoked invite to be listed, got %+v, %v", invites, err)
+	}
+}
+
+func TestValidationErrorsListEveryField(t *testing.T) {
+	_, account := newPlayer(t)
+	client := stack.Client()
+	client.Language = "pl"
+
+	err := client.Register(models.Account{Nickname: account.Nickname, Email: "wrong", Password: "123", Sex: "other"})
+	expected := map[string]string{
+		"nickname": validation.NotUnique,
+		"email":    validation.InvalidEmail,
+		"password": validation.TooShort,
+		"sex":      validation.NotAllowed,
+	}
+	fields := flankiclient.InvalidFields(err)
+	if flankiclient.StatusCode(err) != 400 || len(fields) != len(expected) {
+		t.Fatalf("expected 400 listing %d fields, got %v", len(expected), err)
+	}
+	for _, field := range fields {
+		if expected[field.Field] != field.Code || field.Message == "" {
+			t.Errorf("unexpected field error: %+v", field)
+		}
+	}
+	if err.(*errors.ApiError).Message != "Zapytanie zawiera niepoprawne pola" {
+		t.Errorf("expected message in polish, got %q", err.(*errors.ApiError).Message)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	client, account := newPlayer(t)
+	if err := client.RememberPassword(account.Email); err != nil {
+		t.Fatalf("requesting password reset: %s", err)
+	}
+
+	email, ok := stack.Mailer.WaitForEmail(account.Email, 5*time.Second)
+	if !ok {
+		t.Fatalf("password reset email has not been sent")
+	}
+	code := regexp.MustCompile(`reset_password/([0-9a-f-]+)`).FindStringSubmatch(email.Body)
+	if code == nil {
+		t.Fatalf("reset code not found in email: %s", email.Body)
+	}
+
+	if err := client.ResetPassword(code[1], "new_password"); err != nil {
+		t.Fatalf("resetting password: %s", err)
+	}
+	if _, err := stack.Client().Login(account.Email, playersPassword); err != errors.UnauthorizedAccount {
+		t.Fatalf("expected old password to be rejected, got %v", err)
+	}
+	if _, err := stack.Client().Login(account.Email, "new_password"); err != nil {
+		t.Fatalf("logging in with new password: %s", err)
+	}
+}
+
+func TestAvatarUpload(t *testing.T) {
+	client, account := newPlayer(t)
+	image := []byte("\x89PNG\r\n\x1a\nnot really an image")
+
+	if err := client.UploadImage("text/plain", bytes.NewReader(image)); flankiclient.StatusCode(err) != 400 {
+		t.Fatalf("expected invalid content type to be rejected, got %v", err)
+	}
+	if err := client.UploadImage("image/png", bytes.NewReader(image)); err != nil {
+		t.Fatalf("uploading avatar: %s", err)
+	}
+
+	stored, _, err := stack.Client().Image(account.ID)
+	if err != nil {
+		t.Fatalf("fetching avatar: %s", err)
+	}
+	if !bytes.Equal(stored, image) {
+		t.Fatalf("fetched avatar differs from the uploaded one")
+	}
+}
+
+func TestChat(t *testing.T) {
+	alice, aliceAccount := newPlayer(t)
+	bob, _ := newPlayer(t)
+
+	aliceConn, err := alice.JoinRoom("general")
+	if err != nil {
+		t.Fatalf("joining chat: %s", err)
+	}
+	defer aliceConn.Close()
+	bobConn, err := bob.JoinRoom("general")
+	if err != nil {
+		t.Fatalf("joining chat: %s", err)
+	}
+	defer bobConn.Close()
+
+	// chat answers only after user has been authorized, so asking for users waits until joining is finished
+	for _, conn := range []*flankiclient.ChatConnection{aliceConn, bobConn} {
+		if err := conn.RequestUsers(); err != nil {
+			t.Fatalf("requesting users: %s", err)
+		}
+		if _, err := conn.Receive(5 * time.Second); err != nil {
+			t.Fatalf("waiting for chat authorization: %s", err)
+		}
+	}
+
+	if err := aliceConn.Send("hello there"); err != nil {
+		t.Fatalf("sending message: %s", err)
+	}
+	msg, err := bobConn.Receive(5 * time.Second)
+	if err != nil {
+		t.Fatalf("receiving message: %s", err)
+	}
+	if msg.Text != "hello there" || msg.Nickname != aliceAccount.Nickname {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	if _, err := alice.JoinRoom("no_such_room"); flankiclient.StatusCode(err) != 404 {
+		t.Fatalf("expected joining unknown room to fail with 404, got %v", err)
+	}
+}
+
+func TestSpectatorJoinsLobbyChat(t *testing.T) {
+	owner, _ := newPlayer(t)
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "watched lobby", PlayerLimit: 4})
+	if err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+	spectator, spectatorAccount := newPlayer(t)
+	watched, err := spectator.WatchLobby(lobby.ID, "")
+	if err != nil || len(watched.Spectators) != 1 || watched.Spectators[0].PlayerID != spectatorAccount.ID {
+		t.Fatalf("expected spectator to be listed, got %+v, %v", watched, err)
+	}
+	if _, err := spectator.CurrentLobby(); err != errors.PlayerNotActive {
+		t.Fatalf("expected spectator not to be playing, got %v", err)
+	}
+
+	room := fmt.Sprintf("lobby-%d", lobby.ID)
+	conn, err := spectator.JoinRoom(room)
+	if err != nil {
+		t.Fatalf("joining lobby's chat: %s", err)
+	}
+	defer conn.Close()
+	if err := conn.RequestUsers(); err != nil {
+		t.Fatalf("requesting users: %s", err)
+	}
+	if _, err := conn.Receive(5 * time.Second); err != nil {
+		t.Fatalf("expected spectator to be let into lobby's chat, got %s", err)
+	}
+	stranger, _ := newPlayer(t)
+	strangerConn, err := stranger.JoinRoom(room)
+	if err != nil {
+		t.Fatalf("connecting to lobby's chat: %s", err)
+	}
+	defer strangerConn.Close()
+	strangerConn.RequestUsers()
+	if _, err := strangerConn.Receive(5 * time.Second); err == nil {
+		t.Fatalf("expected stranger to be disconnected from lobby's chat")
+	}
+
+	if err := owner.KickSpectator(spectatorAccount.ID); err != nil {
+		t.Fatalf("removing spectator: %s", err)
+	}
+	if watched, err = owner.Lobby(lobby.ID); err != nil || len(watched.Spectators) != 0 {
+		t.Errorf("expected removed spectator not to be listed, got %+v, %v", watched, err)
+	}
+}
+
+func TestCoOwnerManagesLobbyAndTakesItOver(t *testing.T) {
+	owner, ownerAccount := newPlayer(t)
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "shared lobby", PlayerLimit: 4})
+	if err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+	coOwner, coOwnerAccount := newPlayer(t)
+	player, playerAccount := newPlayer(t)
+	if err := player.JoinLobby(lobby.ID, models.Blue, ""); err != nil {
+		t.Fatalf("joining lobby: %s", err)
+	}
+	if _, err := coOwner.OwnerLobby(); err == nil {
+		t.Fatalf("expected player who isn't a co-owner not to manage the lobby")
+	}
+	if coOwners, err := owner.AddCoOwner(coOwnerAccount.ID); err != nil || len(coOwners) != 1 {
+		t.Fatalf("adding co-owner: %+v, %v", coOwners, err)
+	}
+	if managed, err := coOwner.OwnerLobby(); err != nil || managed.ID != lobby.ID {
+		t.Fatalf("expected co-owner to manage the lobby, got %+v, %v", managed, err)
+	}
+	if err := coOwner.KickPlayer(playerAccount.ID); err != nil {
+		t.Fatalf("co-owner kicking player: %s", err)
+	}
+
+	transferred, err := owner.TransferOwnership(coOwnerAccount.ID)
+	if err != nil || transferred.OwnerID != coOwnerAccount.ID || len(transferred.CoOwners) != 0 {
+		t.Fatalf("expected co-owner to own the lobby, got %+v, %v", transferred, err)
+	}
+	if _, err := owner.OwnerLobby(); err == nil {
+		t.Errorf("expected previous owner not to manage the lobby anymore")
+	}
+	if err := coOwner.RemoveCoOwner(ownerAccount.ID); err == nil {
+		t.Errorf("expected previous owner not to be a co-owner")
+	}
+}
+
+func TestResultWaitsForConfirmation(t *testing.T) {
+	owner, ownerAccount := newPlayer(t)
+	confirm := true
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "confirmed match", PlayerLimit: 4, ConfirmResults: &confirm})
+	if err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+	opponent, _ := newPlayer(t)
+	if err := owner.JoinLobby(lobby.ID, models.Blue, ""); err != nil {
+		t.Fatalf("owner joining lobby: %s", err)
+	}
+	if err := opponent.JoinLobby(lobby.ID, models.Red, ""); err != nil {
+		t.Fatalf("joining red team: %s", err)
+	}
+	if err := owner.StartReadyCheck(); err != nil {
+		t.Fatalf("starting ready check: %s", err)
+	}
+	for _, player := range []*flankiclient.Client{owner, opponent} {
+		if err := player.SetReady(true); err != nil {
+			t.Fatalf("setting ready flag: %s", err)
+		}
+	}
+	if err := owner.StartMatch(); err != nil {
+		t.Fatalf("starting match: %s", err)
+	}
+	if err := owner.SubmitResults(models.Blue); err != nil {
+		t.Fatalf("submitting results: %s", err)
+	}
+
+	if summary, err := owner.PlayerSummary(ownerAccount.ID); err != nil || summary.Wins != 0 {
+		t.Fatalf("expected pending win not to count, got %+v, %v", summary, err)
+	}
+	outsider, _ := newPlayer(t)
+	if err := outsider.DisputeResult(lobby.ID, "I saw it all"); flankiclient.StatusCode(err) != 403 {
+		t.Fatalf("expected only players to dispute results, got %v", err)
+	}
+	if err := outsider.SettleResult(lobby.ID, models.Red, ""); err != errors.NotModerator {
+		t.Fatalf("expected only moderators to settle disputes, got %v", err)
+	}
+	if err := opponent.ConfirmResult(lobby.ID); err != nil {
+		t.Fatalf("confirming results: %s", err)
+	}
+	if err := opponent.ConfirmResult(lobby.ID); err != errors.ResultNotPending {
+		t.Fatalf("expected results to be confirmed once, got %v", err)
+	}
+
+	if summary, err := owner.PlayerSummary(ownerAccount.ID); err != nil || summary.Wins != 1 {
+		t.Fatalf("expected confirmed win to count, got %+v, %v", summary, err)
+	}
+	finished, err := owner.Lobby(lobby.ID)
+	if err != nil {
+		t.Fatalf("fetching lobby: %s", err)
+	}
+	if finished.ResultStatus != models.ResultConfirmed || len(finished.ResultHistory) != 2 {
+		t.Fatalf("expected confirmed result with its history, got %s %+v", finished.ResultStatus, finished.ResultHistory)
+	}
+}
+
+func TestTournamentFinal(t *testing.T) {
+	organizer, _ := newPlayer(t)
+	tournament, err := organizer.CreateTournament(models.Tournament{Name: "integration cup", Format: models.SingleElimination, TeamSize: 1, MaxTeams: 2})
+	if err != nil {
+		t.Fatalf("creating tournament: %s", err)
+	}
+	blue, blueAccount := newPlayer(t)
+	red, redAccount := newPlayer(t)
+	for _, captain := range []struct {
+		client  *flankiclient.Client
+		account *models.Account
+	}{{blue, blueAccount}, {red, redAccount}} {
+		team := models.TeamRegistration{Name: captain.account.Nickname, Players: []uint{captain.account.ID}}
+		if _, err := captain.client.RegisterTeam(tournament.ID, team); err != nil {
+			t.Fatalf("registering team: %s", err)
+		}
+	}
+	if _, err := blue.StartTournament(tournament.ID); err != errors.NotTournamentOwner {
+		t.Fatalf("expected only the organizer to start the tournament, got %v", err)
+	}
+	started, err := organizer.StartTournament(tournament.ID)
+	if err != nil {
+		t.Fatalf("starting tournament: %s", err)
+	}
+	if len(started.Matches) != 1 || started.Matches[0].LobbyID == nil {
+		t.Fatalf("expected the final to get its lobby, got %+v", started.Matches)
+	}
+
+	// both captains have been put into the lobby, the one owning it runs the match
+	lobby, err := blue.CurrentLobby()
+	if err != nil {
+		t.Fatalf("fetching tournament's lobby: %s", err)
+	}
+	owner := blue
+	if lobby.OwnerID == redAccount.ID {
+		owner = red
+	}
+	if err := owner.StartReadyCheck(); err != nil {
+		t.Fatalf("starting ready check: %s", err)
+	}
+	for _, player := range []*flankiclient.Client{blue, red} {
+		if err := player.SetReady(true); err != nil {
+			t.Fatalf("setting ready flag: %s", err)
+		}
+	}
+	if err := owner.StartMatch(); err != nil {
+		t.Fatalf("starting match: %s", err)
+	}
+	if err := owner.SubmitResults(models.Red); err != nil {
+		t.Fatalf("submitting results: %s", err)
+	}
+
+	standings, err := organizer.Standings(tournament.ID)
+	if err != nil {
+		t.Fatalf("fetching standings: %s", err)
+	}
+	if standings.State != models.TournamentFinished || len(standings.Standings) != 2 || standings.Standings[0].Name != redAccount.Nickname {
+		t.Fatalf("expected red captain's team to win the tournament, got %+v", standings)
+	}
+}
+
+func TestScheduledLobbyCalendar(t *testing.T) {
+	owner, _ := newPlayer(t)
+	startsAt := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "scheduled lobby", PlayerLimit: 4, StartsAt: &startsAt})
+	if err != nil {
+		t.Fatalf("creating scheduled lobby: %s", err)
+	}
+	player, _ := newPlayer(t)
+	if _, err := player.RespondToLobby(lobby.ID, models.Going); err != nil {
+		t.Fatalf("responding to lobby: %s", err)
+	}
+	rsvps, err := owner.LobbyRSVPs(lobby.ID)
+	if err != nil || len(rsvps) != 1 || rsvps[0].Response != models.Going {
+		t.Fatalf("expected player's response, got %+v, %v", rsvps, err)
+	}
+
+	path, err := player.CalendarPath(false)
+	if err != nil {
+		t.Fatalf("fetching calendar path: %s", err)
+	}
+	feed, err := stack.Client().Calendar(path)
+	if err != nil {
+		t.Fatalf("fetching calendar without logging in: %s", err)
+	}
+	start := "DTSTART:" + startsAt.UTC().Format("20060102T150405Z")
+	if !bytes.Contains(feed, []byte(start)) || !bytes.Contains(feed, []byte("SUMMARY:scheduled lobby")) {
+		t.Fatalf("expected the lobby in player's calendar, got:\n%s", feed)
+	}
+}
+
+func TestFriendsOnlyLobby(t *testing.T) {
+	owner, ownerAccount := newPlayer(t)
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "friends lobby", PlayerLimit: 4, Visibility: models.FriendsOfOwner})
+	if err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+	player, playerAccount := newPlayer(t)
+	if err := player.JoinLobby(lobby.ID, models.Blue, ""); err != errors.FriendsOnlyLobby {
+		t.Fatalf("expected stranger not to join, got %v", err)
+	}
+
+	if _, err := player.AddFriend(ownerAccount.ID); err != nil {
+		t.Fatalf("sending friend request: %s", err)
+	}
+	if _, err := owner.AcceptFriend(playerAccount.ID); err != nil {
+		t.Fatalf("accepting friend request: %s", err)
+	}
+	if err := player.JoinLobby(lobby.ID, models.Blue, ""); err != nil {
+		t.Fatalf("joining friend's lobby: %s", err)
+	}
+	friends, err := owner.Friends()
+	if err != nil || len(friends) != 1 || !friends[0].Playing || friends[0].LobbyID == nil || *friends[0].LobbyID != lobby.ID {
+		t.Fatalf("expected playing friend in the lobby, got %+v, %v", friends, err)
+	}
+}
+
+func TestKickedPlayerIsNotified(t *testing.T) {
+	owner, _ := newPlayer(t)
+	lobby, err := owner.CreateLobby(models.Lobby{Name: "strict lobby", PlayerLimit: 4})
+	if err != nil {
+		t.Fatalf("creating lobby: %s", err)
+	}
+	player, playerAccount := newPlayer(t)
+	if err := player.JoinLobby(lobby.ID, models.Blue, ""); err != nil {
+		t.Fatalf("joining lobby: %s", err)
+	}
+	if err := owner.KickPlayer(playerAccount.ID); err != nil {
+		t.Fatalf("kicking player: %s", err)
+	}
+
+	// notifications are delivered in background
+	var inbox *models.Inbox
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if inbox, err = player.Notifications(true); err != nil || inbox.Unread > 0 {
+			break
+		}
+	}
+	if err != nil || inbox.Unread != 1 || inbox.Notifications[0].Type != models.KickedNotification || *inbox.Notifications[0].LobbyID != lobby.ID {
+		t.Fatalf("expected notification about the kick, got %+v, %v", inbox, err)
+	}
+	if marked, err := player.MarkNotificationsRead(); err != nil || marked != 1 {
+		t.Fatalf("expected notification to be marked as read, got %d, %v", marked, err)
+	}
+	if inbox, err = player.Notifications(true); err != nil || len(inbox.Notifications) != 0 {
+		t.Errorf("expected no unread notifications, got %+v, %v", inbox, err)
+	}
+}
+
+func TestAdminSeesJobRunByOneInstance(t *testing.T) {
+	player, account := newPlayer(t)
+	if _, err := player.Jobs(); err != errors.NotAdmin {
+		t.Fatalf("expected only admins to see jobs, got %v", err)
+	}
+	appCfg := config.GetAppConfig()
+	admins := appCfg.Admins
+	appCfg.Admins = []uint{account.ID}
+	defer func() { appCfg.Admins = admins }()
+
+	var job jobs.Job
+	for _, scheduled := range stack.Scheduler().Jobs() {
+		if scheduled.Name == "reconcile_playing" {
+			job = scheduled
+		}
+	}
+	now := time.Now()
+	if run, err := stack.Scheduler().Run(context.Background(), job, now); err != nil || run == nil || run.Failed() {
+		t.Fatalf("expected the app to run the job, got %+v, %v", run, err)
+	}
+	other := stack.NewInstanceScheduler("other")
+	if run, err := other.Run(context.Background(), job, now.Add(time.Second)); err != nil || run != nil {
+		t.Fatalf("another instance shouldn't run the job leased by the app, got %+v, %v", run, err)
+	}
+
+	statuses, err := player.Jobs()
+	if err != nil {
+		t.Fatalf("fetching jobs: %s", err)
+	}
+	for _, status := range statuses {
+		if status.Name != job.Name {
+			continue
+		}
+		if status.LastRun == nil || status.LastRun.Instance != jobs.Instance() || len(status.Runs) != 1 || status.LastFailure != nil {
+			t.Errorf("expected a single successful run by the app, got %+v", status)
+		}
+		return
+	}
+	t.Errorf("job %s isn't listed in %+v", job.Name, statuses)
+}
+
+func TestDeletedAccountIsRestoredOrErased(t *testing.T) {
+	client, account := newPlayer(t)
+	image := []byte("\x89PNG\r\n\x1a\nnot really an image")
+	if err := client.UploadImage("image/png", bytes.NewReader(image)); err != nil {
+		t.Fatalf("uploading avatar: %s", err)
+	}
+	conn, err := client.JoinRoom("general")
+	if err != nil {
+		t.Fatalf("joining chat: %s", err)
+	}
+	if err := conn.Send("see you at the pitch"); err != nil {
+		t.Fatalf("sending message: %s", err)
+	}
+	// the message is stored before it is broadcast back to its sender
+	if _, err := conn.Receive(5 * time.Second); err != nil {
+		t.Fatalf("receiving message: %s", err)
+	}
+	conn.Close()
+	export, err := client.Export()
+	if err != nil {
+		t.Fatalf("exporting account: %s", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(export), int64(len(export)))
+	if err != nil {
+		t.Fatalf("reading export: %s", err)
+	}
+	files := map[string]bool{}
+	for _, file := range archive.File {
+		files[file.Name] = true
+	}
+	for _, name := range []string{"profile.json", "statistics.json", "matches.json", "achievements.json", "friendships.json", "follows.json", "rsvps.json", "notifications.json", "push_subscriptions.json", "chat_messages.json", "avatar.png", "README.txt"} {
+		if !files[name] {
+			t.Errorf("export is missing %s, got %v", name, files)
+		}
+	}
+	for _, file := range archive.File {
+		if file.Name != "chat_messages.json" {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening chat messages: %s", err)
+		}
+		var messages []models.ChatMessage
+		err = json.NewDecoder(f).Decode(&messages)
+		f.Close()
+		if err != nil || len(messages) != 1 || messages[0].Text != "see you at the pitch" || messages[0].Room != "general" {
+			t.Errorf("expected the sent message to be exported, got %+v, %v", messages, err)
+		}
+	}
+
+	if err := client.DeleteMe(); err != nil {
+		t.Fatalf("deleting account: %s", err)
+	}
+	if _, err := stack.Client().Login(account.Email, playersPassword); err != errors.UnauthorizedAccount {
+		t.Fatalf("deleted account shouldn't log in, got %v", err)
+	}
+	if err := client.RestoreAccount(account.Email, playersPassword); err != nil {
+		t.Fatalf("restoring account: %s", err)
+	}
+	if _, err := client.Login(account.Email, playersPassword); err != nil {
+		t.Fatalf("restored account should log in, got %v", err)
+	}
+	if messages, err := client.ChatMessages(); err != nil || len(messages) != 0 {
+		t.Errorf("chat messages should be deleted together with the account, got %+v, %v", messages, err)
+	}
+	if err := client.DeleteMe(); err != nil {
+		t.Fatalf("deleting account again: %s", err)
+	}
+
+	var job jobs.Job
+	for _, scheduled := range stack.Scheduler().Jobs() {
+		if scheduled.Name == "erase_deleted_accounts" {
+			job = scheduled
+		}
+	}
+	afterGrace := time.Now().Add(config.GetAccountsConfig().ErasureGracePeriod + time.Hour)
+	if run, err := stack.Scheduler().Run(context.Background(), job, afterGrace); err != nil || run == nil || run.Failed() {
+		t.Fatalf("expected deleted accounts to be erased, got %+v, %v", run, err)
+	}
+	if err := client.RestoreAccount(account.Email, playersPassword); err != errors.UnauthorizedAccount {
+		t.Errorf("erased account shouldn't be restored, got %v", err)
+	}
+	if _, _, err := stack.Client().Image(account.ID); flankiclient.StatusCode(err) != 404 {
+		t.Errorf("avatar of erased account should be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package integration_test
+
+import (
+	"Chat/websocketchat"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// clients connected while chat is shutting down are told about it before their sockets are closed
+func TestChatSendsShutdownMessage(t *testing.T) {
+	if stack != nil {
+		t.Skip("chat's endpoints are configured for the running stack")
+	}
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"expires_in": 3600, "client_id": "app", "user_id": 7}`))
+	}))
+	defer authServer.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"account": {"nickname": "alice"}}`))
+	}))
+	defer api.Close()
+	websocketchat.GetFlankiChecker.SetEndpoints(api.URL, authServer.URL)
+	defer websocketchat.GetFlankiChecker.SetEndpoints("", "")
+
+	chat := websocketchat.NewChatServer()
+	chatServer := httptest.NewServer(chat.Router())
+	defer chatServer.Close()
+
+	socket, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(chatServer.URL, "http")+"/chat/join/general", nil)
+	if err != nil {
+		t.Fatalf("joining chat: %s", err)
+	}
+	defer socket.Close()
+	_ = socket.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := socket.WriteJSON(websocketchat.User{Token: "Bearer token"}); err != nil {
+		t.Fatal(err)
+	}
+	// chat answers only after user has been authorized
+	if err := socket.WriteJSON(websocketchat.Message{Action: "users"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := socket.ReadJSON(&websocketchat.Message{}); err != nil {
+		t.Fatalf("waiting for chat authorization: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- chat.Shutdown(ctx) }()
+
+	msg := websocketchat.Message{}
+	if err := socket.ReadJSON(&msg); err != nil {
+		t.Fatalf("waiting for shutdown message: %s", err)
+	}
+	if msg.Action != websocketchat.ServerShutdownAction {
+		t.Errorf("expected %s message, got %+v", websocketchat.ServerShutdownAction, msg)
+	}
+	if err := socket.ReadJSON(&msg); err == nil {
+		t.Errorf("socket should be closed after shutdown message, got %+v", msg)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("shutdown didn't finish cleanly: %s", err)
+	}
+	resp, err := http.Get(chatServer.URL + "/chat/rooms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var rooms []string
+	if err := json.NewDecoder(resp.Body).Decode(&rooms); err != nil || len(rooms) != 0 {
+		t.Errorf("all rooms should be closed, got %v %v", rooms, err)
+	}
+}
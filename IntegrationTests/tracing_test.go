@@ -0,0 +1,121 @@
+package integration_test
+
+import (
+	"FlankiRest/config"
+	"FlankiRest/logger"
+	"ImageService/imageserver"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// trace which the request to the app continues, as if it was started by its caller
+const (
+	callerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerParent  = "00-" + callerTraceID + "-00f067aa0ba902b7-01"
+)
+
+// request for user's image goes through authorization server and image server,
+// both of them have to receive and continue the trace started by the caller
+func TestTracePropagatesThroughServices(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	var mutex sync.Mutex
+	var authTraceParent string
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		authTraceParent = r.Header.Get("traceparent")
+		mutex.Unlock()
+		_, _ = w.Write([]byte(`{"expires_in": 3600, "client_id": "app", "user_id": 1}`))
+	}))
+	defer authServer.Close()
+
+	dir, err := ioutil.TempDir("", "flanki_images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	images := httptest.NewServer(imageserver.NewImageServer(dir, logger.GetGlobalLogger().WithField("prefix", "[IMAGE SERVER]")).Router)
+	defer images.Close()
+
+	// services of the app are configured globally, the scenarios may be running against other ones
+	authCfg, imgCfg := config.GetAuthServerConfig(), config.GetImageServerConfig()
+	previousAuth, previousImg := *authCfg, *imgCfg
+	defer func() { *authCfg, *imgCfg = previousAuth, previousImg }()
+	authCfg.Domain, authCfg.Port = hostAndPort(t, authServer.URL)
+	imgCfg.Domain, imgCfg.Port = hostAndPort(t, images.URL)
+
+	appServer := httptest.NewServer(newRoutedApp().Router)
+	defer appServer.Close()
+	request, _ := http.NewRequest("GET", appServer.URL+config.API_PREFIX+"/images/my", nil)
+	request.Header.Set("Authorization", "Bearer token")
+	request.Header.Set("traceparent", callerParent)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("requesting image: %s", err)
+	}
+	resp.Body.Close()
+
+	mutex.Lock()
+	if !strings.Contains(authTraceParent, callerTraceID) {
+		t.Errorf("authorization server received traceparent '%s', expected trace %s", authTraceParent, callerTraceID)
+	}
+	mutex.Unlock()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != callerTraceID {
+			t.Errorf("span '%s' doesn't belong to the caller's trace", span.Name())
+		}
+		spans[span.Name()] = span
+	}
+	appSpan, ok := spans["GET "+config.API_PREFIX+"/images/my"]
+	if !ok {
+		t.Fatalf("app's span is missing, recorded: %v", spanNames(spans))
+	}
+	imageSpan, ok := spans["GET /images/"]
+	if !ok {
+		t.Fatalf("image server's span is missing, recorded: %v", spanNames(spans))
+	}
+	// app's span -> client span of request to the image server -> image server's span
+	var clientSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().SpanID() == imageSpan.Parent().SpanID() {
+			clientSpan = span
+		}
+	}
+	if clientSpan == nil {
+		t.Fatalf("image server's span isn't a child of app's request to it")
+	}
+	if clientSpan.Parent().SpanID() != appSpan.SpanContext().SpanID() {
+		t.Errorf("request to the image server isn't a part of app's span")
+	}
+}
+
+func hostAndPort(t *testing.T, rawURL string) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Scheme + "://" + u.Hostname(), u.Port()
+}
+
+func spanNames(spans map[string]sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
@@ -1 +1,3 @@
 module FlankiRest
+
+go 1.27.1